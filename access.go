@@ -0,0 +1,82 @@
+package main
+
+import "strings"
+
+// --- PIC18 Access Bank ---
+
+// bsrUnknown marks a BSR value that cannot be known at this point of the program.
+const bsrUnknown = -1
+
+// isAccessRAM reports whether a file register address is in the access bank of the device,
+// so instructions can reach it without the BSR.
+func isAccessRAM(mcConfig *MicrocontrollerConfig, addr int) bool {
+	if mcConfig.RAMLayout == nil {
+		return false
+	}
+	for _, r := range mcConfig.RAMLayout.Access {
+		if r.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// accessOperand parses an explicit access bank operand: ACCESS (or A, 0) selects the access
+// bank and BANKED (or B, 1) the bank in the BSR.
+func accessOperand(text string) (int, bool) {
+	switch strings.ToUpper(text) {
+	case "ACCESS", "A", "0":
+		return 0, true
+	case "BANKED", "B", "1":
+		return 1, true
+	}
+	return 0, false
+}
+
+// optionalAccessOperand reports whether an instruction may leave out its last operand: the
+// access bank operand of PIC18 file register instructions, which is then chosen from the address.
+func optionalAccessOperand(info InstructionInfo) bool {
+	return len(info.Operands) > 0 && info.Operands[len(info.Operands)-1] == "a"
+}
+
+// ramBank returns the bank of a data memory address.
+func ramBank(mcConfig *MicrocontrollerConfig, addr int) int {
+	if mcConfig.RAMLayout != nil && mcConfig.RAMLayout.BankSize > 0 {
+		return addr / mcConfig.RAMLayout.BankSize
+	}
+	return addr >> 8
+}
+
+// trackBSR returns the BSR value after an instruction, given the value before it. MOVLB sets
+// it; calls and other writes to the BSR make it unknown, as does a skip before MOVLB.
+func (a *PicAssembler) trackBSR(bsr int, inst *Instruction, info InstructionInfo, previousSkips bool) int {
+	opcode := strings.ToUpper(inst.Opcode)
+	switch opcode {
+	case "MOVLB":
+		if previousSkips || len(inst.Operands) != 1 {
+			return bsrUnknown
+		}
+		if val, err := a.evaluateExpression(inst.Operands[0]); err == nil {
+			return val
+		}
+		return bsrUnknown
+	case "CALL", "RCALL":
+		return bsrUnknown
+	}
+	bsrAddr, ok := a.mcConfig.SFRMap["BSR"]
+	operand, hasFile := operandOfKind(inst, info, "f")
+	if !ok || !hasFile {
+		return bsr
+	}
+	if addr, err := a.evaluateExpression(operand); err != nil || addr != bsrAddr {
+		return bsr
+	}
+	// Only tests and moves into W leave the BSR as it was.
+	if dest, ok := operandOfKind(inst, info, "d"); ok && (strings.ToUpper(dest) == "W" || dest == "0") {
+		return bsr
+	}
+	if strings.HasPrefix(opcode, "BTFS") || strings.HasPrefix(opcode, "CPFS") || opcode == "TSTFSZ" {
+		return bsr
+	}
+	return bsrUnknown
+}
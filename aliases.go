@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// --- Instruction Aliases ---
+
+// PseudoOp is a device- or site-specific pseudo-instruction that expands to real instructions,
+// e.g. MOVFW f -> MOVF f, W. It behaves like a macro with the given parameters.
+type PseudoOp struct {
+	Params []string `json:"params"`
+	Body   []string `json:"body"`
+}
+
+// aliasOverlay is the layout of an alias overlay file: the alias keys of a device config.
+type aliasOverlay struct {
+	Aliases   map[string]string   `json:"INSTRUCTION_ALIASES"`
+	PseudoOps map[string]PseudoOp `json:"PSEUDO_OPS"`
+}
+
+// validateInstructionAliases checks that aliases name real instructions and do not shadow
+// them, and that pseudo-op parameters are valid.
+func validateInstructionAliases(mcConfig *MicrocontrollerConfig) error {
+	for alias, target := range mcConfig.Aliases {
+		if _, ok := mcConfig.InstructionSet[strings.ToUpper(alias)]; ok {
+			return fmt.Errorf("alias '%s' shadows an instruction", alias)
+		}
+		if _, ok := mcConfig.InstructionSet[strings.ToUpper(target)]; !ok {
+			return fmt.Errorf("alias '%s' refers to unknown instruction '%s'", alias, target)
+		}
+	}
+	for name, op := range mcConfig.PseudoOps {
+		if _, ok := mcConfig.InstructionSet[strings.ToUpper(name)]; ok {
+			return fmt.Errorf("pseudo-op '%s' shadows an instruction", name)
+		}
+		if _, ok := mcConfig.Aliases[name]; ok {
+			return fmt.Errorf("'%s' is defined both as an alias and as a pseudo-op", name)
+		}
+		for _, param := range op.Params {
+			if !macroParamRegex.MatchString(param) {
+				return fmt.Errorf("pseudo-op '%s' has invalid parameter name '%s'", name, param)
+			}
+		}
+		if len(op.Body) == 0 {
+			return fmt.Errorf("pseudo-op '%s' has an empty body", name)
+		}
+	}
+	return nil
+}
+
+// loadAliasOverlay merges the aliases and pseudo-ops of an overlay file into the device config.
+// Entries in the overlay replace device entries of the same name.
+func loadAliasOverlay(path string, mcConfig *MicrocontrollerConfig) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read alias file '%s': %w", path, err)
+	}
+	var overlay aliasOverlay
+	if err := json.Unmarshal(data, &overlay); err != nil {
+		return fmt.Errorf("could not parse JSON from '%s': %w", path, err)
+	}
+	if mcConfig.Aliases == nil {
+		mcConfig.Aliases = make(map[string]string)
+	}
+	if mcConfig.PseudoOps == nil {
+		mcConfig.PseudoOps = make(map[string]PseudoOp)
+	}
+	for alias, target := range overlay.Aliases {
+		delete(mcConfig.PseudoOps, alias)
+		mcConfig.Aliases[alias] = target
+	}
+	for name, op := range overlay.PseudoOps {
+		delete(mcConfig.Aliases, name)
+		mcConfig.PseudoOps[name] = op
+	}
+	if err := validateInstructionAliases(mcConfig); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// pseudoOpMacros turns the pseudo-ops of a device into macro definitions keyed by upper-case name.
+func pseudoOpMacros(mcConfig *MicrocontrollerConfig) map[string]*MacroDefinition {
+	macros := make(map[string]*MacroDefinition, len(mcConfig.PseudoOps))
+	for name, op := range mcConfig.PseudoOps {
+		macros[strings.ToUpper(name)] = &MacroDefinition{Name: strings.ToUpper(name), Params: op.Params, BodyLines: op.Body}
+	}
+	return macros
+}
+
+// instructionAliases returns the aliases of a device keyed by upper-case name.
+func instructionAliases(mcConfig *MicrocontrollerConfig) map[string]string {
+	aliases := make(map[string]string, len(mcConfig.Aliases))
+	for alias, target := range mcConfig.Aliases {
+		aliases[strings.ToUpper(alias)] = strings.ToUpper(target)
+	}
+	return aliases
+}
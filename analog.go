@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// --- Analog Pin Lint ---
+
+func init() {
+	registerLintRule(lintRule{
+		Name:            "analog-pin",
+		Description:     "PORT pin read while it is still configured as an analog input",
+		DefaultSeverity: SeverityWarning,
+		Check:           lintAnalogPins,
+	})
+}
+
+// AnalogPin is a port pin that is an analog input after reset until bit SelectBit of the
+// Select register (e.g. ANSEL) is cleared. Digital reads of it always return 0.
+type AnalogPin struct {
+	Bit       int    `json:"bit"`
+	Channel   string `json:"channel"`
+	Select    string `json:"select"`
+	SelectBit int    `json:"select_bit"`
+}
+
+// pinName is the datasheet name of a port pin, e.g. RA0 for PORTA, 0.
+func pinName(port string, bit int) string {
+	return fmt.Sprintf("R%s%d", strings.TrimPrefix(port, "PORT"), bit)
+}
+
+// lintAnalogPins follows the program in source order, tracking which analog select bits have
+// been cleared, and reports the first read of each analog pin that is still analog.
+func lintAnalogPins(ctx *lintContext) []lintFinding {
+	ports := make(map[int]string)
+	for port := range ctx.mcConfig.AnalogPins {
+		if addr, ok := ctx.mcConfig.SFRMap[port]; ok {
+			ports[addr] = port
+		}
+	}
+	if len(ports) == 0 {
+		return nil
+	}
+	digital := make(map[string]int) // select register -> bits cleared so far
+	wLiteral, wKnown := 0, false
+
+	var findings []lintFinding
+	reported := make(map[string]bool)
+	for i := range ctx.expanded.Lines {
+		inst, info, ok := ctx.instructionAt(i)
+		if !ok {
+			continue
+		}
+		opcode := strings.ToUpper(inst.Opcode)
+		operand, addr, hasAddr := ctx.fileRegisterAddress(inst, info)
+		bit := -1
+		if bitOperand, ok := operandOfKind(inst, info, "b"); ok {
+			if val, err := ctx.assembler.evaluateExpression(bitOperand); err == nil {
+				bit = val
+			}
+		}
+
+		// Writes to the analog select registers
+		if hasAddr {
+			for _, pins := range ctx.mcConfig.AnalogPins {
+				for _, pin := range pins {
+					if selectAddr, ok := ctx.mcConfig.SFRMap[pin.Select]; !ok || selectAddr != addr {
+						continue
+					}
+					switch {
+					case opcode == "CLRF":
+						digital[pin.Select] = ^0
+					case opcode == "BCF" && bit >= 0:
+						digital[pin.Select] |= 1 << bit
+					case opcode == "BSF" && bit >= 0:
+						digital[pin.Select] &^= 1 << bit
+					case opcode == "MOVWF" && wKnown:
+						digital[pin.Select] = ^wLiteral
+					case writesFileRegister(inst, info):
+						digital[pin.Select] = ^0 // unknown value: assume the code knows what it does
+					}
+				}
+			}
+		}
+
+		// Reads of analog port pins
+		if port, isPort := ports[addr]; hasAddr && isPort && readsFileRegister(inst) {
+			var analog []string
+			for _, pin := range ctx.mcConfig.AnalogPins[port] {
+				name := pinName(port, pin.Bit)
+				if (bit >= 0 && pin.Bit != bit) || digital[pin.Select]&(1<<pin.SelectBit) != 0 || reported[name] {
+					continue
+				}
+				reported[name] = true
+				analog = append(analog, fmt.Sprintf("%s (%s, %s bit %d)", name, pin.Channel, pin.Select, pin.SelectBit))
+			}
+			if len(analog) > 0 {
+				message := tr("%s reads '%s' while %s is still analog (digital reads return 0); clear the select bit first.", opcode, operand, analog[0])
+				if len(analog) > 1 {
+					message = tr("%s reads '%s' while %s are still analog (digital reads return 0); clear the select bit first.", opcode, operand, strings.Join(analog, ", "))
+				}
+				findings = append(findings, lintFinding{Line: ctx.expanded.SourceLines[i], Message: message})
+			}
+		}
+
+		// Track a literal in W for MOVLW/MOVWF sequences
+		switch {
+		case opcode == "MOVLW":
+			wLiteral, wKnown = 0, false
+			if val, err := ctx.assembler.evaluateExpression(inst.Operands[0]); err == nil {
+				wLiteral, wKnown = val, true
+			}
+		case opcode == "CLRW":
+			wLiteral, wKnown = 0, true
+		case writesW(inst, info):
+			wKnown = false
+		}
+	}
+	return findings
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// --- Annotated Source ---
+
+func init() {
+	registerSubcommand("annotate", "Write a copy of a source with the address, machine word and cycles of each line", runAnnotate)
+}
+
+// annotateColumn is the column annotations start at, unless the line is longer.
+const annotateColumn = 40
+
+// annotateMaxWords is the number of machine words an annotation shows before eliding the rest.
+const annotateMaxWords = 4
+
+// opcodeCycles returns the instruction cycles an opcode takes on a mid-range core: one, or two
+// for branches and returns. least and most differ for a skip, which takes a second cycle
+// when it skips.
+func opcodeCycles(opcode string) (least, most int) {
+	switch opcode = strings.ToUpper(opcode); {
+	case opcode == "GOTO" || opcode == "CALL" || opcode == "RETURN" || opcode == "RETLW" || opcode == "RETFIE",
+		opcode == "BRA" || opcode == "BRW" || opcode == "CALLW":
+		return 2, 2
+	case isSkipInstruction(opcode):
+		return 1, 2
+	}
+	return 1, 1
+}
+
+// instructionCycles returns the instruction cycles an instruction takes: those of its opcode,
+// or two for a write to PCL.
+func (a *PicAssembler) instructionCycles(inst *Instruction) (least, most int) {
+	opcode := strings.ToUpper(inst.Opcode)
+	if least, most = opcodeCycles(opcode); most > 1 {
+		return least, most
+	}
+	info := a.mcConfig.InstructionSet[opcode]
+	if reg, ok := operandOfKind(inst, info, "f"); ok && writesFileRegister(inst, info) {
+		if addr, err := a.evaluateExpression(reg); err == nil && addr&0x7F == pclRegister {
+			return 2, 2
+		}
+	}
+	return 1, 1
+}
+
+// lineCode is the code generated for one line of the main source: by the line itself, by the
+// macros it invokes or inserted before it.
+type lineCode struct {
+	addresses   []int
+	least, most int
+	executed    bool // holds instructions, not only data words
+}
+
+// annotation renders the code of a line as a comment: its address range, machine words and
+// cycles, e.g. "; 0x0005  1405  1 cycle".
+func (c *lineCode) annotation(words map[int]int) string {
+	first, last := c.addresses[0], c.addresses[len(c.addresses)-1]
+	text := fmt.Sprintf("; 0x%04X", first)
+	if last != first {
+		text += fmt.Sprintf("-0x%04X", last)
+	}
+	shown := make([]string, 0, annotateMaxWords+1)
+	for k, addr := range c.addresses {
+		if k == annotateMaxWords {
+			shown = append(shown, fmt.Sprintf("... (%d words)", len(c.addresses)))
+			break
+		}
+		shown = append(shown, fmt.Sprintf("%04X", words[addr]))
+	}
+	text += "  " + strings.Join(shown, " ")
+	switch {
+	case !c.executed:
+	case c.least != c.most:
+		text += fmt.Sprintf("  %d-%d cycles", c.least, c.most)
+	case c.least == 1:
+		text += "  1 cycle"
+	default:
+		text += fmt.Sprintf("  %d cycles", c.least)
+	}
+	return text
+}
+
+// displayWidth returns the width of a line on screen, with tabs at every eighth column.
+func displayWidth(line string) int {
+	width := 0
+	for _, r := range line {
+		if r == '\t' {
+			width += 8 - width%8
+		} else {
+			width++
+		}
+	}
+	return width
+}
+
+// GenerateAnnotated returns the main source with every line that generates code followed by a
+// comment giving the address and machine words of that code and the cycles it takes. The code
+// of a macro invocation is summed up on the invocation; include files are not annotated.
+func (a *PicAssembler) GenerateAnnotated(rawText string) string {
+	code := make(map[int]*lineCode)
+	for i, item := range a.parsedAssembly.Lines {
+		addr, hasCode := a.itemAddresses[i]
+		if !hasCode {
+			continue
+		}
+		site := SourceLocation{Line: a.sourceLine(i)}
+		if origin := a.origin(i); origin != nil {
+			site = origin.Location
+			if len(origin.Chain) > 0 {
+				site = origin.Chain[0].Call
+			}
+		}
+		if site.File != "" {
+			continue
+		}
+		c := code[site.Line]
+		if c == nil {
+			c = &lineCode{}
+			code[site.Line] = c
+		}
+		switch v := item.(type) {
+		case *Instruction:
+			least, most := a.instructionCycles(v)
+			c.addresses = append(c.addresses, addr)
+			c.least, c.most, c.executed = c.least+least, c.most+most, true
+		case interface{ wordCount() int }: // DW, DT, __VERSIONSTR and the other data
+			for k := 0; k < v.wordCount(); k++ {
+				c.addresses = append(c.addresses, addr+k)
+			}
+		}
+	}
+
+	lines := strings.Split(rawText, "\n")
+	for n, line := range lines {
+		c := code[n+1]
+		if c == nil || len(c.addresses) == 0 {
+			continue
+		}
+		text, cr := strings.CutSuffix(line, "\r")
+		text = strings.TrimRight(text, " \t")
+		padding := max(annotateColumn-displayWidth(text), 2)
+		if text == "" {
+			padding = 0
+		}
+		lines[n] = text + strings.Repeat(" ", padding) + c.annotation(a.machineCodeWords)
+		if cr {
+			lines[n] += "\r"
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// runAnnotate assembles a source and writes it back annotated.
+func runAnnotate(args []string) error {
+	fs := flag.NewFlagSet("annotate", flag.ExitOnError)
+	mcu := fs.String("mcu", "", "Target microcontroller name, e.g., 'PIC16F687' (required)")
+	configDir := fs.String("config-dir", "./configs", "Directory containing microcontroller JSON config files")
+	outPath := fs.String("o", StdioPath, "Output file for the annotated source ('-' for stdout)")
+	optimize := fs.Bool("O", false, "Annotate the code the optimization passes leave")
+	fs.Parse(args)
+
+	// The source may come before the flags, as in `asm4pic annotate main.asm -mcu PIC16F687`
+	var sources []string
+	for fs.NArg() > 0 {
+		sources = append(sources, fs.Arg(0))
+		fs.Parse(fs.Args()[1:])
+	}
+	if *mcu == "" || len(sources) != 1 {
+		fs.Usage()
+		return fmt.Errorf("-mcu and one source file are required")
+	}
+	source := sources[0]
+
+	mcConfig, err := loadMicrocontrollerConfigByName(*configDir, *mcu)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(source)
+	if err != nil {
+		return fmt.Errorf("could not read assembly file '%s': %w", source, err)
+	}
+
+	printer := &DiagnosticPrinter{File: source, Color: ColorAuto, Source: string(data), Stderr: *outPath == StdioPath}
+	assembler, warnings, err := assembleSource(string(data), mcConfig, &AssemblyOptions{Optimize: *optimize, SourceDir: filepath.Dir(source)})
+	printer.Print(warnings...)
+	if err != nil {
+		printer.Print(errorDiagnostic(err))
+		return fmt.Errorf("assembly of %s failed", source)
+	}
+	if err := writeOutputFile(*outPath, assembler.GenerateAnnotated(string(data))); err != nil {
+		return err
+	}
+	if *outPath != StdioPath {
+		fmt.Printf("Annotated source written to %s\n", *outPath)
+	}
+	return nil
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"fmt"
+	"math/bits"
+	"strings"
+)
+
+// --- Automatic Bank Selection ---
+
+// autoBankselName names the code inserted by -auto-banksel in provenance and diagnostics.
+const autoBankselName = "auto-banksel"
+
+func init() {
+	insertionModes[autoBankselName] = true
+}
+
+// bankState is what is known about the selected bank at a point of the program.
+type bankState struct {
+	reached bool // some path from an entry point arrives here
+	value   int  // the bank select bits, where known
+	known   int  // mask of the bank select bits that are known
+}
+
+// merge returns what is known where control from two paths joins.
+func (s bankState) merge(other bankState) bankState {
+	if !s.reached {
+		return other
+	}
+	if !other.reached {
+		return s
+	}
+	known := s.known & other.known &^ (s.value ^ other.value)
+	return bankState{reached: true, value: s.value & known, known: known}
+}
+
+// bankSelector inserts bank selection for one assembly, whose first pass resolved the symbols.
+type bankSelector struct {
+	a       *PicAssembler
+	movlb   bool // the device selects banks with MOVLB rather than STATUS<RP1:RP0>
+	mask    int  // all bank select bits
+	unknown bankState
+}
+
+// newBankSelector sizes the bank select bits from the highest bank holding a register.
+func newBankSelector(a *PicAssembler) *bankSelector {
+	_, movlb := a.mcConfig.InstructionSet["MOVLB"]
+	return &bankSelector{a: a, movlb: movlb, mask: bankSelectMask(a.mcConfig), unknown: bankState{reached: true}}
+}
+
+// bankSelectMask returns the bank select bits needed to reach the highest bank holding a
+// register.
+func bankSelectMask(mcConfig *MicrocontrollerConfig) int {
+	highest := 0
+	for _, addr := range mcConfig.SFRMap {
+		highest = max(highest, ramBank(mcConfig, addr))
+	}
+	if layout := mcConfig.RAMLayout; layout != nil {
+		for _, r := range layout.GPR {
+			highest = max(highest, ramBank(mcConfig, r.End))
+		}
+	}
+	return 1<<max(bits.Len(uint(highest)), 1) - 1
+}
+
+// bankNeeded returns the bank an instruction's file register operand must be selected for,
+// or -1 when it has none or can reach it from any bank: core registers mirrored in every bank,
+// common RAM and the access bank.
+func (b *bankSelector) bankNeeded(inst *Instruction, info InstructionInfo) int {
+	operand, ok := operandOfKind(inst, info, "f")
+	if !ok {
+		return -1
+	}
+	addr, err := b.a.evaluateExpression(operand)
+	if err != nil {
+		return -1
+	}
+	if access, ok := operandOfKind(inst, info, "a"); ok {
+		if val, ok := accessOperand(access); ok && val == 0 {
+			return -1
+		}
+	} else if isAccessRAM(b.a.mcConfig, addr) {
+		return -1
+	}
+	layout := b.a.mcConfig.RAMLayout
+	if isSharedRAM(b.a.mcConfig, addr) || (!b.movlb && layout != nil && layout.BankSize > 0 && coreMirroredRegisters[addr%layout.BankSize]) {
+		return -1
+	}
+	return ramBank(b.a.mcConfig, addr) & b.mask
+}
+
+// selects reports whether the bank is selected in a state.
+func (b *bankSelector) selects(s bankState, bank int) bool {
+	return s.reached && s.known == b.mask && s.value == bank
+}
+
+// selection returns the instructions that select a bank from a state: MOVLB, or a BSF or BCF
+// of each RP bit that is not known to be right.
+func (b *bankSelector) selection(s bankState, bank int) []*Instruction {
+	if b.movlb {
+		return []*Instruction{{Opcode: "MOVLB", Operands: []string{fmt.Sprint(bank)}}}
+	}
+	var code []*Instruction
+	for bit := 0; 1<<bit <= b.mask; bit++ {
+		if s.reached && s.known>>bit&1 == 1 && s.value>>bit&1 == bank>>bit&1 {
+			continue
+		}
+		opcode := "BCF"
+		if bank>>bit&1 == 1 {
+			opcode = "BSF"
+		}
+		code = append(code, &Instruction{Opcode: opcode, Operands: []string{"STATUS", fmt.Sprint(5 + bit)}})
+	}
+	return code
+}
+
+// transfer returns the state after an instruction: MOVLB and writes to the bank select bits
+// change it.
+func (b *bankSelector) transfer(s bankState, inst *Instruction, info InstructionInfo) bankState {
+	opcode := strings.ToUpper(inst.Opcode)
+	if opcode == "MOVLB" {
+		if len(inst.Operands) == 1 {
+			if val, err := b.a.evaluateExpression(inst.Operands[0]); err == nil {
+				return bankState{reached: true, value: val & b.mask, known: b.mask}
+			}
+		}
+		return b.unknown
+	}
+	register := "STATUS"
+	if b.movlb {
+		register = "BSR"
+	}
+	regAddr, ok := b.a.mcConfig.SFRMap[register]
+	operand, hasFile := operandOfKind(inst, info, "f")
+	if !ok || !hasFile || !writesFileRegister(inst, info) {
+		return s
+	}
+	if addr, err := b.a.evaluateExpression(operand); err != nil || addr != regAddr {
+		return s
+	}
+	if b.movlb {
+		return b.unknown
+	}
+	switch opcode {
+	case "CLRF":
+		return bankState{reached: true, known: b.mask}
+	case "BSF", "BCF":
+		bitOperand, _ := operandOfKind(inst, info, "b")
+		bit, err := b.a.evaluateExpression(bitOperand)
+		if err != nil {
+			return b.unknown
+		}
+		if bit < 5 || bit > 6 {
+			return s
+		}
+		m := 1 << (bit - 5) & b.mask
+		s.known |= m
+		s.value &^= m
+		if opcode == "BSF" {
+			s.value |= m
+		}
+		return s
+	}
+	return b.unknown
+}
+
+// autoBankSelect inserts bank selection before every instruction whose file register is in
+// a bank that is not known to be selected there. The selected bank is followed along the
+// control flow, so a bank selected before a loop or on every path into a label is reused;
+// calls and writes to STATUS or the BSR leave it unknown. Nothing can be inserted between a
+// skip and the instruction it skips, so the selection moves before the skip when the skip
+// itself can run in that bank, and a warning is given otherwise. It returns the new code and
+// a diagnostic for each insertion.
+func (a *PicAssembler) autoBankSelect() (*ExpandedParsedAssembly, []Diagnostic) {
+	b := newBankSelector(a)
+	resolve := func(operand string) (int, bool) {
+		val, err := a.evaluateExpression(operand)
+		return val, err == nil
+	}
+	g := buildFlowGraph(a.parsedAssembly, a.mcConfig, resolve)
+
+	// The bank each instruction needs, with the bank of a skipped instruction moved onto its
+	// skip where it can be
+	need := make(map[int]int)
+	for i := range g.address {
+		inst := a.parsedAssembly.Lines[i].(*Instruction)
+		need[i] = b.bankNeeded(inst, a.mcConfig.InstructionSet[strings.ToUpper(inst.Opcode)])
+	}
+	skipped := make(map[int]int) // skipped instruction -> its skip
+	for i, addr := range g.address {
+		if j, ok := g.atAddress[addr+1]; ok && isSkipInstruction(flowOpcode(a.parsedAssembly, a.mcConfig, i)) {
+			skipped[j] = i
+			if need[j] >= 0 && (need[i] < 0 || need[i] == need[j]) {
+				need[i] = need[j]
+			}
+		}
+	}
+
+	// Follow the selected bank from the vectors to a fixed point
+	in := make(map[int]bankState)
+	var work []int
+	enter := func(i int, s bankState) {
+		if merged := in[i].merge(s); merged != in[i] {
+			in[i] = merged
+			work = append(work, i)
+		}
+	}
+	if i, ok := g.atAddress[resetVector]; ok {
+		enter(i, bankState{reached: true, known: b.mask}) // reset clears the bank select bits
+	}
+	for _, i := range g.roots() {
+		if g.address[i] != resetVector {
+			enter(i, b.unknown)
+		}
+	}
+	for len(work) > 0 {
+		i := work[len(work)-1]
+		work = work[:len(work)-1]
+		s := in[i]
+		if _, isSkipped := skipped[i]; need[i] >= 0 && !isSkipped && !b.selects(s, need[i]) {
+			s = bankState{reached: true, value: need[i], known: b.mask}
+		}
+		inst := a.parsedAssembly.Lines[i].(*Instruction)
+		s = b.transfer(s, inst, a.mcConfig.InstructionSet[strings.ToUpper(inst.Opcode)])
+		for k, next := range g.successors[i] {
+			if strings.ToUpper(inst.Opcode) == "CALL" && k == len(g.successors[i])-1 {
+				enter(next, b.unknown) // the subroutine may have changed the bank
+			} else {
+				enter(next, s)
+			}
+		}
+	}
+
+	out := &ExpandedParsedAssembly{}
+	var diagnostics []Diagnostic
+	for i, item := range a.parsedAssembly.Lines {
+		sourceLine := a.sourceLine(i)
+		origin := a.origin(i)
+		if _, placed := g.address[i]; placed && need[i] >= 0 && !b.selects(in[i], need[i]) {
+			text := instructionText(item.(*Instruction))
+			if skip, isSkipped := skipped[i]; isSkipped {
+				diagnostics = append(diagnostics, Diagnostic{Severity: SeverityWarning, Line: sourceLine, Code: CodeBankAfterSkip,
+					Message: tr("Cannot select bank %d for %s after %s; select it before the skip.", need[i], text, instructionText(a.parsedAssembly.Lines[skip].(*Instruction)))})
+			} else {
+				inserted := insertedOrigin(a.parsedAssembly, i, autoBankselName)
+				var texts []string
+				for _, inst := range b.selection(in[i], need[i]) {
+					out.Lines = append(out.Lines, inst)
+					out.SourceLines = append(out.SourceLines, sourceLine)
+					out.Origins = append(out.Origins, inserted)
+					texts = append(texts, instructionText(inst))
+				}
+				diagnostics = append(diagnostics, Diagnostic{Severity: SeverityInfo, Line: sourceLine, Code: CodeBankselInserted,
+					Message: tr("Inserted %s to select bank %d for %s.", strings.Join(texts, "; "), need[i], text)})
+			}
+		}
+		out.Lines = append(out.Lines, item)
+		out.SourceLines = append(out.SourceLines, sourceLine)
+		out.Origins = append(out.Origins, origin)
+	}
+	return out, diagnostics
+}
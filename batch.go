@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// --- Batch Builds ---
+
+// sourceInputs expands the -asm argument. A directory gives the .asm files in it and a glob
+// pattern the files matching it, both sorted; batch is then set, even for a single match.
+// Anything else is one source file.
+func sourceInputs(arg string) (sources []string, batch bool, err error) {
+	if arg == StdioPath {
+		return []string{arg}, false, nil
+	}
+	if info, err := os.Stat(arg); err == nil && info.IsDir() {
+		entries, err := os.ReadDir(arg)
+		if err != nil {
+			return nil, true, err
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() && strings.EqualFold(filepath.Ext(entry.Name()), ".asm") {
+				sources = append(sources, filepath.Join(arg, entry.Name()))
+			}
+		}
+		if len(sources) == 0 {
+			return nil, true, fmt.Errorf("no .asm files in directory '%s'", arg)
+		}
+		return sources, true, nil
+	}
+	if !strings.ContainsAny(arg, "*?[") {
+		return []string{arg}, false, nil
+	}
+	matches, err := filepath.Glob(arg)
+	if err != nil {
+		return nil, true, fmt.Errorf("invalid pattern '%s': %w", arg, err)
+	}
+	for _, match := range matches {
+		if info, err := os.Stat(match); err == nil && !info.IsDir() {
+			sources = append(sources, match)
+		}
+	}
+	if len(sources) == 0 {
+		return nil, true, fmt.Errorf("no files match '%s'", arg)
+	}
+	sort.Strings(sources)
+	return sources, true, nil
+}
+
+// batchOutputs are the output flags of a batch build. Each names a directory, which the
+// outputs of every source are written to under the source's name; an empty HEX or report
+// directory puts those next to the source, and the other outputs are only written when
+// their directory is given.
+type batchOutputs struct {
+	Hex, Report, Listing, Map, Result, Stats string
+}
+
+// check rejects stdout outputs, which the sources would overwrite in turn, and creates the
+// output directories.
+func (b batchOutputs) check() error {
+	for flag, dir := range map[string]string{"hex": b.Hex, "report": b.Report, "listing": b.Listing, "map": b.Map, "result-json": b.Result, "stats-json": b.Stats} {
+		switch {
+		case dir == "":
+		case dir == StdioPath:
+			return fmt.Errorf("-%s cannot be stdout when -asm names several sources", flag)
+		default:
+			if info, err := os.Stat(dir); err == nil && !info.IsDir() {
+				return fmt.Errorf("-%s must name a directory when -asm names several sources, but '%s' is a file", flag, dir)
+			}
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// batchOutputPath returns the file an output of source is written to in dir, named after
+// the source with the given suffix, or "" when dir is not given.
+func batchOutputPath(dir, source, suffix string) string {
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, strings.TrimSuffix(filepath.Base(source), filepath.Ext(source))+suffix)
+}
+
+// assembleBatch assembles each source as a program of its own, continuing past failures,
+// and returns an error naming how many failed.
+func assembleBatch(sources []string, mcConfig *MicrocontrollerConfig, outputs batchOutputs, printer DiagnosticPrinter, opts *AssemblyOptions) error {
+	failed := 0
+	for _, source := range sources {
+		fmt.Printf("--- %s ---\n", source)
+		data, err := os.ReadFile(source)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading assembly file '%s': %v\n", source, err)
+			failed++
+			continue
+		}
+		next := strings.TrimSuffix(source, filepath.Ext(source))
+		hexPath, reportPath := next+".hex", next+"-report.txt"
+		if outputs.Hex != "" {
+			hexPath = batchOutputPath(outputs.Hex, source, ".hex")
+		}
+		if outputs.Report != "" {
+			reportPath = batchOutputPath(outputs.Report, source, "-report.txt")
+		}
+		sourceOpts := *opts
+		sourceOpts.SourceDir = filepath.Dir(source)
+		sourceOpts.ListingFile = batchOutputPath(outputs.Listing, source, ".lst")
+		sourceOpts.MapFile = batchOutputPath(outputs.Map, source, ".map")
+		sourceOpts.ResultFile = batchOutputPath(outputs.Result, source, ".json")
+		sourceOpts.StatsFile = batchOutputPath(outputs.Stats, source, "-stats.json")
+
+		sourcePrinter := printer
+		sourcePrinter.File, sourcePrinter.Source = source, string(data)
+		if err := assemble(string(data), hexPath, mcConfig, reportPath, &sourcePrinter, &sourceOpts); err != nil {
+			fmt.Fprint(os.Stderr, tr("Assembly of %s failed\n", source))
+			sourcePrinter.Print(errorDiagnostic(err))
+			failed++
+		}
+	}
+	fmt.Print(tr("Assembled %d of %d sources\n", len(sources)-failed, len(sources)))
+	if failed > 0 {
+		return trErrorf("%d of %d sources failed to assemble", failed, len(sources))
+	}
+	return nil
+}
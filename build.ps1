@@ -6,7 +6,8 @@ $targets = @(
     @{ GOOS = "linux"; GOARCH = "amd64"; Name = "asm4PIC_linux_amd64" },
     @{ GOOS = "linux"; GOARCH = "arm64"; Name = "asm4PIC_linux_arm64" },
     @{ GOOS = "darwin"; GOARCH = "amd64"; Name = "asm4PIC_macos_amd64" },
-    @{ GOOS = "darwin"; GOARCH = "arm64"; Name = "asm4PIC_macos_arm64" }
+    @{ GOOS = "darwin"; GOARCH = "arm64"; Name = "asm4PIC_macos_arm64" },
+    @{ GOOS = "js"; GOARCH = "wasm"; Name = "asm4PIC.wasm" }
 )
 
 # Output directory
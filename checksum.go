@@ -0,0 +1,51 @@
+package main
+
+// --- Program Checksum in the User IDs ---
+
+// programChecksum returns the device checksum programmers show for an unprotected part: the
+// 16-bit sum of every program memory word, erased words included, and of the implemented
+// bits of each configuration word.
+func (a *PicAssembler) programChecksum() int {
+	wordMask := (1 << a.mcConfig.ProgramWordSizeBits) - 1
+	sum := 0
+	for addr := 0; addr < a.mcConfig.ProgramMemorySize; addr++ {
+		if word, ok := a.machineCodeWords[addr]; ok {
+			sum += word & wordMask
+		} else {
+			sum += wordMask
+		}
+	}
+	for _, name := range sortedConfigWordNames(a.configWords) {
+		fuseMap, ok := configWordFuseMap(a.mcConfig, name)
+		if !ok {
+			continue
+		}
+		implemented := 0
+		for _, group := range fuseMap {
+			implemented |= group.Mask
+		}
+		sum += a.configWords[name] & implemented
+	}
+	return sum & 0xFFFF
+}
+
+// writeChecksumID stores the program checksum in the first four user ID locations, one
+// nibble per word with the most significant first, which is how programmers display and
+// compare the ID.
+func (a *PicAssembler) writeChecksumID() error {
+	ids := a.mcConfig.IDLocations
+	if ids == nil || ids.End-ids.Start+1 < 4 {
+		return trErrorf("%s has no user ID locations", a.mcConfig.Name)
+	}
+	for addr := ids.Start; addr < ids.Start+4; addr++ {
+		if region, used := a.wordRegion[addr]; used {
+			return &AssemblerError{Line: a.regions[region].Line, Code: CodeIDLocationsUsed,
+				Message: tr("User ID location 0x%04X is written by %s, so the checksum cannot be stored there.", addr, a.regions[region])}
+		}
+	}
+	a.idChecksum = a.programChecksum()
+	for k := 0; k < 4; k++ {
+		a.machineCodeWords[ids.Start+k] = a.idChecksum >> (12 - 4*k) & 0xF
+	}
+	return nil
+}
@@ -0,0 +1,348 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// --- Command-Line Entry Point ---
+
+func main() {
+	messageLocale = environmentLocale()
+
+	// Dispatch to a subcommand when the first argument names one
+	if len(os.Args) > 1 {
+		if cmd, ok := subcommands[os.Args[1]]; ok {
+			if err := cmd.Run(os.Args[2:]); err != nil {
+				log.Fatalf("%s failed: %v", os.Args[1], err)
+			}
+			return
+		}
+	}
+
+	// Define command-line flags
+	asmFile := flag.String("asm", "", "Path to the input assembly (.asm) file, or '-' for stdin (required)")
+	mcu := flag.String("mcu", "", "Target microcontroller name, e.g., 'PIC16F687' (required)")
+	configDir := flag.String("config-dir", "./configs", "Directory containing microcontroller JSON config files")
+	outFile := flag.String("hex", "", "Path to the output HEX file, or '-' for stdout (defaults to <asm-file-name>.hex, or stdout when reading stdin)")
+	reportFile := flag.String("report", "", "Path to the output assembly report file, or '-' for stdout (defaults to printing to console)")
+	listingFile := flag.String("listing", "", "Path to an output listing file with addresses and machine words per source line")
+	buildTime := flag.String("build-time", "", "Pin __DATE__ and __TIME__ to an RFC 3339 time or Unix seconds for reproducible builds (default: SOURCE_DATE_EPOCH or now)")
+	autoBanksel := flag.Bool("auto-banksel", false, "Insert bank selection before register accesses whose bank is not known to be selected, logging each insertion")
+	autoPagesel := flag.Bool("auto-pagesel", false, "Insert PCLATH page selection before GOTO and CALL into a page that is not known to be selected, logging each insertion")
+	splitHex := flag.Bool("split-hex", false, "Also write each region of the HEX file to its own file next to it: <hex>-program.hex, -config.hex, -id.hex and -eeprom.hex")
+	noELA := flag.Bool("no-ela", false, "Write the HEX file without Extended Linear Address (type 04) records; fails if an address does not fit in 16 bits")
+	hexLower := flag.Bool("hex-lowercase", false, "Write the HEX files with lower-case hex digits")
+	hexCRLF := flag.Bool("hex-crlf", false, "Write the HEX files with CR LF line endings")
+	hexPad := flag.Bool("hex-pad", false, "Write every program memory and EEPROM HEX data record with the full 16 bytes of its aligned block, filling unwritten bytes with -hex-fill")
+	hexFill := flag.Uint("hex-fill", 0xFF, "Fill byte for the bytes -hex-pad adds")
+	hexOmit := flag.String("hex-omit", "", "Comma-separated HEX regions to leave out of the HEX files: program, config, id, eeprom")
+	debug := flag.Bool("debug", false, "Build for the in-circuit debugger: define __DEBUG, enable the debug config setting and reserve the debugger's program memory and registers")
+	idChecksum := flag.Bool("id-checksum", false, "Store the program checksum in the user ID locations, one nibble per word")
+	mapFile := flag.String("map", "", "Path to an output map file with the program and data memory layout")
+	resultFile := flag.String("result-json", "", "Path to an output JSON file with the assembly result: memory words, config words, symbols, sections and statistics")
+	manifest := flag.Bool("manifest", false, "Write a JSON build manifest next to the HEX file as <hex>-manifest.json: tool version, device, source hashes, config words, image checksum and build options")
+	noExpand := flag.Bool("noexpand", false, "Hide macro expansions in the listing until an EXPAND directive")
+	msgFormat := flag.String("msg-format", MsgFormatDefault, "Diagnostic format: 'default', or 'gcc' for file:line: severity: message on stderr")
+	color := flag.String("color", ColorAuto, "Color diagnostics: 'auto' (when the output is a terminal), 'always' or 'never'")
+	optimize := flag.Bool("O", false, "Run the optimization passes and log each change")
+	enablePasses := flag.String("enable-passes", "", "Comma-separated opt-in optimization passes to run (e.g. 'dce'); implies -O")
+	disablePasses := flag.String("disable-passes", "", "Comma-separated optimization passes to skip with -O (e.g. 'peephole')")
+	aliasFile := flag.String("aliases", "", "Path to a JSON file of INSTRUCTION_ALIASES/PSEUDO_OPS merged over the device config")
+	isaFile := flag.String("isa-overlay", "", "Path to a JSON file of INSTRUCTION_SET entries added to or replacing those of the device config")
+	stats := flag.Bool("stats", false, "Print per-phase timings, line, instruction and macro expansion counts and output sizes after the build")
+	statsFile := flag.String("stats-json", "", "Path to an output JSON file with the build statistics of -stats")
+	symbolOrder := flag.String("symbol-order", SymbolOrderName, "Order of the report's symbol table: 'name' or 'address'")
+	symbolDetails := flag.Bool("symbol-details", false, "List EQU symbols and SFRs in the report's symbol table, with their type and defining line")
+	compat := flag.String("compat", "", "Read the source the way another assembler does: 'mpasm' (radix, column 1 labels, LIST/RADIX) and flag constructs it would reject")
+	strictConfig := flag.Bool("strict-config", false, "Make unknown, unmapped and ambiguous __CONFIG fuse settings errors")
+	werror := flag.Bool("Werror", false, "Treat warnings as errors (implies -strict-config)")
+	missingEnd := flag.String("missing-end", SeverityWarning, "How to treat a source without END: 'warning', 'error' or 'off'")
+	plugins := pluginFlag{}
+	flag.Var(plugins, "plugin", "Handle a custom directive with an external command, as NAME=command (repeatable)")
+	explain := flag.String("explain", "", "Print the description of a diagnostic code (e.g. 'W0305') and exit")
+	projectPath := flag.String("project", "", "Path to an asm4pic.json project file supplying defaults for the other flags")
+	profileName := flag.String("profile", "", "Build profile of the project file to use (e.g. 'debug' or 'release')")
+	lang := flag.String("lang", "", "Language of diagnostics, status messages and the report: 'en' or 'pt-BR' (default: from LC_ALL, LC_MESSAGES or LANG)")
+	var includeDirs includeDirFlag
+	flag.Var(&includeDirs, "I", "Search this directory for #INCLUDE files, after the including file's directory for \"name\" (repeatable)")
+	defines := defineFlag{}
+	flag.Var(defines, "D", "Define a symbol as #DEFINE does, as NAME or NAME=value (repeatable; NAME alone defines it as 1)")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [subcommand] [flags]\n\nFlags:\n", filepath.Base(os.Args[0]))
+		flag.PrintDefaults()
+		printSubcommands()
+	}
+	flag.Parse()
+	if *lang != "" {
+		if err := setLocale(*lang); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *explain != "" {
+		text, err := explainCode(*explain)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Print(text)
+		return
+	}
+
+	// Fill in flags that were not given explicitly from the project file
+	opts := &AssemblyOptions{IncludeDirs: includeDirs}
+	if *projectPath != "" {
+		project, err := loadProjectFile(*projectPath)
+		if err != nil {
+			log.Fatalf("Error loading project: %v", err)
+		}
+		packageDirs, err := project.resolvePackages()
+		if err != nil {
+			log.Fatalf("Error resolving packages: %v", err)
+		}
+		opts.IncludeDirs = append(opts.IncludeDirs, packageDirs...)
+		setFlags := make(map[string]bool)
+		flag.Visit(func(f *flag.Flag) { setFlags[f.Name] = true })
+		if !setFlags["asm"] {
+			*asmFile = project.Path(project.Source)
+		}
+		if !setFlags["mcu"] {
+			*mcu = project.MCU
+		}
+		if !setFlags["config-dir"] {
+			*configDir = project.Path(project.ConfigDir)
+		}
+		if !setFlags["hex"] {
+			*outFile = project.Path(project.Hex)
+		}
+		if !setFlags["report"] {
+			*reportFile = project.Path(project.Report)
+		}
+		if len(project.Plugins) > 0 && !setFlags["plugin"] {
+			opts.Plugins = project.Plugins
+			opts.PluginDir = project.Dir
+		}
+		if *profileName != "" {
+			profile, err := project.Profile(*profileName)
+			if err != nil {
+				log.Fatalf("Error loading project: %v", err)
+			}
+			for name, path := range map[string]struct {
+				flag  *string
+				value string
+			}{"hex": {outFile, profile.Hex}, "report": {reportFile, profile.Report}, "listing": {listingFile, profile.Listing}, "map": {mapFile, profile.Map}} {
+				if path.value != "" && !setFlags[name] {
+					*path.flag = project.Path(path.value)
+				}
+			}
+			if !setFlags["O"] {
+				*optimize = profile.Optimize
+			}
+			if !setFlags["enable-passes"] {
+				*enablePasses = strings.Join(profile.EnablePasses, ",")
+			}
+			if !setFlags["disable-passes"] {
+				*disablePasses = strings.Join(profile.DisablePasses, ",")
+			}
+			if !setFlags["debug"] {
+				*debug = profile.Debug
+			}
+			opts.FuseSettings = profile.Config
+			opts.Defines = make(map[string]string)
+			for name, value := range profile.Defines {
+				opts.Defines[name] = value
+			}
+		}
+	} else if *profileName != "" {
+		log.Fatal("-profile selects a build profile of the project file and needs -project")
+	}
+	if len(defines) > 0 {
+		if opts.Defines == nil {
+			opts.Defines = make(map[string]string)
+		}
+		for name, value := range defines {
+			opts.Defines[name] = value
+		}
+	}
+
+	// Validate required flags
+	if *asmFile == "" || *mcu == "" {
+		fmt.Println("Error: -asm and -mcu flags (or -project) are required.")
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *msgFormat != MsgFormatDefault && *msgFormat != MsgFormatGCC {
+		log.Fatalf("Invalid -msg-format '%s' (expected '%s' or '%s')", *msgFormat, MsgFormatDefault, MsgFormatGCC)
+	}
+
+	if *color != ColorAuto && *color != ColorAlways && *color != ColorNever {
+		log.Fatalf("Invalid -color '%s' (expected '%s', '%s' or '%s')", *color, ColorAuto, ColorAlways, ColorNever)
+	}
+
+	switch *missingEnd {
+	case SeverityWarning, SeverityError, SeverityOff:
+		opts.MissingEnd = *missingEnd
+	default:
+		log.Fatalf("Invalid -missing-end '%s' (expected '%s', '%s' or '%s')", *missingEnd, SeverityWarning, SeverityError, SeverityOff)
+	}
+
+	switch *symbolOrder {
+	case SymbolOrderName, SymbolOrderAddress:
+		opts.SymbolOrder, opts.SymbolDetails = *symbolOrder, *symbolDetails
+	default:
+		log.Fatalf("Invalid -symbol-order '%s' (expected '%s' or '%s')", *symbolOrder, SymbolOrderName, SymbolOrderAddress)
+	}
+
+	var err error
+	if opts.EnabledPasses, err = parsePassList(*enablePasses); err != nil {
+		log.Fatal(err)
+	}
+	if opts.DisabledPasses, err = parsePassList(*disablePasses); err != nil {
+		log.Fatal(err)
+	}
+	opts.Optimize = *optimize || len(opts.EnabledPasses) > 0
+	opts.ListingFile, opts.NoExpand = *listingFile, *noExpand
+	opts.MapFile, opts.ResultFile, opts.Manifest = *mapFile, *resultFile, *manifest
+	opts.Stats, opts.StatsFile = *stats, *statsFile
+	opts.IDChecksum, opts.AutoBanksel, opts.AutoPagesel = *idChecksum, *autoBanksel, *autoPagesel
+	opts.StrictConfig, opts.WarningsAsErrors = *strictConfig, *werror
+	opts.SplitHex, opts.NoELA, opts.Debug = *splitHex, *noELA, *debug
+	if *hexFill > 0xFF {
+		log.Fatalf("Invalid -hex-fill 0x%X (expected a byte, 0x00-0xFF)", *hexFill)
+	}
+	opts.HexFormat = HexFormat{Lowercase: *hexLower, CRLF: *hexCRLF, PadRecords: *hexPad, Fill: byte(*hexFill)}
+	if opts.OmitHexRegions, err = parseHexRegions(*hexOmit); err != nil {
+		log.Fatal(err)
+	}
+	if *compat != "" && *compat != CompatMPASM {
+		log.Fatalf("Invalid -compat '%s' (expected '%s')", *compat, CompatMPASM)
+	}
+	opts.Compat = *compat
+	if *buildTime != "" {
+		if opts.BuildTime, err = parseBuildTime(*buildTime); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if len(plugins) > 0 {
+		opts.Plugins = plugins
+	}
+
+	// --- Step 1: Load the MCU Configuration ---
+	mcConfig, err := loadMicrocontrollerConfigByName(*configDir, *mcu)
+	if err != nil {
+		log.Fatalf("Error loading configuration: %v", err)
+	}
+	if *isaFile != "" {
+		if err := loadISAOverlay(*isaFile, mcConfig); err != nil {
+			log.Fatalf("Error loading instruction set overlay: %v", err)
+		}
+	}
+	if *aliasFile != "" {
+		if err := loadAliasOverlay(*aliasFile, mcConfig); err != nil {
+			log.Fatalf("Error loading aliases: %v", err)
+		}
+	}
+
+	// A directory or glob pattern assembles each source on its own
+	sources, batch, err := sourceInputs(*asmFile)
+	if err != nil {
+		log.Fatalf("Error reading assembly sources: %v", err)
+	}
+	if batch {
+		outputs := batchOutputs{Hex: *outFile, Report: *reportFile, Listing: opts.ListingFile, Map: opts.MapFile, Result: opts.ResultFile, Stats: opts.StatsFile}
+		if err := outputs.check(); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Print(tr("Configuration loaded for %s\n", *mcu))
+		printer := DiagnosticPrinter{Format: *msgFormat, Color: *color}
+		if err := assembleBatch(sources, mcConfig, outputs, printer, opts); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// --- Step 2: Read the Assembly Source Code ---
+	var asmCodeBytes []byte
+	sourceName := *asmFile
+	if *asmFile == StdioPath {
+		asmCodeBytes, err = io.ReadAll(os.Stdin)
+		sourceName = "<stdin>"
+	} else {
+		asmCodeBytes, err = os.ReadFile(*asmFile)
+		opts.SourceDir = filepath.Dir(*asmFile)
+	}
+	if err != nil {
+		log.Fatalf("Error reading assembly file '%s': %v", sourceName, err)
+	}
+
+	// --- Step 3: Determine Output Filenames ---
+	// A source read from stdin has no name to derive the HEX file from, so the HEX goes to stdout
+	hexFilePath := *outFile
+	if hexFilePath == "" && *asmFile == StdioPath {
+		hexFilePath = StdioPath
+	} else if hexFilePath == "" {
+		baseName := strings.TrimSuffix(*asmFile, filepath.Ext(*asmFile))
+		hexFilePath = baseName + ".hex"
+	}
+	toStdout := 0
+	for _, path := range []string{hexFilePath, *reportFile, opts.ListingFile, opts.MapFile, opts.ResultFile, opts.StatsFile} {
+		if path == StdioPath {
+			toStdout++
+		}
+	}
+	if toStdout > 1 {
+		log.Fatal("Only one output can be written to stdout ('-')")
+	}
+	if opts.SplitHex && hexFilePath == StdioPath {
+		log.Fatal("-split-hex needs a HEX file path to name the region files after")
+	}
+	if opts.Manifest && hexFilePath == StdioPath {
+		log.Fatal("-manifest needs a HEX file path to write the manifest next to")
+	}
+
+	// --- Step 4: Run the Assembler ---
+	printer := &DiagnosticPrinter{Format: *msgFormat, File: sourceName, Color: *color, Source: string(asmCodeBytes), Stderr: toStdout > 0}
+	if printer.Stderr {
+		fmt.Fprint(os.Stderr, tr("Configuration loaded for %s\n", *mcu))
+	} else {
+		fmt.Print(tr("Configuration loaded for %s\n", *mcu))
+	}
+	err = assemble(string(asmCodeBytes), hexFilePath, mcConfig, *reportFile, printer, opts)
+	if err != nil {
+		printer.Fatal(err)
+	}
+}
+
+// includeDirFlag collects repeated -I directory flags, in order.
+type includeDirFlag []string
+
+func (f *includeDirFlag) String() string { return "" }
+
+func (f *includeDirFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// defineFlag collects repeated -D NAME[=value] flags.
+type defineFlag map[string]string
+
+func (f defineFlag) String() string { return "" }
+
+func (f defineFlag) Set(value string) error {
+	name, text, ok := strings.Cut(value, "=")
+	if name = strings.TrimSpace(name); name == "" || strings.ContainsAny(name, " \t") {
+		return fmt.Errorf("expected NAME or NAME=value, got '%s'", value)
+	}
+	if !ok {
+		text = "1"
+	}
+	f[name] = strings.TrimSpace(text)
+	return nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import "strings"
+
+// --- Macro Side-Effect Lint ---
+
+func init() {
+	registerLintRule(lintRule{
+		Name:            "macro-clobber",
+		Description:     "Macro overwrites W or a STATUS flag set just before it and used just after it",
+		DefaultSeverity: SeverityWarning,
+		Check:           lintMacroClobber,
+	})
+}
+
+// statusFlags names the STATUS bits that skips test after arithmetic.
+var statusFlags = map[int]string{0: "C", 1: "DC", 2: "Z"}
+
+// resultFlags returns the STATUS flags an opcode sets from its result, as a mask of their bits.
+// Any instruction that writes STATUS as its file register changes them as well.
+func resultFlags(opcode string) int {
+	opcode = strings.ToUpper(opcode)
+	mask := 0
+	if zeroFlagWriters[opcode] {
+		mask |= 1 << 2
+	}
+	switch opcode {
+	case "ADDWF", "SUBWF", "ADDLW", "SUBLW":
+		mask |= 1<<0 | 1<<1
+	case "RLF", "RRF":
+		mask |= 1 << 0
+	}
+	return mask
+}
+
+// readsW reports whether an instruction uses the value in W.
+func readsW(inst *Instruction) bool {
+	switch strings.ToUpper(inst.Opcode) {
+	case "MOVWF", "ADDWF", "SUBWF", "ANDWF", "IORWF", "XORWF", "ADDLW", "SUBLW", "ANDLW", "IORLW", "XORLW":
+		return true
+	}
+	return false
+}
+
+// flagsWritten returns the STATUS flags an instruction changes, as a mask of their bits.
+func (ctx *lintContext) flagsWritten(inst *Instruction, info InstructionInfo) int {
+	opcode := strings.ToUpper(inst.Opcode)
+	mask := resultFlags(opcode)
+	if _, addr, ok := ctx.fileRegisterAddress(inst, info); ok && addr&0x7F == statusRegister && writesFileRegister(inst, info) {
+		mask = 1<<0 | 1<<1 | 1<<2
+		if opcode == "BSF" || opcode == "BCF" {
+			// Setting or clearing one bit leaves the other flags alone
+			mask = 0
+			if bitOperand, ok := operandOfKind(inst, info, "b"); ok {
+				if bit, err := ctx.assembler.evaluateExpression(bitOperand); err == nil && statusFlags[bit] != "" {
+					mask = 1 << bit
+				}
+			}
+		}
+	}
+	return mask
+}
+
+// flagTested returns the STATUS flag a BTFSS or BTFSC tests, if it tests one.
+func (ctx *lintContext) flagTested(inst *Instruction, info InstructionInfo) (int, bool) {
+	bitOperand, isBitTest := operandOfKind(inst, info, "b")
+	if !isSkipInstruction(inst.Opcode) || !isBitTest {
+		return 0, false
+	}
+	if _, addr, ok := ctx.fileRegisterAddress(inst, info); !ok || addr&0x7F != statusRegister {
+		return 0, false
+	}
+	bit, err := ctx.assembler.evaluateExpression(bitOperand)
+	_, isFlag := statusFlags[bit]
+	return bit, err == nil && isFlag
+}
+
+// outerFrame returns the outermost macro, define or built-in invocation an item was expanded
+// from.
+func (ctx *lintContext) outerFrame(i int) (MacroFrame, bool) {
+	if i >= len(ctx.expanded.Origins) || ctx.expanded.Origins[i] == nil || len(ctx.expanded.Origins[i].Chain) == 0 {
+		return MacroFrame{}, false
+	}
+	return ctx.expanded.Origins[i].Chain[0], true
+}
+
+// adjacentInstruction returns the instruction next to item i in direction step (-1 or 1),
+// skipping comments. A label or any other item in between ends the search, since control may
+// arrive there from elsewhere.
+func (ctx *lintContext) adjacentInstruction(i, step int) (int, bool) {
+	for j := i + step; j >= 0 && j < len(ctx.expanded.Lines); j += step {
+		if _, isComment := ctx.expanded.Lines[j].(*Comment); isComment {
+			continue
+		}
+		_, _, ok := ctx.instructionAt(j)
+		return j, ok
+	}
+	return 0, false
+}
+
+// lintMacroClobber checks each expansion against the instructions around it. When the
+// instruction before the expansion loads W and the one after it uses W, an expansion that
+// overwrites W without reading it first loses the value; likewise when the instruction before
+// sets a STATUS flag that a skip right after tests and the expansion changes the flag.
+func lintMacroClobber(ctx *lintContext) []lintFinding {
+	var findings []lintFinding
+	lines := ctx.expanded.Lines
+	for i := 0; i < len(lines); {
+		frame, ok := ctx.outerFrame(i)
+		if !ok {
+			i++
+			continue
+		}
+		// The expansion runs to the last item of the same invocation, comments included
+		end := i
+		for j := i + 1; j < len(lines); j++ {
+			if next, ok := ctx.outerFrame(j); ok && next == frame {
+				end = j
+			} else if _, isComment := lines[j].(*Comment); !isComment {
+				break
+			}
+		}
+
+		// What the expansion does to W and the flags before it reads them
+		wOverwritten, wTouched, flagsChanged, flagsRead := "", false, 0, 0
+		for j := i; j <= end; j++ {
+			inst, info, ok := ctx.instructionAt(j)
+			if !ok {
+				continue
+			}
+			opcode := strings.ToUpper(inst.Opcode)
+			if !wTouched && (readsW(inst) || writesW(inst, info)) {
+				wTouched = true
+				if !readsW(inst) {
+					wOverwritten = opcode
+				}
+			}
+			if opcode == "RLF" || opcode == "RRF" {
+				flagsRead |= 1 << 0 &^ flagsChanged
+			}
+			flagsChanged |= ctx.flagsWritten(inst, info) &^ flagsRead
+		}
+
+		before, hasBefore := ctx.adjacentInstruction(i, -1)
+		after, hasAfter := ctx.adjacentInstruction(end, 1)
+		if hasBefore && hasAfter {
+			beforeInst, beforeInfo, _ := ctx.instructionAt(before)
+			afterInst, afterInfo, _ := ctx.instructionAt(after)
+			line := ctx.expanded.SourceLines[i]
+			if wOverwritten != "" && writesW(beforeInst, beforeInfo) && readsW(afterInst) {
+				findings = append(findings, lintFinding{Line: line, Message: tr("'%s' overwrites W (%s), which the %s before it loads and the %s after it uses.", frame.Name, wOverwritten, strings.ToUpper(beforeInst.Opcode), strings.ToUpper(afterInst.Opcode))})
+			}
+			if bit, ok := ctx.flagTested(afterInst, afterInfo); ok && flagsChanged>>bit&1 == 1 && ctx.flagsWritten(beforeInst, beforeInfo)>>bit&1 == 1 {
+				findings = append(findings, lintFinding{Line: line, Message: tr("'%s' changes STATUS,%s, which the %s before it sets and the %s after it tests.", frame.Name, statusFlags[bit], strings.ToUpper(beforeInst.Opcode), strings.ToUpper(afterInst.Opcode))})
+			}
+		}
+		i = end + 1
+	}
+	return findings
+}
@@ -0,0 +1,523 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// --- Diagnostic Codes ---
+
+// Stable diagnostic IDs. The letter gives the default severity (E error, W warning, I info) and
+// the first two digits the area: 01 parsing, includes and macros; 02 symbols and expressions;
+// 03 configuration words; 04 instructions; 05 program memory; 06 HEX files; 07 optimizer;
+// 08 data memory.
+// Codes are never renumbered or reused once released.
+const (
+	CodeIncludeForm        = "E0101"
+	CodeIncludeDepth       = "E0102"
+	CodeIncludeFailed      = "E0103"
+	CodeMacroParam         = "E0104"
+	CodeMacroArgs          = "E0105"
+	CodeNestingDepth       = "E0106"
+	CodeExpansionFailed    = "E0107"
+	CodeConditional        = "E0108"
+	CodeConditionValue     = "E0109"
+	CodePassLayout         = "E0110"
+	CodeMissingEnd         = "W0101"
+	CodeCodeAfterEnd       = "W0102"
+	CodeUnhandledLine      = "W0103"
+	CodeDelayRounded       = "W0104"
+	CodeMPASMCompat        = "W0105"
+	CodePassRewrite        = "W0106"
+	CodeUndefinedSymbol    = "E0201"
+	CodeInvalidExpression  = "E0202"
+	CodeEquWithoutLabel    = "E0203"
+	CodeSymbolRedefined    = "E0204"
+	CodeSFRShadowed        = "E0205"
+	CodeDuplicateLabel     = "E0206"
+	CodeUnsettledSymbols   = "E0207"
+	CodeEquOverlap         = "W0208"
+	CodeUnknownFuse        = "W0305"
+	CodeUnmappedConfigWord = "W0306"
+	CodeFuseConflict       = "E0307"
+	CodeUnknownConfigWord  = "E0308"
+	CodeConfigOverride     = "W0309"
+	CodeIDLocationsUsed    = "E0310"
+	CodeAmbiguousFuse      = "W0311"
+	CodeProfileFuse        = "E0312"
+	CodeUnknownInstruction = "E0401"
+	CodeOperandCount       = "E0402"
+	CodeInvalidDestination = "E0403"
+	CodeInvalidOperand     = "E0404"
+	CodeEncodingFailed     = "E0405"
+	CodeAmbiguousBank      = "W0406"
+	CodeBankselInserted    = "I0407"
+	CodeBankAfterSkip      = "W0408"
+	CodeOrgOutOfRange      = "E0501"
+	CodeAddressOverlap     = "E0502"
+	CodeAddressOutOfBounds = "W0503"
+	CodeTablePageCrossing  = "E0504"
+	CodePCLATHNotSet       = "E0505"
+	CodeSelfWriteAlignment = "W0506"
+	CodeSelfWriteNesting   = "E0507"
+	CodePageselInserted    = "I0508"
+	CodePageAtVector       = "W0509"
+	CodeDebugReserved      = "E0510"
+	CodeHexRecord          = "E0601"
+	CodeOptimization       = "I0701"
+	CodeNoSharedRAM        = "E0801"
+	CodeRAMOverflow        = "E0802"
+	CodeResOutsideSection  = "E0803"
+	CodeOverlayName        = "E0804"
+	CodeOverlayCollision   = "E0805"
+	CodeDebugRAM           = "E0806"
+)
+
+// diagnosticInfo is the --explain text of a diagnostic code.
+type diagnosticInfo struct {
+	Summary     string
+	Explanation string
+}
+
+var diagnosticCatalog = map[string]diagnosticInfo{
+	CodeIncludeForm: {"Malformed #INCLUDE", `#INCLUDE names a file as <name>, "name" or a bare file name. Anything else,
+such as an unterminated quote or a name followed by more text, is rejected.
+
+    #INCLUDE "regs.inc      ; E0101
+    #INCLUDE "regs.inc"     ; ok
+    #INCLUDE <std16.inc>    ; ok
+
+Fix: quote the file name, or use angle brackets for a library.`},
+	CodeIncludeDepth: {"Includes nested too deeply", `An include file includes further files beyond the nesting limit. This is
+almost always an include cycle: a.inc includes b.inc, which includes a.inc.
+
+Fix: remove the cycle, or include the shared file only from the top level.`},
+	CodeIncludeFailed: {"Cannot include file", `The file named by #INCLUDE was not found, or it targets a different core
+width than the device. "name" is searched in the directory of the including
+file, then in the -I directories and project packages; <name> skips the first
+of these. Bundled library names are found in any case.
+
+Fix: check the spelling, pass the directory holding it with -I or add the
+package that provides it to the project, or pick the library matching the
+device (e.g. std14.inc vs std16.inc).`},
+	CodeMacroParam: {"Invalid macro parameter", `A MACRO definition lists a parameter that is not a valid identifier, gives
+one an empty default, or puts a parameter without a default after one with a
+default.
+
+    ADD2 MACRO 1st, b      ; E0104
+    ADD2 MACRO a=1, b      ; E0104: b must have a default too
+    ADD2 MACRO first, b    ; ok
+
+Fix: parameter names start with a letter or underscore and contain only
+letters, digits and underscores; parameters with defaults come last.`},
+	CodeMacroArgs: {"Wrong number of macro arguments", `A macro or pseudo-op was invoked with a different number of arguments than
+its definition declares.
+
+    MOV16 MACRO dstL, dstH, srcL, srcH
+    ...
+        MOV16 A, B          ; E0105: expects 4 argument(s), got 2
+
+Fix: pass one argument per parameter, in the order of the definition.
+Trailing parameters with a default (times=3) may be left out.`},
+	CodeNestingDepth: {"Expansion nested too deeply", `A macro, pseudo-op or custom directive expands to itself, directly or
+through other macros, and the expansion never ends.
+
+Fix: break the recursion; macros cannot loop, so unroll the repetition or
+use a built-in such as DELAY_CYCLES.`},
+	CodeExpansionFailed: {"Built-in or directive failed", `A built-in macro (e.g. DELAY_US) or a custom directive plugin rejected its
+operands. The message after the name says why.
+
+    DELAY_US 10             ; E0107: expected DELAY_US microseconds, fosc[, ...]
+
+Fix: correct the operands as described, or fix the plugin command.`},
+	CodeConditional: {"Unbalanced IF block", `An ELSE or ENDIF has no IF before it, an IF block has two ELSEs, an IF has no
+condition, or an IF is never closed with ENDIF. A block opened in a macro body
+must be closed in it.
+
+    IF DEBUG
+            CALL trace
+    ; E0108: IF is never closed with ENDIF
+            END
+
+Fix: add the missing IF or ENDIF, or remove the extra ELSE.`},
+	CodeConditionValue: {"IF condition is not a constant", `The condition of an IF is evaluated when the line is read, so it can only use
+numbers, #DEFINEs, EQU symbols defined above it and predefined symbols such as
+__ASM4PIC_VERSION__. Labels and SFR addresses are not known yet; only a
+condition that calls PASS() is evaluated later, by the assembler passes.
+
+    IF CLOCK == 8           ; E0109 if CLOCK is defined further down
+
+Fix: define the symbol above the IF, pass it with -D, or test whether it is
+defined with DEFINED(name).`},
+	CodePassLayout: {"IF on PASS() moves code between passes", `An IF whose condition calls PASS() is evaluated by the assembler passes, so
+pass 1 can lay out one branch and pass 2 generate the other. When they take a
+different number of words, the labels after them are not where pass 1 put
+them, and the code would jump to the wrong addresses.
+
+    IF PASS() == 2
+            NOP
+    ENDIF
+    next:                   ; E0110: at 0x0001 in pass 2 but 0x0000 in pass 1
+
+Fix: give both branches the same size, or use PASS() only around directives
+that place no code.`},
+	CodeMissingEnd: {"Missing END directive", `The source does not end with END. MPASM requires it, and a missing END often
+means the file was truncated.
+
+Fix: add END as the last line, or choose how this is reported with
+-missing-end warning|error|off.`},
+	CodeCodeAfterEnd: {"Code after END", `Lines after END are ignored, so any instruction or directive there is lost.
+
+Fix: move the code above END, or delete it.`},
+	CodeUnhandledLine: {"Unhandled line", `The line is not a label, instruction, directive or macro invocation the
+assembler knows, so it is skipped.
+
+    ORGG 0x10               ; W0103 (typo for ORG)
+
+Fix: check the spelling of the directive, or define the macro before use.`},
+	CodeDelayRounded: {"Delay rounded to whole cycles", `DELAY_US converts microseconds to instruction cycles (four oscillator clocks
+each); when that is not a whole number the delay is rounded and will be
+slightly shorter or longer than requested.
+
+    DELAY_US 3, 1000000     ; W0104: 0.75 cycles rounded to 1
+
+Fix: none needed if the error is acceptable; otherwise request a delay that
+is a multiple of the cycle time, or use DELAY_CYCLES.`},
+	CodeMPASMCompat: {"MPASM compatibility", `With -compat mpasm the source is read the way MPASM reads it, and constructs
+MPASM would reject or read differently are reported: opcodes in column 1 and
+labels after it, asm4PIC extensions (JUMPTABLE, DELAY_US, SELFWRITE,
+__VERSIONSTR, __DATE__, ...), relocatable sections, the $ and 0b prefixes,
+numbers that are not valid in the current radix and a LIST P= or processor
+include for another device.
+
+    RADIX DEC
+    MOVLW 0b1010            ; fine in DEC, but MPASM's default HEX radix reads it as 0xB1010
+
+Fix: rewrite the construct in a form both assemblers read the same way.`},
+	CodePassRewrite: {"Code rewriting left out for IF on PASS()", `The optimizer, -auto-banksel and -auto-pagesel rewrite the code before the
+assembler passes, but which branch of an IF on PASS() is assembled is only
+decided in the passes. With such a block in the source they leave the code as
+written.
+
+Fix: select banks and pages by hand in the code around the block, or move the
+PASS() test out of the build that needs the rewriting.`},
+	CodeUndefinedSymbol: {"Undefined symbol", `An operand or expression names a symbol that is not a label, an EQU symbol
+or an SFR of the device.
+
+    MOVWF PORTX             ; E0201 on a device without PORTX
+
+Fix: check the spelling and the target device, or define the symbol with EQU.`},
+	CodeInvalidExpression: {"Invalid expression", `An expression could not be evaluated: an operator is missing an operand,
+parentheses do not match, a number is malformed, a symbol is undefined or it
+divides by zero.
+
+    X EQU (BASE + 2         ; E0202: missing ')'
+
+Fix: correct the expression; see the Expressions section of the README.`},
+	CodeEquWithoutLabel: {"EQU without a label", `EQU defines a symbol, so it must be preceded by the symbol name.
+
+    EQU 0x20                ; E0203
+    COUNT EQU 0x20          ; ok`},
+	CodeSymbolRedefined: {"Symbol redefined", `An EQU symbol is defined again with a different value. Repeating the same
+value is allowed.
+
+    COUNT EQU 0x20
+    COUNT EQU 0x21          ; E0204
+
+Fix: rename one of the symbols, or remove the duplicate definition.`},
+	CodeSFRShadowed: {"EQU shadows an SFR", `An EQU gives a special function register name a different address than the
+device config, so code using the name would silently access the wrong register.
+
+    STATUS EQU 0x20         ; E0205: STATUS is at 0x03
+
+Fix: pick another name for the variable.`},
+	CodeDuplicateLabel: {"Duplicate label", `The same label is defined twice.
+
+Fix: rename one of them. Labels inside macros are renamed per invocation
+automatically, so this usually means two top-level labels collide.`},
+	CodeUnsettledSymbols: {"Symbol values did not settle", `EQU and ORG values depending on labels are resolved by repeating the layout,
+but the values kept changing. This happens with circular definitions such as
+an ORG whose address depends on a label placed after it.
+
+Fix: break the circular dependency between the EQU/ORG values and the labels.`},
+	CodeEquOverlap: {"EQU variables share a register", `Several EQU symbols used as file register operands have the same address, or
+one has the address of an SFR, so the variables overwrite each other. This is
+usually a copied allocation line whose address was not updated.
+
+    COUNT   EQU 0x20
+    TEMP    EQU 0x20            ; W0208 once both are used as registers
+
+EQUs whose value is another name (LED EQU PORTA) are aliases and are not
+reported. Fix: give each variable its own address.`},
+	CodeUnknownFuse: {"Unknown fuse setting", `A __CONFIG setting is not one of the fuse names of the device config, so it
+is ignored and the affected bits keep their default values.
+
+    __CONFIG _FOSC_INTOSCIO & _LVP_OFF    ; W0305 if the device has no LVP fuse
+
+Fix: check the setting against the device datasheet and the device config
+file; the fuse may be named differently (e.g. _WDT_OFF vs _WDTE_OFF).`},
+	CodeUnmappedConfigWord: {"Fuse in an unmapped config word", `The fuse setting belongs to a config word that has no address in the device
+config, so it cannot be written to the HEX file.
+
+Fix: add the config word to CONFIG_WORD_DEFAULTS in the device config.`},
+	CodeFuseConflict: {"Conflicting fuse settings", `A __CONFIG line selects two different options of the same fuse group, so
+only the last one would take effect.
+
+    __CONFIG _WDTE_ON & _FOSC_HS & _WDTE_OFF    ; E0307
+
+Fix: remove the option that is not wanted.`},
+	CodeUnknownConfigWord: {"Unknown config word", `The first operand of the two-argument __CONFIG form is neither the name of a
+config word of the device (CONFIG1 or _CONFIG1, ...) nor the address of one.
+
+    __CONFIG _CONFIG3, _WRT_OFF    ; E0308 on a device with two config words
+
+Fix: use a config word listed in CONFIG_WORD_DEFAULTS of the device config.`},
+	CodeConfigOverride: {"Raw config word overrides __CONFIG", `A word placed at a config word address with ORG and DW differs from the
+value the __CONFIG settings give that word. The raw word is written to the HEX
+file.
+
+    __CONFIG _WDTE_OFF
+    ORG 0x2007
+    DW 0x3FFF                      ; W0309: WDTE is on again
+
+Fix: set the fuses in one place, either with __CONFIG or with the raw word.`},
+	CodeIDLocationsUsed: {"User IDs already written", `-id-checksum stores the program checksum in the user ID locations, but the
+source already places words there with ORG and DW.
+
+Fix: remove the DW to the user IDs or build without -id-checksum.`},
+	CodeAmbiguousFuse: {"Fuse setting in several config words", `The setting is named in the fuse maps of more than one config word, so the
+one-argument __CONFIG form cannot tell which word it is meant for; the first
+word is used. With -strict-config this is an error.
+
+Fix: use the two-argument form, e.g. __CONFIG _CONFIG2, _WRT_OFF.`},
+	CodeProfileFuse: {"Unknown fuse setting in a build profile", `A setting in the "config" list of the build profile chosen with -profile is
+not one of the fuse names of the device config. Unlike a __CONFIG line, a
+profile is checked strictly, since it is meant to change the build.
+
+    "release": {"config": ["_CP_ONN"]}     ; E0312
+
+Fix: correct the setting in the project file's profile.`},
+	CodeUnknownInstruction: {"Unknown instruction or directive", `The opcode is not an instruction of the device, an alias, a pseudo-op, a
+macro or a directive.
+
+    MOVFW COUNT             ; E0401 unless the device defines the MOVFW pseudo-op
+
+Fix: check the spelling and the target device, define the macro, or add an
+alias with -aliases.`},
+	CodeOperandCount: {"Wrong number of operands", `The instruction takes a different number of operands.
+
+    BSF PORTA               ; E0402: expects 2 operand(s), got 1
+    BSF PORTA, 0            ; ok`},
+	CodeInvalidDestination: {"Invalid destination", `The destination operand of a byte-oriented file register instruction must be
+W (0) or F (1).
+
+    INCF COUNT, X           ; E0403
+    INCF COUNT, F           ; ok`},
+	CodeInvalidOperand: {"Invalid operand", `An operand could not be evaluated or does not fit its field, e.g. an
+undefined symbol or a literal wider than the instruction allows.
+
+Fix: correct the operand; HIGH and LOW select the bytes of wider values.`},
+	CodeEncodingFailed: {"Internal encoding error", `The instruction pattern of the device config produced a machine word of the
+wrong width. This is a bug in the device config, not in the source.
+
+Fix: check the opcode_pattern of the instruction in the device config.`},
+	CodeAmbiguousBank: {"Banked register with an unknown BSR", `On PIC18 devices a file register operand outside the access bank is reached
+through the bank in the BSR. When the access bank operand is left out, the
+assembler picks BANKED for such registers and warns if the BSR is not known
+to select their bank: after a label, a call or a skipped MOVLB, or when a
+different bank is selected.
+
+COUNT   EQU 0x120
+LOOP:
+    INCF COUNT, F           ; W0406: the BSR is not known after the label
+    MOVLB 1
+    INCF COUNT, F           ; ok
+    INCF COUNT, F, BANKED   ; ok: the operand confirms the BSR is set
+
+Fix: select the bank with MOVLB, or give the operand as BANKED (or ACCESS).`},
+	CodeBankselInserted: {"Bank selection inserted", `With -auto-banksel the assembler follows the selected bank along the control
+flow and inserts BSF/BCF STATUS, RP0/RP1 (MOVLB on devices that have it) before
+each register access whose bank is not known to be selected. Each insertion is
+reported and shown in the listing, since it makes the code larger.
+
+    MOVWF TRISA             ; I0407: BSF STATUS, 5 inserted before it
+
+Fix: nothing needs fixing; select the bank yourself to control where it happens.`},
+	CodeBankAfterSkip: {"Bank selection needed after a skip", `An instruction skipped by BTFSS, BTFSC, DECFSZ or INCFSZ needs a bank that is
+not selected, and -auto-banksel cannot insert code between the skip and it.
+The selection is moved before the skip when the skip can run in that bank, so
+this only happens when the skip itself uses a register of another bank.
+
+    BTFSC FLAGS, 0          ; FLAGS in bank 0
+    MOVWF TRISA             ; W0408: TRISA is in bank 1
+
+Fix: copy the flag to a register in common RAM, or select the bank and test the
+flag through a bank-independent register.`},
+	CodeOrgOutOfRange: {"ORG address out of range", `The ORG address is beyond the program memory of the device.
+
+Fix: use an address below the program memory size of the device.`},
+	CodeAddressOverlap: {"Address written twice", `Two pieces of code are placed at the same program address, usually because
+an ORG points into code placed by an earlier ORG.
+
+    ORG 0x00
+    GOTO MAIN
+    NOP
+    ORG 0x01                ; E0502: 0x0001 is already used
+
+Fix: move one of the ORG addresses so the regions do not overlap.`},
+	CodeAddressOutOfBounds: {"Program address out of bounds", `Machine code was generated for an address outside the memory of the device
+and was left out of the HEX file.
+
+Fix: check the device config sizes, or keep the code below the end of memory.`},
+	CodeTablePageCrossing: {"Computed jump table crosses a page", `ADDWF PCL, F only changes the low byte of the program counter, so a jump
+table entered that way must lie in one 256-word page. An entry past the page
+boundary is never reached: the jump wraps to the start of the page instead.
+
+    ORG 0x00FD
+    ADDWF PCL, F            ; E0504: the table runs from 0x00FE to 0x0101
+    RETLW 0
+    RETLW 1
+    RETLW 2
+    RETLW 3
+
+Fix: move the table with ORG so it fits in one page.`},
+	CodePCLATHNotSet: {"PCLATH not set for a jump table", `The high bits of the program counter come from PCLATH when ADDWF PCL, F
+jumps. PCLATH is 0 after reset, which only selects the first page, so a table
+elsewhere needs PCLATH loaded on the way to the jump: in the code before it, back
+to the previous jump, call or return, or before every GOTO and CALL to the label
+that code starts at. A write in another routine is not counted.
+
+Fix: load the page before the jump:
+
+    MOVLW HIGH(TABLE)
+    MOVWF PCLATH`},
+	CodeSelfWriteAlignment: {"Self-write region not aligned to flash rows", `Flash program memory is erased a whole row (FLASH_ROW_SIZE words) at a time.
+A SELFWRITE region that starts or ends inside a row shares it with other
+code, which is erased too when the program rewrites the region.
+
+    ORG 0x0704
+    SELFWRITE               ; W0506: the row starts at 0x0700
+    RETLW 0
+    ENDSELFWRITE
+
+Fix: place the region at a row boundary with ORG and pad it to whole rows.`},
+	CodeSelfWriteNesting: {"Unbalanced SELFWRITE", `Every SELFWRITE must be closed by ENDSELFWRITE before the next one opens.
+
+Fix: add the missing SELFWRITE or ENDSELFWRITE.`},
+	CodePageselInserted: {"Page selection inserted", `With -auto-pagesel the assembler follows the page PCLATH selects along the
+control flow and inserts BSF/BCF PCLATH, 3/4 before each GOTO and CALL whose
+target is in a page that is not known to be selected, or before the skip in
+front of it. Each insertion is reported and shown in the listing, since it
+makes the code larger.
+
+    ORG 0x0010
+    CALL FAR_SUB            ; I0508: BSF PCLATH, 3; BCF PCLATH, 4 inserted before it
+    ORG 0x0800
+FAR_SUB:
+
+Fix: nothing needs fixing; load PCLATH yourself to control where it happens.`},
+	CodePageAtVector: {"Page selection needed at the interrupt vector", `A GOTO or CALL at the interrupt vector jumps out of page 0, and -auto-pagesel
+cannot select the page there: PCLATH belongs to the interrupted code until the
+interrupt routine has saved it.
+
+    ORG 0x0004
+    GOTO ISR                ; W0509: ISR is in page 1
+
+Fix: place the interrupt routine in page 0, or save PCLATH at the vector before
+selecting the page.`},
+	CodeDebugReserved: {"Program memory reserved for the debugger", `A -debug build reserves the top of program memory for the debug executive of the
+in-circuit debugger, as given by the ICD entry of the device config (the last
+256 words on the PIC16F886). Code placed there would be overwritten when the
+debugger is programmed.
+
+    ORG 0x1F80
+    RETLW 0                 ; E0510: 0x1F00-0x1FFF is reserved
+
+Fix: move the code below the reserved area, or build without -debug.`},
+	CodeHexRecord: {"Invalid HEX record", `A HEX file being read is not valid Intel HEX: a record does not start with
+':', has bad hex digits, a wrong length or checksum, or an unsupported type.
+
+Fix: regenerate the HEX file, or read it back from the device again.`},
+	CodeOptimization: {"Optimization applied", `An optimization pass changed the code; the message names the pass and what
+it did. These messages only appear with -O or -enable-passes.
+
+Fix: none needed. Skip a pass with -disable-passes if its change is unwanted.`},
+	CodeNoSharedRAM: {"No RAM layout for the data section", `UDATA_SHR allocates registers in common RAM (reachable from every bank), but
+the device config does not describe any: it has no RAM_LAYOUT with a shared
+range. UDATA_OVR likewise needs the gpr ranges of RAM_LAYOUT.
+
+Fix: add RAM_LAYOUT to the device config, or place the variables with EQU.`},
+	CodeRAMOverflow: {"Data section overflows its RAM", `The RES directives of a data section need more registers than its memory
+range has left, or the section address is outside that range.
+
+    UDATA_SHR
+    BUFFER RES 20           ; E0802: common RAM is 16 bytes
+
+Fix: reserve fewer registers, or move some variables to banked RAM.`},
+	CodeResOutsideSection: {"RES outside a data section", `RES reserves registers in the current data section, so it must follow a
+section directive such as UDATA_SHR.
+
+    FLAGS RES 1             ; E0803
+    UDATA_SHR
+    FLAGS RES 1             ; ok`},
+	CodeOverlayName: {"Overlay section without a name", `UDATA_OVR sections share addresses with the other sections of the same name,
+so the name is required.
+
+    UDATA_OVR               ; E0804
+    TEMPS UDATA_OVR         ; ok`},
+	CodeOverlayCollision: {"Overlay sections live at the same time", `Two sections of the same overlay share their addresses, so the routines using
+them must never run nested. Here a routine that uses one section calls code
+that uses another one, which overwrites its variables.
+
+    SORT_TMP  UDATA_OVR
+    I         RES 1         ; used by SORT
+    SORT_TMP  UDATA_OVR
+    N         RES 1         ; used by PRINT
+    ...
+    SORT: ... CALL PRINT    ; E0805: PRINT overwrites I
+
+Fix: give one of the sections a different overlay name, or stop the call.`},
+	CodeDebugRAM: {"Register reserved for the debugger", `A -debug build reserves the registers the in-circuit debugger uses, as given by
+the ICD entry of the device config (0x70 and 0x1EB-0x1EF on the PIC16F886). A
+data section or EQU variable used as a file register takes one of them.
+
+    FLAGS EQU 0x70          ; E0806 once FLAGS is used
+    BSF FLAGS, 0
+
+Fix: move the variable to another register, or build without -debug.`},
+}
+
+// errorCode returns the diagnostic code of err if it is an assembler error that has one,
+// otherwise fallback.
+func errorCode(err error, fallback string) string {
+	var asmErr *AssemblerError
+	if errors.As(err, &asmErr) && asmErr.Code != "" {
+		return asmErr.Code
+	}
+	return fallback
+}
+
+// codeSuffix formats a diagnostic code for the end of a message, or "" when there is none.
+func codeSuffix(code string) string {
+	if code == "" {
+		return ""
+	}
+	return " [" + code + "]"
+}
+
+// explainCode returns the extended description of a diagnostic code.
+func explainCode(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	info, ok := diagnosticCatalog[code]
+	if !ok {
+		codes := make([]string, 0, len(diagnosticCatalog))
+		for known := range diagnosticCatalog {
+			codes = append(codes, known)
+		}
+		sort.Strings(codes)
+		return "", fmt.Errorf("unknown diagnostic code '%s' (known: %s)", code, strings.Join(codes, ", "))
+	}
+	return fmt.Sprintf("%s: %s\n\n%s\n", code, info.Summary, info.Explanation), nil
+}
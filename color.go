@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// --- Colored Diagnostics ---
+
+// Color modes accepted by -color.
+const (
+	ColorAuto   = "auto"
+	ColorAlways = "always"
+	ColorNever  = "never"
+)
+
+// ANSI escape sequences used for diagnostics.
+const (
+	ansiReset   = "\x1b[0m"
+	ansiBold    = "\x1b[1m"
+	ansiRed     = "\x1b[1;31m"
+	ansiMagenta = "\x1b[1;35m"
+	ansiCyan    = "\x1b[1;36m"
+	ansiGreen   = "\x1b[1;32m"
+)
+
+// isTerminal reports whether f is an interactive terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// useColor reports whether diagnostics written to f are colored: always, never, or in auto
+// mode when f is a terminal and NO_COLOR is not set.
+func (p *DiagnosticPrinter) useColor(f *os.File) bool {
+	switch p.Color {
+	case ColorAlways:
+		return true
+	case "", ColorNever:
+		return false
+	}
+	return os.Getenv("NO_COLOR") == "" && isTerminal(f)
+}
+
+// severityColor returns the escape sequence for a severity.
+func severityColor(severity string) string {
+	switch severity {
+	case SeverityError:
+		return ansiRed
+	case SeverityWarning:
+		return ansiMagenta
+	}
+	return ansiCyan
+}
+
+// writeColored writes a diagnostic with a colored severity, followed by the offending
+// source line with a bold caret under its code.
+func (p *DiagnosticPrinter) writeColored(w io.Writer, d Diagnostic) {
+	color := severityColor(d.Severity)
+	file, line := p.location(d)
+	switch {
+	case p.Format == MsgFormatGCC && line > 0:
+		fmt.Fprintf(w, "%s%s:%d:%s %s%s:%s %s%s\n", ansiBold, file, line, ansiReset, color, d.Severity, ansiReset, d.Message, codeSuffix(d.Code))
+	case p.Format == MsgFormatGCC:
+		fmt.Fprintf(w, "%s%s:%s %s%s:%s %s%s\n", ansiBold, p.File, ansiReset, color, d.Severity, ansiReset, d.Message, codeSuffix(d.Code))
+	case d.Line > 0:
+		fmt.Fprintf(w, "%s%s:%s %s%s:%s %s%s\n", color, strings.ToUpper(translate(d.Severity)), ansiReset, ansiBold, tr("Line %d", d.Line), ansiReset, d.Message, codeSuffix(d.Code))
+	default:
+		fmt.Fprintf(w, "%s%s:%s %s%s\n", color, strings.ToUpper(translate(d.Severity)), ansiReset, d.Message, codeSuffix(d.Code))
+	}
+
+	// Source only holds the main file, so a line of an include file is not shown
+	lines := strings.Split(p.Source, "\n")
+	if d.Line < 1 || d.Line > len(lines) || p.Format == MsgFormatGCC && file != p.File {
+		return
+	}
+	text := strings.TrimRight(lines[d.Line-1], "\r")
+	code, _, _ := strings.Cut(text, ";")
+	code = strings.TrimRight(code, " \t")
+	indent := len(code) - len(strings.TrimLeft(code, " \t"))
+	if indent == len(code) {
+		return
+	}
+	gutter := fmt.Sprintf("%5d | ", d.Line)
+	fmt.Fprintf(w, "%s%s\n", gutter, text)
+	fmt.Fprintf(w, "%s%s%s^%s%s\n", strings.Repeat(" ", len(gutter)-2)+"| ", code[:indent], ansiGreen, strings.Repeat("~", len(code)-indent-1), ansiReset)
+}
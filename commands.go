@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+// --- Subcommands ---
+
+// subcommand describes a named asm4PIC mode invoked as `asm4pic <name> [flags]`.
+type subcommand struct {
+	Summary string
+	Run     func(args []string) error
+}
+
+// subcommands holds every registered subcommand, keyed by name.
+var subcommands = make(map[string]subcommand)
+
+// registerSubcommand makes a subcommand available from the command line.
+func registerSubcommand(name, summary string, run func(args []string) error) {
+	subcommands[name] = subcommand{Summary: summary, Run: run}
+}
+
+// printSubcommands lists the registered subcommands, sorted by name.
+func printSubcommands() {
+	names := make([]string, 0, len(subcommands))
+	for name := range subcommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(os.Stderr, "\nSubcommands:")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", name, subcommands[name].Summary)
+	}
+}
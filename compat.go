@@ -0,0 +1,314 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// --- MPASM Compatibility ---
+
+// CompatMPASM is the -compat value that reads sources the way MPASM does.
+const CompatMPASM = "mpasm"
+
+// mpasmCompat is the state of the MPASM compatibility mode while parsing: the radix bare
+// numbers are read in (hexadecimal unless RADIX or LIST R= changes it) and the device, to
+// tell labels in column 1 from opcodes and to check LIST P= and processor includes.
+type mpasmCompat struct {
+	radix   int
+	device  string // upper-case device name, e.g. PIC16F886
+	opcodes map[string]bool
+}
+
+func newMPASMCompat(mcConfig *MicrocontrollerConfig) *mpasmCompat {
+	c := &mpasmCompat{radix: 16, device: mcConfig.Name, opcodes: make(map[string]bool)}
+	for name := range mcConfig.InstructionSet {
+		c.opcodes[strings.ToUpper(name)] = true
+	}
+	return c
+}
+
+var (
+	mpasmIncludeRegex   = regexp.MustCompile(`(?i)^(\s*)#?INCLUDE(\s+)(.*)$`)
+	deviceIncludeRegex  = regexp.MustCompile(`(?i)^[<"]P(\d+[A-Z]+\d+[A-Z]*)\.INC[>"]$`)
+	radixNames          = map[string]int{"HEX": 16, "DEC": 10, "OCT": 8}
+	radixPrefixes       = map[string]int{"H": 16, "D": 10, "B": 2, "O": 8, "A": 0} // A'c' is a character
+	mpasmOnlyDirectives = map[string]bool{"CBLOCK": true, "ENDC": true, "IFDEF": true, "IFNDEF": true, "WHILE": true, "ENDW": true, "LOCAL": true, "VARIABLE": true, "SET": true, "FILL": true, "DATA": true, "MESSG": true, "ERROR": true, "BANKISEL": true}
+	asm4picDirectives   = map[string]bool{"SELFWRITE": true, "ENDSELFWRITE": true, "__VERSIONSTR": true, "DTABLE": true, "INCBIN": true}
+	relocatableSections = map[string]bool{"UDATA_SHR": true, "UDATA_OVR": true}
+)
+
+// preprocess rewrites the top-level source before includes are expanded: INCLUDE without
+// '#' gets one, and the processor include (p16f886.inc) is dropped, since the SFR and fuse
+// names come from the device config. Lines are kept so line numbers do not change.
+func (c *mpasmCompat) preprocess(source string) (string, []Diagnostic) {
+	var diagnostics []Diagnostic
+	lines := strings.Split(source, "\n")
+	for i, line := range lines {
+		match := mpasmIncludeRegex.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		target := strings.TrimSpace(match[3])
+		if comment := strings.Index(target, ";"); comment >= 0 {
+			target = strings.TrimSpace(target[:comment])
+		}
+		if m := deviceIncludeRegex.FindStringSubmatch(target); m != nil {
+			if device := "PIC" + strings.ToUpper(m[1]); c.device != "" && device != c.device {
+				diagnostics = append(diagnostics, Diagnostic{Severity: SeverityWarning, Line: i + 1, Code: CodeMPASMCompat,
+					Message: tr("Processor include %s is for %s, but the target is %s.", target, device, c.device)})
+			}
+			lines[i] = "; " + line
+			continue
+		}
+		lines[i] = match[1] + "#INCLUDE" + match[2] + match[3]
+	}
+	return strings.Join(lines, "\n"), diagnostics
+}
+
+// known reports whether a name is something the parser handles other than a label.
+func (p *ASMParser) known(name string) bool {
+	upper := strings.ToUpper(name)
+	if directiveKeywords[upper] || p.compat.opcodes[upper] || builtinMacros[upper] != nil || mpasmOnlyDirectives[upper] {
+		return true
+	}
+	if _, ok := p.parsedData.Macros[name]; ok {
+		return true
+	}
+	if _, ok := p.parsedData.Defines[name]; ok {
+		return true
+	}
+	_, alias := p.aliases[upper]
+	_, pseudoOp := p.pseudoOps[upper]
+	_, plugin := p.plugins[upper]
+	return alias || pseudoOp || plugin || upper == "LIST" || upper == "PROCESSOR" || upper == "RADIX" || upper == "ERRORLEVEL"
+}
+
+// compatLines rewrites one MPASM source line into the lines asm4PIC parses. A name in
+// column 1 is a label, with or without a colon, and is split from the instruction after it;
+// numbers are converted from MPASM's radix forms; LIST, PROCESSOR, RADIX and ERRORLEVEL are
+// applied and removed, except that LIST stays to turn the listing back on after NOLIST.
+// Constructs MPASM would reject or read differently are reported.
+func (p *ASMParser) compatLines(text string) []string {
+	content, comment := p.extractLineContentAndComment(text)
+	if content == "" {
+		return []string{text}
+	}
+	fields := strings.Fields(content)
+	first := strings.ToUpper(fields[0])
+	switch first {
+	case "LIST", "PROCESSOR", "RADIX", "ERRORLEVEL":
+		p.compatDirective(first, strings.TrimSpace(content[len(fields[0]):]))
+		if first == "LIST" {
+			return []string{strings.TrimSpace("LIST " + comment)} // also turns the listing back on
+		}
+		return []string{comment}
+	}
+
+	label, statement := "", content
+	inColumn1 := text[0] != ' ' && text[0] != '\t'
+	name := strings.TrimSuffix(fields[0], ":")
+	switch {
+	case inColumn1 && p.compat.opcodes[first]:
+		p.warn(CodeMPASMCompat, "Found opcode in column 1 (%s); MPASM expects labels there.", fields[0])
+	case !inColumn1 && strings.HasSuffix(fields[0], ":"):
+		label, statement = name, strings.TrimSpace(content[len(fields[0]):])
+	case !p.known(name) && macroParamRegex.MatchString(name):
+		if !inColumn1 {
+			p.warn(CodeMPASMCompat, "Found label after column 1 (%s).", name)
+		}
+		rest := strings.TrimSpace(content[len(fields[0]):])
+		if len(fields) > 1 && namingDirectiveKeywords[strings.ToUpper(fields[1])] {
+			break // NAME EQU value, NAME MACRO, NAME RES count, ...
+		}
+		label, statement = name, rest
+	}
+
+	if statement != "" {
+		op := strings.ToUpper(strings.Fields(statement)[0])
+		p.flagExtensions(op, statement)
+		keepStrings := op == "DT" || op == "DA" || op == "DW" || op == "DB" || op == "DE" || op == "TITLE" || op == "SUBTITL" || op == "SUBTITLE" || op == "#INCLUDE"
+		statement = p.convertNumbers(statement, keepStrings)
+	}
+	if label == "" {
+		return []string{strings.TrimSpace(statement + " " + comment)}
+	}
+	if statement == "" {
+		return []string{strings.TrimSpace(label + ": " + comment)}
+	}
+	return []string{label + ":", strings.TrimRight("    "+statement+" "+comment, " ")}
+}
+
+// compatDirective applies an MPASM directive that only sets up the assembler.
+func (p *ASMParser) compatDirective(name, operands string) {
+	setRadix := func(value string) {
+		if radix, ok := radixNames[strings.ToUpper(value)]; ok {
+			p.compat.radix = radix
+		} else {
+			p.warn(CodeMPASMCompat, "Unknown radix '%s'; expected HEX, DEC or OCT.", value)
+		}
+	}
+	checkProcessor := func(value string) {
+		device := strings.ToUpper(value)
+		if !strings.HasPrefix(device, "PIC") {
+			device = "PIC" + device
+		}
+		if p.compat.device != "" && device != p.compat.device {
+			p.warn(CodeMPASMCompat, "%s selects %s, but the target is %s.", name, device, p.compat.device)
+		}
+	}
+	switch name {
+	case "RADIX":
+		setRadix(operands)
+	case "PROCESSOR":
+		checkProcessor(operands)
+	case "LIST":
+		for _, option := range strings.Split(operands, ",") {
+			key, value, ok := strings.Cut(strings.TrimSpace(option), "=")
+			if !ok {
+				continue
+			}
+			switch strings.ToUpper(strings.TrimSpace(key)) {
+			case "P":
+				checkProcessor(strings.TrimSpace(value))
+			case "R":
+				setRadix(strings.TrimSpace(value))
+			}
+		}
+	}
+}
+
+// predefinedNameRegex matches the symbols only asm4PIC predefines.
+var predefinedNameRegex = regexp.MustCompile(`\b(__DATE__|__TIME__|__MCU__|__ASM4PIC_VERSION__)\b`)
+
+// flagExtensions reports asm4PIC constructs that MPASM would reject.
+func (p *ASMParser) flagExtensions(op, statement string) {
+	switch {
+	case builtinMacros[op] != nil || asm4picDirectives[op]:
+		if _, user := p.parsedData.Macros[strings.Fields(statement)[0]]; !user {
+			p.warn(CodeMPASMCompat, "%s is an asm4PIC extension; MPASM would reject it.", op)
+		}
+	case relocatableSections[op]:
+		p.warn(CodeMPASMCompat, "%s needs MPASM's relocatable mode (an object file and a linker script).", op)
+	case mpasmOnlyDirectives[op]:
+		p.warn(CodeMPASMCompat, "MPASM directive %s is not supported by asm4PIC.", op)
+	}
+	if fields := strings.Fields(statement); len(fields) > 1 && relocatableSections[strings.ToUpper(fields[1])] {
+		p.warn(CodeMPASMCompat, "%s needs MPASM's relocatable mode (an object file and a linker script).", strings.ToUpper(fields[1]))
+	}
+	if m := predefinedNameRegex.FindString(statement); m != "" {
+		p.warn(CodeMPASMCompat, "%s is predefined by asm4PIC only; MPASM would report an undefined symbol.", m)
+	}
+}
+
+// convertNumbers rewrites MPASM numeric literals into asm4PIC ones: H'1F', D'10', B'1010',
+// O'17', A'c', .10, 1Fh and bare numbers in the current radix. Quoted strings are kept when
+// keepStrings is set; otherwise a quoted single character is its ASCII code, as in MPASM.
+func (p *ASMParser) convertNumbers(s string, keepStrings bool) string {
+	var out strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\'':
+			end := quotedEnd(s, i)
+			if text, err := unquoteData(s[i:end]); err == nil && c == '\'' && !keepStrings && len(text) == 1 {
+				out.WriteString(strconv.Itoa(int(text[0])))
+			} else {
+				out.WriteString(s[i:end])
+			}
+			i = end
+		case c == '_' || (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z'):
+			start := i
+			for i < len(s) && isIdentifierChar(s[i]) {
+				i++
+			}
+			word := s[start:i]
+			base, isRadix := radixPrefixes[strings.ToUpper(word)]
+			if !isRadix || i >= len(s) || s[i] != '\'' {
+				out.WriteString(word)
+				continue
+			}
+			end := quotedEnd(s, i)
+			body := strings.TrimSuffix(s[i+1:end], "'")
+			i = end
+			if base == 0 {
+				if text, err := unquoteData("'" + body + "'"); err == nil && len(text) == 1 {
+					out.WriteString(strconv.Itoa(int(text[0])))
+					continue
+				}
+				p.warn(CodeMPASMCompat, "A'%s' must hold one character.", body)
+				out.WriteString(s[start:end])
+				continue
+			}
+			if v, err := strconv.ParseInt(body, base, 64); err == nil {
+				out.WriteString(strconv.FormatInt(v, 10))
+			} else {
+				p.warn(CodeMPASMCompat, "%s'%s' is not a valid number.", word, body)
+				out.WriteString(s[start:end])
+			}
+		case c == '.' && i+1 < len(s) && s[i+1] >= '0' && s[i+1] <= '9':
+			start := i + 1
+			for i++; i < len(s) && s[i] >= '0' && s[i] <= '9'; i++ {
+			}
+			digits := strings.TrimLeft(s[start:i], "0")
+			if digits == "" {
+				digits = "0"
+			}
+			out.WriteString(digits)
+		case c >= '0' && c <= '9':
+			start := i
+			for i < len(s) && isIdentifierChar(s[i]) && s[i] != '.' {
+				i++
+			}
+			out.WriteString(p.convertBareNumber(s[start:i]))
+		case c == '$':
+			p.warn(CodeMPASMCompat, "MPASM reads '$' as the current address; asm4PIC reads it as a hex prefix and has no current-address symbol.")
+			out.WriteByte(c)
+			i++
+		default:
+			out.WriteByte(c)
+			i++
+		}
+	}
+	return out.String()
+}
+
+// convertBareNumber converts a number token starting with a digit: 0x prefixed and h suffixed
+// numbers are hexadecimal, anything else is read in the current radix.
+func (p *ASMParser) convertBareNumber(token string) string {
+	upper := strings.ToUpper(token)
+	switch {
+	case strings.HasPrefix(upper, "0X"):
+		return token
+	case strings.HasSuffix(upper, "H"):
+		if _, err := strconv.ParseInt(upper[:len(upper)-1], 16, 64); err == nil {
+			return "0x" + token[:len(token)-1]
+		}
+	}
+	if strings.HasPrefix(upper, "0B") && p.compat.radix == 16 {
+		p.warn(CodeMPASMCompat, "MPASM reads %s as a hex number, not binary; write B'%s' for binary.", token, token[2:])
+	}
+	v, err := strconv.ParseInt(token, p.compat.radix, 64)
+	if err != nil {
+		p.warn(CodeMPASMCompat, "'%s' is not a valid number in radix %d.", token, p.compat.radix)
+		return token
+	}
+	if p.compat.radix == 16 {
+		return "0x" + token
+	}
+	return strconv.FormatInt(v, 10)
+}
+
+// quotedEnd returns the index just past the quoted string starting at s[start].
+func quotedEnd(s string, start int) int {
+	quote := s[start]
+	for k := start + 1; k < len(s); k++ {
+		switch s[k] {
+		case '\\':
+			k++
+		case quote:
+			return k + 1
+		}
+	}
+	return len(s)
+}
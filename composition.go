@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// --- Code Composition ---
+
+// macroUse is how often a macro, pseudo-op or built-in was invoked and the program words its
+// expansions take, nested invocations included.
+type macroUse struct {
+	name        string
+	invocations int
+	words       int
+}
+
+// codeComposition renders the Code Composition section of the report: how the program words
+// split between instructions and tables, how often each opcode occurs, and what each macro
+// costs. Tables are the data directives, __VERSIONSTR and the entries of computed jump
+// tables.
+func (a *PicAssembler) codeComposition() string {
+	tableEntries := make(map[int]bool) // address -> entry of a computed jump table
+	pcl, ok := a.mcConfig.SFRMap["PCL"]
+	if !ok {
+		pcl = pclRegister
+	}
+	for i, item := range a.parsedAssembly.Lines {
+		inst, ok := item.(*Instruction)
+		if _, placed := a.itemAddresses[i]; !ok || !placed || strings.ToUpper(inst.Opcode) != "ADDWF" {
+			continue
+		}
+		info := a.mcConfig.InstructionSet["ADDWF"]
+		operand, _ := operandOfKind(inst, info, "f")
+		if val, err := a.evaluateExpression(operand); err != nil || val&0x7F != pcl&0x7F || !writesFileRegister(inst, info) {
+			continue
+		}
+		last := a.jumpTableEnd(i)
+		for addr := a.itemAddresses[i] + 1; addr <= last; addr++ {
+			tableEntries[addr] = true
+		}
+	}
+
+	opcodes := make(map[string]int)
+	macros := make(map[string]*macroUse)
+	invocations := make(map[string]bool) // name and call chain of each invocation seen
+	instructionWords, tableWords := 0, 0
+	for i, item := range a.parsedAssembly.Lines {
+		addr, placed := a.itemAddresses[i]
+		if !placed || addr >= a.mcConfig.ProgramMemorySize {
+			continue
+		}
+		words := 0
+		switch v := item.(type) {
+		case *Instruction:
+			words = 1
+			opcodes[strings.ToUpper(v.Opcode)]++
+			if tableEntries[addr] {
+				tableWords++
+			} else {
+				instructionWords++
+			}
+		case *DataDirective:
+			words = v.wordCount()
+			tableWords += words
+		case *VersionString:
+			words = v.wordCount()
+			tableWords += words
+		}
+		if words == 0 {
+			continue
+		}
+		if origin := a.origin(i); origin != nil {
+			var key strings.Builder
+			for _, frame := range origin.Chain {
+				key.WriteString(fmt.Sprintf("%s@%s/", frame.Name, frame.Call))
+				use := macros[frame.Name]
+				if use == nil {
+					use = &macroUse{name: frame.Name}
+					macros[frame.Name] = use
+				}
+				if !invocations[key.String()] {
+					invocations[key.String()] = true
+					use.invocations++
+				}
+				use.words += words
+			}
+		}
+	}
+
+	var out strings.Builder
+	total := instructionWords + tableWords
+	if total == 0 {
+		out.WriteString(tr("  No program code.\n"))
+		return out.String()
+	}
+	percent := func(n int) float64 { return 100 * float64(n) / float64(total) }
+	out.WriteString(tr("  Program words: %d\n", total))
+	out.WriteString(tr("    Instructions  %5d  %5.1f%%\n", instructionWords, percent(instructionWords)))
+	out.WriteString(tr("    Tables        %5d  %5.1f%%\n", tableWords, percent(tableWords)))
+
+	names := make([]string, 0, len(opcodes))
+	for name := range opcodes {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if opcodes[names[i]] != opcodes[names[j]] {
+			return opcodes[names[i]] > opcodes[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	out.WriteString(tr("  Opcodes:\n"))
+	for _, name := range names {
+		out.WriteString(fmt.Sprintf("    %-8s %5d  %5.1f%%\n", name, opcodes[name], percent(opcodes[name])))
+	}
+
+	if len(macros) > 0 {
+		uses := make([]*macroUse, 0, len(macros))
+		for _, use := range macros {
+			uses = append(uses, use)
+		}
+		sort.Slice(uses, func(i, j int) bool {
+			if uses[i].words != uses[j].words {
+				return uses[i].words > uses[j].words
+			}
+			return uses[i].name < uses[j].name
+		})
+		out.WriteString(tr("  Macros, pseudo-ops and built-ins (invocations, words):\n"))
+		for _, use := range uses {
+			out.WriteString(fmt.Sprintf("    %-20s %5d  %5d  %5.1f%%\n", use.name, use.invocations, use.words, percent(use.words)))
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"maps"
+	"regexp"
+	"strings"
+)
+
+// --- Conditional Assembly ---
+
+// IF expr ... [ELSE ...] ENDIF assembles the lines of one branch and drops the other. The
+// condition is evaluated when the IF line is read, from the #DEFINEs (-D included), EQU
+// symbols and predefined symbols above it, and is true when it is not zero. Blocks nest; in a
+// macro body they are evaluated for each invocation, after its arguments are substituted.
+// A condition that calls PASS() is left to the assembler passes, which evaluate it on each
+// pass with the whole symbol table.
+var (
+	ifRegex          = regexp.MustCompile(`(?i)^IF(?:\s+(.*))?$`)
+	elseOrEndifRegex = regexp.MustCompile(`(?i)^(ELSE|ENDIF)$`)
+	passCallRegex    = regexp.MustCompile(`(?i)\bPASS\s*\(`)
+)
+
+func init() {
+	directiveKeywords["IF"] = true
+	directiveKeywords["ELSE"] = true
+	directiveKeywords["ENDIF"] = true
+}
+
+// PassConditional is an IF, ELSE or ENDIF line of a block whose condition calls PASS(). The
+// parser keeps both branches, and the assembler passes choose one on each pass.
+type PassConditional struct {
+	Text string // the line without its comment
+}
+
+func (c *PassConditional) isAssemblyItem() {}
+
+// conditionalBlock is an IF block being read.
+type conditionalBlock struct {
+	at       sourceText // the IF line
+	active   bool       // the lines of the current branch are assembled
+	taken    bool       // a branch of the block is or was assembled, so ELSE is not
+	inElse   bool
+	deferred bool // the condition calls PASS(): both branches are kept for the passes
+}
+
+// conditionals tracks the IF blocks open while a source or a macro body is read. chain is the
+// macro invocation the body belongs to, for locating errors. deferPass leaves the blocks on
+// PASS() to the assembler passes, which read them with it unset.
+type conditionals struct {
+	blocks    []conditionalBlock
+	chain     []MacroFrame
+	deferPass bool
+}
+
+// assembling reports whether lines at the current position are assembled.
+func (c *conditionals) assembling() bool {
+	return len(c.blocks) == 0 || c.blocks[len(c.blocks)-1].active
+}
+
+// errorAt returns an error located at line at.
+func (c *conditionals) errorAt(at sourceText, code, format string, args ...interface{}) *AssemblerError {
+	return &AssemblerError{Line: at.Line, Message: tr(format, args...), Code: code, Origin: &Provenance{Location: at.Origin, Chain: c.chain}}
+}
+
+// line handles an IF, ELSE or ENDIF line and a line in a branch that is not assembled,
+// reporting whether it did; other lines are left to the parser. condition evaluates the
+// expression of an IF. The lines of a deferred block are returned as a PassConditional for
+// the parser to keep.
+func (c *conditionals) line(content string, at sourceText, condition func(string) (bool, error)) (bool, *PassConditional, error) {
+	if match := ifRegex.FindStringSubmatch(content); match != nil {
+		block := conditionalBlock{at: at, taken: !c.assembling()}
+		if !block.taken {
+			expr := strings.TrimSpace(match[1])
+			if expr == "" {
+				return true, nil, c.errorAt(at, CodeConditional, "IF expects a condition.")
+			}
+			if c.deferPass && passCallRegex.MatchString(expr) {
+				block.active, block.deferred = true, true
+				c.blocks = append(c.blocks, block)
+				return true, &PassConditional{Text: content}, nil
+			}
+			value, err := condition(expr)
+			if err != nil {
+				// The block is still opened, so its ELSE and ENDIF match it
+				c.blocks = append(c.blocks, conditionalBlock{at: at, taken: true})
+				return true, nil, c.errorAt(at, CodeConditionValue, "IF condition '%s' is not a constant known at this line: %v", expr, err)
+			}
+			block.active, block.taken = value, value
+		}
+		c.blocks = append(c.blocks, block)
+		return true, nil, nil
+	}
+	if match := elseOrEndifRegex.FindStringSubmatch(content); match != nil {
+		directive := strings.ToUpper(match[1])
+		if len(c.blocks) == 0 {
+			return true, nil, c.errorAt(at, CodeConditional, "%s without IF.", directive)
+		}
+		block := &c.blocks[len(c.blocks)-1]
+		deferred := block.deferred
+		switch {
+		case directive == "ENDIF":
+			c.blocks = c.blocks[:len(c.blocks)-1]
+		case block.inElse:
+			return true, nil, c.errorAt(at, CodeConditional, "Second ELSE in the IF block opened at line %d.", block.at.Line)
+		case deferred:
+			block.inElse = true
+		default:
+			block.inElse, block.active = true, !block.taken
+		}
+		if deferred {
+			return true, &PassConditional{Text: content}, nil
+		}
+		return true, nil, nil
+	}
+	return !c.assembling(), nil, nil
+}
+
+// close reports an IF block left open at the end of a source or macro body.
+func (c *conditionals) close() error {
+	if len(c.blocks) > 0 {
+		return c.errorAt(c.blocks[len(c.blocks)-1].at, CodeConditional, "IF is never closed with ENDIF.")
+	}
+	return nil
+}
+
+// conditionTrue evaluates the condition of an IF from the #DEFINEs and EQU symbols read so
+// far and the predefined symbols. A #DEFINE takes precedence over an EQU of the same name,
+// as it replaces the name before the EQU is looked at.
+func (p *ASMParser) conditionTrue(condition string) (bool, error) {
+	symbols := maps.Clone(p.parsedData.Symbols)
+	for name, value := range p.parsedData.Defines {
+		if value != "" {
+			symbols[name] = value
+		}
+	}
+	lookup := symbolLookup(symbols, lookupPredefined(p.predefined))
+	value, err := evalScopedExpression(condition, lookup, p.exprScope(lookup))
+	return value != 0, err
+}
+
+// hasPassConditionals reports whether the expanded code has IF blocks on PASS().
+func hasPassConditionals(expanded *ExpandedParsedAssembly) bool {
+	for _, item := range expanded.Lines {
+		if _, ok := item.(*PassConditional); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// passConditions follows the IF blocks on PASS() while a pass walks the code, evaluating
+// their conditions with the symbols of the pass.
+type passConditions struct {
+	conditionals
+	seen bool // a block on PASS() was passed, which may have moved the code after it
+}
+
+// assembled reports whether the pass assembles the item at i; the IF, ELSE and ENDIF lines of
+// the blocks on PASS() are never assembled themselves.
+func (c *passConditions) assembled(a *PicAssembler, i int) (bool, error) {
+	v, ok := a.parsedAssembly.Lines[i].(*PassConditional)
+	if !ok {
+		return c.assembling(), nil
+	}
+	c.seen = true
+	at := sourceText{Text: v.Text, Line: a.sourceLine(i)}
+	if i < len(a.parsedAssembly.Origins) && a.parsedAssembly.Origins[i] != nil {
+		at.Origin, c.chain = a.parsedAssembly.Origins[i].Location, a.parsedAssembly.Origins[i].Chain
+	}
+	_, _, err := c.line(v.Text, at, func(expr string) (bool, error) {
+		value, err := a.evaluateExpression(expr)
+		return value != 0, err
+	})
+	return false, err
+}
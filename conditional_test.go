@@ -0,0 +1,72 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIncludeInFalseBranchIsNotOpened(t *testing.T) {
+	source := "        IF 0\n#INCLUDE \"missing.inc\"\n        ELSE\n        ORG 0\n        MOVLW 1\n        ENDIF\n        END\n"
+	assembler, _, err := assembleSource(source, testConfig(t), &AssemblyOptions{SourceDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("include in a false branch was opened: %v", err)
+	}
+	if assembler.machineCodeWords[0] != 0x3001 {
+		t.Errorf("word 0 = %#04x, want MOVLW 1", assembler.machineCodeWords[0])
+	}
+
+	source = strings.Replace(source, "IF 0", "IF 1", 1)
+	if _, _, err := assembleSource(source, testConfig(t), &AssemblyOptions{SourceDir: t.TempDir()}); err == nil || errorDiagnostic(err).Code != CodeIncludeFailed {
+		t.Errorf("include in a true branch: got %v, want %s", err, CodeIncludeFailed)
+	}
+}
+
+func TestIfOnPassIsEvaluatedByThePasses(t *testing.T) {
+	for name, test := range map[string]struct {
+		source string
+		words  []int
+	}{
+		"pass": {"        ORG 0\n        IF PASS() == 1\n        MOVLW 1\n        ELSE\n        MOVLW 2\n        ENDIF\n" +
+			"        GOTO NEXT\nNEXT:\n        NOP\n        END\n", []int{0x3002, 0x2802, 0x0000}},
+		"label": {"        ORG 0\n        GOTO NEXT\nNEXT:\n        IF PASS() == 2 && NEXT == 1\n        MOVLW 3\n        ELSE\n        MOVLW 4\n        ENDIF\n        END\n",
+			[]int{0x2801, 0x3003}},
+		"nested": {"        ORG 0\n        IF PASS() > 1\n        IF 0\n        MOVLW 5\n        ELSE\n        MOVLW 6\n        ENDIF\n        ELSE\n        MOVLW 7\n        ENDIF\n        END\n",
+			[]int{0x3006}},
+		"macro": {"PICK MACRO N\n        IF PASS() == 2\n        MOVLW N\n        ELSE\n        MOVLW 0\n        ENDIF\n        ENDM\n        ORG 0\n        PICK 8\n        END\n",
+			[]int{0x3008}},
+	} {
+		assembler, _, err := assembleSource(test.source, testConfig(t), nil)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		for addr, want := range test.words {
+			if got := assembler.machineCodeWords[addr]; got != want {
+				t.Errorf("%s: word %d = %#04x, want %#04x", name, addr, got, want)
+			}
+		}
+	}
+}
+
+func TestIfOnPassMustKeepTheLayout(t *testing.T) {
+	source := "        ORG 0\n        IF PASS() == 2\n        NOP\n        ENDIF\nNEXT:\n        GOTO NEXT\n        END\n"
+	_, _, err := assembleSource(source, testConfig(t), nil)
+	if err == nil || errorDiagnostic(err).Code != CodePassLayout {
+		t.Fatalf("got %v, want %s", err, CodePassLayout)
+	}
+}
+
+func TestIfOnPassLeavesCodeRewritingOut(t *testing.T) {
+	source := "        ORG 0\n        IF PASS() == 2\n        NOP\n        ELSE\n        NOP\n        ENDIF\n        END\n"
+	_, warnings, err := assembleSource(source, testConfig(t), &AssemblyOptions{Optimize: true, AutoBanksel: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, w := range warnings {
+		found = found || w.Code == CodePassRewrite
+	}
+	if !found {
+		t.Errorf("warnings %v, want %s", warnings, CodePassRewrite)
+	}
+}
@@ -0,0 +1,417 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --- Assembler Daemon ---
+
+func init() {
+	registerSubcommand("daemon", "Keep device configs and includes in memory and assemble requests from a local socket", runDaemon)
+}
+
+// Commands a daemon request can carry; an empty command assembles.
+const (
+	DaemonAssemble = "assemble"
+	DaemonStatus   = "status"
+	DaemonShutdown = "shutdown"
+)
+
+// daemonRequest is one JSON request read from a daemon connection. Relative paths are
+// resolved against Dir, or the daemon's working directory when it is empty.
+type daemonRequest struct {
+	ID      json.RawMessage `json:"id,omitempty"`
+	Command string          `json:"command"`
+	Dir     string          `json:"dir"`
+	MCU     string          `json:"mcu"`
+	// File is the source file to assemble, read on every request; Source is the source text
+	// itself, for editors assembling an unsaved buffer.
+	File   string `json:"file"`
+	Source string `json:"source"`
+	// Hex, Report, Listing and Map are the files to write. Without Hex the HEX file is
+	// returned in the response instead.
+	Hex          string            `json:"hex"`
+	Report       string            `json:"report"`
+	Listing      string            `json:"listing"`
+	Map          string            `json:"map"`
+	Defines      map[string]string `json:"defines"`
+	IncludeDirs  []string          `json:"include_dirs"`
+	Optimize     bool              `json:"optimize"`
+	Debug        bool              `json:"debug"`
+	StrictConfig bool              `json:"strict_config"`
+	Werror       bool              `json:"werror"`
+}
+
+// daemonResponse answers a request on the same connection, one JSON object per line.
+type daemonResponse struct {
+	ID          json.RawMessage `json:"id,omitempty"`
+	Success     bool            `json:"success"`
+	Hex         string          `json:"hex,omitempty"`
+	Written     []string        `json:"written,omitempty"`
+	Diagnostics []Diagnostic    `json:"diagnostics"`
+	Millis      float64         `json:"elapsed_ms"`
+	Status      *daemonStatus   `json:"status,omitempty"`
+}
+
+// daemonStatus is what a status request reports about the daemon.
+type daemonStatus struct {
+	Version  string   `json:"version"`
+	Uptime   float64  `json:"uptime_seconds"`
+	Builds   int      `json:"builds"`
+	Configs  []string `json:"configs"`
+	Includes int      `json:"includes"`
+}
+
+// assemblyDaemon serves assemble requests on a local socket. Device configs and include
+// files stay in memory between builds and are read again only when their file changes; every
+// build still gets its own parser and assembler.
+type assemblyDaemon struct {
+	configDir string
+	slots     chan struct{}
+	includes  *includeCache
+	listener  net.Listener
+	started   time.Time
+
+	mu      sync.Mutex
+	configs map[string]cachedConfig
+	builds  int
+	closing bool
+	active  sync.WaitGroup // requests being answered
+}
+
+// cachedConfig is a device config with the state of the file it was loaded from.
+type cachedConfig struct {
+	config  *MicrocontrollerConfig
+	modTime time.Time
+	size    int64
+}
+
+// config returns the configuration of an MCU, loading it again when its file changed.
+func (d *assemblyDaemon) config(mcu string) (*MicrocontrollerConfig, error) {
+	if !mcuNameRegex.MatchString(mcu) {
+		return nil, fmt.Errorf("invalid MCU name '%s'", mcu)
+	}
+	key := strings.ToLower(mcu)
+	info, err := os.Stat(filepath.Join(d.configDir, key+".json"))
+	if err != nil {
+		return nil, fmt.Errorf("unsupported MCU '%s'", mcu)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if cached, ok := d.configs[key]; ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+		return cached.config, nil
+	}
+	mcConfig, err := loadMicrocontrollerConfigByName(d.configDir, key)
+	if err != nil {
+		return nil, err
+	}
+	d.configs[key] = cachedConfig{config: mcConfig, modTime: info.ModTime(), size: info.Size()}
+	return mcConfig, nil
+}
+
+// status describes the daemon and what it holds in memory.
+func (d *assemblyDaemon) status() *daemonStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	status := &daemonStatus{Version: asm4picVersion, Uptime: time.Since(d.started).Seconds(), Builds: d.builds, Configs: []string{}, Includes: d.includes.len()}
+	for _, cached := range d.configs {
+		status.Configs = append(status.Configs, cached.config.Name)
+	}
+	sort.Strings(status.Configs)
+	return status
+}
+
+// shutdown stops accepting connections and requests; the requests being answered still finish.
+func (d *assemblyDaemon) shutdown() {
+	d.mu.Lock()
+	d.closing = true
+	d.mu.Unlock()
+	d.listener.Close()
+}
+
+// serve accepts connections until the daemon shuts down, then waits for the requests being
+// answered.
+func (d *assemblyDaemon) serve() error {
+	for {
+		conn, err := d.listener.Accept()
+		if err != nil {
+			d.mu.Lock()
+			closing := d.closing
+			d.mu.Unlock()
+			if closing {
+				d.active.Wait()
+				return nil
+			}
+			return err
+		}
+		go d.handle(conn)
+	}
+}
+
+// handle answers the requests of one connection, in order, until the client closes it.
+func (d *assemblyDaemon) handle(conn net.Conn) {
+	defer conn.Close()
+	decoder := json.NewDecoder(conn)
+	encoder := json.NewEncoder(conn)
+	for {
+		var req daemonRequest
+		if err := decoder.Decode(&req); err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				// The stream cannot be resynchronized after malformed JSON, so the connection ends
+				encoder.Encode(daemonResponse{Diagnostics: []Diagnostic{{Severity: SeverityError, Message: "invalid request: " + err.Error()}}})
+			}
+			return
+		}
+		d.mu.Lock()
+		if d.closing {
+			d.mu.Unlock()
+			return
+		}
+		d.active.Add(1)
+		d.mu.Unlock()
+
+		start := time.Now()
+		var resp daemonResponse
+		switch req.Command {
+		case "", DaemonAssemble:
+			resp = d.assemble(&req)
+		case DaemonStatus:
+			resp = daemonResponse{Success: true, Diagnostics: []Diagnostic{}, Status: d.status()}
+		case DaemonShutdown:
+			resp = daemonResponse{Success: true, Diagnostics: []Diagnostic{}}
+			defer d.shutdown()
+		default:
+			resp = daemonResponse{Diagnostics: []Diagnostic{{Severity: SeverityError, Message: fmt.Sprintf("unknown command '%s' (expected %s, %s or %s)", req.Command, DaemonAssemble, DaemonStatus, DaemonShutdown)}}}
+		}
+		resp.ID, resp.Millis = req.ID, float64(time.Since(start).Microseconds())/1000
+		err := encoder.Encode(resp)
+		d.active.Done()
+		if err != nil || req.Command == DaemonShutdown {
+			return
+		}
+	}
+}
+
+// assemble builds one request and writes the output files it names.
+func (d *assemblyDaemon) assemble(req *daemonRequest) daemonResponse {
+	fail := func(err error) daemonResponse {
+		return daemonResponse{Diagnostics: []Diagnostic{errorDiagnostic(err)}}
+	}
+	path := func(name string) string {
+		if name == "" || filepath.IsAbs(name) || req.Dir == "" {
+			return name
+		}
+		return filepath.Join(req.Dir, name)
+	}
+	for _, output := range []string{req.Hex, req.Report, req.Listing, req.Map} {
+		if output == StdioPath {
+			return fail(errors.New("daemon outputs must name files, not stdout"))
+		}
+	}
+	mcConfig, err := d.config(req.MCU)
+	if err != nil {
+		return fail(err)
+	}
+	source, sourceDir := req.Source, req.Dir
+	if req.File != "" {
+		data, err := os.ReadFile(path(req.File))
+		if err != nil {
+			return fail(fmt.Errorf("could not read assembly file '%s': %w", req.File, err))
+		}
+		source, sourceDir = string(data), filepath.Dir(path(req.File))
+	}
+
+	opts := &AssemblyOptions{
+		Defines:          req.Defines,
+		IncludeCache:     d.includes,
+		SourceDir:        sourceDir,
+		Optimize:         req.Optimize,
+		Debug:            req.Debug,
+		StrictConfig:     req.StrictConfig,
+		WarningsAsErrors: req.Werror,
+		ListingFile:      path(req.Listing),
+		MapFile:          path(req.Map),
+	}
+	for _, dir := range req.IncludeDirs {
+		opts.IncludeDirs = append(opts.IncludeDirs, path(dir))
+	}
+
+	d.slots <- struct{}{}
+	output, err := assembleOutput(source, mcConfig, opts)
+	<-d.slots
+	d.mu.Lock()
+	d.builds++
+	d.mu.Unlock()
+
+	resp := daemonResponse{Diagnostics: append([]Diagnostic{}, output.Warnings...)}
+	if err != nil {
+		resp.Diagnostics = append(resp.Diagnostics, errorDiagnostic(err))
+		return resp
+	}
+	resp.Diagnostics = append(resp.Diagnostics, optimizationLog(output.Assembler.optimizations)...)
+	if req.Hex == "" {
+		resp.Hex = output.Hex
+	}
+	for _, file := range []struct{ path, content string }{
+		{path(req.Hex), output.Hex}, {path(req.Report), output.Report}, {opts.ListingFile, output.Listing}, {opts.MapFile, output.Map},
+	} {
+		if file.path == "" {
+			continue
+		}
+		if err := writeOutputFile(file.path, file.content); err != nil {
+			resp.Diagnostics = append(resp.Diagnostics, errorDiagnostic(fmt.Errorf("could not write '%s': %w", file.path, err)))
+			return resp
+		}
+		resp.Written = append(resp.Written, file.path)
+	}
+	resp.Success = true
+	return resp
+}
+
+// defaultDaemonSocket returns the per-user socket path the daemon listens on by default.
+// ASM4PIC_DAEMON_SOCKET overrides it.
+func defaultDaemonSocket() string {
+	if path := os.Getenv("ASM4PIC_DAEMON_SOCKET"); path != "" {
+		return path
+	}
+	if base, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(base, "asm4pic", "daemon.sock")
+	}
+	return filepath.Join(os.TempDir(), "asm4pic-daemon.sock")
+}
+
+// listenDaemonSocket listens on a Unix socket only the current user can connect to. A socket
+// left behind by a daemon that did not shut down cleanly is replaced; one that still answers
+// is an error.
+func listenDaemonSocket(path string) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("a daemon is already listening on %s", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// runDaemon starts the daemon and serves requests until it is interrupted or asked to shut down.
+func runDaemon(args []string) error {
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	socket := fs.String("socket", defaultDaemonSocket(), "Unix socket to listen on (default: $ASM4PIC_DAEMON_SOCKET, else asm4pic/daemon.sock in the user cache directory)")
+	configDir := fs.String("config-dir", "./configs", "Directory containing microcontroller JSON config files")
+	maxConcurrent := fs.Int("max-concurrent", 4, "Maximum number of builds running at the same time")
+	fs.Parse(args)
+
+	if *maxConcurrent < 1 {
+		return fmt.Errorf("-max-concurrent must be at least 1")
+	}
+	if _, err := os.Stat(*configDir); err != nil {
+		return fmt.Errorf("config directory: %w", err)
+	}
+	listener, err := listenDaemonSocket(*socket)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(*socket)
+
+	daemon := &assemblyDaemon{
+		configDir: *configDir,
+		slots:     make(chan struct{}, *maxConcurrent),
+		includes:  newIncludeCache(),
+		listener:  listener,
+		started:   time.Now(),
+		configs:   make(map[string]cachedConfig),
+	}
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	go func() {
+		<-interrupt
+		daemon.shutdown()
+	}()
+
+	log.Printf("asm4PIC daemon listening on %s", *socket)
+	return daemon.serve()
+}
+
+// --- Include Cache ---
+
+// includeCache keeps the text of include files between builds, reading a file again only
+// when its size or modification time changed. It is safe for concurrent builds.
+type includeCache struct {
+	mu    sync.Mutex
+	files map[string]cachedInclude
+}
+
+type cachedInclude struct {
+	modTime time.Time
+	size    int64
+	text    string
+}
+
+func newIncludeCache() *includeCache {
+	return &includeCache{files: make(map[string]cachedInclude)}
+}
+
+// read returns the text of an include file. A nil cache reads the file every time.
+func (c *includeCache) read(path string) (string, error) {
+	if c == nil {
+		data, err := os.ReadFile(path)
+		return string(data), err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	c.mu.Lock()
+	cached, ok := c.files[path]
+	c.mu.Unlock()
+	if ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+		return cached.text, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	c.mu.Lock()
+	c.files[path] = cachedInclude{modTime: info.ModTime(), size: info.Size(), text: string(data)}
+	c.mu.Unlock()
+	return string(data), nil
+}
+
+// len returns the number of include files cached.
+func (c *includeCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.files)
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// --- Data Directives ---
+
+// DataDirective stores data in program memory: DT as RETLW instructions, DW as one word per
+// element, DA as strings packed two 7-bit characters per word, DB as bytes packed two per word
+// and DE as one byte per word for the data EEPROM. Elements are expressions or quoted strings,
+// which give one element per character.
+type DataDirective struct {
+	Name     string        // upper-case directive
+	Operands string        // operand text as written
+	Elements []dataElement // in source order, strings already split into characters
+}
+
+func (d *DataDirective) isAssemblyItem() {}
+
+// dataElement is one element of a data directive: a character of a string, or an expression.
+type dataElement struct {
+	Char       byte
+	Expression string // "" for a character
+	// StringEnd marks the last character of a string, where DA stops packing.
+	StringEnd bool
+}
+
+var dataDirectiveRegex = regexp.MustCompile(`(?i)^(DT|DW|DA|DB|DE)\s+(.+)$`)
+
+func init() {
+	for _, name := range []string{"DT", "DW", "DA", "DB", "DE"} {
+		directiveKeywords[name] = true
+	}
+}
+
+// parseDataElements parses a data directive, or returns nil if the line is not one.
+func parseDataElements(lineContent string) (AssemblyItem, error) {
+	match := dataDirectiveRegex.FindStringSubmatch(lineContent)
+	if match == nil {
+		return nil, nil
+	}
+	d := &DataDirective{Name: strings.ToUpper(match[1]), Operands: strings.TrimSpace(match[2])}
+	operands, err := splitDataOperands(match[2])
+	if err != nil {
+		return nil, err
+	}
+	for _, operand := range operands {
+		if quote := operand[0]; quote != '"' && quote != '\'' {
+			d.Elements = append(d.Elements, dataElement{Expression: operand})
+			continue
+		}
+		text, err := unquoteData(operand)
+		if err != nil {
+			return nil, err
+		}
+		for k := 0; k < len(text); k++ {
+			d.Elements = append(d.Elements, dataElement{Char: text[k], StringEnd: k == len(text)-1})
+		}
+	}
+	if len(d.Elements) == 0 {
+		return nil, trErrorf("%s has no data", d.Name)
+	}
+	return d, nil
+}
+
+// splitDataOperands splits operand text at the commas outside quoted strings and
+// parentheses.
+func splitDataOperands(text string) ([]string, error) {
+	var operands []string
+	start, quote, depth := 0, byte(0), 0
+	for k := 0; k < len(text); k++ {
+		switch c := text[k]; {
+		case quote != 0 && c == '\\':
+			k++
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')' && depth > 0:
+			depth--
+		case c == ',' && depth == 0:
+			operands = append(operands, strings.TrimSpace(text[start:k]))
+			start = k + 1
+		}
+	}
+	if quote != 0 {
+		return nil, trErrorf("unterminated string in '%s'", text)
+	}
+	operands = append(operands, strings.TrimSpace(text[start:]))
+	for _, operand := range operands {
+		if operand == "" {
+			return nil, trErrorf("empty operand in '%s'", text)
+		}
+	}
+	return operands, nil
+}
+
+// unquoteData decodes a quoted string operand. The escapes \n, \r, \t, \0, \xNN, \\ and
+// the quote characters are recognized.
+func unquoteData(operand string) (string, error) {
+	quote := operand[0]
+	if len(operand) < 2 || operand[len(operand)-1] != quote {
+		return "", trErrorf("text after the string %s", operand)
+	}
+	body := operand[1 : len(operand)-1]
+	var out strings.Builder
+	for k := 0; k < len(body); k++ {
+		c := body[k]
+		if c != '\\' {
+			out.WriteByte(c)
+			continue
+		}
+		if k++; k >= len(body) {
+			return "", trErrorf("string %s ends with '\\'", operand)
+		}
+		switch body[k] {
+		case 'n':
+			out.WriteByte('\n')
+		case 'r':
+			out.WriteByte('\r')
+		case 't':
+			out.WriteByte('\t')
+		case '0':
+			out.WriteByte(0)
+		case '\\', '"', '\'':
+			out.WriteByte(body[k])
+		case 'x':
+			if k+2 >= len(body) {
+				return "", trErrorf("\\x needs two hex digits in %s", operand)
+			}
+			v, err := strconv.ParseUint(body[k+1:k+3], 16, 8)
+			if err != nil {
+				return "", trErrorf("\\x needs two hex digits in %s", operand)
+			}
+			out.WriteByte(byte(v))
+			k += 2
+		default:
+			return "", trErrorf("unknown escape '\\%c' in %s", body[k], operand)
+		}
+	}
+	return out.String(), nil
+}
+
+// wordCount returns the number of program words the directive takes.
+func (d *DataDirective) wordCount() int {
+	if d.Name == "DB" {
+		return (len(d.Elements) + 1) / 2
+	}
+	if d.Name != "DA" {
+		return len(d.Elements)
+	}
+	words := 0
+	for k := 0; k < len(d.Elements); k++ {
+		e := d.Elements[k]
+		if e.Expression == "" && !e.StringEnd {
+			k++ // two characters share a word
+		}
+		words++
+	}
+	return words
+}
+
+// encode returns the program words of the directive, evaluating expression elements.
+func (d *DataDirective) encode(a *PicAssembler) ([]int, error) {
+	values := make([]int, len(d.Elements))
+	for k, e := range d.Elements {
+		values[k] = int(e.Char)
+		if e.Expression != "" {
+			val, err := a.evaluateExpression(e.Expression)
+			if err != nil {
+				return nil, err
+			}
+			values[k] = val
+		}
+	}
+	wordMask := 1<<a.mcConfig.ProgramWordSizeBits - 1
+	var words []int
+	switch d.Name {
+	case "DT":
+		for _, val := range values {
+			word, err := encodeRETLW(a.mcConfig, val)
+			if err != nil {
+				return nil, err
+			}
+			words = append(words, word)
+		}
+	case "DW":
+		for _, val := range values {
+			words = append(words, val&wordMask)
+		}
+	case "DE":
+		for _, val := range values {
+			words = append(words, val&0xFF)
+		}
+	case "DB":
+		// As MPASM packs them: the first byte in the low half of a 16-bit word, and in the high
+		// half of a narrower one, which keeps only its low bits. An odd byte is padded with 0.
+		for k := 0; k < len(values); k += 2 {
+			first, second := values[k]&0xFF, 0
+			if k+1 < len(values) {
+				second = values[k+1] & 0xFF
+			}
+			if a.mcConfig.ProgramWordSizeBits >= 16 {
+				first, second = second, first
+			}
+			words = append(words, (first<<8|second)&wordMask)
+		}
+	case "DA":
+		half := a.mcConfig.ProgramWordSizeBits / 2
+		for k := 0; k < len(d.Elements); k++ {
+			e := d.Elements[k]
+			if e.Expression != "" {
+				words = append(words, values[k]&wordMask)
+				continue
+			}
+			word := (values[k] & (1<<half - 1)) << half
+			if !e.StringEnd {
+				k++
+				word |= values[k] & (1<<half - 1)
+			}
+			words = append(words, word)
+		}
+	}
+	return words, nil
+}
+
+// encodeRETLW returns the machine word of RETLW k for the device.
+func encodeRETLW(mcConfig *MicrocontrollerConfig, k int) (int, error) {
+	info, ok := mcConfig.InstructionSet["RETLW"]
+	if !ok {
+		return 0, trErrorf("the device has no RETLW")
+	}
+	bit := strings.Count(info.OpcodePattern, "L") - 1
+	word := 0
+	for _, c := range info.OpcodePattern {
+		word <<= 1
+		switch c {
+		case '1':
+			word |= 1
+		case 'L':
+			word |= k >> bit & 1
+			bit--
+		}
+	}
+	return word, nil
+}
+
+// inEEPROM reports whether a word address is in the data EEPROM area of the HEX file.
+func (c *MicrocontrollerConfig) inEEPROM(addr int) bool {
+	return c.EEPROM != nil && c.EEPROM.Contains(addr)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// --- Data Tables ---
+
+// DTABLE "file.csv"[, col=N | col=name][, skip=N][, format=retlw | dw] reads a CSV file when
+// the source is parsed and stores one column of it as a DT (RETLW) or DW table. Columns are
+// numbered from 1, or named by the header row, which is then not part of the table; skip
+// drops that many rows first. Lines starting with # are comments and blank lines are ignored.
+var dataTableRegex = regexp.MustCompile(`(?i)^DTABLE\s+(.+)$`)
+
+func init() {
+	directiveKeywords["DTABLE"] = true
+}
+
+// parseDataTable parses a DTABLE line into the DT or DW directive of its column, or returns
+// nil if the line is not one.
+func (p *ASMParser) parseDataTable(lineContent string) (AssemblyItem, error) {
+	match := dataTableRegex.FindStringSubmatch(lineContent)
+	if match == nil {
+		return nil, nil
+	}
+	operands, err := splitDataOperands(match[1])
+	if err != nil {
+		return nil, err
+	}
+	name := operands[0]
+	if len(name) < 2 || name[0] != '"' || name[len(name)-1] != '"' {
+		return nil, trErrorf(`expected DTABLE "file.csv"[, col=N][, skip=N][, format=retlw | dw], got '%s'`, name)
+	}
+	name = name[1 : len(name)-1]
+
+	column, header, skip, directive := 0, "", 0, "DT"
+	for _, operand := range operands[1:] {
+		key, value, ok := strings.Cut(operand, "=")
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+		switch {
+		case !ok || value == "":
+			return nil, trErrorf("DTABLE option '%s' needs a value", operand)
+		case key == "col":
+			if n, err := strconv.Atoi(value); err == nil {
+				if n < 1 {
+					return nil, trErrorf("DTABLE columns are numbered from 1, got %d", n)
+				}
+				column, header = n, ""
+			} else {
+				column, header = 0, strings.Trim(value, `"`)
+			}
+		case key == "skip":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return nil, trErrorf("DTABLE skip must be a row count, got '%s'", value)
+			}
+			skip = n
+		case key == "format":
+			switch strings.ToLower(value) {
+			case "retlw":
+				directive = "DT"
+			case "dw":
+				directive = "DW"
+			default:
+				return nil, trErrorf("DTABLE format must be retlw or dw, got '%s'", value)
+			}
+		default:
+			return nil, trErrorf("unknown DTABLE option '%s'", key)
+		}
+	}
+	if column == 0 && header == "" {
+		column = 1
+	}
+
+	text, err := p.readDataFile(name, 0)
+	if err != nil {
+		return nil, trErrorf("cannot read %s: %v", name, err)
+	}
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.Comment, reader.FieldsPerRecord, reader.TrimLeadingSpace = '#', -1, true
+	d := &DataDirective{Name: directive}
+	var values []string
+	for row := 0; ; row++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, trErrorf("%s: %v", name, err)
+		}
+		if row < skip {
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+		if header != "" && column == 0 {
+			for k, field := range record {
+				if strings.EqualFold(strings.TrimSpace(field), header) {
+					column = k + 1
+				}
+			}
+			if column == 0 {
+				return nil, trErrorf("%s has no column '%s' in its header on line %d", name, header, line)
+			}
+			continue
+		}
+		if column > len(record) || strings.TrimSpace(record[column-1]) == "" {
+			return nil, trErrorf("%s line %d has no value in column %d", name, line, column)
+		}
+		value := strings.TrimSpace(record[column-1])
+		d.Elements = append(d.Elements, dataElement{Expression: value})
+		values = append(values, value)
+	}
+	if len(d.Elements) == 0 {
+		return nil, trErrorf("%s has no rows for DTABLE", name)
+	}
+	d.Operands = strings.Join(values, ", ")
+	return d, nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"sort"
+)
+
+// --- Dead Code Elimination ---
+
+func init() {
+	registerOptimizationPass(optimizationPass{
+		Name:        "dce",
+		Description: "Remove code unreachable from the reset and interrupt vectors",
+		Run:         eliminateDeadCode,
+		OptIn:       true,
+	})
+}
+
+// eliminateDeadCode removes instructions that no path from the reset or interrupt vector
+// reaches. Labels and directives are kept. Nothing is removed when a jump target cannot be
+// resolved, since the analysis would not be sound.
+func eliminateDeadCode(ctx *optimizeContext) {
+	g := buildFlowGraph(ctx.expanded, ctx.mcConfig, ctx.value)
+	if g.unresolved != nil {
+		ctx.changes = append(ctx.changes, OptimizationChange{Pass: ctx.pass, Message: tr("Skipped: %v", g.unresolved)})
+		return
+	}
+	if _, ok := g.atAddress[resetVector]; !ok {
+		ctx.changes = append(ctx.changes, OptimizationChange{Pass: ctx.pass, Message: tr("Skipped: no code at the reset vector")})
+		return
+	}
+	live := g.reachable(g.roots())
+
+	var dead []int
+	for i := range g.address {
+		if !live[i] {
+			dead = append(dead, i)
+		}
+	}
+	sort.Ints(dead)
+
+	// Log each run of consecutive dead instructions once
+	removed := make(map[int]bool)
+	for start := 0; start < len(dead); {
+		end := start
+		for end+1 < len(dead) && g.address[dead[end+1]] == g.address[dead[end]]+1 {
+			end++
+		}
+		count := end - start + 1
+		from := ""
+		for j := dead[start] - 1; j >= 0; j-- {
+			if label, ok := ctx.expanded.Lines[j].(*Label); ok {
+				from = " at " + label.Name
+				break
+			}
+			if _, ok := ctx.expanded.Lines[j].(*Instruction); ok {
+				break
+			}
+		}
+		var before []*Instruction
+		for _, i := range dead[start : end+1] {
+			removed[i] = true
+			inst, _ := ctx.code(i)
+			before = append(before, inst)
+		}
+		ctx.record(dead[start], before, nil, 0, "Removed %d unreachable instruction(s)%s", count, from)
+		start = end + 1
+	}
+	ctx.remove(removed)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"maps"
+	"testing"
+)
+
+// enhancedConfig is the test device with the BRW and CALLW of the enhanced mid-range core.
+func enhancedConfig(t *testing.T) *MicrocontrollerConfig {
+	t.Helper()
+	mcConfig := testConfig(t)
+	mcConfig.InstructionSet = maps.Clone(mcConfig.InstructionSet)
+	mcConfig.InstructionSet["BRW"] = InstructionInfo{OpcodePattern: "00000000001011"}
+	mcConfig.InstructionSet["CALLW"] = InstructionInfo{OpcodePattern: "00000000001010"}
+	return mcConfig
+}
+
+func TestDeadCodeKeepsComputedJumpTargets(t *testing.T) {
+	opts := &AssemblyOptions{Optimize: true, EnabledPasses: map[string]bool{"dce": true}, DisabledPasses: map[string]bool{"peephole": true}}
+	for name, test := range map[string]struct {
+		src   string
+		words int
+	}{
+		"BRW": {"        ORG 0\n        MOVLW 2\n        CALL DISPATCH\nLOOP:\n        GOTO LOOP\n" +
+			"        JUMPTABLE DISPATCH, ONE, TWO, THREE\n" +
+			"ONE:\n        RETURN\nTWO:\n        RETURN\nTHREE:\n        RETURN\n        END\n", 10},
+		"CALLW": {"        ORG 0\n        MOVLW 2\n        CALLW\n        GOTO ONE\n        GOTO TWO\n        GOTO THREE\n" +
+			"ONE:\n        RETURN\nTWO:\n        RETURN\nTHREE:\n        RETURN\n        END\n", 8},
+	} {
+		assembler, _, err := assembleSource(test.src, enhancedConfig(t), opts)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(assembler.machineCodeWords) != test.words {
+			t.Errorf("%s: dead code elimination removed table entries: %v", name, assembler.machineCodeWords)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// --- Debug Builds ---
+
+// ICDResources are what the in-circuit debugger takes from the device while debugging: its
+// debug executive at the top of program memory, a few registers of data memory and the config
+// setting that enables it.
+type ICDResources struct {
+	ProgramWords int    `json:"program_words"`
+	RAM          []int  `json:"ram"`
+	Config       string `json:"config"` // fuse setting, e.g. _DEBUG_ON
+}
+
+// reserveDebugResources prepares a -debug build: it enables the debugger in the config words
+// and fails when the program uses the program words or registers the debugger needs.
+func (a *PicAssembler) reserveDebugResources() error {
+	icd := a.mcConfig.ICD
+	if icd == nil {
+		return &AssemblerError{Message: tr("%s has no ICD entry in its device config, so -debug cannot reserve the debugger's resources.", a.mcConfig.Name), Code: CodeDebugReserved}
+	}
+
+	if icd.Config != "" && !a.setFuse(icd.Config) {
+		return &AssemblerError{Message: tr("The ICD config setting '%s' is not in the fuse maps of %s.", icd.Config, a.mcConfig.Name), Code: CodeDebugReserved}
+	}
+
+	start := a.mcConfig.ProgramMemorySize - icd.ProgramWords
+	indices := make([]int, 0, len(a.itemAddresses))
+	for i := range a.itemAddresses {
+		indices = append(indices, i)
+	}
+	sort.Ints(indices)
+	for _, i := range indices {
+		addr, words := a.itemAddresses[i], 0
+		switch v := a.parsedAssembly.Lines[i].(type) {
+		case *Instruction:
+			words = 1
+		case *DataDirective:
+			words = v.wordCount()
+		case *VersionString:
+			words = v.wordCount()
+		}
+		if words > 0 && addr+words > start && addr < a.mcConfig.ProgramMemorySize {
+			return a.errorAt(i, CodeDebugReserved, "Program memory 0x%04X-0x%04X is reserved for the in-circuit debugger in -debug builds; this code reaches 0x%04X.", start, a.mcConfig.ProgramMemorySize-1, addr+words-1)
+		}
+	}
+
+	owners := a.ramOwners()
+	for _, addr := range icd.RAM {
+		if len(owners[addr]) > 0 {
+			names := append([]string(nil), owners[addr]...)
+			sort.Strings(names)
+			return &AssemblerError{Message: tr("Register 0x%03X is reserved for the in-circuit debugger in -debug builds, but holds %s.", addr, strings.Join(names, ", ")), Code: CodeDebugRAM}
+		}
+	}
+	return nil
+}
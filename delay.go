@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+)
+
+// --- Delay Generator ---
+
+func init() {
+	registerBuiltinMacro("DELAY_CYCLES", expandDelayCycles)
+	registerBuiltinMacro("DELAY_US", expandDelayUS)
+}
+
+// defaultDelayCounters are the counter registers used when a delay does not name its own.
+// The program must define them (e.g. with EQU) when the delay needs a loop.
+var defaultDelayCounters = []string{"DELAY_COUNT1", "DELAY_COUNT2", "DELAY_COUNT3"}
+
+// delayLoop describes a counted loop of 1-3 nested levels. Each iteration costs
+// CyclesPerIteration except the last, which is one cycle shorter; loading each
+// counter costs 2 cycles (MOVLW + MOVWF).
+type delayLoop struct {
+	Levels             int
+	CyclesPerIteration int
+	MaxIterations      int
+}
+
+var delayLoops = []delayLoop{
+	{1, 3, 1 << 8},
+	{2, 5, 1 << 16},
+	{3, 7, 1 << 24},
+}
+
+// synthesizeDelay returns source lines that take exactly cycles instruction cycles on a
+// mid-range core (1 cycle per instruction, 2 for GOTO and taken skips).
+func synthesizeDelay(p *ASMParser, cycles int, counters []string) ([]string, error) {
+	if cycles < 0 {
+		return nil, trErrorf("cycle count %d is negative", cycles)
+	}
+	var lines []string
+	remaining := cycles
+
+	// Use the smallest loop that reaches the count; short delays are padded only.
+	for _, loop := range delayLoops {
+		setup := 2 * loop.Levels
+		iterations := (remaining - setup + 1) / loop.CyclesPerIteration
+		if iterations < 2 {
+			break
+		}
+		if iterations > loop.MaxIterations {
+			if loop.Levels == len(delayLoops) {
+				return nil, trErrorf("%d cycles exceeds the longest generated delay", cycles)
+			}
+			continue
+		}
+		if len(counters) < loop.Levels {
+			return nil, trErrorf("%d cycles needs %d counter register(s), got %d", cycles, loop.Levels, len(counters))
+		}
+
+		// iterations = c1 + 256*(c2-1) + 65536*(c3-1), each counter in 1..256 (256 loads as 0)
+		m := iterations - 1
+		for level := 0; level < loop.Levels; level++ {
+			count := (m>>(8*level))&0xFF + 1
+			if level == loop.Levels-1 {
+				count = m>>(8*level) + 1
+			}
+			lines = append(lines, fmt.Sprintf("MOVLW 0x%02X", count&0xFF), fmt.Sprintf("MOVWF %s", counters[level]))
+		}
+		// While a counter is non-zero, jump over the next counter's decrement:
+		// LOOP: DECFSZ c1 / GOTO NEXT / DECFSZ c2 / NEXT: GOTO LOOP
+		loopLabel := p.generateUniqueLabelName("DELAY_LOOP")
+		lines = append(lines, loopLabel+":")
+		skipLabel := ""
+		for level := 0; level < loop.Levels; level++ {
+			lines = append(lines, fmt.Sprintf("DECFSZ %s, F", counters[level]))
+			if skipLabel != "" {
+				lines = append(lines, skipLabel+":")
+			}
+			if level == loop.Levels-1 {
+				lines = append(lines, "GOTO "+loopLabel)
+			} else {
+				skipLabel = p.generateUniqueLabelName("DELAY_NEXT")
+				lines = append(lines, "GOTO "+skipLabel)
+			}
+		}
+		remaining -= setup + loop.CyclesPerIteration*iterations - 1
+		break
+	}
+
+	// Pad the rest: GOTO to the next word costs 2 cycles in 1 word, NOP 1 cycle
+	for ; remaining >= 2; remaining -= 2 {
+		label := p.generateUniqueLabelName("DELAY_PAD")
+		lines = append(lines, "GOTO "+label, label+":")
+	}
+	if remaining == 1 {
+		lines = append(lines, "NOP")
+	}
+	return lines, nil
+}
+
+// delayCounters returns the counter registers named after the leading operands, or the defaults.
+func delayCounters(operands []string) []string {
+	if len(operands) == 0 {
+		return defaultDelayCounters
+	}
+	return operands
+}
+
+// expandDelayCycles implements DELAY_CYCLES n[, counter1[, counter2[, counter3]]].
+func expandDelayCycles(p *ASMParser, operands []string, sourceLine int) ([]string, error) {
+	if len(operands) < 1 || len(operands) > 4 {
+		return nil, trErrorf("expected DELAY_CYCLES cycles[, counter1[, counter2[, counter3]]]")
+	}
+	cycles, err := p.literalOperand(operands[0])
+	if err != nil {
+		return nil, err
+	}
+	return synthesizeDelay(p, cycles, delayCounters(operands[1:]))
+}
+
+// expandDelayUS implements DELAY_US microseconds, fosc[, counter1[, counter2[, counter3]]].
+// fosc is the oscillator frequency in Hz; one instruction cycle takes 4 oscillator periods.
+func expandDelayUS(p *ASMParser, operands []string, sourceLine int) ([]string, error) {
+	if len(operands) < 2 || len(operands) > 5 {
+		return nil, trErrorf("expected DELAY_US microseconds, fosc[, counter1[, counter2[, counter3]]]")
+	}
+	us, err := p.literalOperand(operands[0])
+	if err != nil {
+		return nil, err
+	}
+	fosc, err := p.literalOperand(operands[1])
+	if err != nil {
+		return nil, err
+	}
+	if fosc <= 0 {
+		return nil, trErrorf("oscillator frequency must be positive")
+	}
+	scaled := int64(us) * int64(fosc)
+	cycles := (scaled + 2000000) / 4000000
+	if scaled%4000000 != 0 {
+		p.warn(CodeDelayRounded, "DELAY_US %d at %d Hz is not a whole number of cycles; rounded to %d cycles.", us, fosc, cycles)
+	}
+	return synthesizeDelay(p, int(cycles), delayCounters(operands[2:]))
+}
@@ -0,0 +1,618 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// --- Disassembler ---
+
+func init() {
+	registerSubcommand("disasm", "Disassemble a HEX file into source that reassembles to it", runDisasm)
+}
+
+// minTableLength is the shortest run of RETLW instructions written as a DT table; shorter
+// runs are more likely the early returns of a subroutine than data.
+const minTableLength = 3
+
+// dataLineLength is the number of values written on one DT, DW or DE line.
+const dataLineLength = 16
+
+// decodedInstruction is a program word matched to an instruction of the device.
+type decodedInstruction struct {
+	Opcode string
+	Info   InstructionInfo
+	Fields map[string]int // operand kind -> the value of its field
+}
+
+// writesFile reports whether the instruction stores into its file register.
+func (d decodedInstruction) writesFile() bool {
+	switch d.Opcode {
+	case "MOVWF", "CLRF", "BSF", "BCF":
+		return true
+	}
+	dest, ok := d.Fields["d"]
+	return ok && dest == 1
+}
+
+// endsFlow reports whether control never falls through to the next word.
+func (d decodedInstruction) endsFlow() bool {
+	switch d.Opcode {
+	case "GOTO", "RETURN", "RETLW", "RETFIE":
+		return true
+	}
+	return false
+}
+
+// decodeWord matches a program word against the opcode patterns of the instruction set. Don't
+// care (x) bits must be 0, as the assembler writes them, so that the instruction reassembles
+// to the same word. When several patterns match, the one with the most fixed bits wins.
+func decodeWord(mcConfig *MicrocontrollerConfig, word int) (decodedInstruction, bool) {
+	names := make([]string, 0, len(mcConfig.InstructionSet))
+	for name := range mcConfig.InstructionSet {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	best := -1
+	var result decodedInstruction
+	for _, name := range names {
+		info := mcConfig.InstructionSet[name]
+		n := len(info.OpcodePattern)
+		if word>>n != 0 {
+			continue
+		}
+		fixed, ok := 0, true
+		fields := make(map[rune]int)
+		for i, c := range info.OpcodePattern {
+			bit := word >> (n - 1 - i) & 1
+			switch c {
+			case '0', 'x':
+				ok = ok && bit == 0
+				fixed++
+			case '1':
+				ok = ok && bit == 1
+				fixed++
+			default:
+				fields[c] = fields[c]<<1 | bit
+			}
+		}
+		if !ok || fixed <= best {
+			continue
+		}
+		values := make(map[string]int)
+		for _, kind := range info.Operands {
+			values[kind] = fields[operandPlaceholders[kind].letter]
+		}
+		best, result = fixed, decodedInstruction{Opcode: name, Info: info, Fields: values}
+	}
+	return result, best >= 0
+}
+
+// disassembler rebuilds source from the program memory of a HEX file: jump targets become
+// labels, file registers are named from the SFR map where the selected bank is known, and
+// runs of RETLW become DT tables.
+type disassembler struct {
+	mcConfig *MicrocontrollerConfig
+	memory   map[int]byte
+	words    map[int]int // program memory address -> word
+	addrs    []int       // the addresses in words, in order
+	code     map[int]decodedInstruction
+	targets  map[int]int // address of a GOTO or CALL -> its target
+	labels   map[int]string
+	sfrNames map[int]string // register address -> SFR name
+
+	bankSize int
+	bankMask int
+	movlb    bool
+	pageBits int // width of the jump target; 0 when PCLATH selects no pages
+	pageMask int
+	unknown  bankState
+}
+
+// newDisassembler decodes the program memory of a HEX memory image and names its labels.
+func newDisassembler(mcConfig *MicrocontrollerConfig, memory map[int]byte) *disassembler {
+	d := &disassembler{
+		mcConfig: mcConfig,
+		memory:   memory,
+		words:    make(map[int]int),
+		code:     make(map[int]decodedInstruction),
+		targets:  make(map[int]int),
+		labels:   make(map[int]string),
+		sfrNames: sfrNamesByAddress(mcConfig),
+		bankSize: 256,
+		bankMask: bankSelectMask(mcConfig),
+		unknown:  bankState{reached: true},
+	}
+	if mcConfig.RAMLayout != nil && mcConfig.RAMLayout.BankSize > 0 {
+		d.bankSize = mcConfig.RAMLayout.BankSize
+	}
+	_, d.movlb = mcConfig.InstructionSet["MOVLB"]
+	if pageBits, mask, ok := pageSelectBits(mcConfig); ok {
+		d.pageBits, d.pageMask = pageBits, mask
+	}
+
+	wordMask := (1 << mcConfig.ProgramWordSizeBits) - 1
+	for addr := 0; addr < mcConfig.ProgramMemorySize; addr++ {
+		// Words wider than the program word are the padding of HEX records, not code
+		word, ok := hexWordAt(memory, addr)
+		if !ok || word&^wordMask != 0 {
+			continue
+		}
+		d.words[addr] = word & wordMask
+		d.addrs = append(d.addrs, addr)
+		if inst, ok := decodeWord(mcConfig, word&wordMask); ok {
+			d.code[addr] = inst
+		}
+	}
+	d.findLabels()
+	return d
+}
+
+// sfrNamesByAddress maps each SFR address to its name, the alphabetically first where several
+// names share an address.
+func sfrNamesByAddress(mcConfig *MicrocontrollerConfig) map[int]string {
+	names := make(map[int]string)
+	for name, addr := range mcConfig.SFRMap {
+		if other, ok := names[addr]; !ok || name < other {
+			names[addr] = name
+		}
+	}
+	return names
+}
+
+// findLabels resolves every GOTO and CALL target and names it: SUB_ for subroutines, L_ for
+// other jumps and ISR for code at the interrupt vector. A target in another page is found
+// from the PCLATH writes just before the jump, or else taken to be in the jump's own page.
+func (d *disassembler) findLabels() {
+	page, previous := d.unknown, -1
+	for _, addr := range d.addrs {
+		inst, ok := d.code[addr]
+		prev, hasPrev := d.code[addr-1]
+		hasPrev = hasPrev && previous == addr-1
+		if !ok || !hasPrev || (prev.endsFlow() && !d.skips(addr-2)) {
+			page = d.unknown
+		}
+		previous = addr
+		if !ok {
+			continue
+		}
+		if k, ok := inst.Fields["k11"]; ok {
+			target := k
+			if d.pageBits > 0 {
+				pageOf := addr >> d.pageBits
+				if page.known == d.pageMask {
+					pageOf = page.value
+				}
+				target = pageOf<<d.pageBits | k
+			}
+			d.targets[addr] = target
+			if _, exists := d.words[target]; exists {
+				if inst.Opcode == "CALL" {
+					d.labels[target] = fmt.Sprintf("SUB_%04X", target)
+				} else if _, named := d.labels[target]; !named {
+					d.labels[target] = fmt.Sprintf("L_%04X", target)
+				}
+			}
+		}
+		w := -1
+		if hasPrev && prev.Opcode == "MOVLW" {
+			w = prev.Fields["k8"]
+		}
+		page = d.after(page, addr, d.pageTransfer(page, inst, w))
+	}
+	if _, ok := d.words[interruptVector]; ok {
+		d.labels[interruptVector] = "ISR"
+	}
+}
+
+// skips reports whether the instruction at an address may skip the next one.
+func (d *disassembler) skips(addr int) bool {
+	inst, ok := d.code[addr]
+	return ok && isSkipInstruction(inst.Opcode)
+}
+
+// after returns the state after the instruction at addr, which is next unless the previous
+// instruction may skip it.
+func (d *disassembler) after(before bankState, addr int, next bankState) bankState {
+	if d.skips(addr - 1) {
+		return before.merge(next)
+	}
+	return next
+}
+
+// writesRegister reports whether an instruction stores into a core register mirrored in
+// every bank, such as STATUS or PCLATH.
+func (d *disassembler) writesRegister(inst decodedInstruction, name string) bool {
+	addr, ok := d.mcConfig.SFRMap[name]
+	f, hasFile := inst.Fields["f"]
+	return ok && hasFile && inst.writesFile() && f == addr%d.bankSize
+}
+
+// pageTransfer returns the page PCLATH selects after an instruction; w is the literal the
+// previous MOVLW loaded, or -1.
+func (d *disassembler) pageTransfer(s bankState, inst decodedInstruction, w int) bankState {
+	if d.pageBits == 0 || !d.writesRegister(inst, "PCLATH") {
+		return s
+	}
+	switch inst.Opcode {
+	case "CLRF":
+		return bankState{reached: true, known: d.pageMask}
+	case "MOVWF":
+		if w >= 0 {
+			return bankState{reached: true, value: w >> pclathPageShift & d.pageMask, known: d.pageMask}
+		}
+	case "BSF", "BCF":
+		bit := inst.Fields["b"] - pclathPageShift
+		if bit < 0 || 1<<bit > d.pageMask {
+			return s
+		}
+		s.known |= 1 << bit
+		s.value &^= 1 << bit
+		if inst.Opcode == "BSF" {
+			s.value |= 1 << bit
+		}
+		return s
+	}
+	return d.unknown
+}
+
+// bankTransfer returns the selected bank after an instruction: MOVLB and writes to the bank
+// select bits of STATUS change it, and a call leaves it unknown.
+func (d *disassembler) bankTransfer(s bankState, inst decodedInstruction) bankState {
+	switch {
+	case inst.Opcode == "MOVLB":
+		return bankState{reached: true, value: inst.Fields["k8"] & d.bankMask, known: d.bankMask}
+	case inst.Opcode == "CALL":
+		return d.unknown
+	case d.movlb || !d.writesRegister(inst, "STATUS"):
+		return s
+	}
+	switch inst.Opcode {
+	case "CLRF":
+		return bankState{reached: true, known: d.bankMask}
+	case "BSF", "BCF":
+		bit := inst.Fields["b"] - 5
+		if bit < 0 || 1<<bit > d.bankMask {
+			return s
+		}
+		s.known |= 1 << bit
+		s.value &^= 1 << bit
+		if inst.Opcode == "BSF" {
+			s.value |= 1 << bit
+		}
+		return s
+	}
+	return d.unknown
+}
+
+// registerOperand names a file register field. Registers reachable from every bank are
+// named from their bank 0 address; others need the bank to be known, and are written as
+// their address when no SFR is there.
+func (d *disassembler) registerOperand(inst decodedInstruction, bank bankState) string {
+	f := inst.Fields["f"]
+	if access, ok := inst.Fields["a"]; ok && access == 0 {
+		return fmt.Sprintf("0x%02X", f)
+	}
+	if name, ok := d.sfrNames[f]; ok && (isSharedRAM(d.mcConfig, f) || (!d.movlb && coreMirroredRegisters[f])) {
+		return name
+	}
+	if bank.known != d.bankMask {
+		return fmt.Sprintf("0x%02X", f)
+	}
+	addr := bank.value*d.bankSize + f
+	if name, ok := d.sfrNames[addr]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%02X", addr)
+}
+
+// operands renders the operands of an instruction at addr.
+func (d *disassembler) operands(addr int, inst decodedInstruction, bank bankState) string {
+	operands := make([]string, 0, len(inst.Info.Operands))
+	for _, kind := range inst.Info.Operands {
+		value := inst.Fields[kind]
+		switch kind {
+		case "f":
+			operands = append(operands, d.registerOperand(inst, bank))
+		case "d":
+			operands = append(operands, map[int]string{0: "W", 1: "F"}[value])
+		case "a":
+			operands = append(operands, map[int]string{0: "ACCESS", 1: "BANKED"}[value])
+		case "b":
+			operands = append(operands, fmt.Sprint(value))
+		case "k11":
+			target := d.targets[addr]
+			if label, ok := d.labels[target]; ok {
+				operands = append(operands, label)
+			} else {
+				operands = append(operands, fmt.Sprintf("0x%03X", target))
+			}
+		default:
+			operands = append(operands, fmt.Sprintf("0x%02X", value))
+		}
+	}
+	return strings.Join(operands, ", ")
+}
+
+// tableRun returns how many RETLW instructions follow from the i-th address without a gap
+// or a label after the first.
+func (d *disassembler) tableRun(i int) int {
+	n := 0
+	for ; i+n < len(d.addrs); n++ {
+		addr := d.addrs[i+n]
+		if inst, ok := d.code[addr]; !ok || inst.Opcode != "RETLW" || addr != d.addrs[i]+n {
+			break
+		}
+		if _, labeled := d.labels[addr]; labeled && n > 0 {
+			break
+		}
+	}
+	return n
+}
+
+// dtElements renders table values as DT operands, with runs of printable characters as
+// strings.
+func dtElements(values []int) string {
+	printable := func(v int) bool { return v >= 0x20 && v < 0x7F }
+	var elements []string
+	for i := 0; i < len(values); {
+		j := i
+		for j < len(values) && printable(values[j]) {
+			j++
+		}
+		if j-i >= 3 {
+			var sb strings.Builder
+			for _, v := range values[i:j] {
+				if v == '"' || v == '\\' {
+					sb.WriteByte('\\')
+				}
+				sb.WriteByte(byte(v))
+			}
+			elements = append(elements, `"`+sb.String()+`"`)
+			i = j
+			continue
+		}
+		elements = append(elements, fmt.Sprintf("0x%02X", values[i]))
+		i++
+	}
+	return strings.Join(elements, ", ")
+}
+
+// writeLine writes one statement with the address it came from as a comment.
+func writeLine(sb *strings.Builder, addr int, opcode, operands string) {
+	fmt.Fprintf(sb, "        %-31s ; 0x%04X\n", fmt.Sprintf("%-8s%s", opcode, operands), addr)
+}
+
+// bankStates follows the selected bank from the reset and interrupt vectors along fall
+// through, skips, jumps and calls to a fixed point, giving what is known of it before each
+// instruction. Code that is only reached through computed jumps starts from an unknown bank.
+func (d *disassembler) bankStates() map[int]bankState {
+	in := make(map[int]bankState)
+	var work []int
+	enter := func(addr int, s bankState) {
+		if _, ok := d.code[addr]; !ok {
+			return
+		}
+		if merged := in[addr].merge(s); merged != in[addr] {
+			in[addr] = merged
+			work = append(work, addr)
+		}
+	}
+	drain := func() {
+		for len(work) > 0 {
+			addr := work[len(work)-1]
+			work = work[:len(work)-1]
+			inst, before := d.code[addr], in[addr]
+			after := d.bankTransfer(before, inst)
+			if target, ok := d.targets[addr]; ok {
+				if inst.Opcode == "CALL" {
+					enter(target, before)
+				} else {
+					enter(target, after)
+				}
+			}
+			if isSkipInstruction(inst.Opcode) {
+				enter(addr+2, after)
+			}
+			if !inst.endsFlow() {
+				enter(addr+1, after)
+			}
+		}
+	}
+
+	enter(resetVector, bankState{reached: true, known: d.bankMask})
+	enter(interruptVector, d.unknown)
+	drain()
+	for _, addr := range d.addrs {
+		if !in[addr].reached {
+			enter(addr, d.unknown)
+			drain()
+		}
+	}
+	return in
+}
+
+// writeCode writes the program memory: ORG at every gap, labels, instructions, DT tables and
+// DW for words that are no instruction.
+func (d *disassembler) writeCode(sb *strings.Builder) {
+	banks, next := d.bankStates(), -1
+	for i := 0; i < len(d.addrs); {
+		addr := d.addrs[i]
+		if addr != next {
+			fmt.Fprintf(sb, "\n        ORG     0x%03X\n", addr)
+		}
+		if label, ok := d.labels[addr]; ok {
+			fmt.Fprintf(sb, "%s:\n", label)
+		}
+		if n := d.tableRun(i); n >= minTableLength {
+			for start := 0; start < n; start += dataLineLength {
+				values := make([]int, 0, dataLineLength)
+				for k := start; k < min(start+dataLineLength, n); k++ {
+					values = append(values, d.code[addr+k].Fields["k8"])
+				}
+				writeLine(sb, addr+start, "DT", dtElements(values))
+			}
+			i, next = i+n, addr+n
+			continue
+		}
+
+		if inst, ok := d.code[addr]; ok {
+			writeLine(sb, addr, inst.Opcode, d.operands(addr, inst, banks[addr]))
+		} else {
+			writeLine(sb, addr, "DW", fmt.Sprintf("0x%04X", d.words[addr]))
+		}
+		i, next = i+1, addr+1
+	}
+}
+
+// configLine returns the __CONFIG line of a configuration word, naming the option of each
+// fuse group. ok is false when no options give back the word, which is then written raw.
+func (d *disassembler) configLine(name string, value int) (string, bool) {
+	fuseMap, hasMap := configWordFuseMap(d.mcConfig, name)
+	info := d.mcConfig.ConfigWordDefaults[name]
+	if !hasMap {
+		return "", false
+	}
+	groups := make([]string, 0, len(fuseMap))
+	for group := range fuseMap {
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		mi, mj := fuseMap[groups[i]].Mask, fuseMap[groups[j]].Mask
+		return mi&-mi < mj&-mj || (mi&-mi == mj&-mj && groups[i] < groups[j])
+	})
+
+	rebuilt := info.DefaultValue
+	var options []string
+	for _, group := range groups {
+		groupInfo, chosen := fuseMap[group], ""
+		for option, bits := range groupInfo.Values {
+			if bits&groupInfo.Mask == value&groupInfo.Mask && (chosen == "" || option < chosen) {
+				chosen = option
+			}
+		}
+		if chosen == "" {
+			return "", false
+		}
+		rebuilt = rebuilt&^groupInfo.Mask | groupInfo.Values[chosen]
+		options = append(options, chosen)
+	}
+	wordMask := (1 << d.mcConfig.ProgramWordSizeBits) - 1
+	if (rebuilt&wordMask|info.Padding)&wordMask != value&wordMask {
+		return "", false
+	}
+	if len(d.mcConfig.ConfigWordDefaults) > 1 {
+		return fmt.Sprintf("__CONFIG _%s, %s", name, strings.Join(options, " & ")), true
+	}
+	return "__CONFIG " + strings.Join(options, " & "), true
+}
+
+// writeConfig writes the configuration words the HEX file holds.
+func (d *disassembler) writeConfig(sb *strings.Builder) {
+	names := make([]string, 0, len(d.mcConfig.ConfigWordDefaults))
+	for name := range d.mcConfig.ConfigWordDefaults {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return d.mcConfig.ConfigWordDefaults[names[i]].Address < d.mcConfig.ConfigWordDefaults[names[j]].Address
+	})
+	for _, name := range names {
+		addr := d.mcConfig.ConfigWordDefaults[name].Address
+		value, ok := d.mcConfig.hexValueAt(d.memory, addr)
+		if !ok {
+			continue
+		}
+		if line, ok := d.configLine(name, value); ok {
+			fmt.Fprintf(sb, "        %s\n", line)
+			continue
+		}
+		fmt.Fprintf(sb, "        ORG     0x%04X                  ; %s\n", addr, name)
+		fmt.Fprintf(sb, "        DW      0x%04X\n", value)
+	}
+}
+
+// writeRange writes the values a HEX file holds in a range beyond program memory with ORG
+// and a data directive, one ORG per run of consecutive addresses.
+func (d *disassembler) writeRange(sb *strings.Builder, r *RAMRange, directive, format string) {
+	if r == nil {
+		return
+	}
+	var values []int
+	flush := func(end int) {
+		start := end - len(values)
+		if len(values) == 0 {
+			return
+		}
+		fmt.Fprintf(sb, "\n        ORG     0x%04X\n", start)
+		for i := 0; i < len(values); i += dataLineLength {
+			elements := make([]string, 0, dataLineLength)
+			for _, v := range values[i:min(i+dataLineLength, len(values))] {
+				elements = append(elements, fmt.Sprintf(format, v))
+			}
+			writeLine(sb, start+i, directive, strings.Join(elements, ", "))
+		}
+		values = nil
+	}
+	for addr := r.Start; addr <= r.End; addr++ {
+		value, ok := d.mcConfig.hexValueAt(d.memory, addr)
+		if !ok {
+			flush(addr)
+			continue
+		}
+		values = append(values, value)
+	}
+	flush(r.End + 1)
+}
+
+// source renders the disassembly of a HEX file as assembly source.
+func (d *disassembler) source(hexName string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "; Disassembly of %s for %s\n\n", hexName, d.mcConfig.Name)
+	d.writeConfig(&sb)
+	d.writeCode(&sb)
+	d.writeRange(&sb, d.mcConfig.IDLocations, "DW", "0x%04X")
+	d.writeRange(&sb, d.mcConfig.EEPROM, "DE", "0x%02X")
+	sb.WriteString("\n        END\n")
+	return sb.String()
+}
+
+// runDisasm disassembles a HEX file into assembly source.
+func runDisasm(args []string) error {
+	fs := flag.NewFlagSet("disasm", flag.ExitOnError)
+	hexFile := fs.String("hex", "", "Path to the HEX file to disassemble (required)")
+	mcu := fs.String("mcu", "", "Target microcontroller name, e.g., 'PIC16F687' (required)")
+	configDir := fs.String("config-dir", "./configs", "Directory containing microcontroller JSON config files")
+	outPath := fs.String("o", StdioPath, "Output assembly file ('-' for stdout)")
+	fs.Parse(args)
+
+	if *hexFile == "" || *mcu == "" {
+		fs.Usage()
+		return fmt.Errorf("-hex and -mcu flags are required")
+	}
+
+	mcConfig, err := loadMicrocontrollerConfigByName(*configDir, *mcu)
+	if err != nil {
+		return err
+	}
+	content, err := os.ReadFile(*hexFile)
+	if err != nil {
+		return fmt.Errorf("could not read HEX file '%s': %w", *hexFile, err)
+	}
+	memory, err := parseIntelHex(string(content))
+	if err != nil {
+		return fmt.Errorf("could not parse '%s': %w", *hexFile, err)
+	}
+
+	source := newDisassembler(mcConfig, memory).source(*hexFile)
+	if err := writeOutputFile(*outPath, source); err != nil {
+		return err
+	}
+	if *outPath != StdioPath {
+		fmt.Printf("Disassembly written to %s\n", *outPath)
+	}
+	return nil
+}
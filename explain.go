@@ -0,0 +1,223 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// --- Instruction Reference ---
+
+func init() {
+	registerSubcommand("explain", "Describe the encoding, operands, flags and cycles of instructions", runExplain)
+}
+
+// operandDescription describes the values an operand kind takes, given the width of its field.
+func operandDescription(kind string, bits int) string {
+	switch kind {
+	case "f":
+		return fmt.Sprintf("file register, 0x00-0x%02X within the selected bank", 1<<bits-1)
+	case "d":
+		return "destination, W (0) or F (1)"
+	case "a":
+		return "RAM access, ACCESS (0) or BANKED (1)"
+	case "b":
+		return fmt.Sprintf("bit number, 0-%d", 1<<bits-1)
+	case "k8":
+		return fmt.Sprintf("literal, 0x00-0x%02X", 1<<bits-1)
+	case "k11":
+		return fmt.Sprintf("program address, 0x000-0x%03X within the selected page", 1<<bits-1)
+	}
+	return kind
+}
+
+// exampleOperand is the operand an encoding example uses for an operand kind.
+func exampleOperand(kind string, bitInstruction bool, mcConfig *MicrocontrollerConfig) string {
+	switch kind {
+	case "f":
+		if _, ok := mcConfig.SFRMap["STATUS"]; ok && bitInstruction {
+			return "STATUS"
+		}
+		return "0x20"
+	case "d":
+		return "F"
+	case "a":
+		return "ACCESS"
+	case "b":
+		return "2"
+	case "k8":
+		return "0x2A"
+	case "k11":
+		return fmt.Sprintf("0x%03X", 0x123%max(mcConfig.ProgramMemorySize, 1))
+	}
+	return "0"
+}
+
+// groupBits splits a pattern or binary word into groups of four from the right, as data
+// sheets print opcodes.
+func groupBits(bits string) string {
+	var groups []string
+	for len(bits) > 4 {
+		groups = append([]string{bits[len(bits)-4:]}, groups...)
+		bits = bits[:len(bits)-4]
+	}
+	return strings.Join(append([]string{bits}, groups...), " ")
+}
+
+// explainInstruction returns the reference text of one instruction of the device: its
+// opcode pattern, operands, an encoded example, the STATUS flags it changes and its cycles.
+func explainInstruction(mcConfig *MicrocontrollerConfig, name string) (string, error) {
+	name = strings.ToUpper(name)
+	var sb strings.Builder
+	for alias, target := range mcConfig.Aliases {
+		if strings.EqualFold(alias, name) {
+			fmt.Fprintf(&sb, "%s is an alias of %s\n\n", name, strings.ToUpper(target))
+			name = strings.ToUpper(target)
+			break
+		}
+	}
+	for op, pseudo := range mcConfig.PseudoOps {
+		if strings.EqualFold(op, name) {
+			fmt.Fprintf(&sb, "%s %s is a pseudo-op of %s that expands to:\n\n", name, strings.Join(pseudo.Params, ", "), mcConfig.Name)
+			for _, line := range pseudo.Body {
+				fmt.Fprintf(&sb, "        %s\n", line)
+			}
+			return sb.String(), nil
+		}
+	}
+	info, ok := mcConfig.InstructionSet[name]
+	if !ok {
+		return "", fmt.Errorf("'%s' is not an instruction, alias or pseudo-op of %s", name, mcConfig.Name)
+	}
+
+	fmt.Fprintf(&sb, "%s on %s\n\n", name, mcConfig.Name)
+	fmt.Fprintf(&sb, "  Encoding      %s  (%d bits)\n", groupBits(info.OpcodePattern), len(info.OpcodePattern))
+	operands := make([]string, len(info.Operands))
+	for k, kind := range info.Operands {
+		bits := strings.Count(info.OpcodePattern, string(operandPlaceholders[kind].letter))
+		label := "Operands"
+		if k > 0 {
+			label = ""
+		}
+		fmt.Fprintf(&sb, "  %-12s  %-3s %s\n", label, kind, operandDescription(kind, bits))
+		operands[k] = exampleOperand(kind, strings.Contains(info.OpcodePattern, "b"), mcConfig)
+	}
+	if len(info.Operands) == 0 {
+		fmt.Fprintf(&sb, "  Operands      none\n")
+	}
+
+	example := &Instruction{Opcode: name, Operands: operands}
+	text := strings.TrimSpace(name + " " + strings.Join(operands, ", "))
+	if assembler, _, err := assembleSource("        ORG 0\n        "+text+"\n        END\n", mcConfig, nil); err == nil {
+		word := assembler.machineCodeWords[0]
+		fmt.Fprintf(&sb, "  Example       %s  ->  0x%04X  (%s)\n", text, word, groupBits(fmt.Sprintf("%0*b", len(info.OpcodePattern), word)))
+	} else {
+		fmt.Fprintf(&sb, "  Example       %s does not assemble: %v\n", text, err)
+	}
+
+	var flags []string
+	for bit := 0; bit < 3; bit++ {
+		if resultFlags(name)&(1<<bit) != 0 {
+			flags = append(flags, statusFlags[bit])
+		}
+	}
+	switch {
+	case name == "CLRWDT" || name == "SLEEP":
+		flags = append(flags, "TO", "PD")
+	case len(flags) == 0:
+		flags = append(flags, "none")
+	}
+	fmt.Fprintf(&sb, "  Status flags  %s\n", strings.Join(flags, ", "))
+
+	least, most := opcodeCycles(name)
+	cycles := fmt.Sprint(least)
+	switch {
+	case least != most:
+		cycles += fmt.Sprintf(", %d when it skips", most)
+	case most == 1 && len(info.Operands) > 0 && info.Operands[0] == "f" && writesFileRegister(example, info):
+		cycles += ", 2 when it writes PCL"
+	}
+	fmt.Fprintf(&sb, "  Cycles        %s\n", cycles)
+	return sb.String(), nil
+}
+
+// explainSummary returns one line per instruction of the device: its operands, opcode pattern
+// and cycles.
+func explainSummary(mcConfig *MicrocontrollerConfig) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d instructions on %s\n\n", len(mcConfig.InstructionSet), mcConfig.Name)
+	for _, name := range sortedInstructionNames(mcConfig) {
+		info := mcConfig.InstructionSet[name]
+		least, most := opcodeCycles(name)
+		cycles := fmt.Sprint(least)
+		if least != most {
+			cycles = fmt.Sprintf("%d-%d", least, most)
+		}
+		fmt.Fprintf(&sb, "  %-8s %-10s %-20s %s\n", name, strings.Join(info.Operands, ", "), groupBits(info.OpcodePattern), cycles)
+	}
+	aliases := make([]string, 0, len(mcConfig.Aliases)+len(mcConfig.PseudoOps))
+	for alias, target := range mcConfig.Aliases {
+		aliases = append(aliases, strings.ToUpper(alias)+" -> "+strings.ToUpper(target))
+	}
+	for op := range mcConfig.PseudoOps {
+		aliases = append(aliases, strings.ToUpper(op)+" (pseudo-op)")
+	}
+	sort.Strings(aliases)
+	if len(aliases) > 0 {
+		fmt.Fprintf(&sb, "\nAliases and pseudo-ops: %s\n", strings.Join(aliases, ", "))
+	}
+	return sb.String()
+}
+
+// runExplain prints the reference of the instructions named, or a summary of them all.
+func runExplain(args []string) error {
+	fs := flag.NewFlagSet("explain", flag.ExitOnError)
+	mcu := fs.String("mcu", "", "Target microcontroller name, e.g., 'PIC16F687' (required)")
+	configDir := fs.String("config-dir", "./configs", "Directory containing microcontroller JSON config files")
+	isaFile := fs.String("isa-overlay", "", "Path to a JSON file of INSTRUCTION_SET entries added to or replacing those of the device config")
+	aliasFile := fs.String("aliases", "", "Path to a JSON file of INSTRUCTION_ALIASES/PSEUDO_OPS merged over the device config")
+	fs.Parse(args)
+
+	// Instructions may come before the flags, as in `asm4pic explain BTFSS -mcu PIC16F687`
+	var names []string
+	for fs.NArg() > 0 {
+		names = append(names, fs.Arg(0))
+		fs.Parse(fs.Args()[1:])
+	}
+	if *mcu == "" {
+		fs.Usage()
+		return fmt.Errorf("-mcu is required")
+	}
+
+	mcConfig, err := loadMicrocontrollerConfigByName(*configDir, *mcu)
+	if err != nil {
+		return err
+	}
+	if *isaFile != "" {
+		if err := loadISAOverlay(*isaFile, mcConfig); err != nil {
+			return err
+		}
+	}
+	if *aliasFile != "" {
+		if err := loadAliasOverlay(*aliasFile, mcConfig); err != nil {
+			return err
+		}
+	}
+
+	if len(names) == 0 {
+		fmt.Print(explainSummary(mcConfig))
+		return nil
+	}
+	for k, name := range names {
+		text, err := explainInstruction(mcConfig, name)
+		if err != nil {
+			return err
+		}
+		if k > 0 {
+			fmt.Println()
+		}
+		fmt.Print(text)
+	}
+	return nil
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// --- Project Export ---
+
+func init() {
+	registerSubcommand("export", "Export the project as a Makefile or MPLAB X project", runExport)
+}
+
+// exportedPaths holds the project paths rewritten relative to the export directory.
+type exportedPaths struct {
+	Source    string
+	ConfigDir string
+	Hex       string
+	Report    string
+}
+
+// relativePath rewrites a project path relative to dir, using forward slashes.
+func relativePath(project *ProjectFile, dir, rel string) (string, error) {
+	if rel == "" {
+		return "", nil
+	}
+	absTarget, err := filepath.Abs(project.Path(rel))
+	if err != nil {
+		return "", err
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	path, err := filepath.Rel(absDir, absTarget)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(path), nil
+}
+
+// exportPaths rewrites all project paths relative to dir.
+func exportPaths(project *ProjectFile, dir string) (*exportedPaths, error) {
+	var paths exportedPaths
+	var err error
+	for _, p := range []struct {
+		dst *string
+		src string
+	}{
+		{&paths.Source, project.Source},
+		{&paths.ConfigDir, project.ConfigDir},
+		{&paths.Hex, project.Hex},
+		{&paths.Report, project.Report},
+	} {
+		if *p.dst, err = relativePath(project, dir, p.src); err != nil {
+			return nil, err
+		}
+	}
+	return &paths, nil
+}
+
+// asm4picCommand builds the asm4pic invocation for a project, prefixed by the tool name.
+func asm4picCommand(tool, mcu string, paths *exportedPaths) string {
+	cmd := fmt.Sprintf("%s -asm %s -mcu %s -config-dir %s -hex %s", tool, paths.Source, mcu, paths.ConfigDir, paths.Hex)
+	if paths.Report != "" {
+		cmd += " -report " + paths.Report
+	}
+	return cmd
+}
+
+// generateMakefile produces a standalone Makefile that builds the project with asm4pic.
+func generateMakefile(project *ProjectFile, paths *exportedPaths) string {
+	var sb strings.Builder
+	sb.WriteString("# Generated by asm4pic export. Builds the project with asm4pic.\n\n")
+	sb.WriteString("ASM4PIC ?= asm4pic\n")
+	fmt.Fprintf(&sb, "MCU = %s\n", project.MCU)
+	fmt.Fprintf(&sb, "SOURCE = %s\n", paths.Source)
+	fmt.Fprintf(&sb, "CONFIG_DIR = %s\n", paths.ConfigDir)
+	fmt.Fprintf(&sb, "HEX = %s\n", paths.Hex)
+	if paths.Report != "" {
+		fmt.Fprintf(&sb, "REPORT = %s\n", paths.Report)
+	}
+
+	vars := &exportedPaths{Source: "$(SOURCE)", ConfigDir: "$(CONFIG_DIR)", Hex: "$(HEX)"}
+	if paths.Report != "" {
+		vars.Report = "$(REPORT)"
+	}
+	sb.WriteString("\nall: $(HEX)\n\n")
+	sb.WriteString("$(HEX): $(SOURCE)\n")
+	fmt.Fprintf(&sb, "\t%s\n\n", asm4picCommand("$(ASM4PIC)", "$(MCU)", vars))
+	sb.WriteString("clean:\n")
+	if paths.Report != "" {
+		sb.WriteString("\trm -f $(HEX) $(REPORT)\n\n")
+	} else {
+		sb.WriteString("\trm -f $(HEX)\n\n")
+	}
+	sb.WriteString(".PHONY: all clean\n")
+	return sb.String()
+}
+
+// xmlText escapes a string for use as XML character data.
+func xmlText(s string) string {
+	var sb strings.Builder
+	xml.EscapeText(&sb, []byte(s))
+	return sb.String()
+}
+
+// generateMPLABXProjectXML produces nbproject/project.xml for an MPLAB X project.
+func generateMPLABXProjectXML(project *ProjectFile) string {
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<project xmlns="http://www.netbeans.org/ns/project/1">
+    <type>com.microchip.mplab.nbide.embedded.makeproject</type>
+    <configuration>
+        <data xmlns="http://www.netbeans.org/ns/make-project/1">
+            <name>` + xmlText(project.Name) + `</name>
+            <make-project-type>0</make-project-type>
+            <c-extensions/>
+            <cpp-extensions/>
+            <header-extensions/>
+            <asminc-extensions>inc</asminc-extensions>
+            <sourceEncoding>ISO-8859-1</sourceEncoding>
+            <make-dep-projects/>
+        </data>
+    </configuration>
+</project>
+`
+}
+
+// generateMPLABXConfigurations produces nbproject/configurations.xml. The project targets MPASM so
+// the IDE can build natively, while a pre-build step runs asm4pic and its HEX is used for programming.
+func generateMPLABXConfigurations(project *ProjectFile, paths *exportedPaths) string {
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<configurationDescriptor version="65">
+  <logicalFolder name="root" displayName="root" projectFiles="true">
+    <logicalFolder name="SourceFiles" displayName="Source Files" projectFiles="true">
+      <itemPath>` + xmlText(paths.Source) + `</itemPath>
+    </logicalFolder>
+    <logicalFolder name="ExternalFiles" displayName="Important Files" projectFiles="false">
+      <itemPath>Makefile</itemPath>
+    </logicalFolder>
+  </logicalFolder>
+  <sourceRootList>
+    <Elem>` + xmlText(filepath.ToSlash(filepath.Dir(paths.Source))) + `</Elem>
+  </sourceRootList>
+  <projectmakefile>Makefile</projectmakefile>
+  <confs>
+    <conf name="default" type="2">
+      <toolsSet>
+        <developmentServer>localhost</developmentServer>
+        <targetDevice>` + xmlText(strings.ToUpper(project.MCU)) + `</targetDevice>
+        <targetHeader></targetHeader>
+        <targetPluginBoard></targetPluginBoard>
+        <platformTool>PICkit3PlatformTool</platformTool>
+        <languageToolchain>MPASMWIN</languageToolchain>
+        <languageToolchainVersion></languageToolchainVersion>
+        <platform>3</platform>
+      </toolsSet>
+      <compileType>
+        <linkerTool>
+          <linkerLibItems>
+          </linkerLibItems>
+        </linkerTool>
+        <archiverTool>
+        </archiverTool>
+        <loading>
+          <useAlternateLoadableFile>true</useAlternateLoadableFile>
+          <parseOnProdLoad>false</parseOnProdLoad>
+          <alternateLoadableFile>` + xmlText(paths.Hex) + `</alternateLoadableFile>
+        </loading>
+      </compileType>
+      <makeCustomizationType>
+        <makeCustomizationPreStepEnabled>true</makeCustomizationPreStepEnabled>
+        <makeCustomizationPreStep>` + xmlText(asm4picCommand("asm4pic", project.MCU, paths)) + `</makeCustomizationPreStep>
+        <makeCustomizationPostStepEnabled>false</makeCustomizationPostStepEnabled>
+        <makeCustomizationPostStep></makeCustomizationPostStep>
+        <makeCustomizationPutChecksumInUserID>false</makeCustomizationPutChecksumInUserID>
+        <makeCustomizationEnableLongLines>false</makeCustomizationEnableLongLines>
+        <makeCustomizationNormalizeHexFile>false</makeCustomizationNormalizeHexFile>
+      </makeCustomizationType>
+      <MPASMWIN>
+        <property key="absolute-mode" value="true"/>
+      </MPASMWIN>
+    </conf>
+  </confs>
+</configurationDescriptor>
+`
+}
+
+// mplabxMakefile is the top-level Makefile MPLAB X expects; the IDE regenerates the included files.
+const mplabxMakefile = `# Top-level MPLAB X Makefile. The nbproject/Makefile-*.mk files are generated by the IDE.
+
+SUB_IMAKEFILES=
+MKDIR=mkdir -p
+RM=rm -f
+MV=mv
+CP=cp
+
+build: .build-post
+
+.build-pre:
+
+.build-post: .build-impl
+
+clean: .clean-post
+
+.clean-pre:
+
+.clean-post: .clean-impl
+
+include nbproject/Makefile-impl.mk
+include nbproject/Makefile-variables.mk
+`
+
+// writeExportFile writes one generated file, creating its directory first.
+func writeExportFile(path, content string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s\n", path)
+	return nil
+}
+
+// runExport generates a Makefile or MPLAB X project from an asm4pic.json project file.
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	projectPath := fs.String("project", defaultProjectFileName, "Path to the asm4pic.json project file")
+	format := fs.String("format", "makefile", "Export format: 'makefile' or 'mplabx'")
+	outDir := fs.String("o", "", "Output directory (defaults to the project directory)")
+	fs.Parse(args)
+
+	project, err := loadProjectFile(*projectPath)
+	if err != nil {
+		return err
+	}
+	dir := *outDir
+	if dir == "" {
+		dir = project.Dir
+	}
+
+	switch *format {
+	case "makefile":
+		paths, err := exportPaths(project, dir)
+		if err != nil {
+			return err
+		}
+		return writeExportFile(filepath.Join(dir, "Makefile"), generateMakefile(project, paths))
+	case "mplabx":
+		projectDir := filepath.Join(dir, project.Name+".X")
+		paths, err := exportPaths(project, projectDir)
+		if err != nil {
+			return err
+		}
+		files := []struct{ name, content string }{
+			{"Makefile", mplabxMakefile},
+			{filepath.Join("nbproject", "project.xml"), generateMPLABXProjectXML(project)},
+			{filepath.Join("nbproject", "configurations.xml"), generateMPLABXConfigurations(project, paths)},
+		}
+		for _, f := range files {
+			if err := writeExportFile(filepath.Join(projectDir, f.name), f.content); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown -format '%s' (expected 'makefile' or 'mplabx')", *format)
+	}
+}
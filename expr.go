@@ -0,0 +1,443 @@
+package main
+
+import (
+	"fmt"
+	"math/bits"
+	"strings"
+)
+
+// --- Expressions ---
+
+// exprLookup resolves a symbol used in an expression.
+type exprLookup func(name string) (int, bool)
+
+// exprScope answers the predicates DEFINED(name), ISLABEL(name) and PASS() for the code
+// being assembled.
+type exprScope struct {
+	defined func(name string) bool // symbol, label or #DEFINE
+	isLabel func(name string) bool
+	pass    int // 1 while laying out the code, 2 while generating it; 0 outside the passes
+}
+
+// evalExpression evaluates an integer expression. Operands are numeric literals and symbols
+// resolved through lookup; operators follow C precedence (unary - + ~ !, * / %, + -, << >>,
+// < <= > >=, == !=, &, ^, |, &&, ||) with parentheses, plus the unary HIGH, LOW and UPPER byte selectors and the
+// functions of exprFunctions. DEFINED(name) is true when lookup resolves the name.
+func evalExpression(expression string, lookup exprLookup) (int, error) {
+	return evalScopedExpression(expression, lookup, nil)
+}
+
+// evalScopedExpression evaluates an integer expression like evalExpression, answering the
+// predicates from scope when it is not nil.
+func evalScopedExpression(expression string, lookup exprLookup, scope *exprScope) (int, error) {
+	e := &exprParser{src: expression, lookup: lookup, scope: scope}
+	val, err := e.parseBinary(0)
+	if err != nil {
+		return 0, err
+	}
+	e.skipSpace()
+	if e.pos < len(e.src) {
+		return 0, trErrorf("unexpected '%s'", e.src[e.pos:])
+	}
+	return val, nil
+}
+
+// symbolLookup resolves EQU symbols from their source text, evaluating values that refer to
+// other symbols, and passes any other name to fallback (which may be nil).
+func symbolLookup(symbols map[string]string, fallback exprLookup) exprLookup {
+	resolving := make(map[string]bool)
+	var lookup exprLookup
+	lookup = func(name string) (int, bool) {
+		text, ok := symbols[name]
+		if !ok {
+			if fallback == nil {
+				return 0, false
+			}
+			return fallback(name)
+		}
+		if resolving[name] {
+			return 0, false // circular definition
+		}
+		resolving[name] = true
+		defer delete(resolving, name)
+		val, err := evalExpression(text, lookup)
+		return val, err == nil
+	}
+	return lookup
+}
+
+// exprParser is a recursive descent parser over one expression.
+type exprParser struct {
+	src    string
+	pos    int
+	lookup exprLookup
+	scope  *exprScope
+	// skipping is set for the right operand of && or || when the left one decides the result:
+	// undefined symbols there read as 0, so DEFINED(X) && X > 1 works when X is not defined.
+	skipping bool
+}
+
+// exprBinaryOperators lists the binary operators from lowest to highest precedence.
+var exprBinaryOperators = [][]string{
+	{"||"},
+	{"&&"},
+	{"|"},
+	{"^"},
+	{"&"},
+	{"==", "!="},
+	{"<=", ">=", "<", ">"},
+	{"<<", ">>"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (e *exprParser) skipSpace() {
+	for e.pos < len(e.src) && (e.src[e.pos] == ' ' || e.src[e.pos] == '\t') {
+		e.pos++
+	}
+}
+
+// operator consumes one of ops at the current position and returns it, or "". & and | do
+// not match the first half of && and ||.
+func (e *exprParser) operator(ops []string) string {
+	e.skipSpace()
+	for _, op := range ops {
+		if (op == "&" || op == "|") && strings.HasPrefix(e.src[e.pos:], op+op) {
+			continue
+		}
+		if strings.HasPrefix(e.src[e.pos:], op) {
+			e.pos += len(op)
+			return op
+		}
+	}
+	return ""
+}
+
+// parseBinary parses the operators of the given precedence level and above.
+func (e *exprParser) parseBinary(level int) (int, error) {
+	if level == len(exprBinaryOperators) {
+		return e.parseUnary()
+	}
+	left, err := e.parseBinary(level + 1)
+	if err != nil {
+		return 0, err
+	}
+	for {
+		op := e.operator(exprBinaryOperators[level])
+		if op == "" {
+			return left, nil
+		}
+		skipping := e.skipping
+		e.skipping = skipping || op == "&&" && left == 0 || op == "||" && left != 0
+		right, err := e.parseBinary(level + 1)
+		e.skipping = skipping
+		if err != nil {
+			return 0, err
+		}
+		switch op {
+		case "||":
+			left = exprBool(left != 0 || right != 0)
+		case "&&":
+			left = exprBool(left != 0 && right != 0)
+		case "==":
+			left = exprBool(left == right)
+		case "!=":
+			left = exprBool(left != right)
+		case "<":
+			left = exprBool(left < right)
+		case "<=":
+			left = exprBool(left <= right)
+		case ">":
+			left = exprBool(left > right)
+		case ">=":
+			left = exprBool(left >= right)
+		case "|":
+			left |= right
+		case "^":
+			left ^= right
+		case "&":
+			left &= right
+		case "<<":
+			left <<= uint(right)
+		case ">>":
+			left >>= uint(right)
+		case "+":
+			left += right
+		case "-":
+			left -= right
+		case "*":
+			left *= right
+		case "/", "%":
+			if right == 0 && e.skipping {
+				left = 0
+				continue
+			}
+			if right == 0 {
+				return 0, trErrorf("division by zero")
+			}
+			if op == "/" {
+				left /= right
+			} else {
+				left %= right
+			}
+		}
+	}
+}
+
+// exprBool is the value of a comparison or logical operator: 1 for true and 0 for false.
+func exprBool(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func (e *exprParser) parseUnary() (int, error) {
+	if op := e.operator([]string{"-", "+", "~", "!"}); op != "" {
+		val, err := e.parseUnary()
+		if err != nil {
+			return 0, err
+		}
+		switch op {
+		case "-":
+			return -val, nil
+		case "~":
+			return ^val, nil
+		case "!":
+			if val == 0 {
+				return 1, nil
+			}
+			return 0, nil
+		}
+		return val, nil
+	}
+	return e.parsePrimary()
+}
+
+func (e *exprParser) parsePrimary() (int, error) {
+	e.skipSpace()
+	if e.pos >= len(e.src) {
+		return 0, trErrorf("missing operand")
+	}
+	c := e.src[e.pos]
+	switch {
+	case c == '(':
+		e.pos++
+		val, err := e.parseBinary(0)
+		if err != nil {
+			return 0, err
+		}
+		if e.operator([]string{")"}) == "" {
+			return 0, trErrorf("missing ')'")
+		}
+		return val, nil
+	case c >= '0' && c <= '9' || c == '$' || c == '%':
+		start := e.pos
+		e.pos++
+		for e.pos < len(e.src) && isIdentifierChar(e.src[e.pos]) {
+			e.pos++
+		}
+		literal := e.src[start:e.pos]
+		if len(literal) < 2 && c != '$' && !(c >= '0' && c <= '9') {
+			return 0, trErrorf("invalid number '%s'", literal)
+		}
+		val, ok, err := parseNumericLiteral(literal)
+		if !ok || err != nil {
+			return 0, trErrorf("invalid number '%s'", literal)
+		}
+		return val, nil
+	case isIdentifierChar(c):
+		start := e.pos
+		for e.pos < len(e.src) && isIdentifierChar(e.src[e.pos]) {
+			e.pos++
+		}
+		name := e.src[start:e.pos]
+		switch strings.ToUpper(name) {
+		case "HIGH", "LOW", "UPPER":
+			val, err := e.parseUnary()
+			if err != nil {
+				return 0, err
+			}
+			return selectByte(strings.ToUpper(name), val), nil
+		}
+		if isExprPredicate(name) && e.operator([]string{"("}) != "" {
+			return e.parsePredicate(strings.ToUpper(name))
+		}
+		if fn, ok := exprFunctions[strings.ToUpper(name)]; ok && e.operator([]string{"("}) != "" {
+			args, err := e.parseArguments()
+			if err != nil {
+				return 0, err
+			}
+			if len(args) < fn.minArgs || (fn.maxArgs > 0 && len(args) > fn.maxArgs) {
+				return 0, trErrorf("%s takes %s", strings.ToUpper(name), fn.arity())
+			}
+			val, err := fn.eval(args)
+			if err != nil {
+				return 0, fmt.Errorf("%s: %v", strings.ToUpper(name), err)
+			}
+			return val, nil
+		}
+		if val, ok := e.lookup(name); ok || e.skipping {
+			return val, nil
+		}
+		return 0, trErrorf("undefined symbol '%s'", name)
+	}
+	return 0, trErrorf("unexpected '%s'", e.src[e.pos:])
+}
+
+// parseArguments parses the comma-separated arguments of a function call up to and
+// including the closing parenthesis.
+func (e *exprParser) parseArguments() ([]int, error) {
+	var args []int
+	for {
+		val, err := e.parseBinary(0)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, val)
+		switch e.operator([]string{",", ")"}) {
+		case ",":
+			continue
+		case ")":
+			return args, nil
+		}
+		return nil, trErrorf("missing ')'")
+	}
+}
+
+// isExprPredicate reports whether name is one of the predicates DEFINED, ISLABEL and PASS,
+// which look at symbols rather than at values.
+func isExprPredicate(name string) bool {
+	switch strings.ToUpper(name) {
+	case "DEFINED", "ISLABEL", "PASS":
+		return true
+	}
+	return false
+}
+
+// parsePredicate parses the arguments of a predicate up to and including the closing
+// parenthesis and returns 1 for true and 0 for false, or the pass number for PASS().
+func (e *exprParser) parsePredicate(predicate string) (int, error) {
+	if predicate == "PASS" {
+		if e.operator([]string{")"}) == "" {
+			return 0, trErrorf("PASS takes no arguments")
+		}
+		if e.scope == nil || e.scope.pass == 0 {
+			return 0, trErrorf("PASS() is only known in the assembler passes")
+		}
+		return e.scope.pass, nil
+	}
+	e.skipSpace()
+	start := e.pos
+	for e.pos < len(e.src) && isIdentifierChar(e.src[e.pos]) {
+		e.pos++
+	}
+	name := e.src[start:e.pos]
+	if name == "" || name[0] >= '0' && name[0] <= '9' || e.operator([]string{")"}) == "" {
+		return 0, trErrorf("%s takes one symbol name", predicate)
+	}
+	var result bool
+	switch {
+	case predicate == "ISLABEL" && e.scope == nil:
+		return 0, trErrorf("ISLABEL() is only known in the assembler passes")
+	case predicate == "ISLABEL":
+		result = e.scope.isLabel(name)
+	case e.scope != nil:
+		result = e.scope.defined(name)
+	default:
+		_, result = e.lookup(name)
+	}
+	if result {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// exprFunction is a function usable in expressions, such as MAX(a, b).
+type exprFunction struct {
+	minArgs, maxArgs int // maxArgs 0 means any number
+	eval             func(args []int) (int, error)
+}
+
+// arity describes the arguments the function takes, for error messages.
+func (f exprFunction) arity() string {
+	switch {
+	case f.maxArgs == 0:
+		return tr("%d or more arguments", f.minArgs)
+	case f.minArgs == 1 && f.maxArgs == 1:
+		return "1 argument"
+	}
+	return tr("%d arguments", f.minArgs)
+}
+
+// exprFunctions are the assembly-time functions, for deriving constants such as baud rate
+// and timer reload values from the oscillator frequency. A name is only a function when an
+// opening parenthesis follows it, so symbols of the same name keep working.
+var exprFunctions = map[string]exprFunction{
+	"MIN": {2, 0, func(args []int) (int, error) {
+		val := args[0]
+		for _, arg := range args[1:] {
+			val = min(val, arg)
+		}
+		return val, nil
+	}},
+	"MAX": {2, 0, func(args []int) (int, error) {
+		val := args[0]
+		for _, arg := range args[1:] {
+			val = max(val, arg)
+		}
+		return val, nil
+	}},
+	"ABS": {1, 1, func(args []int) (int, error) {
+		if args[0] < 0 {
+			return -args[0], nil
+		}
+		return args[0], nil
+	}},
+	// LOG2 rounds down and CLOG2 up, so CLOG2(n) is the number of bits needed for n values.
+	"LOG2": {1, 1, func(args []int) (int, error) {
+		if args[0] <= 0 {
+			return 0, trErrorf("argument %d is not positive", args[0])
+		}
+		return bits.Len(uint(args[0])) - 1, nil
+	}},
+	"CLOG2": {1, 1, func(args []int) (int, error) {
+		if args[0] <= 0 {
+			return 0, trErrorf("argument %d is not positive", args[0])
+		}
+		return bits.Len(uint(args[0] - 1)), nil
+	}},
+	// DIVROUND rounds to the nearest integer, halves away from zero; DIVCEIL rounds up.
+	"DIVROUND": {2, 2, func(args []int) (int, error) {
+		n, d := args[0], args[1]
+		if d == 0 {
+			return 0, trErrorf("division by zero")
+		}
+		if (n < 0) != (d < 0) {
+			return (n - d/2) / d, nil
+		}
+		return (n + d/2) / d, nil
+	}},
+	"DIVCEIL": {2, 2, func(args []int) (int, error) {
+		n, d := args[0], args[1]
+		if d == 0 {
+			return 0, trErrorf("division by zero")
+		}
+		q := n / d
+		if n%d != 0 && (n < 0) == (d < 0) {
+			q++
+		}
+		return q, nil
+	}},
+}
+
+// selectByte applies a HIGH, LOW or UPPER byte selector.
+func selectByte(selector string, val int) int {
+	switch selector {
+	case "HIGH":
+		return (val >> 8) & 0xFF
+	case "UPPER":
+		return (val >> 16) & 0xFF
+	}
+	return val & 0xFF
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// --- Flash Subcommand ---
+
+func init() {
+	registerSubcommand("flash", "Assemble a source, program it into the device and verify it", runFlash)
+}
+
+// runFlash assembles one source, writes its HEX file, programs it with a programmer backend
+// and reads the device back to verify it: the whole edit-build-upload loop in one command.
+func runFlash(args []string) error {
+	fs := flag.NewFlagSet("flash", flag.ExitOnError)
+	mcu := fs.String("mcu", "", "Target microcontroller name, e.g., 'PIC16F687' (required)")
+	configDir := fs.String("config-dir", "./configs", "Directory containing microcontroller JSON config files")
+	hexFile := fs.String("hex", "", "Path to the output HEX file (defaults to the source name with .hex)")
+	reportFile := fs.String("report", "", "Path to the report file (defaults to the source name with -report.txt)")
+	programmerName := fs.String("programmer", "pickit2", "Programmer used to program the device (pickit2, pickit3, pickit4, icd3, icd4)")
+	programmerPath := fs.String("programmer-path", "", "Path to the programmer's command-line tool (defaults to searching PATH)")
+	noVerify := fs.Bool("no-verify", false, "Skip reading the device back after programming")
+	msgFormat := fs.String("msg-format", MsgFormatDefault, "Diagnostic format: 'default', or 'gcc' for file:line: severity: message on stderr")
+	color := fs.String("color", ColorAuto, "Color diagnostics: 'auto' (when the output is a terminal), 'always' or 'never'")
+	monitorPort := fs.String("monitor", "", "After a successful flash, stream device output from this serial port, as the monitor subcommand does")
+	baud := fs.Int("baud", 9600, "With -monitor: serial baud rate")
+	monitorLog := fs.String("monitor-log", "", "With -monitor: also append the received output to this file")
+	fs.Parse(args)
+
+	// The source may come before the flags, as in `asm4pic flash main.asm -mcu PIC16F687`
+	var sources []string
+	for fs.NArg() > 0 {
+		sources = append(sources, fs.Arg(0))
+		fs.Parse(fs.Args()[1:])
+	}
+	if *mcu == "" || len(sources) != 1 {
+		fs.Usage()
+		return fmt.Errorf("-mcu and one source file are required")
+	}
+	source := sources[0]
+
+	// Resolve the programmer first, so a typo fails before the build
+	programmer, err := newProgrammer(*programmerName, *programmerPath)
+	if err != nil {
+		return err
+	}
+	mcConfig, err := loadMicrocontrollerConfigByName(*configDir, *mcu)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(source)
+	if err != nil {
+		return fmt.Errorf("could not read assembly file '%s': %w", source, err)
+	}
+
+	base := strings.TrimSuffix(source, filepath.Ext(source))
+	hexPath, reportPath := *hexFile, *reportFile
+	if hexPath == "" {
+		hexPath = base + ".hex"
+	}
+	if reportPath == "" {
+		reportPath = base + "-report.txt"
+	}
+	if hexPath == StdioPath || reportPath == StdioPath {
+		return fmt.Errorf("-hex and -report must name files, since the programmer reads the HEX file")
+	}
+
+	fmt.Printf("Assembling %s...\n", source)
+	printer := &DiagnosticPrinter{Format: *msgFormat, File: source, Color: *color, Source: string(data)}
+	if err := assemble(string(data), hexPath, mcConfig, reportPath, printer, &AssemblyOptions{SourceDir: filepath.Dir(source)}); err != nil {
+		printer.Print(errorDiagnostic(err))
+		return fmt.Errorf("assembly of %s failed", source)
+	}
+
+	fmt.Printf("Programming %s with %s...\n", mcConfig.Name, *programmerName)
+	if err := programmer.Program(mcConfig.Name, hexPath); err != nil {
+		return err
+	}
+	if *noVerify {
+		fmt.Printf("Flash successful. %s programmed with %s (not verified)\n", mcConfig.Name, hexPath)
+		return monitorAfterFlash(*monitorPort, *baud, *monitorLog)
+	}
+
+	content, err := os.ReadFile(hexPath)
+	if err != nil {
+		return fmt.Errorf("could not read HEX file '%s': %w", hexPath, err)
+	}
+	expected, err := parseIntelHex(string(content))
+	if err != nil {
+		return fmt.Errorf("could not parse '%s': %w", hexPath, err)
+	}
+	tmpDir, err := os.MkdirTemp("", "asm4pic-flash")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	readPath := filepath.Join(tmpDir, "readback.hex")
+	fmt.Printf("Reading %s with %s...\n", mcConfig.Name, *programmerName)
+	if err := programmer.ReadBack(mcConfig.Name, readPath); err != nil {
+		return err
+	}
+	if err := verifyDump(mcConfig, expected, readPath, hexPath); err != nil {
+		return err
+	}
+	fmt.Printf("Flash successful. %s programmed with %s and verified\n", mcConfig.Name, hexPath)
+	return monitorAfterFlash(*monitorPort, *baud, *monitorLog)
+}
+
+// monitorAfterFlash opens the serial monitor on port once the device is programmed, for the
+// edit-flash-monitor loop in one command. It does nothing when no port is given.
+func monitorAfterFlash(port string, baud int, logPath string) error {
+	if port == "" {
+		return nil
+	}
+	return runSerialMonitor(monitorOptions{Port: port, Baud: baud, LogPath: logPath, Timestamps: true})
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// --- Control Flow Analysis ---
+
+// Interrupt and reset vector addresses of the mid-range core.
+const (
+	resetVector     = 0x000
+	interruptVector = 0x004
+	pclRegister     = 0x02
+)
+
+// flowGraph is the control flow of the expanded code with one node per machine instruction,
+// identified by its index in the expanded item list. Addresses are assigned the same way as
+// the assembler's first pass.
+type flowGraph struct {
+	expanded   *ExpandedParsedAssembly
+	address    map[int]int // instruction index -> program address
+	atAddress  map[int]int // program address -> instruction index
+	labels     map[string]int
+	successors map[int][]int
+	// addressTaken are instructions whose label is used as data (e.g. MOVLW LABEL).
+	addressTaken []int
+	// unresolved describes the first GOTO/CALL whose target is not known; nil if all are.
+	unresolved error
+}
+
+// flowResolver resolves numeric operands (literals, EQU symbols, SFR names).
+type flowResolver func(operand string) (int, bool)
+
+// flowOpcode returns the upper-case opcode of a machine instruction at i, or "" for anything else.
+func flowOpcode(expanded *ExpandedParsedAssembly, mcConfig *MicrocontrollerConfig, i int) string {
+	inst, ok := expanded.Lines[i].(*Instruction)
+	if !ok {
+		return ""
+	}
+	opcode := strings.ToUpper(inst.Opcode)
+	if _, known := mcConfig.InstructionSet[opcode]; !known {
+		return ""
+	}
+	return opcode
+}
+
+// buildFlowGraph assigns addresses to the expanded code and links every instruction to the
+// instructions that may execute next.
+func buildFlowGraph(expanded *ExpandedParsedAssembly, mcConfig *MicrocontrollerConfig, resolve flowResolver) *flowGraph {
+	g := &flowGraph{
+		expanded:   expanded,
+		address:    make(map[int]int),
+		atAddress:  make(map[int]int),
+		labels:     make(map[string]int),
+		successors: make(map[int][]int),
+	}
+
+	// Assign addresses, stopping at END like the assembler does
+	pc := 0
+scan:
+	for i, item := range expanded.Lines {
+		switch v := item.(type) {
+		case *OrgDirective:
+			addr, ok := resolve(v.Address)
+			if !ok && g.unresolved == nil {
+				g.unresolved = &AssemblerError{Line: expanded.SourceLines[i], Message: tr("ORG address '%s' is not a constant", v.Address)}
+			}
+			pc = addr
+		case *Label:
+			g.labels[v.Name] = pc
+		case *DataDirective:
+			pc += v.wordCount()
+		case *VersionString:
+			if v.Address == "" {
+				pc += v.wordCount()
+			}
+		case *Instruction:
+			if strings.ToUpper(v.Opcode) == "END" {
+				break scan
+			}
+			if flowOpcode(expanded, mcConfig, i) != "" {
+				g.address[i] = pc
+				g.atAddress[pc] = i
+				pc++
+			}
+		}
+	}
+
+	target := func(operand string) (int, bool) {
+		if addr, ok := g.labels[operand]; ok {
+			return addr, true
+		}
+		return resolve(operand)
+	}
+	// Unprogrammed words execute as harmless instructions, so a jump or fall-through to an
+	// address without code continues at the next programmed address.
+	addresses := make([]int, 0, len(g.atAddress))
+	for addr := range g.atAddress {
+		addresses = append(addresses, addr)
+	}
+	sort.Ints(addresses)
+	link := func(from, addr int) {
+		if k := sort.SearchInts(addresses, addr); k < len(addresses) {
+			g.successors[from] = append(g.successors[from], g.atAddress[addresses[k]])
+		}
+	}
+	// A computed jump may land on any word of the table that follows it: the code from addr
+	// up to the first address without code.
+	linkTable := func(from, addr int) {
+		for ; ; addr++ {
+			if _, ok := g.atAddress[addr]; !ok {
+				break
+			}
+			link(from, addr)
+		}
+	}
+
+	for i, addr := range g.address {
+		inst := expanded.Lines[i].(*Instruction)
+		opcode := flowOpcode(expanded, mcConfig, i)
+		info := mcConfig.InstructionSet[opcode]
+
+		// Labels used as data keep the code they mark alive
+		if opcode != "GOTO" && opcode != "CALL" && opcode != "BRA" {
+			for _, operand := range inst.Operands {
+				if labelAddr, ok := g.labels[operand]; ok {
+					if to, ok := g.atAddress[labelAddr]; ok {
+						g.addressTaken = append(g.addressTaken, to)
+					}
+				}
+			}
+		}
+
+		switch {
+		case opcode == "GOTO" || opcode == "CALL" || opcode == "BRA":
+			// BRA is written with its destination, like GOTO
+			if len(inst.Operands) == 1 {
+				if dest, ok := target(inst.Operands[0]); ok {
+					link(i, dest)
+				} else if g.unresolved == nil {
+					g.unresolved = &AssemblerError{Line: expanded.SourceLines[i], Message: tr("%s target '%s' is not a label or constant", opcode, inst.Operands[0])}
+				}
+			}
+			if opcode == "CALL" {
+				link(i, addr+1)
+			}
+		case opcode == "BRW" || opcode == "CALLW":
+			// BRW and CALLW add W to the program counter of the next instruction; CALLW
+			// returns there, which the table includes
+			linkTable(i, addr+1)
+		case opcode == "RETURN" || opcode == "RETLW" || opcode == "RETFIE":
+			// No successor within the program
+		case isSkipInstruction(opcode):
+			link(i, addr+1)
+			link(i, addr+2)
+		default:
+			link(i, addr+1)
+			// A computed jump (write to PCL) may land anywhere in the code that follows it
+			if reg, ok := operandOfKind(inst, info, "f"); ok && writesFileRegister(inst, info) {
+				if regAddr, ok := resolve(reg); ok && regAddr&0x7F == pclRegister {
+					linkTable(i, addr+2)
+				}
+			}
+		}
+	}
+	return g
+}
+
+// roots returns the instructions execution can start from: the reset and interrupt vectors
+// and every instruction whose label is used as data.
+func (g *flowGraph) roots() []int {
+	var roots []int
+	for _, vector := range []int{resetVector, interruptVector} {
+		if i, ok := g.atAddress[vector]; ok {
+			roots = append(roots, i)
+		}
+	}
+	return append(roots, g.addressTaken...)
+}
+
+// reachable returns the set of instructions reachable from the given roots.
+func (g *flowGraph) reachable(roots []int) map[int]bool {
+	seen := make(map[int]bool)
+	stack := append([]int(nil), roots...)
+	for len(stack) > 0 {
+		i := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if seen[i] {
+			continue
+		}
+		seen[i] = true
+		stack = append(stack, g.successors[i]...)
+	}
+	return seen
+}
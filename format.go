@@ -0,0 +1,368 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// --- Source Formatter ---
+
+func init() {
+	registerSubcommand("fmt", "Reformat assembly source files", runFormat)
+}
+
+// sourceField is a piece of a source line and the byte offset where it starts.
+type sourceField struct {
+	Text   string
+	Column int
+}
+
+// sourceLineFields is the syntactic breakdown of one source line.
+type sourceLineFields struct {
+	Label    sourceField   // Label or name being defined (EQU/MACRO), without the trailing ':'
+	HasColon bool          // Label was written with a trailing ':'
+	Opcode   sourceField   // Instruction, directive or macro invocation
+	Operands []sourceField // Comma-separated operands, trimmed
+	Comment  sourceField   // Comment including the leading ';'
+	Indented bool          // The first non-blank character is not in column 0
+}
+
+// findCommentStart returns the offset of the ';' starting a comment, ignoring quoted text, or -1.
+func findCommentStart(line string) int {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0 && c == '\\':
+			i++
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == 0 && c == ';':
+			return i
+		}
+	}
+	return -1
+}
+
+// splitOperandFields splits operand text on top-level commas, tracking each operand's offset.
+func splitOperandFields(text string, offset int) []sourceField {
+	var fields []sourceField
+	var quote byte
+	depth, start := 0, 0
+	flush := func(end int) {
+		raw := text[start:end]
+		trimmed := strings.TrimSpace(raw)
+		if trimmed != "" {
+			fields = append(fields, sourceField{Text: trimmed, Column: offset + start + strings.Index(raw, trimmed)})
+		}
+	}
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0 && c == '\\':
+			i++
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			flush(i)
+			start = i + 1
+		}
+	}
+	flush(len(text))
+	return fields
+}
+
+// collapseSpaces replaces each run of blanks in an operand with one space, leaving quoted
+// string and character literals as written.
+func collapseSpaces(text string) string {
+	var out strings.Builder
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0 && c == '\\' && i+1 < len(text):
+			out.WriteByte(c)
+			i++
+			c = text[i]
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ' ' || c == '\t':
+			for i+1 < len(text) && (text[i+1] == ' ' || text[i+1] == '\t') {
+				i++
+			}
+			c = ' '
+		}
+		out.WriteByte(c)
+	}
+	return out.String()
+}
+
+// nextToken returns the whitespace-delimited token starting at or after pos, and its bounds.
+func nextToken(s string, pos int) (string, int, int) {
+	for pos < len(s) && (s[pos] == ' ' || s[pos] == '\t') {
+		pos++
+	}
+	end := pos
+	for end < len(s) && s[end] != ' ' && s[end] != '\t' {
+		end++
+	}
+	return s[pos:end], pos, end
+}
+
+// splitSourceLine breaks a source line into label, opcode, operand and comment fields.
+func splitSourceLine(line string) sourceLineFields {
+	var fields sourceLineFields
+	line = strings.TrimRight(line, "\r")
+
+	code := line
+	if idx := findCommentStart(line); idx >= 0 {
+		fields.Comment = sourceField{Text: strings.TrimRight(line[idx:], " \t"), Column: idx}
+		code = line[:idx]
+	}
+	code = strings.TrimRight(code, " \t")
+	fields.Indented = len(strings.TrimLeft(line, " \t")) < len(line)
+
+	first, firstStart, firstEnd := nextToken(code, 0)
+	if first == "" {
+		return fields
+	}
+
+	// Preprocessor directives keep their first operand (the name) separate from the rest.
+	if strings.HasPrefix(first, "#") {
+		fields.Opcode = sourceField{Text: first, Column: firstStart}
+		name, nameStart, nameEnd := nextToken(code, firstEnd)
+		if name != "" {
+			fields.Operands = append(fields.Operands, sourceField{Text: name, Column: nameStart})
+			rest := strings.TrimSpace(code[nameEnd:])
+			if rest != "" {
+				fields.Operands = append(fields.Operands, sourceField{Text: rest, Column: strings.Index(code[nameEnd:], rest) + nameEnd})
+			}
+		}
+		return fields
+	}
+
+	pos := firstStart
+	if strings.HasSuffix(first, ":") {
+		fields.Label = sourceField{Text: strings.TrimSuffix(first, ":"), Column: firstStart}
+		fields.HasColon = true
+		pos = firstEnd
+	} else if second, _, _ := nextToken(code, firstEnd); namingDirectiveKeywords[strings.ToUpper(second)] {
+		fields.Label = sourceField{Text: first, Column: firstStart}
+		pos = firstEnd
+	}
+
+	opcode, opStart, opEnd := nextToken(code, pos)
+	if opcode == "" {
+		return fields
+	}
+	fields.Opcode = sourceField{Text: opcode, Column: opStart}
+	fields.Operands = splitOperandFields(code[opEnd:], opEnd)
+	return fields
+}
+
+// formatStyle holds the layout rules applied by formatSource.
+type formatStyle struct {
+	OpcodeColumn  int
+	OperandColumn int
+	CommentColumn int
+	Case          string // "upper", "lower" or "preserve"
+	MaxBlankLines int
+}
+
+// formatSource lays out every line according to style. Only names in mnemonics (instructions
+// and directives) are re-cased; labels, symbols and macro names are case-sensitive and kept as written.
+func formatSource(text string, style formatStyle, mnemonics map[string]bool) string {
+	newline := "\n"
+	if strings.Contains(text, "\r\n") {
+		newline = "\r\n"
+	}
+
+	applyCase := func(word string) string {
+		if !mnemonics[strings.ToUpper(word)] && !directiveKeywords[strings.ToUpper(word)] {
+			return word
+		}
+		switch style.Case {
+		case "upper":
+			return strings.ToUpper(word)
+		case "lower":
+			return strings.ToLower(word)
+		}
+		return word
+	}
+
+	var out []string
+	blankRun := 0
+	for _, rawLine := range strings.Split(text, "\n") {
+		fields := splitSourceLine(rawLine)
+		if fields.Label.Text == "" && fields.Opcode.Text == "" && fields.Comment.Text == "" {
+			blankRun++
+			if blankRun <= style.MaxBlankLines {
+				out = append(out, "")
+			}
+			continue
+		}
+		blankRun = 0
+
+		var line strings.Builder
+		padTo := func(column int) {
+			if line.Len() == 0 && column == 0 {
+				return
+			}
+			if line.Len() >= column {
+				line.WriteString(" ")
+			} else {
+				line.WriteString(strings.Repeat(" ", column-line.Len()))
+			}
+		}
+
+		switch {
+		case strings.HasPrefix(fields.Opcode.Text, "#"):
+			parts := []string{applyCase(fields.Opcode.Text)}
+			for _, op := range fields.Operands {
+				parts = append(parts, op.Text)
+			}
+			line.WriteString(strings.Join(parts, " "))
+
+		case fields.Label.Text != "" || fields.Opcode.Text != "":
+			if fields.Label.Text != "" {
+				line.WriteString(fields.Label.Text)
+				if fields.HasColon {
+					line.WriteString(":")
+				}
+			}
+			if fields.Opcode.Text != "" {
+				padTo(style.OpcodeColumn)
+				line.WriteString(applyCase(fields.Opcode.Text))
+				if len(fields.Operands) > 0 {
+					operands := make([]string, len(fields.Operands))
+					for i, op := range fields.Operands {
+						operands[i] = collapseSpaces(op.Text)
+					}
+					padTo(style.OperandColumn)
+					line.WriteString(strings.Join(operands, ", "))
+				}
+			}
+
+		case fields.Indented:
+			// Indented full-line comments follow the code they annotate.
+			padTo(style.OpcodeColumn)
+		}
+
+		if fields.Comment.Text != "" {
+			if line.Len() > 0 && strings.TrimSpace(line.String()) != "" {
+				padTo(style.CommentColumn)
+			}
+			line.WriteString(fields.Comment.Text)
+		}
+		out = append(out, line.String())
+	}
+
+	// Trim trailing blank lines and end the file with exactly one newline.
+	for len(out) > 0 && out[len(out)-1] == "" {
+		out = out[:len(out)-1]
+	}
+	return strings.Join(out, newline) + newline
+}
+
+// loadMnemonics collects instruction names from the given MCU, or from every config in configDir.
+func loadMnemonics(configDir, mcu string) (map[string]bool, error) {
+	var configPaths []string
+	if mcu != "" {
+		configPaths = []string{filepath.Join(configDir, strings.ToLower(mcu)+".json")}
+	} else {
+		configPaths, _ = filepath.Glob(filepath.Join(configDir, "*.json"))
+	}
+
+	mnemonics := make(map[string]bool)
+	for _, path := range configPaths {
+		mcConfig, err := loadMicrocontrollerConfig(path)
+		if err != nil {
+			if mcu != "" {
+				return nil, err
+			}
+			continue
+		}
+		for name := range mcConfig.InstructionSet {
+			mnemonics[strings.ToUpper(name)] = true
+		}
+	}
+	return mnemonics, nil
+}
+
+// runFormat formats the named files (or stdin) according to the style flags.
+func runFormat(args []string) error {
+	fs := flag.NewFlagSet("fmt", flag.ExitOnError)
+	write := fs.Bool("w", false, "Write the result back to the source files instead of stdout")
+	list := fs.Bool("l", false, "Only list files whose formatting differs")
+	mcu := fs.String("mcu", "", "Microcontroller whose instruction set is re-cased (defaults to all configs)")
+	configDir := fs.String("config-dir", "./configs", "Directory containing microcontroller JSON config files")
+	opcodeColumn := fs.Int("opcode-column", 8, "Column where opcodes and directives start")
+	operandColumn := fs.Int("operand-column", 16, "Column where operands start")
+	commentColumn := fs.Int("comment-column", 32, "Column where trailing comments start")
+	caseStyle := fs.String("case", "upper", "Case of instructions and directives: upper, lower or preserve")
+	maxBlankLines := fs.Int("max-blank-lines", 1, "Maximum number of consecutive blank lines kept")
+	fs.Parse(args)
+
+	if *caseStyle != "upper" && *caseStyle != "lower" && *caseStyle != "preserve" {
+		return fmt.Errorf("invalid -case value '%s' (expected upper, lower or preserve)", *caseStyle)
+	}
+	style := formatStyle{
+		OpcodeColumn:  *opcodeColumn,
+		OperandColumn: *operandColumn,
+		CommentColumn: *commentColumn,
+		Case:          *caseStyle,
+		MaxBlankLines: *maxBlankLines,
+	}
+	mnemonics, err := loadMnemonics(*configDir, *mcu)
+	if err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		source, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		fmt.Print(formatSource(string(source), style, mnemonics))
+		return nil
+	}
+
+	for _, path := range fs.Args() {
+		source, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("could not read '%s': %w", path, err)
+		}
+		formatted := formatSource(string(source), style, mnemonics)
+		switch {
+		case *list:
+			if formatted != string(source) {
+				fmt.Println(path)
+			}
+		case *write:
+			if formatted != string(source) {
+				if err := os.WriteFile(path, []byte(formatted), 0644); err != nil {
+					return fmt.Errorf("could not write '%s': %w", path, err)
+				}
+			}
+		default:
+			fmt.Print(formatted)
+		}
+	}
+	return nil
+}
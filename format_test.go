@@ -0,0 +1,22 @@
+package main
+
+import "testing"
+
+func TestFormatKeepsQuotedOperands(t *testing.T) {
+	style := formatStyle{OpcodeColumn: 8, OperandColumn: 16, CommentColumn: 40, Case: "upper", MaxBlankLines: 1}
+	for operands, want := range map[string]string{
+		`"a   b;c"`:              `"a   b;c"`,
+		"\"tab\t\tinside\",  0":  "\"tab\t\tinside\", 0",
+		`'  ', ' '`:              `'  ', ' '`,
+		`"say \"hi   there\""`:   `"say \"hi   there\""`,
+		`"x"  +  1`:              `"x" + 1`,
+		`HIGH   ( TABLE ),  'a'`: `HIGH ( TABLE ), 'a'`,
+	} {
+		for _, directive := range []string{"DT", "DW", "DB"} {
+			got := formatSource("  "+directive+"   "+operands+"\n", style, map[string]bool{directive: true})
+			if got != "        "+directive+"      "+want+"\n" {
+				t.Errorf("%s %s formatted as %q", directive, operands, got)
+			}
+		}
+	}
+}
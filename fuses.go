@@ -0,0 +1,258 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// --- Configuration Fuses ---
+
+func init() {
+	registerSubcommand("fuses", "Decode the configuration words of a HEX file", runFuses)
+}
+
+// fuseSetting is one fuse group of a configuration word and the option its bits select.
+type fuseSetting struct {
+	Group  string
+	Option string // option name without the leading _GROUP_, or "" if no option matches
+	Value  int    // the bits of the group
+}
+
+// optionText returns the option name, or the raw bits when no option matches.
+func (s fuseSetting) optionText() string {
+	if s.Option == "" {
+		return tr("0x%X (no matching option)", s.Value)
+	}
+	return s.Option
+}
+
+// configWordFuseMap returns the fuse map of a configuration word (CONFIG1 is the first map).
+func configWordFuseMap(mcConfig *MicrocontrollerConfig, name string) (map[string]FuseGroupInfo, bool) {
+	index := configWordIndex(name)
+	if index < 0 || index >= len(mcConfig.AllConfigFuseMaps) {
+		return nil, false
+	}
+	return mcConfig.AllConfigFuseMaps[index], true
+}
+
+// configWordIndex returns the index of the fuse map of a configuration word, or -1 if the
+// name is not CONFIGn.
+func configWordIndex(name string) int {
+	var n int
+	if _, err := fmt.Sscanf(name, "CONFIG%d", &n); err != nil || n < 1 {
+		return -1
+	}
+	return n - 1
+}
+
+// configWordOperand resolves the word operand of the two-argument __CONFIG form: a config word
+// name, with or without the leading underscore of the MPASM include files, or an expression
+// giving its address. It returns the word name and the index of its fuse map.
+func (a *PicAssembler) configWordOperand(operand string) (string, int, error) {
+	name := strings.ToUpper(strings.TrimPrefix(operand, "_"))
+	if _, ok := a.mcConfig.ConfigWordDefaults[name]; !ok {
+		addr, err := a.evaluateExpression(operand)
+		if err != nil {
+			return "", 0, err
+		}
+		name = ""
+		for word, info := range a.mcConfig.ConfigWordDefaults {
+			if info.Address == addr {
+				name = word
+			}
+		}
+		if name == "" {
+			return "", 0, trErrorf("no config word at 0x%04X", addr)
+		}
+	}
+	return name, configWordIndex(name), nil
+}
+
+// configWordAt returns the name of the configuration word at a program word address.
+func (c *MicrocontrollerConfig) configWordAt(addr int) (string, bool) {
+	for name, info := range c.ConfigWordDefaults {
+		if info.Address == addr {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// inIDLocations reports whether a word address is one of the user ID locations.
+func (c *MicrocontrollerConfig) inIDLocations(addr int) bool {
+	return c.IDLocations != nil && c.IDLocations.Contains(addr)
+}
+
+// inConfigSpace reports whether ORG may place raw words at an address beyond program memory
+// that is a configuration word or a user ID location.
+func (c *MicrocontrollerConfig) inConfigSpace(addr int) bool {
+	_, ok := c.configWordAt(addr)
+	return ok || c.inIDLocations(addr)
+}
+
+// placeConfigWord sets a configuration word from a raw word placed at its address, warning
+// when it changes bits that __CONFIG settings gave the word.
+func (a *PicAssembler) placeConfigWord(i int, name string, word int) {
+	wordMask := (1 << a.mcConfig.ProgramWordSizeBits) - 1
+	if previous := a.configWords[name]; a.fuseWords[name] && previous&wordMask != word&wordMask {
+		a.warn(a.sourceLine(i), CodeConfigOverride, "Word 0x%04X at 0x%04X replaces %s = 0x%04X from __CONFIG.",
+			word&wordMask, a.mcConfig.ConfigWordDefaults[name].Address, name, previous&wordMask)
+	}
+	a.configWords[name] = word & wordMask
+}
+
+// fuseWordsOf returns the configuration words whose fuse maps contain a setting.
+func (a *PicAssembler) fuseWordsOf(setting string) []string {
+	var words []string
+	for i, configMap := range a.mcConfig.AllConfigFuseMaps {
+		for _, groupInfo := range configMap {
+			if _, ok := groupInfo.Values[setting]; ok {
+				words = append(words, fmt.Sprintf("CONFIG%d", i+1))
+				break
+			}
+		}
+	}
+	return words
+}
+
+// setFuse applies a fuse setting to the first configuration word whose fuse map contains it,
+// over whatever the __CONFIG lines selected. It reports whether the setting was found.
+func (a *PicAssembler) setFuse(setting string) bool {
+	setting = strings.ToUpper(strings.TrimSpace(setting))
+	for i, configMap := range a.mcConfig.AllConfigFuseMaps {
+		for _, groupInfo := range configMap {
+			if value, ok := groupInfo.Values[setting]; ok {
+				name := fmt.Sprintf("CONFIG%d", i+1)
+				a.configWords[name] = a.configWords[name]&^groupInfo.Mask | value
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// applyFuseSettings applies the fuse settings of a build profile after the __CONFIG lines.
+func (a *PicAssembler) applyFuseSettings(settings []string) error {
+	for _, setting := range settings {
+		if !a.setFuse(setting) {
+			return &AssemblerError{Message: tr("Unknown fuse setting '%s' in the build profile.", setting), Code: CodeProfileFuse}
+		}
+	}
+	return nil
+}
+
+// configProblem reports a fuse setting that cannot be applied as intended: an error with
+// -strict-config, otherwise a warning ending with what the assembler does instead.
+func (a *PicAssembler) configProblem(i int, code, message, fallback string) error {
+	if a.strictConfig {
+		return a.errorAt(i, code, "%s", message)
+	}
+	a.warn(a.sourceLine(i), code, "%s %s", message, fallback)
+	return nil
+}
+
+// decodeConfigWord reverse-maps a configuration word value through its fuse map, giving the
+// option each fuse group selects, lowest bits first. When several options share a value the
+// first in alphabetical order is used.
+func decodeConfigWord(mcConfig *MicrocontrollerConfig, name string, value int) []fuseSetting {
+	fuseMap, ok := configWordFuseMap(mcConfig, name)
+	if !ok {
+		return nil
+	}
+	groups := make([]string, 0, len(fuseMap))
+	for group := range fuseMap {
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		mi, mj := fuseMap[groups[i]].Mask, fuseMap[groups[j]].Mask
+		if mi&-mi != mj&-mj {
+			return mi&-mi < mj&-mj
+		}
+		return groups[i] < groups[j]
+	})
+
+	settings := make([]fuseSetting, 0, len(groups))
+	for _, group := range groups {
+		info := fuseMap[group]
+		setting := fuseSetting{Group: group, Value: value & info.Mask}
+		for option, bits := range info.Values {
+			if bits&info.Mask != setting.Value {
+				continue
+			}
+			option = strings.TrimPrefix(strings.TrimPrefix(option, "_"), group+"_")
+			if setting.Option == "" || option < setting.Option {
+				setting.Option = option
+			}
+		}
+		settings = append(settings, setting)
+	}
+	return settings
+}
+
+// sortedConfigWordNames returns the names of the configuration words in a map, in order.
+func sortedConfigWordNames(configWords map[string]int) []string {
+	names := make([]string, 0, len(configWords))
+	for name := range configWords {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// runFuses reads the configuration word records of a HEX file and prints the fuse settings
+// they select. Words missing from the file are decoded from their erased value, which is what
+// a programmer leaves in the device.
+func runFuses(args []string) error {
+	fs := flag.NewFlagSet("fuses", flag.ExitOnError)
+	hexFile := fs.String("hex", "", "Path to the HEX file to decode (required)")
+	mcu := fs.String("mcu", "", "Target microcontroller name, e.g., 'PIC16F687' (required)")
+	configDir := fs.String("config-dir", "./configs", "Directory containing microcontroller JSON config files")
+	fs.Parse(args)
+
+	if *hexFile == "" || *mcu == "" {
+		fs.Usage()
+		return fmt.Errorf("-hex and -mcu flags are required")
+	}
+
+	mcConfig, err := loadMicrocontrollerConfigByName(*configDir, *mcu)
+	if err != nil {
+		return err
+	}
+	content, err := os.ReadFile(*hexFile)
+	if err != nil {
+		return fmt.Errorf("could not read HEX file '%s': %w", *hexFile, err)
+	}
+	memory, err := parseIntelHex(string(content))
+	if err != nil {
+		return fmt.Errorf("could not parse '%s': %w", *hexFile, err)
+	}
+
+	wordMask := (1 << mcConfig.ProgramWordSizeBits) - 1
+	names := make([]string, 0, len(mcConfig.ConfigWordDefaults))
+	for name := range mcConfig.ConfigWordDefaults {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return mcConfig.ConfigWordDefaults[names[i]].Address < mcConfig.ConfigWordDefaults[names[j]].Address
+	})
+	if len(names) == 0 {
+		return fmt.Errorf("%s has no configuration words", *mcu)
+	}
+	for _, name := range names {
+		addr := mcConfig.ConfigWordDefaults[name].Address
+		value, ok := mcConfig.hexValueAt(memory, addr)
+		value &= wordMask
+		note := ""
+		if !ok {
+			value, note = wordMask, "  (not in file, erased)"
+		}
+		fmt.Printf("%s at 0x%04X = 0x%04X%s\n", name, addr, value, note)
+		for _, setting := range decodeConfigWord(mcConfig, name, value) {
+			fmt.Printf("    %-8s = %s\n", setting.Group, setting.optionText())
+		}
+	}
+	return nil
+}
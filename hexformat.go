@@ -0,0 +1,98 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// --- HEX Formatting ---
+
+// hexRecordSize is the number of data bytes in a full HEX record.
+const hexRecordSize = 16
+
+// HexFormat adjusts how HEX files are written, for bootloaders and programming tools that
+// only accept one spelling of the format. The zero value writes upper-case digits, LF line
+// endings and records as long as the data they hold.
+type HexFormat struct {
+	Lowercase bool // lower-case hex digits
+	CRLF      bool // CR LF line endings
+	// PadRecords writes every data record of program memory and the data EEPROM with the full
+	// 16 bytes of its aligned block, filling the bytes the program does not write with Fill.
+	// The config words and user ID locations are left as written, since a fill byte there
+	// would program a word the source did not set.
+	PadRecords bool
+	Fill       byte
+}
+
+// apply rewrites HEX content produced by GenerateHex in the format.
+func (f HexFormat) apply(content string, mcConfig *MicrocontrollerConfig, noELA bool) (string, error) {
+	if f.PadRecords {
+		var err error
+		if content, err = padHexRecords(content, mcConfig, f.Fill, noELA); err != nil {
+			return "", err
+		}
+	}
+	if f.Lowercase {
+		content = strings.ToLower(content)
+	}
+	if f.CRLF {
+		content = strings.ReplaceAll(content, "\n", "\r\n")
+	}
+	return content, nil
+}
+
+// padRegions returns the byte ranges of the HEX file that padding fills: program memory and
+// the data EEPROM, each as start and end (exclusive).
+func padRegions(mcConfig *MicrocontrollerConfig) [][2]int {
+	regions := [][2]int{{0, mcConfig.ProgramMemorySize * 2}}
+	if mcConfig.EEPROM != nil {
+		start, _ := mcConfig.hexLocation(mcConfig.EEPROM.Start)
+		last, size := mcConfig.hexLocation(mcConfig.EEPROM.End)
+		regions = append(regions, [2]int{start, last + size})
+	}
+	return regions
+}
+
+// padHexRecords rewrites HEX content as one full record per 16-byte aligned block holding
+// data in program memory or the data EEPROM, with fill in the bytes no record wrote, and the
+// other bytes as they are, all in address order.
+func padHexRecords(content string, mcConfig *MicrocontrollerConfig, fill byte, noELA bool) (string, error) {
+	memory, err := parseIntelHex(content)
+	if err != nil {
+		return "", err
+	}
+	addrs := make([]int, 0, len(memory))
+	for addr := range memory {
+		addrs = append(addrs, addr)
+	}
+	sort.Ints(addrs)
+	regions := padRegions(mcConfig)
+	padded := make([]hexImage, len(regions))
+	var exact hexImage
+	for _, addr := range addrs {
+		image := &exact
+		for k, region := range regions {
+			if addr >= region[0] && addr < region[1] {
+				image = &padded[k]
+			}
+		}
+		image.set(addr, memory[addr])
+	}
+
+	records := exact.records(hexRecordSize)
+	for k, region := range regions {
+		for _, block := range padded[k].blocks(hexRecordSize, fill) {
+			start, end := max(block.start, region[0]), min(block.end(), region[1])
+			records = append(records, hexRun{start: start, data: block.data[start-block.start : end-block.start]})
+		}
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].start < records[j].start })
+	w := &hexWriter{noELA: noELA, ela: -1}
+	for _, record := range records {
+		if err := w.data(record.start, record.data); err != nil {
+			return "", err
+		}
+	}
+	w.out.WriteString(":00000001FF\n")
+	return w.out.String(), nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPadRecordsLeavesConfigAndIDSpace(t *testing.T) {
+	src := "        __CONFIG _FOSC_INTOSCIO & _WDTE_OFF\n        ORG 0\n        GOTO 0\n" +
+		"        ORG 0x2000\n        DW 0x1\n        ORG 0x2100\n        DE 0x42\n        END\n"
+	mcConfig := testConfig(t)
+	assembler, _, err := assembleSource(src, mcConfig, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	generate := func(format HexFormat) map[int]byte {
+		g := NewHexGenerator(mcConfig)
+		g.format = format
+		content, err := g.GenerateHex(assembler.machineCodeWords, assembler.configWords)
+		if err != nil {
+			t.Fatal(err)
+		}
+		memory, err := parseIntelHex(content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return memory
+	}
+	plain := generate(HexFormat{})
+	padded := generate(HexFormat{PadRecords: true, Fill: 0xFF})
+
+	eepromStart, _ := mcConfig.hexLocation(mcConfig.EEPROM.Start)
+	for addr := range padded {
+		if _, ok := plain[addr]; !ok && addr >= mcConfig.ProgramMemorySize*2 && addr < eepromStart {
+			t.Errorf("padding added byte 0x%X in the config and ID space", addr)
+		}
+	}
+	for _, addr := range []int{0x0F, eepromStart + 0x0F} {
+		if padded[addr] != 0xFF {
+			t.Errorf("byte 0x%X = %#02x, want the fill", addr, padded[addr])
+		}
+	}
+}
+
+func TestParseIntelHexAddressRecordLength(t *testing.T) {
+	for _, record := range []string{":0100000400FB", ":0100000200FD", ":00000004FC", ":03000004000000F9"} {
+		_, err := parseIntelHex(record + "\n:00000001FF\n")
+		if err == nil {
+			t.Errorf("%s: parsed, want an error", record)
+		} else if !strings.Contains(err.Error(), "must hold 2 bytes") {
+			t.Errorf("%s: %v, want the record length refused", record, err)
+		}
+	}
+	if _, err := parseIntelHex(":020000040001F9\n:00000001FF\n"); err != nil {
+		t.Errorf("valid ELA record: %v", err)
+	}
+}
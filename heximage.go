@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// --- Sparse HEX Image ---
+
+// hexImage is a byte-addressed memory image holding only the bytes written, as sorted runs
+// of consecutive bytes. Its size follows what the program writes, not the device's memory,
+// so regions far apart, such as PIC18 config bytes at 0x300000, cost nothing in between.
+type hexImage struct {
+	runs []hexRun // sorted by start, neither overlapping nor touching
+}
+
+// hexRun is a run of consecutive bytes starting at byte address start.
+type hexRun struct {
+	start int
+	data  []byte
+}
+
+func (r hexRun) end() int { return r.start + len(r.data) }
+
+// set writes a byte, extending or joining the runs next to it.
+func (m *hexImage) set(addr int, b byte) {
+	// i is the first run that ends at or after addr, the one addr falls in or extends
+	i := sort.Search(len(m.runs), func(i int) bool { return m.runs[i].end() >= addr })
+	switch {
+	case i < len(m.runs) && addr < m.runs[i].end() && addr >= m.runs[i].start:
+		m.runs[i].data[addr-m.runs[i].start] = b
+	case i < len(m.runs) && addr == m.runs[i].end():
+		m.runs[i].data = append(m.runs[i].data, b)
+		if i+1 < len(m.runs) && m.runs[i+1].start == addr+1 {
+			m.runs[i].data = append(m.runs[i].data, m.runs[i+1].data...)
+			m.runs = append(m.runs[:i+1], m.runs[i+2:]...)
+		}
+	case i < len(m.runs) && addr == m.runs[i].start-1:
+		m.runs[i].start, m.runs[i].data = addr, append([]byte{b}, m.runs[i].data...)
+	default:
+		m.runs = append(m.runs, hexRun{})
+		copy(m.runs[i+1:], m.runs[i:])
+		m.runs[i] = hexRun{start: addr, data: []byte{b}}
+	}
+}
+
+// get reads a byte; ok is false when it was never written.
+func (m *hexImage) get(addr int) (byte, bool) {
+	i := sort.Search(len(m.runs), func(i int) bool { return m.runs[i].end() > addr })
+	if i < len(m.runs) && addr >= m.runs[i].start {
+		return m.runs[i].data[addr-m.runs[i].start], true
+	}
+	return 0, false
+}
+
+// blocks returns the size-aligned blocks holding any written byte, in address order, each
+// filled out with fill where nothing was written.
+func (m *hexImage) blocks(size int, fill byte) []hexRun {
+	var blocks []hexRun
+	for _, run := range m.runs {
+		for addr := run.start &^ (size - 1); addr < run.end(); addr += size {
+			if len(blocks) > 0 && blocks[len(blocks)-1].start == addr {
+				continue // begun by the previous run
+			}
+			block := hexRun{start: addr, data: make([]byte, size)}
+			for k := range block.data {
+				if b, ok := m.get(addr + k); ok {
+					block.data[k] = b
+				} else {
+					block.data[k] = fill
+				}
+			}
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks
+}
+
+// records returns the runs of written bytes split at size-aligned boundaries, one per HEX
+// record, in address order.
+func (m *hexImage) records(size int) []hexRun {
+	var records []hexRun
+	for _, run := range m.runs {
+		for addr := run.start; addr < run.end(); {
+			next := min(addr&^(size-1)+size, run.end())
+			records = append(records, hexRun{start: addr, data: run.data[addr-run.start : next-run.start]})
+			addr = next
+		}
+	}
+	return records
+}
+
+// hexWriter writes HEX data records, preceded by an Extended Linear Address record whenever
+// the 64 KB segment changes.
+type hexWriter struct {
+	out   strings.Builder
+	noELA bool // fail instead of writing ELA records
+	ela   int  // segment of the last ELA record, -1 to write one before the next record
+}
+
+// setELA writes the ELA record of a segment if it is not the current one.
+func (w *hexWriter) setELA(ela int) {
+	if ela == w.ela || w.noELA {
+		return
+	}
+	w.ela = ela
+	checksum := calculateChecksum([]byte{0x02, 0x00, 0x00, 0x04, byte(ela >> 8), byte(ela)})
+	w.out.WriteString(fmt.Sprintf(":02000004%04X%02X\n", ela, checksum))
+}
+
+// data writes a data record of the bytes at a byte address.
+func (w *hexWriter) data(addr int, data []byte) error {
+	if w.noELA && addr > 0xFFFF {
+		return noELAError(addr)
+	}
+	w.setELA(addr >> 16)
+	record := append([]byte{byte(len(data)), byte(addr >> 8), byte(addr), 0x00}, data...)
+	w.out.WriteString(fmt.Sprintf(":%X%02X\n", record, calculateChecksum(record)))
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// --- HEX Regions ---
+
+// The regions of the HEX file, which -split-hex writes to files of their own and -hex-omit
+// leaves out.
+const (
+	HexRegionProgram = "program"
+	HexRegionConfig  = "config"
+	HexRegionEEPROM  = "eeprom"
+	HexRegionID      = "id"
+)
+
+// hexRegions lists the regions in the order they appear in the HEX file.
+var hexRegions = []string{HexRegionProgram, HexRegionConfig, HexRegionID, HexRegionEEPROM}
+
+// parseHexRegions parses a comma-separated list of HEX regions.
+func parseHexRegions(list string) (map[string]bool, error) {
+	regions := make(map[string]bool)
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name == "" {
+			continue
+		}
+		known := false
+		for _, region := range hexRegions {
+			known = known || region == name
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown HEX region '%s' (expected %s)", name, strings.Join(hexRegions, ", "))
+		}
+		regions[name] = true
+	}
+	return regions, nil
+}
+
+// hexRegion returns the HEX region of a word address. Addresses outside the config words,
+// the user ID locations and the data EEPROM count as program memory.
+func (c *MicrocontrollerConfig) hexRegion(addr int) string {
+	switch {
+	case c.inEEPROM(addr):
+		return HexRegionEEPROM
+	case c.inIDLocations(addr):
+		return HexRegionID
+	}
+	if _, ok := c.configWordAt(addr); ok {
+		return HexRegionConfig
+	}
+	return HexRegionProgram
+}
+
+// GenerateRegionsHex produces a HEX file holding only the given regions.
+func (g *HexGenerator) GenerateRegionsHex(machineCodeWords map[int]int, configWords map[string]int, regions map[string]bool) (string, error) {
+	words := make(map[int]int)
+	for addr, word := range machineCodeWords {
+		if regions[g.mcConfig.hexRegion(addr)] {
+			words[addr] = word
+		}
+	}
+	if !regions[HexRegionConfig] {
+		configWords = nil
+	}
+	return g.GenerateHex(words, configWords)
+}
+
+// hexRegionsUsed reports which regions the assembly writes anything to.
+func (a *PicAssembler) hexRegionsUsed() map[string]bool {
+	used := map[string]bool{HexRegionConfig: len(a.configWords) > 0}
+	for addr := range a.machineCodeWords {
+		used[a.mcConfig.hexRegion(addr)] = true
+	}
+	return used
+}
+
+// splitHexPath returns the file -split-hex writes a region to, next to the HEX file:
+// firmware.hex gives firmware-program.hex, firmware-config.hex and so on.
+func splitHexPath(hexPath, region string) string {
+	return strings.TrimSuffix(hexPath, filepath.Ext(hexPath)) + "-" + region + ".hex"
+}
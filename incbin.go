@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// --- Binary Includes ---
+
+// INCBIN "file"[, offset[, length]] embeds the bytes of a file in program memory at the
+// current location: two bytes per word, low byte first, on 16-bit cores (the DB packing),
+// and one byte per word on the narrower cores, whose words cannot hold two. offset and
+// length are numbers, selecting part of the file; the default is all of it.
+var incbinRegex = regexp.MustCompile(`(?i)^INCBIN\s+(.+)$`)
+
+func init() {
+	directiveKeywords["INCBIN"] = true
+}
+
+// parseBinaryInclude parses an INCBIN line into the DB or DW directive of the file's bytes,
+// or returns nil if the line is not one.
+func (p *ASMParser) parseBinaryInclude(lineContent string) (AssemblyItem, error) {
+	match := incbinRegex.FindStringSubmatch(lineContent)
+	if match == nil {
+		return nil, nil
+	}
+	operands, err := splitDataOperands(match[1])
+	if err != nil {
+		return nil, err
+	}
+	name := operands[0]
+	if len(operands) > 3 || len(name) < 2 || name[0] != '"' || name[len(name)-1] != '"' {
+		return nil, trErrorf(`expected INCBIN "file"[, offset[, length]], got '%s'`, match[1])
+	}
+	name = name[1 : len(name)-1]
+	var bounds []int // offset, then length
+	for _, operand := range operands[1:] {
+		n, ok, err := parseNumericLiteral(operand)
+		if !ok || err != nil || n < 0 {
+			return nil, trErrorf("INCBIN offset and length must be numbers, got '%s'", operand)
+		}
+		bounds = append(bounds, n)
+	}
+
+	// A file that could not fit in program memory is refused before it is read
+	maxBytes := int64(p.programWords)
+	if p.coreWordBits >= 16 {
+		maxBytes *= 2
+	}
+	data, err := p.readDataFile(name, maxBytes)
+	if err != nil {
+		return nil, trErrorf("cannot read %s: %v", name, err)
+	}
+	offset, length := 0, len(data)
+	if len(bounds) > 0 {
+		offset = bounds[0]
+		if offset > len(data) {
+			return nil, trErrorf("INCBIN offset %d is past the end of %s (%d bytes)", offset, name, len(data))
+		}
+		length = len(data) - offset
+	}
+	if len(bounds) > 1 {
+		if bounds[1] > length {
+			return nil, trErrorf("INCBIN length %d runs past the end of %s (%d bytes from offset %d)", bounds[1], name, length, offset)
+		}
+		length = bounds[1]
+	}
+	if length == 0 {
+		return nil, trErrorf("%s has no data", "INCBIN")
+	}
+
+	d := &DataDirective{Name: "DW"}
+	if p.coreWordBits >= 16 {
+		d.Name = "DB"
+	}
+	values := make([]string, length)
+	for k := range values {
+		values[k] = fmt.Sprintf("0x%02X", data[offset+k])
+		d.Elements = append(d.Elements, dataElement{Expression: values[k]})
+	}
+	d.Operands = strings.Join(values, ", ")
+	return d, nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIncbinRefusesFileLargerThanProgramMemory(t *testing.T) {
+	mcConfig, err := loadMicrocontrollerConfigByName("./configs", "PIC16F687")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "big.bin"), make([]byte, mcConfig.ProgramMemorySize+1), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	source := "        ORG 0\n        INCBIN \"big.bin\", 0, 4\n        END\n"
+	_, _, err = assembleSource(source, mcConfig, &AssemblyOptions{SourceDir: dir})
+	if err == nil || !strings.Contains(err.Error(), "more than the") {
+		t.Fatalf("got %v, want the file refused as larger than program memory", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "small.bin"), []byte{1, 2, 3}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	assembler, _, err := assembleSource("        ORG 0\n        INCBIN \"small.bin\"\n        END\n", mcConfig, &AssemblyOptions{SourceDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assembler.machineCodeWords[2] != 3 {
+		t.Errorf("word 2 = %#x, want 3", assembler.machineCodeWords[2])
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFiles creates files under dir from a map of relative names to contents.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, text := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func testConfig(t *testing.T) *MicrocontrollerConfig {
+	t.Helper()
+	mcConfig, err := loadMicrocontrollerConfigByName("./configs", "PIC16F687")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mcConfig
+}
+
+func TestLocalIncludesResolveFromIncludingFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"src/main.asm":     "#INCLUDE \"sub/regs.inc\"\n#INCLUDE <lib.inc>\n        ORG 0\n        MOVLW VAL\n        END\n",
+		"src/sub/regs.inc": "#INCLUDE \"more.inc\"\n",
+		"src/sub/more.inc": "VAL EQU 0x05\n",
+		"lib/lib.inc":      "LIBVAL EQU 1\n",
+	})
+	opts := &AssemblyOptions{SourceDir: filepath.Join(dir, "src"), IncludeDirs: []string{filepath.Join(dir, "lib")}}
+	assembler, _, err := assembleSource(readTestFile(t, filepath.Join(dir, "src/main.asm")), testConfig(t), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assembler.machineCodeWords[0] != 0x3005 {
+		t.Errorf("word 0 = %#04x, want MOVLW 0x05", assembler.machineCodeWords[0])
+	}
+}
+
+func TestSandboxedIncludesRefuseAbsoluteAndEscapingPaths(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"secret.inc": "VAL EQU 1\n", "src/local.inc": "VAL EQU 1\n"})
+	opts := &AssemblyOptions{SourceDir: filepath.Join(dir, "src"), NoFileAccess: true}
+	for target, want := range map[string]string{
+		`"` + filepath.Join(dir, "secret.inc") + `"`: "absolute path",
+		`"../secret.inc"`:        "climbs out",
+		`"sub/../../secret.inc"`: "climbs out",
+		`"local.inc"`:            "cannot be read",
+	} {
+		_, _, err := assembleSource("#INCLUDE "+target+"\n        END\n", testConfig(t), opts)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("#INCLUDE %s: got %v, want an error saying %q", target, err, want)
+		}
+	}
+	if _, _, err := assembleSource("#INCLUDE \"std16.inc\"\n        END\n", testConfig(t), opts); err != nil {
+		t.Errorf("bundled library by quoted name: %v", err)
+	}
+}
+
+func TestGCCDiagnosticsPointIntoIncludeFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"src/sub/bad.inc":   "VAL EQU 1\n\n        BOGUS 1\n",
+		"src/sub/call.inc":  "\n        LOAD\n",
+		"src/sub/macro.inc": "LOAD MACRO\n        MOVLW BOGUS\n        ENDM\n",
+	})
+	opts := &AssemblyOptions{SourceDir: filepath.Join(dir, "src")}
+	printer := &DiagnosticPrinter{Format: MsgFormatGCC, File: "main.asm"}
+	for name, test := range map[string]struct {
+		source string
+		file   string
+		line   int
+	}{
+		"text":             {"        ORG 0\n#INCLUDE \"sub/bad.inc\"\n        END\n", filepath.Join(dir, "src/sub/bad.inc"), 3},
+		"invocation":       {"LOAD MACRO\n        MOVLW BOGUS\n        ENDM\n        ORG 0\n#INCLUDE \"sub/call.inc\"\n        END\n", filepath.Join(dir, "src/sub/call.inc"), 2},
+		"main source":      {"#INCLUDE \"sub/macro.inc\"\n        ORG 0\n        MOVLW BOGUS\n        END\n", "main.asm", 3},
+		"macro in include": {"#INCLUDE \"sub/macro.inc\"\n        ORG 0\n        LOAD\n        END\n", filepath.Join(dir, "src/sub/macro.inc"), 2},
+	} {
+		_, _, err := assembleSource(test.source, testConfig(t), opts)
+		if err == nil {
+			t.Errorf("%s: no error", name)
+			continue
+		}
+		if file, line := printer.location(errorDiagnostic(err)); file != test.file || line != test.line {
+			t.Errorf("%s: %v reported at %s:%d, want %s:%d", name, err, file, line, test.file, test.line)
+		}
+	}
+}
+
+func readTestFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// --- Indirect Addressing Lint ---
+
+func init() {
+	registerLintRule(lintRule{
+		Name:            "indirect-addressing",
+		Description:     "INDF read before FSR is loaded on some path, or MOVIW/MOVWI operand out of range",
+		DefaultSeverity: SeverityWarning,
+		Check:           lintIndirectAddressing,
+	})
+}
+
+// indirectPointer is an INDF register and the FSR registers that select what it accesses.
+type indirectPointer struct {
+	indf string
+	fsr  []string
+}
+
+// indirectPointers are the pointers of the mid-range core (INDF at 0x00 when the device config
+// does not name it) and of the enhanced mid-range core.
+var indirectPointers = []indirectPointer{
+	{"INDF", []string{"FSR"}},
+	{"INDF0", []string{"FSR0L", "FSR0H", "FSR0"}},
+	{"INDF1", []string{"FSR1L", "FSR1H", "FSR1"}},
+}
+
+// indexedOperandRegex matches the MOVIW/MOVWI operand forms: k[FSRn], ++FSRn, --FSRn,
+// FSRn++ and FSRn--.
+var indexedOperandRegex = regexp.MustCompile(`(?i)^(?:(.+)\[\s*(?:FSR|INDF)[01]\s*\]|(?:\+\+|--)FSR[01]|FSR[01](?:\+\+|--))$`)
+
+// lintIndirectAddressing follows the control flow from the vectors and reports reads of INDF
+// on a path where its FSR has not been loaded (with MOVWF or CLRF) first, and MOVIW/MOVWI
+// operands that are not one of the enhanced core forms or whose offset is outside -32..31.
+func lintIndirectAddressing(ctx *lintContext) []lintFinding {
+	var findings []lintFinding
+	for i, item := range ctx.expanded.Lines {
+		inst, ok := item.(*Instruction)
+		if !ok {
+			continue
+		}
+		if opcode := strings.ToUpper(inst.Opcode); opcode == "MOVIW" || opcode == "MOVWI" {
+			if message := ctx.checkIndexedOperand(inst); message != "" {
+				findings = append(findings, lintFinding{Line: ctx.expanded.SourceLines[i], Message: message})
+			}
+		}
+	}
+
+	// Each pointer the device has gets one bit of the loaded set.
+	indf := make(map[int]int) // INDF address -> pointer bit
+	fsr := make(map[int]int)  // FSR address -> pointer bit
+	var names []string
+	for _, p := range indirectPointers {
+		addr, ok := ctx.mcConfig.SFRMap[p.indf]
+		if !ok && p.indf == "INDF" {
+			_, ok = ctx.mcConfig.SFRMap["FSR"]
+			addr = 0x00
+		}
+		if !ok {
+			continue
+		}
+		bit := len(names)
+		indf[addr] = bit
+		for _, name := range p.fsr {
+			if a, ok := ctx.mcConfig.SFRMap[name]; ok {
+				fsr[a] = bit
+			}
+		}
+		names = append(names, p.indf)
+	}
+	if len(names) == 0 {
+		return findings
+	}
+	bankMask := 0x7F
+	if layout := ctx.mcConfig.RAMLayout; layout != nil && layout.BankSize > 0 {
+		bankMask = layout.BankSize - 1
+	}
+	pointerOf := func(registers map[int]int, i int) (int, bool) {
+		inst, info, ok := ctx.instructionAt(i)
+		if !ok {
+			return 0, false
+		}
+		_, addr, ok := ctx.fileRegisterAddress(inst, info)
+		if !ok {
+			return 0, false
+		}
+		bit, ok := registers[addr&bankMask]
+		return bit, ok
+	}
+
+	resolve := func(operand string) (int, bool) {
+		val, err := ctx.assembler.evaluateExpression(operand)
+		return val, err == nil
+	}
+	g := buildFlowGraph(ctx.expanded, ctx.mcConfig, resolve)
+
+	// loaded[i] is the set of pointers loaded on every path reaching i.
+	loaded := make(map[int]int)
+	var work []int
+	for _, root := range g.roots() {
+		loaded[root] = 0
+		work = append(work, root)
+	}
+	for len(work) > 0 {
+		i := work[len(work)-1]
+		work = work[:len(work)-1]
+		out := loaded[i]
+		if bit, ok := pointerOf(fsr, i); ok {
+			if opcode := flowOpcode(ctx.expanded, ctx.mcConfig, i); opcode == "MOVWF" || opcode == "CLRF" {
+				out |= 1 << bit
+			}
+		}
+		for _, next := range g.successors[i] {
+			in, seen := loaded[next]
+			if seen && in&out == in {
+				continue
+			}
+			if seen {
+				loaded[next] = in & out
+			} else {
+				loaded[next] = out
+			}
+			work = append(work, next)
+		}
+	}
+
+	reads := make([]int, 0, len(loaded))
+	for i := range loaded {
+		reads = append(reads, i)
+	}
+	sort.Ints(reads)
+	for _, i := range reads {
+		inst, _, _ := ctx.instructionAt(i)
+		bit, ok := pointerOf(indf, i)
+		if !ok || !readsFileRegister(inst) || loaded[i]&(1<<bit) != 0 {
+			continue
+		}
+		findings = append(findings, lintFinding{
+			Line:    ctx.expanded.SourceLines[i],
+			Message: tr("%s reads %s, but on some path its FSR has not been loaded; load the address into FSR first.", instructionText(inst), names[bit]),
+		})
+	}
+	return findings
+}
+
+// checkIndexedOperand validates the operand of an enhanced core MOVIW/MOVWI and returns a
+// message describing the problem, or "" if it is valid.
+func (ctx *lintContext) checkIndexedOperand(inst *Instruction) string {
+	opcode := strings.ToUpper(inst.Opcode)
+	if len(inst.Operands) != 1 {
+		return tr("%s takes one operand: k[FSRn], ++FSRn, --FSRn, FSRn++ or FSRn--.", opcode)
+	}
+	m := indexedOperandRegex.FindStringSubmatch(strings.TrimSpace(inst.Operands[0]))
+	if m == nil {
+		return tr("%s operand '%s' is not one of k[FSRn], ++FSRn, --FSRn, FSRn++ or FSRn--.", opcode, inst.Operands[0])
+	}
+	if m[1] == "" {
+		return ""
+	}
+	offset, err := ctx.assembler.evaluateExpression(strings.TrimSpace(m[1]))
+	if err != nil {
+		return ""
+	}
+	if offset < -32 || offset > 31 {
+		return tr("%s offset %d is outside -32..31.", opcode, offset)
+	}
+	return ""
+}
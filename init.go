@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// --- Project Scaffolding ---
+
+func init() {
+	registerSubcommand("init", "Create a starter project for a microcontroller", runInit)
+}
+
+// starterConfigSettings are the fuse settings the starter template asks for, in order.
+// Settings the target device does not have are left out.
+var starterConfigSettings = []string{
+	"_FOSC_INTOSCIO", "_WDTE_OFF", "_PWRTE_ON", "_MCLRE_ON", "_CP_OFF", "_CPD_OFF",
+	"_BOREN_OFF", "_IESO_OFF", "_FCMEN_OFF", "_LVP_OFF",
+}
+
+// starterConfigOptions returns the starter fuse settings supported by the device.
+func starterConfigOptions(mcConfig *MicrocontrollerConfig) []string {
+	var options []string
+	for _, setting := range starterConfigSettings {
+		for _, fuseMap := range mcConfig.AllConfigFuseMaps {
+			found := false
+			for _, group := range fuseMap {
+				if _, ok := group.Values[setting]; ok {
+					found = true
+					break
+				}
+			}
+			if found {
+				options = append(options, setting)
+				break
+			}
+		}
+	}
+	return options
+}
+
+// starterSource renders the template program: reset and interrupt vectors, config
+// directive, an ISR skeleton with context save/restore and an empty main loop.
+func starterSource(name, mcu string, configOptions []string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "; %s.asm - %s firmware\n", name, strings.ToUpper(mcu))
+	sb.WriteString(";\n; Build with: asm4pic -project asm4pic.json\n\n")
+	if len(configOptions) > 0 {
+		fmt.Fprintf(&sb, "        __CONFIG %s\n\n", strings.Join(configOptions, " & "))
+	}
+	sb.WriteString(`; --- Variables ---
+; Interrupt context is saved in shared RAM, reachable from every bank.
+W_TEMP  EQU     0x70
+STATUS_TEMP EQU 0x71
+PCLATH_TEMP EQU 0x72
+
+; --- Reset Vector ---
+        ORG     0x000
+        GOTO    INIT
+
+; --- Interrupt Vector ---
+        ORG     0x004
+ISR:
+        MOVWF   W_TEMP          ; Save W, STATUS and PCLATH
+        SWAPF   STATUS, W
+        CLRF    STATUS          ; Bank 0
+        MOVWF   STATUS_TEMP
+        MOVF    PCLATH, W
+        MOVWF   PCLATH_TEMP
+        CLRF    PCLATH
+
+        ; TODO: handle the interrupt and clear its flag
+
+        MOVF    PCLATH_TEMP, W  ; Restore context
+        MOVWF   PCLATH
+        SWAPF   STATUS_TEMP, W
+        MOVWF   STATUS
+        SWAPF   W_TEMP, F
+        SWAPF   W_TEMP, W
+        RETFIE
+
+; --- Initialization ---
+INIT:
+        ; TODO: configure I/O ports and peripherals
+        GOTO    MAIN_LOOP
+
+; --- Main Loop ---
+MAIN_LOOP:
+        ; TODO: application code
+        GOTO    MAIN_LOOP
+
+        END
+`)
+	return sb.String()
+}
+
+// starterGitignore keeps build outputs out of version control.
+const starterGitignore = `# asm4pic build outputs
+/build/
+`
+
+// runInit creates a starter project in a directory.
+func runInit(args []string) error {
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	mcu := fs.String("mcu", "", "Target microcontroller name, e.g., 'PIC16F687' (required)")
+	configDir := fs.String("config-dir", "./configs", "Directory containing microcontroller JSON config files")
+	dir := fs.String("dir", ".", "Directory to create the project in")
+	name := fs.String("name", "", "Project name (defaults to the directory name)")
+	force := fs.Bool("force", false, "Overwrite existing files")
+	fs.Parse(args)
+
+	if *mcu == "" {
+		return fmt.Errorf("-mcu is required")
+	}
+	mcConfig, err := loadMicrocontrollerConfigByName(*configDir, *mcu)
+	if err != nil {
+		return err
+	}
+	projectName := *name
+	if projectName == "" {
+		absDir, err := filepath.Abs(*dir)
+		if err != nil {
+			return err
+		}
+		projectName = filepath.Base(absDir)
+	}
+
+	// Point the project at the config directory as seen from the project directory
+	projectConfigDir, err := filepath.Abs(*configDir)
+	if err != nil {
+		return err
+	}
+	if absDir, err := filepath.Abs(*dir); err == nil {
+		if rel, err := filepath.Rel(absDir, projectConfigDir); err == nil {
+			projectConfigDir = rel
+		}
+	}
+
+	sourceName := projectName + ".asm"
+	project := ProjectFile{
+		Name:      projectName,
+		MCU:       strings.ToUpper(*mcu),
+		Source:    sourceName,
+		ConfigDir: filepath.ToSlash(projectConfigDir),
+		Hex:       "build/" + projectName + ".hex",
+		Report:    "build/" + projectName + "-report.txt",
+	}
+	projectJSON, err := json.MarshalIndent(project, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	files := []struct{ name, content string }{
+		{sourceName, starterSource(projectName, *mcu, starterConfigOptions(mcConfig))},
+		{defaultProjectFileName, string(projectJSON) + "\n"},
+		{".gitignore", starterGitignore},
+	}
+	if !*force {
+		for _, f := range files {
+			if _, err := os.Stat(filepath.Join(*dir, f.name)); err == nil {
+				return fmt.Errorf("%s already exists (use -force to overwrite)", filepath.Join(*dir, f.name))
+			}
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(*dir, "build"), 0755); err != nil {
+		return err
+	}
+	for _, f := range files {
+		if err := writeExportFile(filepath.Join(*dir, f.name), f.content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
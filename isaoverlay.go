@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// --- Instruction Set Overlays ---
+
+// isaOverlay is the layout of an instruction set overlay file: the INSTRUCTION_SET key of a
+// device config.
+type isaOverlay struct {
+	InstructionSet map[string]InstructionInfo `json:"INSTRUCTION_SET"`
+}
+
+// operandPlaceholders maps each operand kind to the opcode pattern letter its value is written
+// to, and the number of bits the encoder writes (0: as many as the pattern has).
+var operandPlaceholders = map[string]struct {
+	letter rune
+	bits   int
+}{
+	"f":   {'f', 0},
+	"d":   {'d', 1},
+	"a":   {'a', 1},
+	"b":   {'b', 3},
+	"k8":  {'L', 8},
+	"k11": {'k', 11},
+}
+
+// validateInstruction checks that an instruction can be encoded: the pattern is one program
+// word of 0, 1 and x bits plus one contiguous field of the right width per operand.
+func validateInstruction(name string, info InstructionInfo, wordBits int) error {
+	if len(info.OpcodePattern) != wordBits {
+		return fmt.Errorf("instruction '%s' has a %d-bit opcode pattern, but program words are %d bits", name, len(info.OpcodePattern), wordBits)
+	}
+	fields := make(map[rune]bool)
+	for _, kind := range info.Operands {
+		placeholder, ok := operandPlaceholders[kind]
+		if !ok {
+			kinds := make([]string, 0, len(operandPlaceholders))
+			for kind := range operandPlaceholders {
+				kinds = append(kinds, kind)
+			}
+			sort.Strings(kinds)
+			return fmt.Errorf("instruction '%s' has unknown operand kind '%s' (expected %s)", name, kind, strings.Join(kinds, ", "))
+		}
+		if fields[placeholder.letter] {
+			return fmt.Errorf("instruction '%s' has operand kind '%s' twice", name, kind)
+		}
+		fields[placeholder.letter] = true
+		first, last := strings.IndexRune(info.OpcodePattern, placeholder.letter), strings.LastIndex(info.OpcodePattern, string(placeholder.letter))
+		count := strings.Count(info.OpcodePattern, string(placeholder.letter))
+		switch {
+		case count == 0:
+			return fmt.Errorf("instruction '%s' has no '%c' bits for its '%s' operand", name, placeholder.letter, kind)
+		case last-first+1 != count:
+			return fmt.Errorf("instruction '%s' has its '%c' bits split", name, placeholder.letter)
+		case placeholder.bits != 0 && count != placeholder.bits:
+			return fmt.Errorf("instruction '%s' has %d '%c' bits; a '%s' operand takes %d", name, count, placeholder.letter, kind, placeholder.bits)
+		}
+	}
+	for _, c := range info.OpcodePattern {
+		if c != '0' && c != '1' && c != 'x' && !fields[c] {
+			return fmt.Errorf("instruction '%s' has '%c' in its opcode pattern, which is not a bit or a field of its operands", name, c)
+		}
+	}
+	return nil
+}
+
+// loadISAOverlay merges the instructions of an overlay file into the device config, for
+// undocumented instructions and experimental cores. Entries in the overlay replace device
+// instructions of the same name.
+func loadISAOverlay(path string, mcConfig *MicrocontrollerConfig) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read instruction set overlay '%s': %w", path, err)
+	}
+	var overlay isaOverlay
+	if err := json.Unmarshal(data, &overlay); err != nil {
+		return fmt.Errorf("could not parse JSON from '%s': %w", path, err)
+	}
+	if len(overlay.InstructionSet) == 0 {
+		return fmt.Errorf("%s: no INSTRUCTION_SET entries", path)
+	}
+	if mcConfig.InstructionSet == nil {
+		mcConfig.InstructionSet = make(map[string]InstructionInfo)
+	}
+	for name, info := range overlay.InstructionSet {
+		name = strings.ToUpper(name)
+		if err := validateInstruction(name, info, mcConfig.ProgramWordSizeBits); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		mcConfig.InstructionSet[name] = info
+	}
+	if err := validateInstructionAliases(mcConfig); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
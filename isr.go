@@ -0,0 +1,150 @@
+package main
+
+import "strings"
+
+// --- Interrupt Service Routine Lint ---
+
+func init() {
+	registerLintRule(lintRule{
+		Name:            "isr-context",
+		Description:     "Interrupt service routine changes W or STATUS without saving them, or does not end with RETFIE",
+		DefaultSeverity: SeverityWarning,
+		Check:           lintISRContext,
+	})
+}
+
+// writesW reports whether an instruction leaves a new value in W.
+func writesW(inst *Instruction, info InstructionInfo) bool {
+	switch strings.ToUpper(inst.Opcode) {
+	case "MOVLW", "ADDLW", "SUBLW", "ANDLW", "IORLW", "XORLW", "RETLW", "CLRW":
+		return true
+	}
+	dest, ok := operandOfKind(inst, info, "d")
+	return ok && (strings.ToUpper(dest) == "W" || dest == "0")
+}
+
+// reachableWithoutCalls is reachable, except that CALL only continues with the next
+// instruction: the result is the routine starting at roots without its subroutines.
+func (g *flowGraph) reachableWithoutCalls(roots []int) map[int]bool {
+	seen := make(map[int]bool)
+	stack := append([]int(nil), roots...)
+	for len(stack) > 0 {
+		i := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if seen[i] {
+			continue
+		}
+		seen[i] = true
+		inst := g.expanded.Lines[i].(*Instruction)
+		for _, next := range g.successors[i] {
+			if strings.ToUpper(inst.Opcode) == "CALL" && g.address[next] != g.address[i]+1 {
+				continue
+			}
+			stack = append(stack, next)
+		}
+	}
+	return seen
+}
+
+// lintISRContext checks the code placed at the interrupt vector with ORG. The routine must
+// save W (MOVWF as its first instruction) and STATUS (SWAPF/MOVF STATUS, W) before changing
+// them and restore both before returning, and it must return with RETFIE. Subroutines it
+// calls count as changing W and STATUS too.
+func lintISRContext(ctx *lintContext) []lintFinding {
+	resolve := func(operand string) (int, bool) {
+		val, err := ctx.assembler.evaluateExpression(operand)
+		return val, err == nil
+	}
+	hasVector := false
+	for _, item := range ctx.expanded.Lines {
+		if org, ok := item.(*OrgDirective); ok {
+			if addr, ok := resolve(org.Address); ok && addr == interruptVector {
+				hasVector = true
+			}
+		}
+	}
+	g := buildFlowGraph(ctx.expanded, ctx.mcConfig, resolve)
+	entry, ok := g.atAddress[interruptVector]
+	if !hasVector || !ok {
+		return nil
+	}
+	statusAddr, hasStatus := ctx.mcConfig.SFRMap["STATUS"]
+	if !hasStatus {
+		statusAddr = statusRegister
+	}
+
+	// The first instruction after any GOTOs must save W.
+	first := entry
+	for hops := 0; hops < len(g.address); hops++ {
+		if inst, _, _ := ctx.instructionAt(first); strings.ToUpper(inst.Opcode) != "GOTO" || len(g.successors[first]) != 1 {
+			break
+		}
+		first = g.successors[first][0]
+	}
+	wTemp := -1
+	if inst, info, ok := ctx.instructionAt(first); ok && strings.ToUpper(inst.Opcode) == "MOVWF" {
+		if _, addr, ok := ctx.fileRegisterAddress(inst, info); ok {
+			wTemp = addr
+		}
+	}
+
+	body := g.reachableWithoutCalls([]int{entry})
+	var savesStatus, restoresStatus, restoresW bool
+	var findings []lintFinding
+	for i := range ctx.expanded.Lines {
+		if !body[i] {
+			continue
+		}
+		inst, info, ok := ctx.instructionAt(i)
+		if !ok {
+			continue
+		}
+		opcode := strings.ToUpper(inst.Opcode)
+		_, addr, hasAddr := ctx.fileRegisterAddress(inst, info)
+		switch {
+		case opcode == "RETURN" || opcode == "RETLW":
+			findings = append(findings, lintFinding{Line: ctx.expanded.SourceLines[i], Message: tr("Interrupt service routine returns with %s; use RETFIE to re-enable interrupts.", opcode)})
+		case !hasAddr:
+		case (opcode == "SWAPF" || opcode == "MOVF") && addr == statusAddr && writesW(inst, info):
+			savesStatus = true
+		case opcode == "MOVWF" && addr == statusAddr:
+			restoresStatus = true
+		case (opcode == "SWAPF" || opcode == "MOVF") && addr == wTemp && writesW(inst, info):
+			restoresW = true
+		}
+	}
+	returns := false
+	for i := range body {
+		if flowOpcode(ctx.expanded, ctx.mcConfig, i) == "RETFIE" {
+			returns = true
+		}
+	}
+	if !returns {
+		findings = append(findings, lintFinding{Line: ctx.expanded.SourceLines[entry], Message: tr("Interrupt service routine never executes RETFIE.")})
+	}
+
+	// Report the first change to W and STATUS the routine does not undo.
+	changedW, changedStatus := false, false
+	routine := g.reachable([]int{entry})
+	for i := range ctx.expanded.Lines {
+		if !routine[i] {
+			continue
+		}
+		inst, info, ok := ctx.instructionAt(i)
+		if !ok {
+			continue
+		}
+		opcode := strings.ToUpper(inst.Opcode)
+		_, addr, hasAddr := ctx.fileRegisterAddress(inst, info)
+		if !changedW && writesW(inst, info) && !(wTemp >= 0 && restoresW) {
+			changedW = true
+			findings = append(findings, lintFinding{Line: ctx.expanded.SourceLines[i], Message: tr("Interrupt service routine changes W (%s) without saving and restoring it; start with MOVWF W_TEMP and end with SWAPF W_TEMP, F / SWAPF W_TEMP, W.", opcode)})
+		}
+		changesStatus := resultFlags(opcode) != 0 || (hasAddr && addr == statusAddr && writesFileRegister(inst, info))
+		if !changedStatus && changesStatus && !(savesStatus && restoresStatus) {
+			changedStatus = true
+			findings = append(findings, lintFinding{Line: ctx.expanded.SourceLines[i], Message: tr("Interrupt service routine changes STATUS (%s) without saving and restoring it; save it with SWAPF STATUS, W / MOVWF STATUS_TEMP and restore it with SWAPF STATUS_TEMP, W / MOVWF STATUS.", opcode)})
+		}
+	}
+	return findings
+}
@@ -0,0 +1,39 @@
+package main
+
+// --- Jump Table Directive ---
+
+func init() {
+	registerBuiltinMacro("JUMPTABLE", expandJumpTable)
+}
+
+// expandJumpTable implements JUMPTABLE label, target1[, target2...]: a routine at label that
+// jumps to the target selected by the index in W. Enhanced cores use BRW, which adds W to
+// the full program counter. Other cores use ADDWF PCL, F, which takes the high bits from
+// PCLATH: the routine loads PCLATH with the page of the table first, swapping it with W
+// through XOR so the index is kept, and the assembler checks that the table stays in one
+// page (see checkJumpTables).
+func expandJumpTable(p *ASMParser, operands []string, sourceLine int) ([]string, error) {
+	if len(operands) < 2 {
+		return nil, trErrorf("expected JUMPTABLE label, target1[, target2...]")
+	}
+	label := operands[0]
+	if !labelRegex.MatchString(label + ":") {
+		return nil, trErrorf("'%s' is not a valid label name", label)
+	}
+	lines := []string{label + ":"}
+	if p.enhancedCore {
+		lines = append(lines, "BRW")
+	} else {
+		lines = append(lines,
+			"MOVWF PCLATH",
+			"MOVLW HIGH("+label+" + 6)", // the first GOTO
+			"XORWF PCLATH, F",
+			"XORWF PCLATH, W",
+			"XORWF PCLATH, F",
+			"ADDWF PCL, F")
+	}
+	for _, target := range operands[1:] {
+		lines = append(lines, "GOTO "+target)
+	}
+	return lines, nil
+}
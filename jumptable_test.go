@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// jumpTableSource places a JUMPTABLE routine at org.
+func jumpTableSource(org int) string {
+	return fmt.Sprintf("        ORG 0\n        GOTO MAIN\n        ORG 0x%X\n        JUMPTABLE DISPATCH, ONE, TWO, THREE\n"+
+		"ONE:\n        RETURN\nTWO:\n        RETURN\nTHREE:\n        RETURN\n"+
+		"MAIN:\n        MOVLW 1\n        CALL DISPATCH\n        GOTO MAIN\n        END\n", org)
+}
+
+func TestJumpTableAcrossPageBoundaryFails(t *testing.T) {
+	// The routine takes six words before the table, which then spans 0xFE-0x100
+	_, _, err := assembleSource(jumpTableSource(0xF8), testConfig(t), nil)
+	if err == nil {
+		t.Fatal("table across 0xFF/0x100 assembled")
+	}
+	if code := errorDiagnostic(err).Code; code != CodeTablePageCrossing {
+		t.Errorf("error code = %s, want %s: %v", code, CodeTablePageCrossing, err)
+	}
+}
+
+func TestJumpTableLoadsPCLATH(t *testing.T) {
+	for org, page := range map[int]int{0x10: 0, 0x100: 1, 0x2F0: 2} {
+		assembler, _, err := assembleSource(jumpTableSource(org), testConfig(t), nil)
+		if err != nil {
+			t.Fatalf("table at 0x%X: %v", org, err)
+		}
+		if word := assembler.machineCodeWords[org+1]; word != 0x3000|page {
+			t.Errorf("table at 0x%X: word 0x%X = %#04x, want MOVLW %d", org, org+1, word, page)
+		}
+	}
+}
@@ -0,0 +1,469 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// --- Lint ---
+
+func init() {
+	registerSubcommand("lint", "Check assembly sources against a configurable rule set", runLint)
+
+	registerLintRule(lintRule{
+		Name:            "missing-end",
+		Description:     "Source file has no END directive",
+		DefaultSeverity: SeverityWarning,
+		Check:           lintMissingEnd,
+	})
+	registerLintRule(lintRule{
+		Name:            "magic-file-register",
+		Description:     "File register operand written as a numeric literal instead of a named symbol",
+		DefaultSeverity: SeverityWarning,
+		Check:           lintMagicFileRegister,
+	})
+	registerLintRule(lintRule{
+		Name:            "uninitialized-ram",
+		Description:     "RAM variable is read but never written anywhere in the program",
+		DefaultSeverity: SeverityWarning,
+		Check:           lintUninitializedRAM,
+	})
+	registerLintRule(lintRule{
+		Name:            "banking",
+		Description:     "Register accessed while RP0/RP1 select a different bank",
+		DefaultSeverity: SeverityWarning,
+		Check:           lintBanking,
+	})
+	registerLintRule(lintRule{
+		Name:            "naming",
+		Description:     "Label or symbol name does not match the naming convention",
+		DefaultSeverity: SeverityInfo,
+		Check:           lintNaming,
+	})
+}
+
+// SeverityInfo and SeverityOff are used by lint rules; SeverityInfo also marks optimizer log entries.
+const (
+	SeverityInfo = "info"
+	SeverityOff  = "off"
+)
+
+// lintRule is a single named check run by the lint subcommand.
+type lintRule struct {
+	Name            string
+	Description     string
+	DefaultSeverity string
+	Check           func(ctx *lintContext) []lintFinding
+}
+
+// lintFinding is one problem reported by a rule.
+type lintFinding struct {
+	Line    int
+	Message string
+}
+
+// lintContext gives rules access to the expanded program and its resolved symbols.
+type lintContext struct {
+	mcConfig      *MicrocontrollerConfig
+	expanded      *ExpandedParsedAssembly
+	assembler     *PicAssembler
+	namingPattern *regexp.Regexp
+}
+
+// lintRules holds every registered rule in registration order.
+var lintRules []lintRule
+
+// registerLintRule makes a rule available to the lint subcommand.
+func registerLintRule(rule lintRule) {
+	lintRules = append(lintRules, rule)
+}
+
+// lintConfig is the optional JSON file selecting rule severities.
+type lintConfig struct {
+	Rules         map[string]string `json:"rules"`
+	NamingPattern string            `json:"naming_pattern"`
+}
+
+// instructionAt returns the instruction at index i of the expanded program, if it is one.
+func (ctx *lintContext) instructionAt(i int) (*Instruction, InstructionInfo, bool) {
+	inst, ok := ctx.expanded.Lines[i].(*Instruction)
+	if !ok {
+		return nil, InstructionInfo{}, false
+	}
+	info, ok := ctx.mcConfig.InstructionSet[strings.ToUpper(inst.Opcode)]
+	return inst, info, ok
+}
+
+// operandOfKind returns the operand written for the given operand kind ("f", "b", "d", ...).
+func operandOfKind(inst *Instruction, info InstructionInfo, kind string) (string, bool) {
+	for idx, opKind := range info.Operands {
+		if opKind == kind && idx < len(inst.Operands) {
+			return inst.Operands[idx], true
+		}
+	}
+	return "", false
+}
+
+// fileRegisterAddress resolves the file register operand of an instruction.
+func (ctx *lintContext) fileRegisterAddress(inst *Instruction, info InstructionInfo) (string, int, bool) {
+	operand, ok := operandOfKind(inst, info, "f")
+	if !ok {
+		return "", 0, false
+	}
+	addr, err := ctx.assembler.evaluateExpression(operand)
+	if err != nil {
+		return operand, 0, false
+	}
+	return operand, addr, true
+}
+
+// writesFileRegister reports whether an instruction stores its result in the file register.
+func writesFileRegister(inst *Instruction, info InstructionInfo) bool {
+	switch strings.ToUpper(inst.Opcode) {
+	case "MOVWF", "CLRF", "BSF", "BCF":
+		return true
+	}
+	dest, ok := operandOfKind(inst, info, "d")
+	return ok && strings.ToUpper(dest) == "F"
+}
+
+// readsFileRegister reports whether an instruction uses the current value of its file register.
+func readsFileRegister(inst *Instruction) bool {
+	switch strings.ToUpper(inst.Opcode) {
+	case "MOVWF", "CLRF", "BSF", "BCF":
+		return false
+	}
+	return true
+}
+
+// isSkipInstruction reports whether an instruction may skip the one that follows it.
+func isSkipInstruction(opcode string) bool {
+	switch strings.ToUpper(opcode) {
+	case "BTFSS", "BTFSC", "DECFSZ", "INCFSZ":
+		return true
+	}
+	return false
+}
+
+// isSFRAddress reports whether addr is the address of a special function register.
+func isSFRAddress(mcConfig *MicrocontrollerConfig, addr int) bool {
+	for _, sfrAddr := range mcConfig.SFRMap {
+		if sfrAddr == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// coreMirroredRegisters are the midrange core registers mapped into every bank.
+var coreMirroredRegisters = map[int]bool{0x00: true, 0x02: true, 0x03: true, 0x04: true, 0x0A: true, 0x0B: true}
+
+func lintMissingEnd(ctx *lintContext) []lintFinding {
+	for _, item := range ctx.expanded.Lines {
+		if inst, ok := item.(*Instruction); ok && strings.ToUpper(inst.Opcode) == "END" {
+			return nil
+		}
+	}
+	lastLine := 1
+	if n := len(ctx.expanded.SourceLines); n > 0 {
+		lastLine = ctx.expanded.SourceLines[n-1]
+	}
+	return []lintFinding{{Line: lastLine, Message: tr("Missing END directive.")}}
+}
+
+func lintMagicFileRegister(ctx *lintContext) []lintFinding {
+	var findings []lintFinding
+	for i := range ctx.expanded.Lines {
+		inst, info, ok := ctx.instructionAt(i)
+		if !ok {
+			continue
+		}
+		operand, ok := operandOfKind(inst, info, "f")
+		if !ok {
+			continue
+		}
+		if _, err := ctx.assembler.evaluateExpression(operand); err == nil && isNumericLiteral(operand) {
+			findings = append(findings, lintFinding{Line: ctx.expanded.SourceLines[i], Message: tr("File register written as literal '%s' in %s; give it a name with EQU.", operand, strings.ToUpper(inst.Opcode))})
+		}
+	}
+	return findings
+}
+
+// isNumericLiteral reports whether an operand is a plain number rather than a symbol.
+func isNumericLiteral(operand string) bool {
+	operand = strings.TrimSpace(operand)
+	return operand != "" && (operand[0] >= '0' && operand[0] <= '9' || operand[0] == '$' || operand[0] == '%')
+}
+
+func lintUninitializedRAM(ctx *lintContext) []lintFinding {
+	written := make(map[int]bool)
+	type read struct {
+		line    int
+		operand string
+		addr    int
+	}
+	var reads []read
+
+	for i := range ctx.expanded.Lines {
+		inst, info, ok := ctx.instructionAt(i)
+		if !ok {
+			continue
+		}
+		operand, addr, ok := ctx.fileRegisterAddress(inst, info)
+		if !ok || isSFRAddress(ctx.mcConfig, addr) || coreMirroredRegisters[addr&0x7F] {
+			continue
+		}
+		if writesFileRegister(inst, info) {
+			written[addr] = true
+		}
+		if readsFileRegister(inst) {
+			reads = append(reads, read{ctx.expanded.SourceLines[i], operand, addr})
+		}
+	}
+
+	var findings []lintFinding
+	reported := make(map[int]bool)
+	for _, r := range reads {
+		if !written[r.addr] && !reported[r.addr] {
+			reported[r.addr] = true
+			findings = append(findings, lintFinding{Line: r.line, Message: tr("'%s' (0x%02X) is read but never written.", r.operand, r.addr)})
+		}
+	}
+	return findings
+}
+
+func lintBanking(ctx *lintContext) []lintFinding {
+	if ctx.mcConfig.RAMLayout != nil && len(ctx.mcConfig.RAMLayout.Access) > 0 {
+		return nil // PIC18: the assembler checks the BSR of banked operands itself
+	}
+	const unknown = -1
+	rp0, rp1 := unknown, unknown
+	statusAddr, hasStatus := ctx.mcConfig.SFRMap["STATUS"]
+
+	var findings []lintFinding
+	previousSkips := false
+	for i, item := range ctx.expanded.Lines {
+		switch item.(type) {
+		case *Label, *OrgDirective:
+			// Control can arrive here from anywhere, so the bank is no longer known.
+			rp0, rp1 = unknown, unknown
+			previousSkips = false
+			continue
+		}
+		inst, info, ok := ctx.instructionAt(i)
+		if !ok {
+			continue
+		}
+		opcode := strings.ToUpper(inst.Opcode)
+		_, addr, hasAddr := ctx.fileRegisterAddress(inst, info)
+
+		if hasAddr && !coreMirroredRegisters[addr&0x7F] && !isSharedRAM(ctx.mcConfig, addr) && rp0 != unknown && rp1 != unknown {
+			if bank := addr >> 7; bank != rp1<<1|rp0 {
+				findings = append(findings, lintFinding{
+					Line:    ctx.expanded.SourceLines[i],
+					Message: tr("%s accesses '%s' in bank %d while bank %d is selected.", opcode, inst.Operands[0], bank, rp1<<1|rp0),
+				})
+			}
+		}
+
+		// Track writes to the bank select bits of STATUS.
+		if hasStatus && hasAddr && addr == statusAddr {
+			set := unknown
+			switch opcode {
+			case "BSF":
+				set = 1
+			case "BCF":
+				set = 0
+			case "CLRF":
+				rp0, rp1 = 0, 0
+			default:
+				if writesFileRegister(inst, info) {
+					rp0, rp1 = unknown, unknown
+				}
+			}
+			if set != unknown {
+				if previousSkips {
+					set = unknown
+				}
+				if bitOperand, ok := operandOfKind(inst, info, "b"); ok {
+					if bit, err := ctx.assembler.evaluateExpression(bitOperand); err == nil {
+						switch bit {
+						case 5:
+							rp0 = set
+						case 6:
+							rp1 = set
+						}
+					}
+				}
+			}
+		}
+		if opcode == "CALL" {
+			rp0, rp1 = unknown, unknown
+		}
+		previousSkips = isSkipInstruction(opcode)
+	}
+	return findings
+}
+
+func lintNaming(ctx *lintContext) []lintFinding {
+	var findings []lintFinding
+	for i, item := range ctx.expanded.Lines {
+		var name, kind string
+		switch v := item.(type) {
+		case *Label:
+			name, kind = v.Name, "Label"
+		case *EquDirective:
+			name, kind = v.Symbol, "Symbol"
+		default:
+			continue
+		}
+		if !ctx.namingPattern.MatchString(name) {
+			findings = append(findings, lintFinding{Line: ctx.expanded.SourceLines[i], Message: tr("%s '%s' does not match naming pattern %s.", translate(kind), name, ctx.namingPattern)})
+		}
+	}
+	return findings
+}
+
+// lintSource runs every enabled rule over one source file, whose includes and data files are
+// looked up from sourceDir, and returns its findings as diagnostics.
+func lintSource(source, sourceDir string, mcConfig *MicrocontrollerConfig, severities map[string]string, namingPattern *regexp.Regexp) []Diagnostic {
+	var diagnostics []Diagnostic
+
+	parser := newParser(mcConfig, &AssemblyOptions{SourceDir: sourceDir})
+	parsedData, err := parser.Parse(source)
+	if err != nil {
+		return []Diagnostic{{Severity: SeverityError, Message: err.Error()}}
+	}
+	expanded, err := parser.ExpandMacros(parsedData)
+	if err != nil {
+		return []Diagnostic{{Severity: SeverityError, Message: err.Error()}}
+	}
+	assembler := NewPicAssembler(mcConfig, expanded)
+	assembler.predefined, assembler.defines = parser.predefined, parsedData.Defines
+	if err := assembler.firstPass(); err != nil {
+		diagnostics = append(diagnostics, Diagnostic{Severity: SeverityError, Message: err.Error()})
+	}
+
+	ctx := &lintContext{mcConfig: mcConfig, expanded: expanded, assembler: assembler, namingPattern: namingPattern}
+	for _, rule := range lintRules {
+		severity := severities[rule.Name]
+		if severity == SeverityOff {
+			continue
+		}
+		for _, f := range rule.Check(ctx) {
+			diagnostics = append(diagnostics, Diagnostic{Severity: severity, Line: f.Line, Message: fmt.Sprintf("%s [%s]", f.Message, rule.Name)})
+		}
+	}
+	sort.SliceStable(diagnostics, func(i, j int) bool { return diagnostics[i].Line < diagnostics[j].Line })
+	return diagnostics
+}
+
+// ruleSeverityFlag collects repeated -rule name=severity flags.
+type ruleSeverityFlag map[string]string
+
+func (f ruleSeverityFlag) String() string { return "" }
+
+func (f ruleSeverityFlag) Set(value string) error {
+	name, severity, ok := strings.Cut(value, "=")
+	if !ok {
+		return fmt.Errorf("expected name=severity, got '%s'", value)
+	}
+	f[strings.TrimSpace(name)] = strings.ToLower(strings.TrimSpace(severity))
+	return nil
+}
+
+// runLint lints each source file given on the command line.
+func runLint(args []string) error {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	mcu := fs.String("mcu", "", "Target microcontroller name, e.g., 'PIC16F687' (required)")
+	configDir := fs.String("config-dir", "./configs", "Directory containing microcontroller JSON config files")
+	lintConfigPath := fs.String("lint-config", "", "JSON file with per-rule severities and the naming pattern")
+	namingPattern := fs.String("naming-pattern", "^[A-Z][A-Z0-9_]*$", "Regular expression labels and symbols must match")
+	listRules := fs.Bool("list-rules", false, "List the available rules and exit")
+	overrides := ruleSeverityFlag{}
+	fs.Var(overrides, "rule", "Set a rule's severity as name=off|info|warning|error (repeatable)")
+	fs.Parse(args)
+
+	if *listRules {
+		for _, rule := range lintRules {
+			fmt.Printf("  %-22s %-8s %s\n", rule.Name, rule.DefaultSeverity, rule.Description)
+		}
+		return nil
+	}
+	if *mcu == "" || fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("-mcu and at least one source file are required")
+	}
+
+	severities := make(map[string]string)
+	for _, rule := range lintRules {
+		severities[rule.Name] = rule.DefaultSeverity
+	}
+	pattern := *namingPattern
+	if *lintConfigPath != "" {
+		content, err := os.ReadFile(*lintConfigPath)
+		if err != nil {
+			return fmt.Errorf("could not read lint config '%s': %w", *lintConfigPath, err)
+		}
+		var cfg lintConfig
+		if err := json.Unmarshal(content, &cfg); err != nil {
+			return fmt.Errorf("could not parse lint config '%s': %w", *lintConfigPath, err)
+		}
+		for name, severity := range cfg.Rules {
+			severities[name] = strings.ToLower(severity)
+		}
+		if cfg.NamingPattern != "" {
+			pattern = cfg.NamingPattern
+		}
+	}
+	for name, severity := range overrides {
+		severities[name] = severity
+	}
+	known := make(map[string]bool)
+	for _, rule := range lintRules {
+		known[rule.Name] = true
+	}
+	for name, severity := range severities {
+		if !known[name] {
+			return fmt.Errorf("unknown lint rule '%s'", name)
+		}
+		switch severity {
+		case SeverityOff, SeverityInfo, SeverityWarning, SeverityError:
+		default:
+			return fmt.Errorf("invalid severity '%s' for rule '%s'", severity, name)
+		}
+	}
+	namingRegex, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid naming pattern: %w", err)
+	}
+
+	mcConfig, err := loadMicrocontrollerConfigByName(*configDir, *mcu)
+	if err != nil {
+		return err
+	}
+
+	errorCount := 0
+	for _, path := range fs.Args() {
+		source, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("could not read '%s': %w", path, err)
+		}
+		for _, d := range lintSource(string(source), filepath.Dir(path), mcConfig, severities, namingRegex) {
+			fmt.Printf("%s:%d: %s: %s\n", path, d.Line, d.Severity, d.Message)
+			if d.Severity == SeverityError {
+				errorCount++
+			}
+		}
+	}
+	if errorCount > 0 {
+		return fmt.Errorf("%d error(s) found", errorCount)
+	}
+	return nil
+}
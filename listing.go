@@ -0,0 +1,233 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// --- Listing ---
+
+// ListingDirective is a directive that only affects the listing and report: EXPAND, NOEXPAND,
+// LIST, NOLIST, TITLE, SUBTITL (or SUBTITLE) and PAGE. Operand is the directive text with
+// quotes removed; the options of LIST are not used.
+type ListingDirective struct {
+	Name    string // upper-case directive name
+	Operand string
+}
+
+func (l *ListingDirective) isAssemblyItem() {}
+
+var listingRegex = regexp.MustCompile(`(?i)^(EXPAND|NOEXPAND|NOLIST|LIST|TITLE|SUBTITLE|SUBTITL|PAGE)(?:\s+(.*))?$`)
+
+func init() {
+	for _, name := range []string{"EXPAND", "NOEXPAND", "NOLIST", "LIST", "TITLE", "SUBTITL", "SUBTITLE", "PAGE"} {
+		directiveKeywords[name] = true
+	}
+}
+
+// listingRow is a line of code pulled into the listing from a macro or include file.
+type listingRow struct {
+	loc, object, text string
+}
+
+// origin returns the provenance of the expanded item at index i, or nil if unknown.
+func (a *PicAssembler) origin(i int) *Provenance {
+	if i < len(a.parsedAssembly.Origins) {
+		return a.parsedAssembly.Origins[i]
+	}
+	return nil
+}
+
+// listingPageLength is the number of lines per listing page, headers included.
+const listingPageLength = 60
+
+// listingTitles returns the text of the first TITLE and SUBTITL directives.
+func (a *PicAssembler) listingTitles() (title, subtitle string) {
+	for _, item := range a.parsedAssembly.Lines {
+		if d, ok := item.(*ListingDirective); ok {
+			switch {
+			case d.Name == "TITLE" && title == "":
+				title = d.Operand
+			case (d.Name == "SUBTITL" || d.Name == "SUBTITLE") && subtitle == "":
+				subtitle = d.Operand
+			}
+		}
+	}
+	return title, subtitle
+}
+
+// GenerateListing renders an MPASM-style listing of the main source: every line with the
+// address and machine word it produced, followed by the code pulled in from include files and,
+// while EXPAND is in effect, the code expanded from macros. expand is the state at the start.
+// Code between NOLIST and LIST is assembled but left out; the lines holding the two
+// directives are listed to show where the gap is. Pages are headed by the TITLE and SUBTITL
+// text and broken every listingPageLength lines and at PAGE directives.
+func (a *PicAssembler) GenerateListing(rawText string, expand bool) string {
+	byLine := make(map[int][]int)
+	for i := range a.parsedAssembly.Lines {
+		line := a.sourceLine(i)
+		byLine[line] = append(byLine[line], i)
+	}
+
+	title, subtitle := a.listingTitles()
+	list := true
+	var listing strings.Builder
+	page, pageLines := 0, listingPageLength
+	newPage := func() {
+		if page > 0 {
+			listing.WriteString("\f")
+		}
+		page++
+		listing.WriteString(fmt.Sprintf("%-68s Page %d\n", strings.TrimSpace("asm4PIC  "+title), page))
+		listing.WriteString(subtitle + "\n\n")
+		listing.WriteString(fmt.Sprintf("%-8s  %-4s  %5s  %s\n\n", "LOC", "OBJ", "LINE", "SOURCE TEXT"))
+		pageLines = 5
+	}
+	row := func(loc, object, line, text string) {
+		if pageLines >= listingPageLength {
+			newPage()
+		}
+		listing.WriteString(strings.TrimRight(fmt.Sprintf("%-8s  %-4s  %5s  %s", loc, object, line, text), " ") + "\n")
+		pageLines++
+	}
+
+	for n, text := range strings.Split(strings.TrimRight(rawText, "\n"), "\n") {
+		loc, object := "", ""
+		breakAfter := false
+		listed := list
+		var generated []listingRow
+		for _, i := range byLine[n+1] {
+			origin := a.origin(i)
+			direct := origin == nil || (len(origin.Chain) == 0 && origin.Location.File == "")
+			if _, ok := a.parsedAssembly.Lines[i].(*ListingDirective); !list && !ok {
+				continue
+			}
+			switch v := a.parsedAssembly.Lines[i].(type) {
+			case *ListingDirective:
+				switch v.Name {
+				case "LIST":
+					list, listed = true, true
+				case "NOLIST":
+					list = false
+				case "EXPAND":
+					expand = true
+				case "NOEXPAND":
+					expand = false
+				case "SUBTITL", "SUBTITLE":
+					subtitle = v.Operand
+				case "PAGE":
+					breakAfter = true
+				}
+			case *EquDirective:
+				if val, ok := a.symbolTable[v.Symbol]; ok && direct {
+					loc = fmt.Sprintf("%08X", val)
+				}
+			case *ReserveDirective:
+				if val, ok := a.symbolTable[v.Symbol]; ok && direct {
+					loc = fmt.Sprintf("%08X", val)
+				}
+			case *Label:
+				if !direct && (expand || len(origin.Chain) == 0) {
+					generated = append(generated, listingRow{text: v.Name + ":"})
+				}
+			case *Instruction, *DataDirective:
+				addr, hasCode := a.itemAddresses[i]
+				if !hasCode {
+					continue
+				}
+				addrText, wordText := fmt.Sprintf("%04X", addr), fmt.Sprintf("%04X", a.machineCodeWords[addr])
+				if direct {
+					loc, object = addrText, wordText
+				} else if by := origin.insertedBy(); by != "" {
+					generated = append(generated, listingRow{addrText, wordText, expandedItemText(v) + "    ; inserted by " + by})
+				} else if expand || len(origin.Chain) == 0 {
+					generated = append(generated, listingRow{addrText, wordText, expandedItemText(v)})
+				} else if loc == "" {
+					loc = addrText // hidden expansion: show where it starts
+				}
+			}
+		}
+		if !listed {
+			continue
+		}
+		row(loc, object, fmt.Sprint(n+1), strings.TrimRight(text, "\r"))
+		for _, g := range generated {
+			row(g.loc, g.object, "+", g.text)
+		}
+		if breakAfter {
+			pageLines = listingPageLength
+		}
+	}
+	return listing.String()
+}
+
+// expandedItemText renders an expanded item as source text, or "" for items
+// that only affect the listing.
+func expandedItemText(item AssemblyItem) string {
+	switch v := item.(type) {
+	case *Label:
+		return v.Name + ":"
+	case *Instruction:
+		return "    " + instructionText(v)
+	case *EquDirective:
+		return v.Symbol + " EQU " + v.Value
+	case *OrgDirective:
+		return "    ORG " + v.Address
+	case *DataSection:
+		return strings.TrimSpace(v.Name + " " + v.Kind + " " + v.Address)
+	case *ReserveDirective:
+		return v.Symbol + " RES " + v.Count
+	case *ConfigDirective:
+		if v.Word != "" {
+			return "    __CONFIG " + v.Word + ", " + strings.Join(v.Options, " & ")
+		}
+		return "    __CONFIG " + strings.Join(v.Options, " & ")
+	case *VersionString:
+		operands := []string{`"` + v.Text + `"`}
+		if v.Address != "" {
+			operands = append(operands, v.Address)
+		}
+		if v.Packed {
+			operands = append(operands, "PACKED")
+		}
+		return "    __VERSIONSTR " + strings.Join(operands, ", ")
+	case *DataDirective:
+		return "    " + v.Name + " " + v.Operands
+	case *SelfWriteDirective:
+		if v.End {
+			return "    ENDSELFWRITE"
+		}
+		return "    SELFWRITE"
+	}
+	return ""
+}
+
+// expandedSource renders the code consumed by the assembler passes, after macro, define,
+// alias and include expansion and any optimization: one row per item with its address,
+// source line and text, annotated with where expanded items came from.
+func (a *PicAssembler) expandedSource() string {
+	var out strings.Builder
+	for i, item := range a.parsedAssembly.Lines {
+		text := expandedItemText(item)
+		if text == "" {
+			continue
+		}
+		addr := ""
+		switch v := item.(type) {
+		case *Label:
+			if val, ok := a.labels[v.Name]; ok {
+				addr = fmt.Sprintf("0x%04X", val)
+			}
+		case *Instruction, *DataDirective, *VersionString:
+			if val, ok := a.itemAddresses[i]; ok {
+				addr = fmt.Sprintf("0x%04X", val)
+			}
+		}
+		if origin := a.origin(i).String(); origin != "" {
+			text = fmt.Sprintf("%-32s ; %s", text, origin)
+		}
+		out.WriteString(fmt.Sprintf("  %-6s  %4d: %s\n", addr, a.sourceLine(i), text))
+	}
+	return out.String()
+}
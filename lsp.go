@@ -0,0 +1,466 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// --- Language Server ---
+
+func init() {
+	registerSubcommand("lsp", "Run a Language Server Protocol server on stdin/stdout", runLSP)
+}
+
+// lspRequest is an incoming JSON-RPC request or notification.
+type lspRequest struct {
+	ID     *json.RawMessage `json:"id,omitempty"`
+	Method string           `json:"method"`
+	Params json.RawMessage  `json:"params,omitempty"`
+}
+
+// lspResponse is an outgoing JSON-RPC response. Result is always encoded, even when null.
+type lspResponse struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Result  interface{}      `json:"result"`
+	Error   *lspError        `json:"error,omitempty"`
+}
+
+// lspNotification is an outgoing JSON-RPC notification.
+type lspNotification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+type lspError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspLocation struct {
+	URI   string   `json:"uri"`
+	Range lspRange `json:"range"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Code     string   `json:"code,omitempty"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type lspTextDocumentPositionParams struct {
+	TextDocument struct {
+		URI string `json:"uri"`
+	} `json:"textDocument"`
+	Position lspPosition `json:"position"`
+}
+
+type lspCompletionItem struct {
+	Label  string `json:"label"`
+	Kind   int    `json:"kind"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// LSP enumeration values used by this server.
+const (
+	lspSeverityError   = 1
+	lspSeverityWarning = 2
+
+	lspCompletionFunction  = 3
+	lspCompletionVariable  = 6
+	lspCompletionKeyword   = 14
+	lspCompletionReference = 18
+	lspCompletionConstant  = 21
+
+	lspErrorMethodNotFound = -32601
+)
+
+// lspServer holds the open documents and the device configuration used to check them.
+type lspServer struct {
+	configDir string
+	mcu       string
+	mcConfig  *MicrocontrollerConfig
+	documents map[string]string
+	// assembled keeps the last successful assembly of each document for label addresses.
+	assembled map[string]*PicAssembler
+	writer    io.Writer
+}
+
+// runLSP serves the Language Server Protocol over stdin/stdout until the client exits.
+func runLSP(args []string) error {
+	fs := flag.NewFlagSet("lsp", flag.ExitOnError)
+	mcu := fs.String("mcu", "", "Target microcontroller name (may also be set through initializationOptions.mcu)")
+	configDir := fs.String("config-dir", "./configs", "Directory containing microcontroller JSON config files")
+	fs.Parse(args)
+
+	server := &lspServer{
+		configDir: *configDir,
+		documents: make(map[string]string),
+		assembled: make(map[string]*PicAssembler),
+		writer:    os.Stdout,
+	}
+	if *mcu != "" {
+		if err := server.loadConfig(*mcu); err != nil {
+			return err
+		}
+	}
+	return server.serve(bufio.NewReader(os.Stdin))
+}
+
+// loadConfig switches the server to a new target MCU.
+func (s *lspServer) loadConfig(mcu string) error {
+	mcConfig, err := loadMicrocontrollerConfigByName(s.configDir, mcu)
+	if err != nil {
+		return err
+	}
+	s.mcu, s.mcConfig = mcu, mcConfig
+	return nil
+}
+
+// serve reads framed JSON-RPC messages and dispatches them until "exit" or end of input.
+func (s *lspServer) serve(reader *bufio.Reader) error {
+	for {
+		body, err := readLSPMessage(reader)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var req lspRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			continue
+		}
+		if req.Method == "exit" {
+			return nil
+		}
+		result, rpcErr := s.handle(req)
+		if req.ID != nil {
+			s.send(lspResponse{JSONRPC: "2.0", ID: req.ID, Result: result, Error: rpcErr})
+		}
+	}
+}
+
+// readLSPMessage reads one Content-Length framed message body.
+func readLSPMessage(reader *bufio.Reader) ([]byte, error) {
+	contentLength := -1
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if name, value, ok := strings.Cut(line, ":"); ok && strings.EqualFold(name, "Content-Length") {
+			contentLength, err = strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return nil, fmt.Errorf("invalid Content-Length header: %w", err)
+			}
+		}
+	}
+	if contentLength < 0 {
+		return nil, errors.New("message without Content-Length header")
+	}
+	body := make([]byte, contentLength)
+	if _, err := io.ReadFull(reader, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// send frames and writes a message to the client.
+func (s *lspServer) send(message interface{}) {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(s.writer, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+// handle dispatches a single request or notification.
+func (s *lspServer) handle(req lspRequest) (interface{}, *lspError) {
+	switch req.Method {
+	case "initialize":
+		var params struct {
+			InitializationOptions struct {
+				MCU       string `json:"mcu"`
+				ConfigDir string `json:"configDir"`
+			} `json:"initializationOptions"`
+		}
+		json.Unmarshal(req.Params, &params)
+		if params.InitializationOptions.ConfigDir != "" {
+			s.configDir = params.InitializationOptions.ConfigDir
+		}
+		if params.InitializationOptions.MCU != "" {
+			if err := s.loadConfig(params.InitializationOptions.MCU); err != nil {
+				return nil, &lspError{Code: -32602, Message: err.Error()}
+			}
+		}
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync":   1, // Full document sync
+				"definitionProvider": true,
+				"hoverProvider":      true,
+				"completionProvider": map[string]interface{}{},
+			},
+			"serverInfo": map[string]string{"name": "asm4PIC"},
+		}, nil
+
+	case "textDocument/didOpen":
+		var params struct {
+			TextDocument struct {
+				URI  string `json:"uri"`
+				Text string `json:"text"`
+			} `json:"textDocument"`
+		}
+		json.Unmarshal(req.Params, &params)
+		s.documents[params.TextDocument.URI] = params.TextDocument.Text
+		s.publishDiagnostics(params.TextDocument.URI)
+
+	case "textDocument/didChange":
+		var params struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+			ContentChanges []struct {
+				Text string `json:"text"`
+			} `json:"contentChanges"`
+		}
+		json.Unmarshal(req.Params, &params)
+		if n := len(params.ContentChanges); n > 0 {
+			s.documents[params.TextDocument.URI] = params.ContentChanges[n-1].Text
+			s.publishDiagnostics(params.TextDocument.URI)
+		}
+
+	case "textDocument/didClose":
+		var params struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+		}
+		json.Unmarshal(req.Params, &params)
+		delete(s.documents, params.TextDocument.URI)
+		delete(s.assembled, params.TextDocument.URI)
+		s.send(lspNotification{JSONRPC: "2.0", Method: "textDocument/publishDiagnostics", Params: map[string]interface{}{
+			"uri": params.TextDocument.URI, "diagnostics": []lspDiagnostic{},
+		}})
+
+	case "textDocument/definition":
+		var params lspTextDocumentPositionParams
+		json.Unmarshal(req.Params, &params)
+		return s.definition(params), nil
+
+	case "textDocument/hover":
+		var params lspTextDocumentPositionParams
+		json.Unmarshal(req.Params, &params)
+		return s.hover(params), nil
+
+	case "textDocument/completion":
+		return s.completion(), nil
+
+	case "initialized", "shutdown", "$/cancelRequest", "$/setTrace":
+		// Nothing to do
+
+	default:
+		if req.ID != nil {
+			return nil, &lspError{Code: lspErrorMethodNotFound, Message: "method not found: " + req.Method}
+		}
+	}
+	return nil, nil
+}
+
+// lineRange returns a range covering the whole of a 1-based source line.
+func lineRange(text string, line int) lspRange {
+	lines := strings.Split(text, "\n")
+	if line < 1 {
+		line = 1
+	}
+	length := 0
+	if line <= len(lines) {
+		length = len(strings.TrimRight(lines[line-1], "\r"))
+	}
+	return lspRange{Start: lspPosition{Line: line - 1}, End: lspPosition{Line: line - 1, Character: length}}
+}
+
+// publishDiagnostics assembles a document and sends its warnings and errors to the client.
+func (s *lspServer) publishDiagnostics(uri string) {
+	text := s.documents[uri]
+	diagnostics := []lspDiagnostic{}
+
+	if s.mcConfig == nil {
+		diagnostics = append(diagnostics, lspDiagnostic{
+			Range: lineRange(text, 1), Severity: lspSeverityWarning, Source: "asm4PIC",
+			Message: "No target MCU configured; start the server with -mcu or set initializationOptions.mcu.",
+		})
+	} else {
+		// Includes and data files are looked up next to the document, as for a file on disk
+		opts := &AssemblyOptions{SourceDir: filepath.Dir(uriToPath(uri))}
+		assembler, warnings, err := assembleSource(text, s.mcConfig, opts)
+		for _, w := range warnings {
+			diagnostics = append(diagnostics, lspDiagnostic{Range: lineRange(text, w.Line), Severity: lspSeverityWarning, Code: w.Code, Source: "asm4PIC", Message: w.Message})
+		}
+		if err != nil {
+			d := errorDiagnostic(err)
+			diagnostics = append(diagnostics, lspDiagnostic{Range: lineRange(text, d.Line), Severity: lspSeverityError, Code: d.Code, Source: "asm4PIC", Message: d.Message})
+		} else {
+			s.assembled[uri] = assembler
+		}
+	}
+
+	s.send(lspNotification{JSONRPC: "2.0", Method: "textDocument/publishDiagnostics", Params: map[string]interface{}{
+		"uri": uri, "diagnostics": diagnostics,
+	}})
+}
+
+// uriToPath returns the file path of a file:// document URI, or "" for another scheme, so
+// that its directory is the working directory.
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return ""
+	}
+	path := u.Path
+	// file:///C:/dir/main.asm names a Windows drive
+	if len(path) > 2 && path[0] == '/' && path[2] == ':' {
+		path = path[1:]
+	}
+	return filepath.FromSlash(path)
+}
+
+// wordAt returns the identifier under the given position.
+func wordAt(text string, pos lspPosition) string {
+	lines := strings.Split(text, "\n")
+	if pos.Line < 0 || pos.Line >= len(lines) {
+		return ""
+	}
+	line := lines[pos.Line]
+	isWordChar := func(c byte) bool {
+		return c == '_' || (c >= '0' && c <= '9') || (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z')
+	}
+	start, end := pos.Character, pos.Character
+	if start > len(line) {
+		start, end = len(line), len(line)
+	}
+	for start > 0 && isWordChar(line[start-1]) {
+		start--
+	}
+	for end < len(line) && isWordChar(line[end]) {
+		end++
+	}
+	return line[start:end]
+}
+
+// definition resolves the symbol under the cursor to where it is defined in the document.
+func (s *lspServer) definition(params lspTextDocumentPositionParams) interface{} {
+	uri := params.TextDocument.URI
+	text := s.documents[uri]
+	word := wordAt(text, params.Position)
+	if word == "" {
+		return nil
+	}
+	def, ok := lookupSymbolDefinition(findSymbolDefinitions(text), word)
+	if !ok {
+		return nil
+	}
+	pos := lspPosition{Line: def.Line - 1, Character: def.Column}
+	return lspLocation{URI: uri, Range: lspRange{Start: pos, End: lspPosition{Line: pos.Line, Character: pos.Character + len(def.Name)}}}
+}
+
+// hover describes instructions, SFRs and document symbols under the cursor.
+func (s *lspServer) hover(params lspTextDocumentPositionParams) interface{} {
+	uri := params.TextDocument.URI
+	text := s.documents[uri]
+	word := wordAt(text, params.Position)
+	if word == "" {
+		return nil
+	}
+
+	var contents string
+	if s.mcConfig != nil {
+		if info, ok := s.mcConfig.InstructionSet[strings.ToUpper(word)]; ok {
+			operands := "none"
+			if len(info.Operands) > 0 {
+				operands = strings.Join(info.Operands, ", ")
+			}
+			contents = fmt.Sprintf("**%s** (%s)\n\nEncoding: `%s`\n\nOperands: %s", strings.ToUpper(word), s.mcu, info.OpcodePattern, operands)
+		} else if addr, ok := s.mcConfig.SFRMap[strings.ToUpper(word)]; ok {
+			contents = fmt.Sprintf("**%s** special function register\n\nAddress: `0x%03X` (bank %d)", strings.ToUpper(word), addr, addr>>7)
+		}
+	}
+	if contents == "" {
+		if def, ok := lookupSymbolDefinition(findSymbolDefinitions(text), word); ok {
+			contents = fmt.Sprintf("**%s** %s (line %d)", def.Name, def.Kind, def.Line)
+			if def.Value != "" {
+				contents += fmt.Sprintf("\n\nValue: `%s`", def.Value)
+			}
+			if assembler, ok := s.assembled[uri]; ok && def.Kind == SymbolKindLabel {
+				if addr, ok := assembler.labels[def.Name]; ok {
+					contents += fmt.Sprintf("\n\nAddress: `0x%04X`", addr)
+				}
+			}
+		}
+	}
+	if contents == "" {
+		return nil
+	}
+	return map[string]interface{}{
+		"contents": map[string]string{"kind": "markdown", "value": contents},
+	}
+}
+
+// completion offers opcodes, SFR names and every symbol defined in open documents.
+func (s *lspServer) completion() interface{} {
+	items := []lspCompletionItem{}
+	if s.mcConfig != nil {
+		for opcode, info := range s.mcConfig.InstructionSet {
+			items = append(items, lspCompletionItem{Label: opcode, Kind: lspCompletionKeyword, Detail: info.OpcodePattern})
+		}
+		for name, addr := range s.mcConfig.SFRMap {
+			items = append(items, lspCompletionItem{Label: name, Kind: lspCompletionVariable, Detail: fmt.Sprintf("SFR 0x%03X", addr)})
+		}
+	}
+
+	kinds := map[string]int{
+		SymbolKindLabel:  lspCompletionReference,
+		SymbolKindEqu:    lspCompletionConstant,
+		SymbolKindDefine: lspCompletionConstant,
+		SymbolKindMacro:  lspCompletionFunction,
+	}
+	seen := make(map[string]bool)
+	for _, text := range s.documents {
+		for _, def := range findSymbolDefinitions(text) {
+			if seen[def.Name] {
+				continue
+			}
+			seen[def.Name] = true
+			items = append(items, lspCompletionItem{Label: def.Name, Kind: kinds[def.Kind], Detail: def.Kind})
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Label < items[j].Label })
+	return items
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"io"
+	"net/url"
+	"path/filepath"
+	"testing"
+)
+
+func TestLSPResolvesIncludesNextToTheDocument(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"src/regs.inc": "VAL EQU 0x05\n"})
+	uri := (&url.URL{Scheme: "file", Path: filepath.ToSlash(filepath.Join(dir, "src/main.asm"))}).String()
+	server := &lspServer{
+		mcConfig:  testConfig(t),
+		documents: map[string]string{uri: "#INCLUDE \"regs.inc\"\n        ORG 0\n        MOVLW VAL\n        END\n"},
+		assembled: make(map[string]*PicAssembler),
+		writer:    io.Discard,
+	}
+	server.publishDiagnostics(uri)
+	assembler, ok := server.assembled[uri]
+	if !ok {
+		t.Fatal("document with a local #INCLUDE did not assemble")
+	}
+	if assembler.machineCodeWords[0] != 0x3005 {
+		t.Errorf("word 0 = %#04x, want MOVLW 0x05", assembler.machineCodeWords[0])
+	}
+}
+
+func TestURIToPath(t *testing.T) {
+	for uri, want := range map[string]string{
+		"file:///home/me/main.asm":     filepath.FromSlash("/home/me/main.asm"),
+		"file:///home/me/my%20src.asm": filepath.FromSlash("/home/me/my src.asm"),
+		"file:///C:/work/main.asm":     filepath.FromSlash("C:/work/main.asm"),
+		"untitled:Untitled-1":          "",
+	} {
+		if got := uriToPath(uri); got != want {
+			t.Errorf("uriToPath(%q) = %q, want %q", uri, got, want)
+		}
+	}
+}
@@ -1,29 +1,153 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/hex"
 	"encoding/json"
-	"flag"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"maps"
 	"os"
 	"path/filepath"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 // --- Custom Error ---
 
 // AssemblerError is a custom error type for assembler-specific errors.
+// Line is the source line the error refers to, or 0 when it is not tied to a line.
 type AssemblerError struct {
+	Line    int
 	Message string
+	Code    string // stable diagnostic ID, see codes.go
+	// Origin locates the item inside an include file or macro body, if it came from one.
+	Origin *Provenance
 }
 
 func (e *AssemblerError) Error() string {
+	if e.Line > 0 {
+		return tr("Line %d: %s", e.Line, e.detail())
+	}
+	return e.detail()
+}
+
+// detail is the message followed by the origin of the offending item, if any.
+func (e *AssemblerError) detail() string {
+	if origin := e.Origin.String(); origin != "" {
+		return e.Message + " (" + origin + ")"
+	}
 	return e.Message
 }
 
+// --- Diagnostics ---
+
+// Severity levels for non-fatal diagnostics.
+const (
+	SeverityWarning = "warning"
+	SeverityError   = "error"
+)
+
+// Diagnostic is a message about the source that does not stop assembly, such as a warning.
+type Diagnostic struct {
+	Severity string `json:"severity"`
+	Line     int    `json:"line,omitempty"`
+	Message  string `json:"message"`
+	Code     string `json:"code,omitempty"`
+	// Origin locates an error inside an include file or macro body, if it came from one.
+	Origin *Provenance `json:"-"`
+}
+
+func (d Diagnostic) String() string {
+	if d.Line > 0 {
+		return tr("%s: Line %d: %s%s", strings.ToUpper(translate(d.Severity)), d.Line, d.Message, codeSuffix(d.Code))
+	}
+	return fmt.Sprintf("%s: %s%s", strings.ToUpper(translate(d.Severity)), d.Message, codeSuffix(d.Code))
+}
+
+// errorDiagnostic converts an assembly error into an error diagnostic, keeping its source line.
+func errorDiagnostic(err error) Diagnostic {
+	d := Diagnostic{Severity: SeverityError, Message: err.Error()}
+	var asmErr *AssemblerError
+	if errors.As(err, &asmErr) {
+		d.Line, d.Message, d.Code, d.Origin = asmErr.Line, asmErr.detail(), asmErr.Code, asmErr.Origin
+	}
+	return d
+}
+
+// Message formats accepted by -msg-format.
+const (
+	MsgFormatDefault = "default"
+	MsgFormatGCC     = "gcc"
+)
+
+// DiagnosticPrinter writes the diagnostics of one source file in the selected message format.
+// The gcc format ("file:line: severity: message" on stderr) is understood by editor problem matchers.
+// Color selects ColorAuto, ColorAlways or ColorNever (the default); colored diagnostics also
+// show the offending line of Source.
+type DiagnosticPrinter struct {
+	Format string
+	File   string
+	Color  string
+	Source string
+	// Stderr sends diagnostics and status messages to stderr, leaving stdout to an output
+	// file written there.
+	Stderr bool
+}
+
+// Print writes each diagnostic to the console.
+func (p *DiagnosticPrinter) Print(diagnostics ...Diagnostic) {
+	out := os.Stdout
+	if p.Format == MsgFormatGCC || p.Stderr {
+		out = os.Stderr
+	}
+	color := p.useColor(out)
+	for _, d := range diagnostics {
+		if color {
+			p.writeColored(out, d)
+		} else if p.Format != MsgFormatGCC {
+			fmt.Fprintln(out, d.String())
+		} else if file, line := p.location(d); line > 0 {
+			fmt.Fprintf(os.Stderr, "%s:%d: %s: %s%s\n", file, line, d.Severity, d.Message, codeSuffix(d.Code))
+		} else {
+			fmt.Fprintf(os.Stderr, "%s: %s: %s%s\n", p.File, d.Severity, d.Message, codeSuffix(d.Code))
+		}
+	}
+}
+
+// location returns the file and line a gcc-format diagnostic points at: the include file
+// line it came from, if any, or else its line of the main source.
+func (p *DiagnosticPrinter) location(d Diagnostic) (string, int) {
+	if at, ok := d.Origin.includeLocation(); ok {
+		if at.Path != "" {
+			return at.Path, at.Line
+		}
+		return at.File, at.Line
+	}
+	return p.File, d.Line
+}
+
+// Fatal reports an assembly error and exits with a non-zero status.
+func (p *DiagnosticPrinter) Fatal(err error) {
+	if p.Format != MsgFormatGCC {
+		if !p.useColor(os.Stderr) {
+			log.Fatalf("%s: %v%s", tr("Assembly failed"), err, codeSuffix(errorCode(err, "")))
+		}
+		fmt.Fprintf(os.Stderr, "%s%s%s\n", ansiBold, tr("Assembly failed"), ansiReset)
+		p.writeColored(os.Stderr, errorDiagnostic(err))
+		os.Exit(1)
+	}
+	p.Print(errorDiagnostic(err))
+	os.Exit(1)
+}
+
 // --- Data Structures ---
 
 // MicrocontrollerConfig holds all configuration details for a specific microcontroller.
@@ -35,6 +159,36 @@ type MicrocontrollerConfig struct {
 	AllConfigFuseMaps   []map[string]FuseGroupInfo `json:"ALL_CONFIG_FUSE_MAPS"`
 	ConfigWordDefaults  map[string]ConfigDefault   `json:"CONFIG_WORD_DEFAULTS"`
 	ProgramWordSizeBits int                        `json:"PROGRAM_WORD_SIZE_BITS"`
+
+	// Aliases map extra opcode names to instructions; PseudoOps expand to instruction sequences.
+	Aliases   map[string]string   `json:"INSTRUCTION_ALIASES,omitempty"`
+	PseudoOps map[string]PseudoOp `json:"PSEUDO_OPS,omitempty"`
+
+	// AnalogPins lists, per PORT register, the pins that are analog inputs after reset.
+	AnalogPins map[string][]AnalogPin `json:"ANALOG_PINS,omitempty"`
+	// RAMLayout gives the banks, general purpose registers and common RAM of data memory.
+	RAMLayout *RAMLayout `json:"RAM_LAYOUT,omitempty"`
+	// EEPROM is where DE data for the data EEPROM is placed, as word addresses of the HEX file.
+	EEPROM *RAMRange `json:"EEPROM,omitempty"`
+	// IDLocations are the user ID words, which ORG and DW can write like the config words.
+	IDLocations *RAMRange `json:"ID_LOCATIONS,omitempty"`
+	// FlashRowSize is the number of program words erased together by a self-write.
+	FlashRowSize int `json:"FLASH_ROW_SIZE,omitempty"`
+	// ByteAddressedSpaces places the config words, user ID locations and data EEPROM at their
+	// addresses in the HEX file, one byte each, as PIC18 devices do (config at 0x300000, IDs at
+	// 0x200000, EEPROM at 0xF00000). Otherwise each address is a 16-bit word at twice it.
+	ByteAddressedSpaces bool `json:"BYTE_ADDRESSED_SPACES,omitempty"`
+	// ICD lists the resources a -debug build reserves for the in-circuit debugger.
+	ICD *ICDResources `json:"ICD,omitempty"`
+	// ADC describes the analog-to-digital converter for the simulator.
+	ADC *ADCConfig `json:"ADC,omitempty"`
+	// Interrupts describes the interrupt sources for the simulator.
+	Interrupts *InterruptConfig `json:"INTERRUPTS,omitempty"`
+	// Reset describes the reset flags and the watchdog timer for the simulator.
+	Reset *ResetConfig `json:"RESET,omitempty"`
+
+	// Name is the upper-case device name the config was loaded by, if any.
+	Name string `json:"-"`
 }
 
 // InstructionInfo defines the structure for an instruction.
@@ -62,17 +216,24 @@ type AssemblyItem interface {
 }
 
 // ExpandedParsedAssembly holds the final, macro-expanded list of assembly items.
+// SourceLines[i] is the source line number that produced Lines[i]; items expanded
+// from a macro or define carry the line of the invocation. Origins[i] tells where
+// inside include files and macro bodies the item came from.
 type ExpandedParsedAssembly struct {
-	Lines []AssemblyItem
+	Lines       []AssemblyItem
+	SourceLines []int
+	Origins     []*Provenance
 }
 
 // ParsedAssembly holds the result of the initial parsing pass.
 type ParsedAssembly struct {
-	Lines   []AssemblyItem
-	Defines map[string]string
-	Macros  map[string]*MacroDefinition
-	Labels  map[string]int
-	Symbols map[string]string
+	Lines       []AssemblyItem
+	SourceLines []int
+	Origins     []SourceLocation
+	Defines     map[string]string
+	Macros      map[string]*MacroDefinition
+	Labels      map[string]int
+	Symbols     map[string]string
 }
 
 // Define structs for each assembly item type.
@@ -91,10 +252,19 @@ type Define struct {
 
 func (d *Define) isAssemblyItem() {}
 
+// Undefine removes a #DEFINE from that point on.
+type Undefine struct {
+	Name string
+}
+
+func (u *Undefine) isAssemblyItem() {}
+
 type Instruction struct {
 	Opcode   string
 	Operands []string
 	Comment  string
+	// OperandText is the operand field as written, before splitting (empty for generated code).
+	OperandText string
 }
 
 func (i *Instruction) isAssemblyItem() {}
@@ -115,6 +285,7 @@ type EquDirective struct {
 func (e *EquDirective) isAssemblyItem() {}
 
 type ConfigDirective struct {
+	Word    string // config word name or address of the two-argument form, "" if not given
 	Options []string
 	Comment string
 }
@@ -128,10 +299,15 @@ type Label struct {
 
 func (l *Label) isAssemblyItem() {}
 
+// MacroDefinition keeps the raw body lines of a macro; they are parsed again for every
+// invocation so that parameters and local labels can be substituted.
 type MacroDefinition struct {
-	Name         string
-	Body         []AssemblyItem
-	MacroComment string
+	Name          string
+	Params        []string
+	Defaults      map[string]string // default argument of the trailing parameters that have one
+	BodyLines     []string
+	BodyLocations []SourceLocation // where each body line is written
+	MacroComment  string
 }
 
 func (m *MacroDefinition) isAssemblyItem() {}
@@ -143,8 +319,50 @@ type ASMParser struct {
 	parsedData              *ParsedAssembly
 	expandedParsedData      *ExpandedParsedAssembly
 	currentSourceLineNumber int
+	currentOrigin           SourceLocation
 	relabelCounters         map[string]int
 	currentMacroLabelsMap   map[string]string
+	warnings                []Diagnostic
+	// initialDefines are the #DEFINEs set before the source is read (-D). Expansion starts
+	// from them and replays the #DEFINE and #UNDEFINE lines in order.
+	initialDefines map[string]string
+
+	// coreWordBits is the target's program word size, used to check library includes (0 = unchecked).
+	coreWordBits int
+	// programWords is the size of the target's program memory, which bounds INCBIN files.
+	programWords int
+	// enhancedCore is set when the device has BRW, which JUMPTABLE uses instead of ADDWF PCL, F.
+	enhancedCore bool
+	// includeDirs are searched for #INCLUDE <name> before the bundled libraries, whose files
+	// are read through includeCache when a daemon keeps them between builds.
+	includeDirs  []string
+	includeCache *includeCache
+	// noFileAccess refuses every file the source names but the bundled libraries.
+	noFileAccess bool
+	// ctx stops the expansion when the build is abandoned (see AssemblyOptions.Context).
+	ctx context.Context
+	// sourceDir is the directory of the source file, which data files such as the CSV of
+	// DTABLE are looked up in first ("" for a source read from stdin: the working directory).
+	sourceDir string
+	// aliases and pseudoOps come from the device config, keyed by upper-case name.
+	aliases   map[string]string
+	pseudoOps map[string]*MacroDefinition
+	// plugins are the custom directives given for this assembly, keyed by upper-case name.
+	plugins map[string]directiveHandler
+	// missingEnd is the severity of a source without END: warning, error or off.
+	missingEnd string
+	// predefined are the build-time symbols (__DATE__, __MCU__, ...); buildTime is the time
+	// __DATE__ and __TIME__ give.
+	predefined map[string]predefinedSymbol
+	buildTime  time.Time
+	// included are the files #INCLUDE, DTABLE and INCBIN read, in the order they were read.
+	included []includedFile
+	// compat is the MPASM compatibility state, nil unless -compat mpasm is given.
+	compat *mpasmCompat
+	// linesRead counts the source lines after include expansion and expansions the macro,
+	// pseudo-op, built-in and directive invocations expanded, for -stats.
+	linesRead  int
+	expansions int
 }
 
 // NewASMParser creates a new parser instance.
@@ -165,13 +383,22 @@ func NewASMParser() *ASMParser {
 
 // extractLineContentAndComment separates the main content of a line from its comment.
 func (p *ASMParser) extractLineContentAndComment(line string) (string, string) {
-	parts := strings.SplitN(line, ";", 2)
-	content := strings.TrimSpace(parts[0])
-	comment := ""
-	if len(parts) > 1 {
-		comment = ";" + parts[1]
+	// The comment starts at the first ';' outside a quoted string.
+	quote := byte(0)
+	for k := 0; k < len(line); k++ {
+		switch c := line[k]; {
+		case quote != 0 && c == '\\':
+			k++
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ';':
+			return strings.TrimSpace(line[:k]), strings.TrimSpace(line[k:])
+		}
 	}
-	return content, strings.TrimSpace(comment)
+	return strings.TrimSpace(line), ""
 }
 
 // generateUniqueLabelName creates a unique label name for use within macros.
@@ -215,17 +442,69 @@ func (p *ASMParser) substituteOperand(operand string) string {
 	return currentValue
 }
 
+// splitInstructionOperands splits an operand field at commas and spaces, except inside
+// parentheses, so function arguments such as MAX(A, B) stay in one operand.
+func splitInstructionOperands(text string) []string {
+	var operands []string
+	start, depth := -1, 0
+	for k := 0; k <= len(text); k++ {
+		var c byte = ','
+		if k < len(text) {
+			c = text[k]
+		}
+		switch {
+		case c == '(':
+			depth++
+		case c == ')' && depth > 0:
+			depth--
+		case depth > 0:
+		case c == ',' || c == ' ' || c == '\t':
+			if start >= 0 {
+				operands = append(operands, text[start:k])
+				start = -1
+			}
+			continue
+		}
+		if start < 0 {
+			start = k
+		}
+	}
+	return operands
+}
+
 // Compile regexes once for efficiency
 var (
 	defineRegex      = regexp.MustCompile(`(?i)^#DEFINE\s+([A-Z_0-9]+)\s+(.*)$`)
+	undefineRegex    = regexp.MustCompile(`(?i)^#UNDEFINE\s+([A-Z_0-9]+)$`)
 	configRegex      = regexp.MustCompile(`(?i)^__CONFIG\s+(.*)$`)
-	orgRegex         = regexp.MustCompile(`(?i)^ORG\s+(0[Xx][0-9a-fA-F]+|[0-9]+)$`)
-	equRegex         = regexp.MustCompile(`(?i)^([A-Z_0-9]+)\s+EQU\s+(0[Xx][0-9a-fA-F]+|[0-9]+)$`)
+	orgRegex         = regexp.MustCompile(`(?i)^ORG\s+(.+)$`)
+	equRegex         = regexp.MustCompile(`(?i)^([A-Z_0-9]+)\s+EQU\s+(.+)$`)
 	labelRegex       = regexp.MustCompile(`(?i)^([A-Z_0-9]+):$`)
 	instructionRegex = regexp.MustCompile(`(?i)^([A-Z_0-9]+)\s*(.*)$`)
-	macroStartRegex  = regexp.MustCompile(`(?i)^([A-Z_0-9]+)\s+MACRO\s*(;.*)?$`)
+	macroStartRegex  = regexp.MustCompile(`(?i)^([A-Z_0-9]+)\s+MACRO(?:\s+([^;]*?))?\s*(;.*)?$`)
+	includeRegex     = regexp.MustCompile(`(?i)^#INCLUDE\s+(.+)$`)
+	macroParamRegex  = regexp.MustCompile(`^[A-Za-z_][A-Za-z_0-9]*$`)
 )
 
+// directiveKeywords lists the directive names recognized by the parser (matched case-insensitively).
+var directiveKeywords = map[string]bool{
+	"#DEFINE":   true,
+	"#UNDEFINE": true,
+	"#INCLUDE":  true,
+	"__CONFIG":  true,
+	"ORG":       true,
+	"EQU":       true,
+	"MACRO":     true,
+	"ENDM":      true,
+	"END":       true,
+}
+
+// namingDirectiveKeywords are directives written after the name they define (e.g. "DELAY MACRO").
+var namingDirectiveKeywords = map[string]bool{
+	"EQU":   true,
+	"MACRO": true,
+}
+
 // parseSingleLineItem parses one line of assembly code.
 func (p *ASMParser) parseSingleLineItem(line string, inMacroContext bool) (AssemblyItem, error) {
 	originalLine := line
@@ -245,13 +524,23 @@ func (p *ASMParser) parseSingleLineItem(line string, inMacroContext bool) (Assem
 		return &Define{Name: name, Value: value}, nil
 	}
 
+	if match := undefineRegex.FindStringSubmatch(lineContent); match != nil {
+		delete(p.parsedData.Defines, match[1])
+		return &Undefine{Name: match[1]}, nil
+	}
+
 	if match := configRegex.FindStringSubmatch(lineContent); match != nil {
 		optionsStr := strings.TrimSpace(match[1])
+		word := ""
+		if comma := strings.Index(optionsStr, ","); comma >= 0 {
+			// __CONFIG word, settings
+			word, optionsStr = strings.TrimSpace(optionsStr[:comma]), optionsStr[comma+1:]
+		}
 		options := strings.Split(optionsStr, "&")
 		for i := range options {
 			options[i] = strings.TrimSpace(options[i])
 		}
-		return &ConfigDirective{Options: options, Comment: commentText}, nil
+		return &ConfigDirective{Word: word, Options: options, Comment: commentText}, nil
 	}
 
 	if match := orgRegex.FindStringSubmatch(lineContent); match != nil {
@@ -264,28 +553,54 @@ func (p *ASMParser) parseSingleLineItem(line string, inMacroContext bool) (Assem
 		return &EquDirective{Symbol: symbol, Value: value, Comment: commentText}, nil
 	}
 
+	if item := parseDataDirective(lineContent, commentText); item != nil {
+		return item, nil
+	}
+
 	if match := labelRegex.FindStringSubmatch(lineContent); match != nil {
 		originalLabelName := match[1]
 		finalLabelName := originalLabelName
 		if inMacroContext {
-			finalLabelName = p.generateUniqueLabelName(originalLabelName)
-			p.currentMacroLabelsMap[originalLabelName] = finalLabelName
+			if mapped, ok := p.currentMacroLabelsMap[originalLabelName]; ok {
+				finalLabelName = mapped
+			} else {
+				finalLabelName = p.generateUniqueLabelName(originalLabelName)
+				p.currentMacroLabelsMap[originalLabelName] = finalLabelName
+			}
 		}
 		p.parsedData.Labels[finalLabelName] = p.currentSourceLineNumber
 		return &Label{Name: finalLabelName, Comment: commentText}, nil
 	}
 
+	if item := parseSelfWriteDirective(lineContent); item != nil {
+		return item, nil
+	}
+
+	if item, err := parseVersionString(lineContent, p.predefined); item != nil || err != nil {
+		return item, err
+	}
+
+	if item, err := p.parseDataTable(lineContent); item != nil || err != nil {
+		return item, err
+	}
+
+	if item, err := p.parseBinaryInclude(lineContent); item != nil || err != nil {
+		return item, err
+	}
+
+	if item, err := parseDataElements(lineContent); item != nil || err != nil {
+		return item, err
+	}
+
+	if match := listingRegex.FindStringSubmatch(lineContent); match != nil {
+		return &ListingDirective{Name: strings.ToUpper(match[1]), Operand: strings.Trim(strings.TrimSpace(match[2]), `"'`)}, nil
+	}
+
 	if match := instructionRegex.FindStringSubmatch(lineContent); match != nil {
 		opcode := match[1]
 		operandsStr := strings.TrimSpace(match[2])
 
-		// Split by comma then by space
-		var operands []string
-		parts := strings.Split(operandsStr, ",")
-		for _, part := range parts {
-			subParts := strings.Fields(part)
-			operands = append(operands, subParts...)
-		}
+		operands := splitInstructionOperands(operandsStr)
 
 		// Substitute #DEFINEs
 		for i, op := range operands {
@@ -300,107 +615,614 @@ func (p *ASMParser) parseSingleLineItem(line string, inMacroContext bool) (Assem
 				}
 			}
 		}
-		return &Instruction{Opcode: opcode, Operands: operands, Comment: commentText}, nil
+		return &Instruction{Opcode: opcode, Operands: operands, Comment: commentText, OperandText: operandsStr}, nil
 	}
 
-	fmt.Printf("Warning: Unhandled line type at source line %d: '%s'\n", p.currentSourceLineNumber, originalLine)
+	p.warn(CodeUnhandledLine, "Unhandled line type: '%s'", strings.TrimSpace(originalLine))
 	return nil, nil
 }
 
+// warn records a warning with the given diagnostic code for the source line currently being parsed.
+func (p *ASMParser) warn(code, format string, args ...interface{}) {
+	p.warnings = append(p.warnings, Diagnostic{Severity: SeverityWarning, Line: p.currentSourceLineNumber, Message: tr(format, args...), Code: code})
+}
+
+// appendLine adds an item to the parsed output, remembering the source line it came from.
+func (p *ASMParser) appendLine(item AssemblyItem) {
+	p.parsedData.Lines = append(p.parsedData.Lines, item)
+	p.parsedData.SourceLines = append(p.parsedData.SourceLines, p.currentSourceLineNumber)
+	p.parsedData.Origins = append(p.parsedData.Origins, p.currentOrigin)
+}
+
+// sourceText is one input line together with the source line number it is reported against
+// and the file and line it was read from.
+type sourceText struct {
+	Text   string
+	Line   int
+	Origin SourceLocation
+}
+
+// includedFile is a file read by #INCLUDE or a data directive: from Path, or from the bundled
+// libraries when Path is "".
+type includedFile struct {
+	Name string
+	Path string
+	Text string
+}
+
+// maxIncludeDepth bounds nested #INCLUDEs so include cycles fail instead of looping.
+const maxIncludeDepth = 16
+
+// includeTarget splits the operand of #INCLUDE into the file name and whether it is a library
+// include, written <name>. "name" and a bare name, as MPASM also accepts, are local includes.
+// ok is false for anything else.
+func includeTarget(operand string) (name string, library, ok bool) {
+	switch {
+	case len(operand) > 2 && operand[0] == '<' && operand[len(operand)-1] == '>':
+		return operand[1 : len(operand)-1], true, true
+	case len(operand) > 2 && operand[0] == '"' && operand[len(operand)-1] == '"':
+		return operand[1 : len(operand)-1], false, true
+	case !strings.ContainsAny(operand, "<>\" \t"):
+		return operand, false, true
+	}
+	return "", false, false
+}
+
+// findFile reads a file the source names from the first of dirs that has it, returning its
+// text and path. An absolute name is read as is. With NoFileAccess every file is refused,
+// naming why for an absolute name or one that climbs out of the directories with "..". A
+// file larger than maxBytes (0 for no limit) is refused before it is read.
+func (p *ASMParser) findFile(name string, dirs []string, maxBytes int64) (string, string, error) {
+	if len(dirs) == 0 {
+		return "", "", os.ErrNotExist
+	}
+	if p.noFileAccess {
+		switch {
+		case filepath.IsAbs(name) || strings.HasPrefix(filepath.ToSlash(name), "/"):
+			return "", "", trErrorf("absolute path %s is not allowed in this assembly", name)
+		case !filepath.IsLocal(name):
+			return "", "", trErrorf("%s climbs out of the include directories", name)
+		}
+		return "", "", trErrorf("files cannot be read in this assembly")
+	}
+	if filepath.IsAbs(name) {
+		dirs = []string{""}
+	}
+	var notFound error
+	for _, dir := range dirs {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && maxBytes > 0 && info.Size() > maxBytes {
+			return "", "", trErrorf("%s is %d bytes, more than the %d the program memory holds", name, info.Size(), maxBytes)
+		}
+		text, err := p.includeCache.read(path)
+		if err == nil {
+			return text, path, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", "", err
+		}
+		if notFound == nil {
+			notFound = err // reported against the first directory
+		}
+	}
+	return "", "", notFound
+}
+
+// readInclude returns the text of an included file and the path it was read from ("" for a
+// bundled library). A library include searches the include directories, then the bundled
+// libraries; a local include searches localDir, the directory of the including file, first.
+// With NoFileAccess only the bundled libraries are found.
+func (p *ASMParser) readInclude(name, localDir string, library bool) (string, string, error) {
+	_, bundled := stdLibraryCores[strings.ToLower(name)]
+	dirs := p.includeDirs
+	if !library {
+		dirs = append([]string{localDir}, dirs...)
+	}
+	if p.noFileAccess && (library || bundled) {
+		dirs = nil
+	}
+	text, path, err := p.findFile(name, dirs, 0)
+	switch {
+	case err == nil:
+		p.included = append(p.included, includedFile{Name: name, Path: path, Text: text})
+		return text, path, nil
+	case !errors.Is(err, os.ErrNotExist) || !library && !bundled:
+		return "", "", err // an unreadable or missing local file rather than an unknown library
+	}
+	text, err = loadStdLibrary(name, p.coreWordBits)
+	if err != nil {
+		return "", "", err
+	}
+	p.included = append(p.included, includedFile{Name: name, Text: text})
+	return text, "", nil
+}
+
+// readDataFile returns the text of a data file a directive names: an absolute path, or a
+// path relative to the source's directory or, failing that, to an include directory. A file
+// larger than maxBytes (0 for no limit) is refused before it is read.
+func (p *ASMParser) readDataFile(name string, maxBytes int64) (string, error) {
+	text, path, err := p.findFile(name, append([]string{p.sourceDir}, p.includeDirs...), maxBytes)
+	if err != nil {
+		return "", err
+	}
+	for _, file := range p.included {
+		if file.Path == path {
+			return text, nil // listed once however many directives read it
+		}
+	}
+	p.included = append(p.included, includedFile{Name: name, Path: path, Text: text})
+	return text, nil
+}
+
+// expandIncludes splices the files named by #INCLUDE into the source, passing each line to
+// emit as it is read. Included lines are reported against the line of the top-level #INCLUDE
+// that pulled them in. An #INCLUDE read while skipping reports true, in an IF branch that is
+// not assembled, is passed on like any other line rather than opened.
+func (p *ASMParser) expandIncludes(asmContent string, skipping func() bool, emit func(sourceText) error) error {
+	var splice func(text, file, path, dir string, includeLine, depth int) error
+	splice = func(text, file, path, dir string, includeLine, depth int) error {
+		for i, line := range strings.Split(text, "\n") {
+			lineNum := includeLine
+			if depth == 0 {
+				lineNum = i + 1
+			}
+			content, _ := p.extractLineContentAndComment(line)
+			match := includeRegex.FindStringSubmatch(content)
+			if match == nil || skipping() {
+				if err := emit(sourceText{Text: strings.TrimRight(line, "\r"), Line: lineNum, Origin: SourceLocation{File: file, Path: path, Line: i + 1}}); err != nil {
+					return err
+				}
+				continue
+			}
+			target := strings.TrimSpace(match[1])
+			name, library, ok := includeTarget(target)
+			if !ok {
+				return &AssemblerError{Line: lineNum, Message: tr("#INCLUDE expects <name>, \"name\" or a file name, got %s.", target), Code: CodeIncludeForm}
+			}
+			if depth >= maxIncludeDepth {
+				return &AssemblerError{Line: lineNum, Message: tr("Includes nested too deeply at %s (include cycle?).", target), Code: CodeIncludeDepth}
+			}
+			included, path, err := p.readInclude(name, dir, library)
+			if err != nil {
+				return &AssemblerError{Line: lineNum, Message: tr("Cannot include %s: %v", target, err), Code: CodeIncludeFailed}
+			}
+			includedDir := dir
+			if path != "" {
+				includedDir = filepath.Dir(path)
+			}
+			if err := splice(included, name, path, includedDir, lineNum, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return splice(asmContent, "", "", p.sourceDir, 0, 0)
+}
+
+// parseMacroParams splits the parameter list of a MACRO line. A parameter written name=value
+// has a default argument; those must come after the parameters without one.
+func parseMacroParams(list string, lineNum int) ([]string, map[string]string, error) {
+	if strings.TrimSpace(list) == "" {
+		return nil, nil, nil
+	}
+	fields, err := splitDataOperands(list)
+	if err != nil {
+		return nil, nil, &AssemblerError{Line: lineNum, Message: tr("Invalid macro parameter list: %v.", err), Code: CodeMacroParam}
+	}
+	var params []string
+	var defaults map[string]string
+	for _, param := range fields {
+		param, value, hasDefault := strings.Cut(param, "=")
+		param, value = strings.TrimSpace(param), strings.TrimSpace(value)
+		if !macroParamRegex.MatchString(param) {
+			return nil, nil, &AssemblerError{Line: lineNum, Message: tr("Invalid macro parameter '%s'.", param), Code: CodeMacroParam}
+		}
+		switch {
+		case hasDefault && value == "":
+			return nil, nil, &AssemblerError{Line: lineNum, Message: tr("Macro parameter '%s' has an empty default.", param), Code: CodeMacroParam}
+		case hasDefault:
+			if defaults == nil {
+				defaults = make(map[string]string)
+			}
+			defaults[param] = value
+		case len(defaults) > 0:
+			return nil, nil, &AssemblerError{Line: lineNum, Message: tr("Macro parameter '%s' has no default but follows one that has; parameters with defaults must come last.", param), Code: CodeMacroParam}
+		}
+		params = append(params, param)
+	}
+	return params, defaults, nil
+}
+
 // Parse processes the entire assembly content string.
 func (p *ASMParser) Parse(asmContent string) (*ParsedAssembly, error) {
-	lines := strings.Split(asmContent, "\n")
-	inMacro := false
-	var currentMacroName string
-	var macroBodyLines []string
-	var macroStartComment string
-
-	for i, line := range lines {
-		p.currentSourceLineNumber = i + 1
-		strippedLine := strings.TrimSpace(line)
-
-		if match := macroStartRegex.FindStringSubmatch(strippedLine); match != nil && !inMacro {
-			currentMacroName = match[1]
-			inMacro = true
-			macroBodyLines = []string{}
-			macroStartComment = ""
-			if len(match) > 2 {
-				macroStartComment = match[2]
-			}
-			p.currentMacroLabelsMap = make(map[string]string)
-			continue
+	p.initialDefines = maps.Clone(p.parsedData.Defines)
+	if p.compat != nil {
+		var diagnostics []Diagnostic
+		asmContent, diagnostics = p.compat.preprocess(asmContent)
+		p.warnings = append(p.warnings, diagnostics...)
+	}
+	var currentMacro *MacroDefinition
+	conds := conditionals{deferPass: true}
+	endLine, lastLine := 0, 0
+	warnedAfterEnd := false
+
+	// Lines are parsed as includes are expanded, so an #INCLUDE in an IF branch that is not
+	// assembled is never opened
+	skipping := func() bool { return currentMacro == nil && !conds.assembling() }
+	err := p.expandIncludes(asmContent, skipping, func(line sourceText) error {
+		p.linesRead++
+		p.currentSourceLineNumber = line.Line
+		p.currentOrigin = line.Origin
+		texts := []string{line.Text}
+		if p.compat != nil {
+			texts = p.compatLines(line.Text)
 		}
+		for _, text := range texts {
+			strippedLine := strings.TrimSpace(text)
+
+			// IF blocks are resolved here, except in macro bodies, which keep them for expansion
+			if currentMacro == nil {
+				content, _ := p.extractLineContentAndComment(text)
+				if handled, deferred, err := conds.line(content, line, p.conditionTrue); err != nil {
+					return err
+				} else if handled {
+					if deferred != nil {
+						p.appendLine(deferred)
+					}
+					continue
+				}
+			}
 
-		if strings.ToUpper(strippedLine) == "ENDM" && inMacro {
-			inMacro = false
-			var parsedMacroBody []AssemblyItem
-			for _, macroLine := range macroBodyLines {
-				parsedItem, err := p.parseSingleLineItem(macroLine, true)
+			if match := macroStartRegex.FindStringSubmatch(strippedLine); match != nil && currentMacro == nil {
+				params, defaults, err := parseMacroParams(match[2], line.Line)
 				if err != nil {
-					return nil, err
+					return err
+				}
+				currentMacro = &MacroDefinition{Name: match[1], Params: params, Defaults: defaults, MacroComment: match[3]}
+				continue
+			}
+
+			if strings.ToUpper(strippedLine) == "ENDM" && currentMacro != nil {
+				p.parsedData.Macros[currentMacro.Name] = currentMacro
+				p.appendLine(currentMacro)
+				currentMacro = nil
+				continue
+			}
+
+			if currentMacro != nil {
+				currentMacro.BodyLines = append(currentMacro.BodyLines, text)
+				currentMacro.BodyLocations = append(currentMacro.BodyLocations, line.Origin)
+				continue
+			}
+			parsedItem, err := p.parseSingleLineItem(text, false)
+			if err != nil {
+				return err
+			}
+			if parsedItem != nil {
+				p.appendLine(parsedItem)
+				if _, isComment := parsedItem.(*Comment); !isComment {
+					lastLine = line.Line
+					if endLine > 0 && !warnedAfterEnd {
+						p.warn(CodeCodeAfterEnd, "Code after END (line %d) is ignored.", endLine)
+						warnedAfterEnd = true
+					}
 				}
-				if parsedItem != nil {
-					parsedMacroBody = append(parsedMacroBody, parsedItem)
+				if inst, ok := parsedItem.(*Instruction); ok && strings.ToUpper(inst.Opcode) == "END" && endLine == 0 {
+					endLine = line.Line
 				}
 			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := conds.close(); err != nil {
+		return nil, err
+	}
+
+	if endLine == 0 && p.missingEnd != SeverityOff {
+		message := tr("Missing END directive at the end of the source.")
+		if p.missingEnd == SeverityError {
+			return nil, &AssemblerError{Line: lastLine, Message: message, Code: CodeMissingEnd}
+		}
+		p.warnings = append(p.warnings, Diagnostic{Severity: SeverityWarning, Line: lastLine, Message: message, Code: CodeMissingEnd})
+	}
+	return p.parsedData, nil
+}
+
+// builtinMacro synthesizes source lines for a built-in macro invocation at assembly time.
+type builtinMacro func(p *ASMParser, operands []string, sourceLine int) ([]string, error)
 
-			macroDef := &MacroDefinition{
-				Name:         currentMacroName,
-				Body:         parsedMacroBody,
-				MacroComment: macroStartComment,
+// builtinMacros holds the built-in macros, keyed by upper-case name. User macros of the same name take precedence.
+var builtinMacros = make(map[string]builtinMacro)
+
+// registerBuiltinMacro makes a built-in macro available and known to the tooling as a directive.
+func registerBuiltinMacro(name string, expand builtinMacro) {
+	builtinMacros[name] = expand
+	directiveKeywords[name] = true
+}
+
+// literalOperand resolves a macro operand that must be known while parsing: a numeric
+// literal, or an EQU/#DEFINE symbol whose value is one.
+func (p *ASMParser) literalOperand(operand string) (int, error) {
+	lookup := symbolLookup(p.parsedData.Symbols, lookupPredefined(p.predefined))
+	val, err := evalScopedExpression(p.substituteOperand(operand), lookup, p.exprScope(lookup))
+	if err != nil {
+		return 0, trErrorf("'%s' is not a numeric constant", operand)
+	}
+	return val, nil
+}
+
+// exprScope answers DEFINED and ISLABEL in expressions evaluated while parsing, from the
+// symbols, labels and #DEFINEs read so far.
+func (p *ASMParser) exprScope(lookup exprLookup) *exprScope {
+	return &exprScope{
+		defined: func(name string) bool {
+			_, isDefine := p.parsedData.Defines[name]
+			_, isLabel := p.parsedData.Labels[name]
+			_, isSymbol := lookup(name)
+			return isDefine || isLabel || isSymbol
+		},
+		isLabel: func(name string) bool {
+			_, isLabel := p.parsedData.Labels[name]
+			return isLabel
+		},
+	}
+}
+
+// maxMacroDepth bounds nested macro invocations so recursive macros fail instead of looping.
+const maxMacroDepth = 32
+
+// substituteMacroArgs replaces whole-word occurrences of macro parameters in a body line.
+func substituteMacroArgs(line string, params, args []string) string {
+	if len(params) == 0 {
+		return line
+	}
+	values := make(map[string]string, len(params))
+	for i, param := range params {
+		values[param] = args[i]
+	}
+	var sb strings.Builder
+	for i := 0; i < len(line); {
+		if !isIdentifierChar(line[i]) {
+			sb.WriteByte(line[i])
+			i++
+			continue
+		}
+		j := i
+		for j < len(line) && isIdentifierChar(line[j]) {
+			j++
+		}
+		word := line[i:j]
+		if value, ok := values[word]; ok {
+			word = value
+		}
+		sb.WriteString(word)
+		i = j
+	}
+	return sb.String()
+}
+
+// expandMacro parses the body of a macro for one invocation, giving its labels fresh names.
+// It returns the items together with the body location of each.
+func (p *ASMParser) expandMacro(macro *MacroDefinition, args []string, sourceLine int) ([]AssemblyItem, []SourceLocation, error) {
+	required := len(macro.Params) - len(macro.Defaults)
+	if len(args) < required || len(args) > len(macro.Params) {
+		expected := fmt.Sprint(len(macro.Params))
+		if required < len(macro.Params) {
+			expected = tr("%d to %d", required, len(macro.Params))
+		}
+		return nil, nil, &AssemblerError{Line: sourceLine, Message: tr("Macro '%s' expects %s argument(s), got %d.", macro.Name, expected, len(args)), Code: CodeMacroArgs}
+	}
+	if len(args) < len(macro.Params) {
+		args = append([]string(nil), args...)
+		for _, param := range macro.Params[len(args):] {
+			args = append(args, macro.Defaults[param])
+		}
+	}
+
+	bodyLines := make([]string, len(macro.BodyLines))
+	p.currentMacroLabelsMap = make(map[string]string)
+	for i, line := range macro.BodyLines {
+		bodyLines[i] = substituteMacroArgs(line, macro.Params, args)
+		// Allocate local label names up front so forward references are renamed too
+		content, _ := p.extractLineContentAndComment(bodyLines[i])
+		if match := labelRegex.FindStringSubmatch(content); match != nil {
+			if _, ok := p.currentMacroLabelsMap[match[1]]; !ok {
+				p.currentMacroLabelsMap[match[1]] = p.generateUniqueLabelName(match[1])
 			}
-			p.parsedData.Macros[currentMacroName] = macroDef
-			p.parsedData.Lines = append(p.parsedData.Lines, macroDef)
+		}
+	}
 
-			// Reset state
-			currentMacroName = ""
-			macroBodyLines = []string{}
-			p.currentMacroLabelsMap = make(map[string]string)
+	p.currentSourceLineNumber = sourceLine
+	var items []AssemblyItem
+	var locations []SourceLocation
+	conds := conditionals{chain: []MacroFrame{{Name: macro.Name, Call: SourceLocation{Line: sourceLine}}}, deferPass: true}
+	for i, line := range bodyLines {
+		location := SourceLocation{Line: sourceLine}
+		if i < len(macro.BodyLocations) {
+			location = macro.BodyLocations[i]
+		}
+		content, _ := p.extractLineContentAndComment(line)
+		if handled, deferred, err := conds.line(content, sourceText{Text: line, Line: sourceLine, Origin: location}, p.conditionTrue); err != nil {
+			return nil, nil, err
+		} else if handled {
+			if deferred != nil {
+				items = append(items, deferred)
+				locations = append(locations, location)
+			}
 			continue
 		}
+		item, err := p.parseSingleLineItem(line, true)
+		if err != nil {
+			return nil, nil, err
+		}
+		if item != nil {
+			items = append(items, item)
+			locations = append(locations, location)
+		}
+	}
+	if err := conds.close(); err != nil {
+		return nil, nil, err
+	}
+	p.currentMacroLabelsMap = make(map[string]string)
+	return items, locations, nil
+}
 
-		if inMacro {
-			macroBodyLines = append(macroBodyLines, line)
-		} else {
-			parsedItem, err := p.parseSingleLineItem(line, false)
+// expandItem appends the expansion of one parsed item, recursing into macros invoked from macro bodies.
+// origin is where the item came from; expanded items get origins inside the macro bodies.
+func (p *ASMParser) expandItem(item AssemblyItem, sourceLine int, origin *Provenance, depth int, emit func(*Provenance, ...AssemblyItem)) error {
+	fail := func(code, format string, args ...interface{}) error {
+		return &AssemblerError{Line: sourceLine, Message: tr(format, args...), Code: code, Origin: origin}
+	}
+	if err := buildStopped(p.ctx); err != nil {
+		return err
+	}
+	switch v := item.(type) {
+	case *Instruction:
+		// Expand macro
+		if macroToExpand, ok := p.parsedData.Macros[v.Opcode]; ok {
+			if depth >= maxMacroDepth {
+				return fail(CodeNestingDepth, "Macro '%s' is nested too deeply (recursive macro?).", v.Opcode)
+			}
+			body, locations, err := p.expandMacro(macroToExpand, v.Operands, sourceLine)
 			if err != nil {
-				return nil, err
+				return err
 			}
-			if parsedItem != nil {
-				p.parsedData.Lines = append(p.parsedData.Lines, parsedItem)
+			emit(origin, &Comment{Text: fmt.Sprintf("; --- Expanding Macro: %s ---", v.Opcode)})
+			p.expansions++
+			for k, bodyItem := range body {
+				if err := p.expandItem(bodyItem, sourceLine, origin.expandedFrom(v.Opcode, locations[k]), depth+1, emit); err != nil {
+					return err
+				}
+			}
+			emit(origin, &Comment{Text: fmt.Sprintf("; --- End of Macro: %s ---", v.Opcode)})
+		} else if pseudoOp, ok := p.pseudoOps[strings.ToUpper(v.Opcode)]; ok {
+			if depth >= maxMacroDepth {
+				return fail(CodeNestingDepth, "Pseudo-op '%s' is nested too deeply (recursive definition?).", pseudoOp.Name)
+			}
+			body, _, err := p.expandMacro(pseudoOp, v.Operands, sourceLine)
+			if err != nil {
+				return err
+			}
+			emit(origin, &Comment{Text: fmt.Sprintf("; --- Expanding Pseudo-op: %s ---", pseudoOp.Name)})
+			p.expansions++
+			for _, bodyItem := range body {
+				if err := p.expandItem(bodyItem, sourceLine, origin.expandedFrom(pseudoOp.Name, origin.Location), depth+1, emit); err != nil {
+					return err
+				}
+			}
+			emit(origin, &Comment{Text: fmt.Sprintf("; --- End of Pseudo-op: %s ---", pseudoOp.Name)})
+		} else if expand, ok := builtinMacros[strings.ToUpper(v.Opcode)]; ok {
+			name := strings.ToUpper(v.Opcode)
+			p.currentSourceLineNumber = sourceLine
+			lines, err := expand(p, v.Operands, sourceLine)
+			if err != nil {
+				return fail(CodeExpansionFailed, "%s: %v", name, err)
+			}
+			generatedOrigin := origin.expandedFrom(name, origin.Location)
+			emit(origin, &Comment{Text: fmt.Sprintf("; --- Expanding Built-in: %s ---", name)})
+			p.expansions++
+			for _, line := range lines {
+				generated, err := p.parseSingleLineItem(line, false)
+				if err != nil {
+					return err
+				}
+				if generated != nil {
+					emit(generatedOrigin, generated)
+				}
+			}
+			emit(origin, &Comment{Text: fmt.Sprintf("; --- End of Built-in: %s ---", name)})
+		} else if handler, ok := p.directive(v.Opcode); ok {
+			name := strings.ToUpper(v.Opcode)
+			if depth >= maxMacroDepth {
+				return fail(CodeNestingDepth, "Directive '%s' is nested too deeply.", name)
+			}
+			lines, err := handler(DirectiveInvocation{Name: name, OperandText: v.OperandText, Operands: v.Operands, Line: sourceLine, CoreWordBits: p.coreWordBits})
+			if err != nil {
+				return fail(CodeExpansionFailed, "%s: %v", name, err)
+			}
+			generatedOrigin := origin.expandedFrom(name, origin.Location)
+			emit(origin, &Comment{Text: fmt.Sprintf("; --- Expanding Directive: %s ---", name)})
+			p.expansions++
+			for _, line := range lines {
+				p.currentSourceLineNumber = sourceLine
+				generated, err := p.parseSingleLineItem(line, false)
+				if err != nil {
+					return err
+				}
+				if generated != nil {
+					if err := p.expandItem(generated, sourceLine, generatedOrigin, depth+1, emit); err != nil {
+						return err
+					}
+				}
+			}
+			emit(origin, &Comment{Text: fmt.Sprintf("; --- End of Directive: %s ---", name)})
+			// Expand define used as instruction
+		} else if defineValue, ok := p.parsedData.Defines[v.Opcode]; ok {
+			p.currentSourceLineNumber = sourceLine
+			newInstruction, err := p.parseSingleLineItem(defineValue, false)
+			if err != nil {
+				return err
 			}
+			if newInstruction != nil {
+				emit(origin, &Comment{Text: fmt.Sprintf("; --- Expanding Define: %s ---", v.Opcode)})
+				emit(origin.expandedFrom(v.Opcode, origin.Location), newInstruction)
+			}
+		} else if target, ok := p.aliases[strings.ToUpper(v.Opcode)]; ok {
+			emit(origin, &Instruction{Opcode: target, Operands: v.Operands, Comment: v.Comment})
+		} else {
+			emit(origin, v)
 		}
+	case *Define:
+		p.parsedData.Defines[v.Name] = v.Value // definitions are not in the output
+	case *Undefine:
+		delete(p.parsedData.Defines, v.Name)
+	case *MacroDefinition:
+		// Do not include definitions in the final output
+	default:
+		emit(origin, v)
 	}
-	return p.parsedData, nil
+	return nil
+}
+
+// buildStopped returns an error once ctx is done. The loops that a large or runaway source
+// keeps busy, macro expansion and the assembler passes, check it for every item.
+func buildStopped(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return trErrorf("build stopped: %w", err)
+	}
+	return nil
 }
 
 // ExpandMacros expands all macro invocations.
 func (p *ASMParser) ExpandMacros(parsedAssembly *ParsedAssembly) (*ExpandedParsedAssembly, error) {
-	for _, item := range parsedAssembly.Lines {
-		switch v := item.(type) {
-		case *Instruction:
-			// Expand macro
-			if macroToExpand, ok := p.parsedData.Macros[v.Opcode]; ok {
-				p.expandedParsedData.Lines = append(p.expandedParsedData.Lines, &Comment{Text: fmt.Sprintf("; --- Expanding Macro: %s ---", v.Opcode)})
-				p.expandedParsedData.Lines = append(p.expandedParsedData.Lines, macroToExpand.Body...)
-				p.expandedParsedData.Lines = append(p.expandedParsedData.Lines, &Comment{Text: fmt.Sprintf("; --- End of Macro: %s ---", v.Opcode)})
-				// Expand define used as instruction
-			} else if defineValue, ok := p.parsedData.Defines[v.Opcode]; ok {
-				newInstruction, err := p.parseSingleLineItem(defineValue, false)
-				if err != nil {
-					return nil, err
-				}
-				if newInstruction != nil {
-					p.expandedParsedData.Lines = append(p.expandedParsedData.Lines, &Comment{Text: fmt.Sprintf("; --- Expanding Define: %s ---", v.Opcode)})
-					p.expandedParsedData.Lines = append(p.expandedParsedData.Lines, newInstruction)
-				}
-			} else {
-				p.expandedParsedData.Lines = append(p.expandedParsedData.Lines, v)
+	// Macro bodies and defined opcodes are substituted here, with the #DEFINEs in effect at
+	// each line rather than those left at the end of the source.
+	if p.initialDefines != nil {
+		clear(p.parsedData.Defines)
+		maps.Copy(p.parsedData.Defines, p.initialDefines)
+	}
+	for idx, item := range parsedAssembly.Lines {
+		sourceLine := idx + 1
+		if idx < len(parsedAssembly.SourceLines) {
+			sourceLine = parsedAssembly.SourceLines[idx]
+		}
+		origin := &Provenance{Location: SourceLocation{Line: sourceLine}}
+		if idx < len(parsedAssembly.Origins) {
+			origin.Location = parsedAssembly.Origins[idx]
+		}
+		emit := func(itemOrigin *Provenance, items ...AssemblyItem) {
+			for _, it := range items {
+				p.expandedParsedData.Lines = append(p.expandedParsedData.Lines, it)
+				p.expandedParsedData.SourceLines = append(p.expandedParsedData.SourceLines, sourceLine)
+				p.expandedParsedData.Origins = append(p.expandedParsedData.Origins, itemOrigin)
 			}
-		case *MacroDefinition, *Define:
-			// Do not include definitions in the final output
-		default:
-			p.expandedParsedData.Lines = append(p.expandedParsedData.Lines, v)
+		}
+		if err := p.expandItem(item, sourceLine, origin, 0, emit); err != nil {
+			return nil, err
 		}
 	}
 	return p.expandedParsedData, nil
@@ -408,17 +1230,69 @@ func (p *ASMParser) ExpandMacros(parsedAssembly *ParsedAssembly) (*ExpandedParse
 
 // --- Pic Assembler ---
 
+// orgRegion is the program memory filled after one ORG, or from address 0 before the first ORG.
+// Directives placing data at a fixed address (__VERSIONSTR) get a region of their own.
+type orgRegion struct {
+	Line      int    // source line of the ORG; 0 for code before the first ORG
+	Directive string // directive that started the region; "" for ORG
+	Start     int
+	End       int // one past the last word written
+}
+
+// String describes the region for error messages.
+func (r orgRegion) String() string {
+	span := fmt.Sprintf("0x%04X-0x%04X", r.Start, r.End-1)
+	if r.Line == 0 {
+		return "code before the first ORG (" + span + ")"
+	}
+	return tr("%s at line %d (%s)", r.origin(), r.Line, span)
+}
+
+// origin names the directive that started the region.
+func (r orgRegion) origin() string {
+	if r.Directive == "" {
+		return "ORG"
+	}
+	return r.Directive
+}
+
 type PicAssembler struct {
 	mcConfig         *MicrocontrollerConfig
 	parsedAssembly   *ExpandedParsedAssembly
 	symbolTable      map[string]int
 	configDirectives []struct {
+		item    int // expanded item index
 		lineNum int
+		word    string
 		options []string
 	}
 	machineCodeWords map[int]int
+	regions          []orgRegion
+	wordRegion       map[int]int // program address -> index into regions
+	itemAddresses    map[int]int // expanded item index -> address of its machine word
 	configWords      map[string]int
+	fuseWords        map[string]bool // config words set by __CONFIG
+	idChecksum       int             // program checksum stored in the user IDs, -1 if not
+	strictConfig     bool            // unknown, unmapped and ambiguous fuse settings are errors
 	labels           map[string]int
+	equLines         map[string]int    // EQU symbol -> source line of its definition
+	defineSites      []defineSite      // command-line defines and #DEFINE/#UNDEFINE lines, for the report
+	previousSymbols  map[string]int    // symbol values from the previous layout pass, for forward references
+	previousLabels   map[string]int    // labels of the previous layout pass, for ISLABEL() of later labels
+	defines          map[string]string // #DEFINE symbols of the source, for DEFINED()
+	pass             int               // 1 while laying out the code, 2 while generating it, for PASS()
+	predefined       map[string]predefinedSymbol
+	buildTime        time.Time      // time given by __DATE__ and __TIME__
+	included         []includedFile // files read by #INCLUDE, DTABLE and INCBIN, for the build manifest
+	data             dataAllocator  // RES allocation state of the current layout pass
+	// previousOverlaySizes are the overlay sizes from the previous layout pass, used to
+	// place overlays before all of their sections have been seen.
+	previousOverlaySizes map[string]int
+	warnings             []Diagnostic
+	optimized            bool // optimization passes ran, even if they changed nothing
+	optimizations        []OptimizationChange
+	stats                *buildStats // phase timings and counts of the build, for -stats
+	ctx                  context.Context
 }
 
 // NewPicAssembler creates a new assembler instance.
@@ -428,8 +1302,13 @@ func NewPicAssembler(mcConfig *MicrocontrollerConfig, parsedAssembly *ExpandedPa
 		parsedAssembly:   parsedAssembly,
 		symbolTable:      make(map[string]int),
 		machineCodeWords: make(map[int]int),
+		wordRegion:       make(map[int]int),
+		itemAddresses:    make(map[int]int),
 		configWords:      make(map[string]int),
+		idChecksum:       -1,
 		labels:           make(map[string]int),
+		equLines:         make(map[string]int),
+		ctx:              context.Background(),
 	}
 	// Initialize config words with defaults
 	for name, info := range mcConfig.ConfigWordDefaults {
@@ -438,106 +1317,305 @@ func NewPicAssembler(mcConfig *MicrocontrollerConfig, parsedAssembly *ExpandedPa
 	return a
 }
 
-// evaluateExpression evaluates a numeric expression from a string.
-func (a *PicAssembler) evaluateExpression(expression string) (int, error) {
-	expression = strings.TrimSpace(expression)
+// sourceLine returns the source line number of the expanded item at index i.
+func (a *PicAssembler) sourceLine(i int) int {
+	if i < len(a.parsedAssembly.SourceLines) {
+		return a.parsedAssembly.SourceLines[i]
+	}
+	return i + 1
+}
+
+// errorAt creates an error with the given diagnostic code for the expanded item at index i,
+// locating it in its macro body or include file.
+func (a *PicAssembler) errorAt(i int, code, format string, args ...interface{}) *AssemblerError {
+	err := &AssemblerError{Line: a.sourceLine(i), Message: tr(format, args...), Code: code}
+	if i < len(a.parsedAssembly.Origins) {
+		err.Origin = a.parsedAssembly.Origins[i]
+	}
+	return err
+}
 
+// warn records a warning with the given diagnostic code for the given source line.
+func (a *PicAssembler) warn(lineNum int, code, format string, args ...interface{}) {
+	a.warnings = append(a.warnings, Diagnostic{Severity: SeverityWarning, Line: lineNum, Message: tr(format, args...), Code: code})
+}
+
+// parseNumericLiteral parses a hex (0x, $), binary (0b, %) or decimal literal.
+// ok is false when the text does not look like a literal at all.
+func parseNumericLiteral(expression string) (val int, ok bool, err error) {
 	// Hex
 	if strings.HasPrefix(expression, "0x") || strings.HasPrefix(expression, "0X") {
-		val, err := strconv.ParseInt(expression[2:], 16, 64)
-		return int(val), err
+		v, err := strconv.ParseInt(expression[2:], 16, 64)
+		return int(v), true, err
 	}
 	if strings.HasPrefix(expression, "$") {
-		val, err := strconv.ParseInt(expression[1:], 16, 64)
-		return int(val), err
+		v, err := strconv.ParseInt(expression[1:], 16, 64)
+		return int(v), true, err
 	}
 	// Binary
 	if strings.HasPrefix(expression, "0b") || strings.HasPrefix(expression, "%") {
-		val, err := strconv.ParseInt(expression[2:], 2, 64)
-		return int(val), err
+		v, err := strconv.ParseInt(expression[2:], 2, 64)
+		return int(v), true, err
 	}
 	// Decimal
-	if val, err := strconv.ParseInt(expression, 10, 64); err == nil {
-		return int(val), nil
+	if v, err := strconv.ParseInt(expression, 10, 64); err == nil {
+		return int(v), true, nil
 	}
-	// Symbol Table
-	if val, ok := a.symbolTable[expression]; ok {
+	return 0, false, nil
+}
+
+// evaluateExpression evaluates a numeric expression from a string.
+func (a *PicAssembler) evaluateExpression(expression string) (int, error) {
+	expression = strings.TrimSpace(expression)
+
+	if val, ok, err := parseNumericLiteral(expression); ok {
+		return val, err
+	}
+	// Symbol Table and SFR Map
+	if val, ok := a.lookupSymbol(expression); ok {
 		return val, nil
 	}
-	// SFR Map
-	if val, ok := a.mcConfig.SFRMap[strings.ToUpper(expression)]; ok {
+	// Arithmetic over literals, symbols and SFRs
+	if strings.ContainsAny(expression, "+-*/%&|^~!<>=() \t") {
+		val, err := evalScopedExpression(expression, a.lookupSymbol, a.exprScope())
+		if err != nil {
+			return 0, &AssemblerError{Message: tr("Invalid expression '%s': %v", expression, err), Code: CodeInvalidExpression}
+		}
 		return val, nil
 	}
 
-	return 0, &AssemblerError{Message: fmt.Sprintf("Undefined symbol or invalid expression: '%s'", expression)}
+	return 0, &AssemblerError{Message: tr("Undefined symbol or invalid expression: '%s'", expression), Code: CodeUndefinedSymbol}
+}
+
+// lookupSymbol resolves a symbol or SFR name inside an expression. Symbols not defined yet
+// in this pass take their value from the previous layout pass.
+func (a *PicAssembler) lookupSymbol(name string) (int, bool) {
+	if val, ok := a.symbolTable[name]; ok {
+		return val, true
+	}
+	if val, ok := a.mcConfig.SFRMap[strings.ToUpper(name)]; ok {
+		return val, true
+	}
+	if val, ok := a.previousSymbols[name]; ok {
+		return val, true
+	}
+	return lookupPredefined(a.predefined)(name)
+}
+
+// exprScope answers DEFINED, ISLABEL and PASS in expressions. Like symbol values, labels
+// further down are known from the previous layout pass.
+func (a *PicAssembler) exprScope() *exprScope {
+	return &exprScope{
+		defined: func(name string) bool {
+			_, isDefine := a.defines[name]
+			_, isSymbol := a.lookupSymbol(name)
+			return isDefine || isSymbol
+		},
+		isLabel: func(name string) bool {
+			_, isLabel := a.labels[name]
+			_, wasLabel := a.previousLabels[name]
+			return isLabel || wasLabel
+		},
+		pass: a.pass,
+	}
 }
 
+// maxLayoutPasses bounds how often the first pass is repeated while symbol values change.
+const maxLayoutPasses = 10
+
 // firstPass builds the symbol table.
+// It assigns addresses to labels and values to EQU symbols. EQU and ORG expressions may
+// refer to symbols defined further down, so the layout is repeated with the values of the
+// previous round until they stop changing; errors are reported from the final round.
 func (a *PicAssembler) firstPass() error {
+	a.pass = 1
+	for pass := 1; pass <= maxLayoutPasses; pass++ {
+		if err := buildStopped(a.ctx); err != nil {
+			return err
+		}
+		a.previousSymbols, a.previousLabels = a.symbolTable, a.labels
+		a.previousOverlaySizes = a.data.overlaySizes()
+		err := a.layoutPass()
+		if maps.Equal(a.previousSymbols, a.symbolTable) && maps.Equal(a.previousOverlaySizes, a.data.overlaySizes()) {
+			if err == nil {
+				err = a.checkOverlays()
+			}
+			return err
+		}
+	}
+	return &AssemblerError{Message: tr("Symbol values did not settle after %d passes (circular EQU/ORG definitions?).", maxLayoutPasses), Code: CodeUnsettledSymbols}
+}
+
+// layoutPass walks the code once, defining labels and EQU symbols. It continues past errors so
+// later symbols still get values for the next round, and returns the first error.
+func (a *PicAssembler) layoutPass() error {
 	programCounter := 0
+	a.symbolTable = make(map[string]int)
 	a.labels = make(map[string]int)
+	a.equLines = make(map[string]int)
+	a.configDirectives = nil
+	a.data = dataAllocator{}
+	var firstErr error
+	fail := func(i int, code, format string, args ...interface{}) {
+		if firstErr == nil {
+			firstErr = a.errorAt(i, code, format, args...)
+		}
+	}
 
+	var conds passConditions
 	for i, item := range a.parsedAssembly.Lines {
-		lineNum := i + 1
+		if err := buildStopped(a.ctx); err != nil {
+			return err
+		}
+		if assembled, err := conds.assembled(a, i); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		} else if !assembled {
+			continue
+		}
+		lineNum := a.sourceLine(i)
 
 		switch v := item.(type) {
 		case *EquDirective:
 			if v.Symbol == "" {
-				return &AssemblerError{Message: fmt.Sprintf("Line %d: EQU directive must have a label.", lineNum)}
+				fail(i, CodeEquWithoutLabel, "EQU directive must have a label.")
+				continue
 			}
 			val, err := a.evaluateExpression(v.Value)
 			if err != nil {
-				return &AssemblerError{Message: fmt.Sprintf("Line %d: Invalid EQU expression - %v", lineNum, err)}
+				fail(i, errorCode(err, CodeInvalidExpression), "Invalid EQU expression - %v", err)
+				continue
+			}
+			if prevLine, defined := a.equLines[v.Symbol]; defined && a.symbolTable[v.Symbol] != val {
+				fail(i, CodeSymbolRedefined, "Symbol '%s' redefined as 0x%X; line %d defined it as 0x%X.", v.Symbol, val, prevLine, a.symbolTable[v.Symbol])
+				continue
+			}
+			if sfrAddr, isSFR := a.mcConfig.SFRMap[strings.ToUpper(v.Symbol)]; isSFR && sfrAddr != val {
+				fail(i, CodeSFRShadowed, "EQU '%s' = 0x%X shadows the SFR %s at 0x%X defined by the device config.", v.Symbol, val, strings.ToUpper(v.Symbol), sfrAddr)
+				continue
+			}
+			if _, defined := a.equLines[v.Symbol]; !defined {
+				a.equLines[v.Symbol] = lineNum
 			}
 			a.symbolTable[v.Symbol] = val
 
+		case *DataSection:
+			if err := a.openDataSection(i, v); err != nil && firstErr == nil {
+				firstErr = err
+			}
+
+		case *ReserveDirective:
+			addr, err := a.reserve(i, v)
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			if _, exists := a.symbolTable[v.Symbol]; exists {
+				fail(i, CodeSymbolRedefined, "Symbol '%s' is already defined.", v.Symbol)
+				continue
+			}
+			a.symbolTable[v.Symbol] = addr
+
 		case *Label:
 			if _, exists := a.symbolTable[v.Name]; exists {
 				if _, isSFR := a.mcConfig.SFRMap[v.Name]; !isSFR {
-					return &AssemblerError{Message: fmt.Sprintf("Line %d: Duplicate label '%s'", lineNum, v.Name)}
+					fail(i, CodeDuplicateLabel, "Duplicate label '%s'", v.Name)
 				}
 			}
 			a.symbolTable[v.Name] = programCounter
 			a.labels[v.Name] = programCounter
 
 		case *OrgDirective:
-			var err error
-			programCounter, err = a.evaluateExpression(v.Address)
+			addr, err := a.evaluateExpression(v.Address)
 			if err != nil {
-				return &AssemblerError{Message: fmt.Sprintf("Line %d: Invalid ORG address - %v", lineNum, err)}
+				fail(i, errorCode(err, CodeInvalidExpression), "Invalid ORG address - %v", err)
+				continue
+			}
+			if addr < 0 || (addr >= a.mcConfig.ProgramMemorySize && !a.mcConfig.inEEPROM(addr) && !a.mcConfig.inConfigSpace(addr)) {
+				fail(i, CodeOrgOutOfRange, "ORG address 0x%X out of range.", addr)
+				continue
+			}
+			programCounter = addr
+
+		case *DataDirective:
+			programCounter += v.wordCount()
+
+		case *VersionString:
+			addr := programCounter
+			if v.Address != "" {
+				var err error
+				if addr, err = a.evaluateExpression(v.Address); err != nil {
+					fail(i, errorCode(err, CodeInvalidExpression), "Invalid __VERSIONSTR address - %v", err)
+					continue
+				}
+			}
+			if addr < 0 || addr+v.wordCount() > a.mcConfig.ProgramMemorySize {
+				fail(i, CodeOrgOutOfRange, "__VERSIONSTR at 0x%X does not fit in program memory.", addr)
+				continue
+			}
+			if _, exists := a.symbolTable[versionSymbol]; exists {
+				fail(i, CodeSymbolRedefined, "Symbol '%s' is already defined.", versionSymbol)
+				continue
 			}
-			if programCounter < 0 || programCounter >= a.mcConfig.ProgramMemorySize {
-				return &AssemblerError{Message: fmt.Sprintf("Line %d: ORG address 0x%X out of range.", lineNum, programCounter)}
+			a.symbolTable[versionSymbol] = addr
+			a.labels[versionSymbol] = addr
+			if v.Address == "" {
+				programCounter += v.wordCount()
 			}
 
 		case *ConfigDirective:
 			a.configDirectives = append(a.configDirectives, struct {
+				item    int
 				lineNum int
+				word    string
 				options []string
-			}{lineNum, v.Options})
+			}{i, lineNum, v.Word, v.Options})
 
 		case *Instruction:
 			if strings.ToUpper(v.Opcode) == "END" {
-				goto endFirstPass // Exit loop on END directive
+				return firstErr
 			}
 			if _, ok := a.mcConfig.InstructionSet[strings.ToUpper(v.Opcode)]; ok {
 				programCounter++
 			}
 		}
 	}
-endFirstPass:
-	return nil
+	return firstErr
 }
 
 // secondPass generates machine code.
 func (a *PicAssembler) secondPass() error {
+	a.pass = 2
 	// Process Config Directives first
+	a.fuseWords = make(map[string]bool)
 	for _, cd := range a.configDirectives {
+		// The two-argument form names the word, so only its fuse map is searched.
+		wordName, wordIndex := "", -1
+		if cd.word != "" {
+			var err error
+			if wordName, wordIndex, err = a.configWordOperand(cd.word); err != nil {
+				return a.errorAt(cd.item, CodeUnknownConfigWord, "Invalid config word '%s' - %v", cd.word, err)
+			}
+		}
+		selected := make(map[string]string) // config word and fuse group -> setting on this line
 		for _, setting := range cd.options {
 			setting = strings.ToUpper(strings.TrimSpace(setting))
+			if words := a.fuseWordsOf(setting); cd.word == "" && len(words) > 1 {
+				message := tr("Fuse setting '%s' is in %s; name the word with __CONFIG _%s, ...", setting, strings.Join(words, " and "), words[0])
+				if err := a.configProblem(cd.item, CodeAmbiguousFuse, message, tr("Using %s.", words[0])); err != nil {
+					return err
+				}
+			}
 			foundSetting := false
 			for i, configMap := range a.mcConfig.AllConfigFuseMaps {
-				for _, groupInfo := range configMap {
+				if cd.word != "" && i != wordIndex {
+					continue
+				}
+				for group, groupInfo := range configMap {
 					if value, ok := groupInfo.Values[setting]; ok {
 						// Determine the config word name based on the index of the map.
 						var configWordName string
@@ -547,13 +1625,23 @@ func (a *PicAssembler) secondPass() error {
 							configWordName = "CONFIG2"
 						} else {
 							// This handles PICs with more than 2 config words if defined (like PIC16F886).
-							fmt.Printf("WARNING: Line %d: Fuse setting '%s' belongs to unmapped config word index %d. Skipping.\n", cd.lineNum, setting, i)
+							message := tr("Fuse setting '%s' belongs to unmapped config word index %d.", setting, i)
+							if err := a.configProblem(cd.item, CodeUnmappedConfigWord, message, tr("Skipping.")); err != nil {
+								return err
+							}
 							continue
 						}
 
+						key := configWordName + " " + group
+						if earlier, ok := selected[key]; ok && groupInfo.Values[earlier] != value {
+							return a.errorAt(cd.item, CodeFuseConflict, "Fuse group %s of %s is set twice on one line: %s and %s. Keep one of them.", group, configWordName, earlier, setting)
+						}
+						selected[key] = setting
+
 						mask := groupInfo.Mask
 						a.configWords[configWordName] &= ^mask
 						a.configWords[configWordName] |= value
+						a.fuseWords[configWordName] = true
 						foundSetting = true
 						break
 					}
@@ -562,23 +1650,51 @@ func (a *PicAssembler) secondPass() error {
 					break
 				}
 			}
-			if !foundSetting {
-				fmt.Printf("WARNING: Line %d: Unknown fuse setting '%s'. Ignoring.\n", cd.lineNum, setting)
+			if foundSetting {
+				continue
+			}
+			message := tr("Unknown fuse setting '%s'.", setting)
+			if wordName != "" {
+				message = tr("Fuse setting '%s' is not in %s.", setting, wordName)
+			}
+			if err := a.configProblem(cd.item, CodeUnknownFuse, message, tr("Ignoring.")); err != nil {
+				return err
 			}
 		}
 	}
 
 	programCounter := 0
+	a.regions = []orgRegion{{}}
+	bsr, previousSkips := bsrUnknown, false
+	var conds passConditions
 	for i, item := range a.parsedAssembly.Lines {
-		lineNum := i + 1
+		if err := buildStopped(a.ctx); err != nil {
+			return err
+		}
+		if assembled, err := conds.assembled(a, i); err != nil {
+			return err
+		} else if !assembled {
+			continue
+		}
+		lineNum := a.sourceLine(i)
 
 		switch v := item.(type) {
+		case *Label:
+			// Control can arrive here from anywhere, so the BSR is no longer known.
+			bsr, previousSkips = bsrUnknown, false
+			// Code under IF PASS() that differs in size moves the labels after it
+			if addr, ok := a.labels[v.Name]; ok && conds.seen && addr != programCounter {
+				return a.errorAt(i, CodePassLayout, "Label '%s' is at 0x%04X when the code is generated but at 0x%04X when it was laid out; the branches of an IF on PASS() must take the same space.", v.Name, programCounter, addr)
+			}
+
 		case *OrgDirective:
+			bsr, previousSkips = bsrUnknown, false
 			var err error
 			programCounter, err = a.evaluateExpression(v.Address)
 			if err != nil {
 				return err
 			}
+			a.regions = append(a.regions, orgRegion{Line: lineNum, Start: programCounter, End: programCounter})
 
 		case *Instruction:
 			instruction := strings.ToUpper(v.Opcode)
@@ -590,11 +1706,11 @@ func (a *PicAssembler) secondPass() error {
 
 			instInfo, ok := a.mcConfig.InstructionSet[instruction]
 			if !ok {
-				return &AssemblerError{Message: fmt.Sprintf("Line %d: Unknown instruction or directive '%s'.", lineNum, instruction)}
+				return a.errorAt(i, CodeUnknownInstruction, "Unknown instruction or directive '%s'.", instruction)
 			}
 
-			if len(operands) != len(instInfo.Operands) {
-				return &AssemblerError{Message: fmt.Sprintf("Line %d: Instruction '%s' expects %d operand(s), got %d.", lineNum, instruction, len(instInfo.Operands), len(operands))}
+			if len(operands) != len(instInfo.Operands) && !(len(operands) == len(instInfo.Operands)-1 && optionalAccessOperand(instInfo)) {
+				return a.errorAt(i, CodeOperandCount, "Instruction '%s' expects %d operand(s), got %d.", instruction, len(instInfo.Operands), len(operands))
 			}
 
 			opcodePattern := instInfo.OpcodePattern
@@ -603,25 +1719,56 @@ func (a *PicAssembler) secondPass() error {
 			operandValues := make(map[string]int)
 
 			for opIdx, opType := range instInfo.Operands {
-				opValueStr := operands[opIdx]
-				if opType == "d" {
+				opValueStr := ""
+				if opIdx < len(operands) {
+					opValueStr = operands[opIdx]
+				}
+				if opIdx >= len(operands) {
+					continue // the access bank operand, chosen below
+				}
+				if opType == "a" {
+					val, ok := accessOperand(opValueStr)
+					if !ok {
+						return a.errorAt(i, CodeInvalidOperand, "Invalid access bank operand '%s'. Must be 'ACCESS' or 'BANKED'.", opValueStr)
+					}
+					operandValues["a"] = val
+				} else if opType == "d" {
 					switch strings.ToUpper(opValueStr) {
 					case "W":
 						operandValues["d"] = 0
 					case "F":
 						operandValues["d"] = 1
 					default:
-						return &AssemblerError{Message: fmt.Sprintf("Line %d: Invalid destination '%s'. Must be 'W' or 'F'.", lineNum, opValueStr)}
+						return a.errorAt(i, CodeInvalidDestination, "Invalid destination '%s'. Must be 'W' or 'F'.", opValueStr)
 					}
 				} else {
 					val, err := a.evaluateExpression(opValueStr)
 					if err != nil {
-						return &AssemblerError{Message: fmt.Sprintf("Line %d: Invalid operand '%s' for '%s' - %v", lineNum, opValueStr, instruction, err)}
+						return a.errorAt(i, errorCode(err, CodeInvalidOperand), "Invalid operand '%s' for '%s' - %v", opValueStr, instruction, err)
 					}
 					operandValues[opType] = val
 				}
 			}
 
+			// Without an explicit access bank operand, registers outside the access bank are
+			// reached through the BSR, which should be known to select their bank.
+			if _, explicit := operandValues["a"]; !explicit && optionalAccessOperand(instInfo) {
+				addr := operandValues["f"]
+				operandValues["a"] = 0
+				if !isAccessRAM(a.mcConfig, addr) {
+					operandValues["a"] = 1
+					bank := ramBank(a.mcConfig, addr)
+					switch {
+					case bsr == bsrUnknown:
+						a.warn(lineNum, CodeAmbiguousBank, "'%s' is outside the access bank but the BSR is not known here; select bank %d with MOVLB or write '%s, BANKED'.", operands[0], bank, strings.Join(operands, ", "))
+					case bsr != bank:
+						a.warn(lineNum, CodeAmbiguousBank, "'%s' is in bank %d but the BSR selects bank %d.", operands[0], bank, bsr)
+					}
+				}
+			}
+			bsr = a.trackBSR(bsr, v, instInfo, previousSkips)
+			previousSkips = isSkipInstruction(instruction)
+
 			// Helper function to replace placeholders in the binary string
 			replacePlaceholder := func(placeholder rune, value int, bits int) {
 				binVal := fmt.Sprintf("%0*b", bits, value)
@@ -646,9 +1793,10 @@ func (a *PicAssembler) secondPass() error {
 				replacePlaceholder('L', val, 8)
 			}
 			if val, ok := operandValues["f"]; ok {
-				// The file register address is split into 7 bits for the opcode and 2 for bank selection.
-				// For this instruction set, only the lower 7 bits go into the opcode directly.
-				replacePlaceholder('f', val&0x7F, 7)
+				// Only the address within the bank goes into the opcode: the lower 7 bits on
+				// mid-range cores and 8 on PIC18, where the rest comes from the BSR.
+				bits := strings.Count(opcodePattern, "f")
+				replacePlaceholder('f', val&(1<<bits-1), bits)
 				// TO DO: Handle RP0/RP1 bits in STATUS for banking. This implementation assumes user manages banking.
 			}
 			if val, ok := operandValues["b"]; ok {
@@ -657,80 +1805,237 @@ func (a *PicAssembler) secondPass() error {
 			if val, ok := operandValues["d"]; ok {
 				replacePlaceholder('d', val, 1)
 			}
+			if val, ok := operandValues["a"]; ok {
+				replacePlaceholder('a', val, 1)
+			}
 
 			finalBinaryStr := strings.ReplaceAll(string(machineWordChars), "x", "0")
 
 			if len(finalBinaryStr) != a.mcConfig.ProgramWordSizeBits {
-				return &AssemblerError{Message: fmt.Sprintf("Line %d: Internal error: Generated binary string length mismatch for '%s'.", lineNum, instruction)}
+				return a.errorAt(i, CodeEncodingFailed, "Internal error: Generated binary string length mismatch for '%s'.", instruction)
 			}
 
 			parsedWord, err := strconv.ParseInt(finalBinaryStr, 2, 64)
 			if err != nil {
-				return &AssemblerError{Message: fmt.Sprintf("Line %d: Internal error converting binary string '%s' to integer.", lineNum, finalBinaryStr)}
+				return a.errorAt(i, CodeEncodingFailed, "Internal error converting binary string '%s' to integer.", finalBinaryStr)
 			}
 
-			a.machineCodeWords[programCounter] = int(parsedWord)
+			if err := a.placeWord(i, programCounter, int(parsedWord)); err != nil {
+				return err
+			}
+			a.itemAddresses[i] = programCounter
 			programCounter++
+
+		case *DataDirective:
+			words, err := v.encode(a)
+			if err != nil {
+				return a.errorAt(i, errorCode(err, CodeInvalidOperand), "Invalid %s data - %v", v.Name, err)
+			}
+			for k, word := range words {
+				if err := a.placeWord(i, programCounter+k, word); err != nil {
+					return err
+				}
+			}
+			a.itemAddresses[i] = programCounter
+			programCounter += len(words)
+
+		case *VersionString:
+			words, err := v.encode(a.mcConfig)
+			if err != nil {
+				return a.errorAt(i, CodeInvalidOperand, "Invalid __VERSIONSTR - %v", err)
+			}
+			start, resume := programCounter, a.regions[len(a.regions)-1]
+			if v.Address != "" {
+				start = a.symbolTable[versionSymbol]
+				a.regions = append(a.regions, orgRegion{Line: lineNum, Directive: "__VERSIONSTR", Start: start, End: start})
+			}
+			for k, word := range words {
+				if err := a.placeWord(i, start+k, word); err != nil {
+					return err
+				}
+			}
+			a.itemAddresses[i] = start
+			if v.Address != "" {
+				// Code after the string continues where it was.
+				a.regions = append(a.regions, orgRegion{Line: resume.Line, Directive: resume.Directive, Start: programCounter, End: programCounter})
+			} else {
+				programCounter += len(words)
+			}
 		}
 	}
 
 	return nil
 }
 
-// GenerateReport creates a formatted string report of the assembly process.
-func (a *PicAssembler) GenerateReport(rawText string) string {
+// placeWord writes a machine word at addr, as part of the current region.
+func (a *PicAssembler) placeWord(i, addr, word int) error {
+	region := len(a.regions) - 1
+	if earlier, used := a.wordRegion[addr]; used {
+		current := a.regions[region]
+		current.End = addr + 1
+		return a.errorAt(i, CodeAddressOverlap, "Address 0x%04X is written twice: %s overlaps %s.", addr, current, a.regions[earlier])
+	}
+	a.wordRegion[addr] = region
+	a.regions[region].End = addr + 1
+	a.machineCodeWords[addr] = word
+	if name, ok := a.mcConfig.configWordAt(addr); ok {
+		a.placeConfigWord(i, name, word)
+	}
+	return nil
+}
+
+// GenerateReport creates a formatted string report of the assembly process. opts (which may be
+// nil) selects the order and detail of the symbol table.
+func (a *PicAssembler) GenerateReport(rawText string, opts *AssemblyOptions) string {
 	var report strings.Builder
 	separator := strings.Repeat("=", 80)
 
 	center := func(s string) string {
-		pad := (80 - len(s)) / 2
+		pad := (80 - utf8.RuneCountInString(s)) / 2
 		return strings.Repeat(" ", pad) + s
 	}
 
-	report.WriteString(center("Assembly Process Report") + "\n")
+	report.WriteString(center(tr("Assembly Process Report")) + "\n")
+	if title, subtitle := a.listingTitles(); title != "" || subtitle != "" {
+		report.WriteString(center(strings.Trim(title+" - "+subtitle, " -")) + "\n")
+	}
 
 	// Original Code
 	report.WriteString("\n" + separator + "\n")
-	report.WriteString(center("Original Assembly Code") + "\n")
+	report.WriteString(center(tr("Original Assembly Code")) + "\n")
 	report.WriteString(separator + "\n")
+	optimizedLines := make(map[int][]string)
+	for _, change := range a.optimizations {
+		passes := optimizedLines[change.Line]
+		if len(passes) == 0 || passes[len(passes)-1] != change.Pass {
+			optimizedLines[change.Line] = append(passes, change.Pass)
+		}
+	}
 	for i, line := range strings.Split(rawText, "\n") {
+		if passes, ok := optimizedLines[i+1]; ok {
+			line += tr("    <- optimized (%s)", strings.Join(passes, ", "))
+		}
 		report.WriteString(fmt.Sprintf("%4d: %s\n", i+1, line))
 	}
 
+	// Optimizations
+	if a.optimized {
+		report.WriteString("\n" + separator + "\n")
+		report.WriteString(center(tr("Optimizations")) + "\n")
+		report.WriteString(separator + "\n")
+		if len(a.optimizations) > 0 {
+			for _, change := range sortedOptimizations(a.optimizations) {
+				if change.Line > 0 {
+					report.WriteString(tr("  Line %d [%s]: %s\n", change.Line, change.Pass, change.Message))
+				} else {
+					report.WriteString(fmt.Sprintf("  [%s]: %s\n", change.Pass, change.Message))
+				}
+				for _, text := range change.Before {
+					report.WriteString("    - " + text + "\n")
+				}
+				for _, text := range change.After {
+					report.WriteString("    + " + text + "\n")
+				}
+				if len(change.Before) > 0 {
+					report.WriteString(tr("    Saved %d word(s), %d cycle(s)\n", change.WordsSaved, change.CyclesSaved))
+				}
+			}
+			words, cycles := optimizationTotals(a.optimizations)
+			report.WriteString(tr("  Total: %d word(s), %d cycle(s) saved\n", words, cycles))
+		} else {
+			report.WriteString(tr("  No optimizations applied.\n"))
+		}
+	}
+
+	// Expanded Code
+	report.WriteString("\n" + separator + "\n")
+	report.WriteString(center(tr("Expanded Assembly Code")) + "\n")
+	report.WriteString(separator + "\n")
+	if expanded := a.expandedSource(); expanded != "" {
+		report.WriteString(expanded)
+	} else {
+		report.WriteString(tr("  No code after expansion.\n"))
+	}
+
 	// Labels
 	report.WriteString("\n" + separator + "\n")
-	report.WriteString(center("Labels (Symbol Table)") + "\n")
+	symbolOrder, allSymbols := SymbolOrderName, false
+	if opts != nil {
+		if opts.SymbolOrder != "" {
+			symbolOrder = opts.SymbolOrder
+		}
+		allSymbols = opts.SymbolDetails
+	}
+	if allSymbols {
+		report.WriteString(center(tr("Symbol Table")) + "\n")
+	} else {
+		report.WriteString(center(tr("Labels (Symbol Table)")) + "\n")
+	}
 	report.WriteString(separator + "\n")
-	if len(a.labels) > 0 {
-		// Sort labels by name for consistent output
-		sortedLabels := make([]string, 0, len(a.labels))
-		for label := range a.labels {
-			sortedLabels = append(sortedLabels, label)
+	if symbols := a.reportSymbols(symbolOrder, allSymbols); len(symbols) > 0 {
+		for _, symbol := range symbols {
+			if !allSymbols {
+				report.WriteString(fmt.Sprintf("  %-20s -> 0x%04X\n", symbol.Name, symbol.Value))
+				continue
+			}
+			defined := tr("device")
+			if symbol.Line > 0 {
+				defined = tr("line %d", symbol.Line)
+			}
+			report.WriteString(fmt.Sprintf("  %-20s -> 0x%04X  %-5s  %s\n", symbol.Name, symbol.Value, symbol.Kind, defined))
 		}
-		sort.Strings(sortedLabels)
-		for _, label := range sortedLabels {
-			address := a.labels[label]
-			report.WriteString(fmt.Sprintf("  %-20s -> 0x%04X\n", label, address))
+	} else if allSymbols {
+		report.WriteString(tr("  No symbols found.\n"))
+	} else {
+		report.WriteString(tr("  No labels found.\n"))
+	}
+
+	// Defines and Constants
+	report.WriteString("\n" + separator + "\n")
+	report.WriteString(center(tr("Defines and Constants")) + "\n")
+	report.WriteString(separator + "\n")
+	if rows := a.reportConstants(); len(rows) > 0 {
+		for _, row := range rows {
+			report.WriteString(fmt.Sprintf("  %-20s %-9s %-24s %s\n", row.Name, row.Kind, row.Value, row.Defined))
 		}
 	} else {
-		report.WriteString("  No labels found.\n")
+		report.WriteString(tr("  No defines or EQU symbols.\n"))
 	}
 
+	// Memory Usage
+	report.WriteString("\n" + separator + "\n")
+	report.WriteString(center(tr("Memory Usage")) + "\n")
+	report.WriteString(separator + "\n")
+	report.WriteString(a.memoryUsage())
+
+	// Code Composition
+	report.WriteString("\n" + separator + "\n")
+	report.WriteString(center(tr("Code Composition")) + "\n")
+	report.WriteString(separator + "\n")
+	report.WriteString(a.codeComposition())
+
 	// Config Words
 	report.WriteString("\n" + separator + "\n")
-	report.WriteString(center("Configuration Words") + "\n")
+	report.WriteString(center(tr("Configuration Words")) + "\n")
 	report.WriteString(separator + "\n")
 	if len(a.configWords) > 0 {
-		for name, value := range a.configWords {
+		for _, name := range sortedConfigWordNames(a.configWords) {
+			value := a.configWords[name]
 			report.WriteString(fmt.Sprintf("  %-20s = 0x%04X\n", name, value))
+			for _, setting := range decodeConfigWord(a.mcConfig, name, value) {
+				report.WriteString(fmt.Sprintf("      %-8s = %s\n", setting.Group, setting.optionText()))
+			}
+		}
+		if a.idChecksum >= 0 {
+			report.WriteString(tr("  %-20s = 0x%04X (checksum)\n", "User ID", a.idChecksum))
 		}
 	} else {
-		report.WriteString("  No configuration words set.\n")
+		report.WriteString(tr("  No configuration words set.\n"))
 	}
 
 	// Machine Code
 	report.WriteString("\n" + separator + "\n")
-	report.WriteString(center("Generated Machine Code") + "\n")
+	report.WriteString(center(tr("Generated Machine Code")) + "\n")
 	report.WriteString(separator + "\n")
 	if len(a.machineCodeWords) > 0 {
 		// Sort addresses for ordered output
@@ -744,7 +2049,7 @@ func (a *PicAssembler) GenerateReport(rawText string) string {
 			report.WriteString(fmt.Sprintf("  0x%04X: 0x%04X\n", addr, word))
 		}
 	} else {
-		report.WriteString("  No machine code generated.\n")
+		report.WriteString(tr("  No machine code generated.\n"))
 	}
 
 	return report.String()
@@ -764,6 +2069,10 @@ func calculateChecksum(recordBytes []byte) byte {
 // HexGenerator creates Intel HEX files.
 type HexGenerator struct {
 	mcConfig *MicrocontrollerConfig
+	warnings []Diagnostic
+	// noELA leaves out the Extended Linear Address records, failing when an address needs one.
+	noELA  bool
+	format HexFormat
 }
 
 // NewHexGenerator creates a new HEX generator.
@@ -771,71 +2080,58 @@ func NewHexGenerator(mcConfig *MicrocontrollerConfig) *HexGenerator {
 	return &HexGenerator{mcConfig: mcConfig}
 }
 
-// GenerateHex produces the Intel HEX file content as a string.
+// GenerateHex produces the Intel HEX file content as a string. Program memory is written
+// in 16-byte aligned records, erased bytes included, skipping blocks that are all erased;
+// then come the config words, one record each, and the written bytes of the user ID
+// locations and data EEPROM.
 func (g *HexGenerator) GenerateHex(machineCodeWords map[int]int, configWords map[string]int) (string, error) {
-	var hexLines strings.Builder
-	const recordSize = 16 // Bytes per data record
-
-	// --- Part 1: Process Program Memory ---
-	fullMemoryBytes := make([]byte, g.mcConfig.TotalMemoryBytes)
-	for i := range fullMemoryBytes {
-		fullMemoryBytes[i] = 0xFF // Erased state
-	}
-
-	for wordAddr, word := range machineCodeWords {
-		byteAddr := wordAddr * 2
-		if byteAddr+1 < g.mcConfig.TotalMemoryBytes {
-			mask := (1 << g.mcConfig.ProgramWordSizeBits) - 1
-			value16bit := word & mask
-			lowByte := byte(value16bit & 0xFF)
-			highByte := byte((value16bit >> 8) & 0xFF)
-			fullMemoryBytes[byteAddr] = lowByte
-			fullMemoryBytes[byteAddr+1] = highByte
-		} else {
-			fmt.Printf("WARNING: Program memory address 0x%X out of bounds.\n", wordAddr)
-		}
-	}
-
-	// ELA Record for address 0x0000
-	hexLines.WriteString(":020000040000FA\n")
-
+	mask := (1 << g.mcConfig.ProgramWordSizeBits) - 1
 	endOfProgramMemory := g.mcConfig.ProgramMemorySize * 2
-	for currentByteAddr := 0; currentByteAddr < endOfProgramMemory; currentByteAddr += recordSize {
-		endOfChunk := currentByteAddr + recordSize
-		if endOfChunk > endOfProgramMemory {
-			endOfChunk = endOfProgramMemory
-		}
-		dataChunk := fullMemoryBytes[currentByteAddr:endOfChunk]
 
-		// Skip if chunk is all 0xFF
-		isErased := true
-		for _, b := range dataChunk {
-			if b != 0xFF {
-				isErased = false
-				break
+	// --- Part 1: Lay out the words in address order ---
+	addrs := make([]int, 0, len(machineCodeWords))
+	for wordAddr := range machineCodeWords {
+		addrs = append(addrs, wordAddr)
+	}
+	sort.Ints(addrs)
+	var program, extra hexImage // extra holds the ID locations and data EEPROM
+	for _, wordAddr := range addrs {
+		word := machineCodeWords[wordAddr]
+		byteAddr, size := g.mcConfig.hexLocation(wordAddr)
+		if _, ok := g.mcConfig.configWordAt(wordAddr); ok {
+			continue // written with the config words
+		} else if g.mcConfig.inEEPROM(wordAddr) {
+			extra.set(byteAddr, byte(word))
+			if size == 2 {
+				extra.set(byteAddr+1, 0x00)
 			}
+		} else if g.mcConfig.inIDLocations(wordAddr) {
+			extra.set(byteAddr, byte(word&mask))
+			if size == 2 {
+				extra.set(byteAddr+1, byte(word&mask>>8))
+			}
+		} else if byteAddr+1 >= g.mcConfig.TotalMemoryBytes {
+			g.warnings = append(g.warnings, Diagnostic{Severity: SeverityWarning, Message: tr("Program memory address 0x%X out of bounds.", wordAddr), Code: CodeAddressOutOfBounds})
+		} else if byteAddr < endOfProgramMemory {
+			program.set(byteAddr, byte(word&mask))
+			program.set(byteAddr+1, byte(word&mask>>8))
 		}
-		if isErased {
-			continue
-		}
-
-		byteCount := len(dataChunk)
-		addrField := currentByteAddr & 0xFFFF
-		recordType := 0x00
-
-		recordBytes := []byte{byte(byteCount), byte(addrField >> 8), byte(addrField), byte(recordType)}
-		recordBytes = append(recordBytes, dataChunk...)
-		checksum := calculateChecksum(recordBytes)
+	}
 
-		dataHexString := ""
-		for _, b := range dataChunk {
-			dataHexString += fmt.Sprintf("%02X", b)
+	// --- Part 2: Program Memory, after the ELA record for address 0x0000 ---
+	w := &hexWriter{noELA: g.noELA, ela: -1}
+	w.setELA(0)
+	for _, block := range program.blocks(hexRecordSize, 0xFF) {
+		data := block.data[:min(hexRecordSize, endOfProgramMemory-block.start)]
+		if bytes.Count(data, []byte{0xFF}) == len(data) {
+			continue // all erased
+		}
+		if err := w.data(block.start, data); err != nil {
+			return "", err
 		}
-
-		hexLines.WriteString(fmt.Sprintf(":%02X%04X%02X%s%02X\n", byteCount, addrField, recordType, dataHexString, checksum))
 	}
 
-	// --- Part 2: Process Configuration Words ---
+	// --- Part 3: Configuration Words ---
 	type sortedConfig struct {
 		Name  string
 		Value int
@@ -850,147 +2146,572 @@ func (g *HexGenerator) GenerateHex(machineCodeWords map[int]int, configWords map
 	sort.Slice(sortedConfigs, func(i, j int) bool {
 		return sortedConfigs[i].Addr < sortedConfigs[j].Addr
 	})
-
-	currentELA := -1
+	w.ela = -1 // the config words always start with their ELA record
 	for _, config := range sortedConfigs {
-		configInfo := g.mcConfig.ConfigWordDefaults[config.Name]
-		configByteAddr := config.Addr * 2
+		configByteAddr, size := g.mcConfig.hexLocation(config.Addr)
+		paddedValue := (config.Value & mask) | g.mcConfig.ConfigWordDefaults[config.Name].Padding
+		if err := w.data(configByteAddr, []byte{byte(paddedValue), byte(paddedValue >> 8)}[:size]); err != nil {
+			return "", err
+		}
+	}
+
+	// --- Part 4: ID Locations and Data EEPROM, one record per run of bytes ---
+	for _, record := range extra.records(hexRecordSize) {
+		if err := w.data(record.start, record.data); err != nil {
+			return "", err
+		}
+	}
+
+	// --- Part 5: End of File Record ---
+	w.out.WriteString(":00000001FF\n")
+
+	return g.format.apply(w.out.String(), g.mcConfig, g.noELA)
+}
+
+// noELAError reports a byte address that a HEX file without ELA records cannot hold.
+func noELAError(byteAddr int) error {
+	return trErrorf("byte address 0x%X needs an Extended Linear Address record, which -no-ela leaves out", byteAddr)
+}
+
+// --- Intel HEX File Parsing ---
 
-		requiredELA := configByteAddr >> 16
-		if requiredELA != currentELA {
-			currentELA = requiredELA
-			elaChecksum := calculateChecksum([]byte{0x02, 0x00, 0x00, 0x04, byte(currentELA >> 8), byte(currentELA)})
-			hexLines.WriteString(fmt.Sprintf(":02000004%04X%02X\n", currentELA, elaChecksum))
+// parseIntelHex decodes Intel HEX content into a byte-addressed memory image.
+func parseIntelHex(content string) (map[int]byte, error) {
+	memory := make(map[int]byte)
+	upperAddr := 0
+
+	for i, line := range strings.Split(content, "\n") {
+		lineNum := i + 1
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, ":") {
+			return nil, &AssemblerError{Message: tr("HEX line %d: Record does not start with ':'.", lineNum), Code: CodeHexRecord}
+		}
+		record, err := hex.DecodeString(line[1:])
+		if err != nil {
+			return nil, &AssemblerError{Message: tr("HEX line %d: Invalid hex digits - %v", lineNum, err), Code: CodeHexRecord}
+		}
+		if len(record) < 5 || len(record) != int(record[0])+5 {
+			return nil, &AssemblerError{Message: tr("HEX line %d: Record length mismatch.", lineNum), Code: CodeHexRecord}
+		}
+		if calculateChecksum(record[:len(record)-1]) != record[len(record)-1] {
+			return nil, &AssemblerError{Message: tr("HEX line %d: Checksum mismatch.", lineNum), Code: CodeHexRecord}
 		}
 
-		mask := (1 << g.mcConfig.ProgramWordSizeBits) - 1
-		paddedValue := (config.Value & mask) | configInfo.Padding
-		dataBytes := []byte{byte(paddedValue & 0xFF), byte(paddedValue >> 8)}
-		byteCount := 2
-		recordAddrField := configByteAddr & 0xFFFF
-		recordType := 0x00
+		byteCount := int(record[0])
+		addrField := int(record[1])<<8 | int(record[2])
+		data := record[4 : 4+byteCount]
 
-		checksumInput := []byte{byte(byteCount), byte(recordAddrField >> 8), byte(recordAddrField), byte(recordType)}
-		checksumInput = append(checksumInput, dataBytes...)
-		checksum := calculateChecksum(checksumInput)
-		dataHexString := fmt.Sprintf("%02X%02X", dataBytes[0], dataBytes[1])
+		if (record[3] == 0x02 || record[3] == 0x04) && byteCount != 2 {
+			return nil, &AssemblerError{Message: tr("HEX line %d: Record type 0x%02X must hold 2 bytes, not %d.", lineNum, record[3], byteCount), Code: CodeHexRecord}
+		}
 
-		hexLines.WriteString(fmt.Sprintf(":%02X%04X%02X%s%02X\n", byteCount, recordAddrField, recordType, dataHexString, checksum))
+		switch record[3] {
+		case 0x00: // Data
+			for j, b := range data {
+				memory[upperAddr+addrField+j] = b
+			}
+		case 0x01: // End of file
+			return memory, nil
+		case 0x02: // Extended segment address
+			upperAddr = (int(data[0])<<8 | int(data[1])) << 4
+		case 0x04: // Extended linear address
+			upperAddr = (int(data[0])<<8 | int(data[1])) << 16
+		case 0x03, 0x05:
+			// Start address records carry no memory contents
+		default:
+			return nil, &AssemblerError{Message: tr("HEX line %d: Unsupported record type 0x%02X.", lineNum, record[3]), Code: CodeHexRecord}
+		}
 	}
+	return memory, nil
+}
 
-	// --- Part 3: End of File Record ---
-	hexLines.WriteString(":00000001FF\n")
+// hexLocation returns where the value at a word address is stored in the HEX file: its byte
+// address and its size in bytes.
+func (c *MicrocontrollerConfig) hexLocation(addr int) (byteAddr, size int) {
+	if c.ByteAddressedSpaces {
+		if _, ok := c.configWordAt(addr); ok || c.inIDLocations(addr) || c.inEEPROM(addr) {
+			return addr, 1
+		}
+	}
+	return addr * 2, 2
+}
 
-	return hexLines.String(), nil
+// hexValueAt reads the value stored for a word address in a HEX memory image, with missing
+// bytes read as erased. ok is false when none of its bytes are in the image.
+func (c *MicrocontrollerConfig) hexValueAt(memory map[int]byte, addr int) (int, bool) {
+	byteAddr, size := c.hexLocation(addr)
+	if size == 2 {
+		return hexWordAt(memory, addr)
+	}
+	value, ok := memory[byteAddr]
+	return int(value), ok
+}
+
+// hexWordAt reads the little-endian program word stored at wordAddr in a HEX memory image.
+func hexWordAt(memory map[int]byte, wordAddr int) (int, bool) {
+	low, lowOk := memory[wordAddr*2]
+	high, highOk := memory[wordAddr*2+1]
+	if !lowOk && !highOk {
+		return 0, false
+	}
+	if !lowOk {
+		low = 0xFF
+	}
+	if !highOk {
+		high = 0xFF
+	}
+	return int(high)<<8 | int(low), true
 }
 
 // --- Main Assembly Function ---
 
-// assemble is the main function to process assembly code.
-func assemble(asmCodeString, hexFilePath string, mcConfig *MicrocontrollerConfig, reportFilePath string) error {
-	// --- Step 1: Parse and expand macros ---
+// AssemblyOptions controls optional assembler behaviour. A nil *AssemblyOptions means the defaults.
+type AssemblyOptions struct {
+	// IncludeDirs are searched, in order, for #INCLUDE files before the bundled libraries.
+	// IncludeCache keeps the files read between builds (nil reads them every time).
+	IncludeDirs  []string
+	IncludeCache *includeCache
+	// SourceDir is the directory of the source file, searched first for #INCLUDE "name" and
+	// the data files directives such as DTABLE read ("" is the working directory).
+	SourceDir string
+	// NoFileAccess keeps the source from reading files: #INCLUDE finds only the bundled
+	// libraries, and DTABLE and INCBIN fail. serve and the WebAssembly build set it, since
+	// they assemble source from anyone who can reach them.
+	NoFileAccess bool
+	// Context stops the macro expansion and the assembler passes once it is done, so a build
+	// serve gives up on does not keep running (nil never stops).
+	Context context.Context
+	// Defines are #DEFINE symbols set before the source is read, from -D and build profiles.
+	Defines map[string]string
+	// FuseSettings are applied over the __CONFIG lines; they come from the build profile.
+	FuseSettings []string
+	// Optimize runs the default optimization passes except those in DisabledPasses,
+	// plus the opt-in passes named in EnabledPasses.
+	Optimize       bool
+	EnabledPasses  map[string]bool
+	DisabledPasses map[string]bool
+	// Plugins maps custom directive names to external commands run in PluginDir.
+	Plugins   map[string]string
+	PluginDir string
+	// MissingEnd is the severity of a source without END: warning (the default), error or off.
+	MissingEnd string
+	// ListingFile is where assemble writes the listing ("" for none); NoExpand hides macro
+	// expansions in it until an EXPAND directive.
+	ListingFile string
+	NoExpand    bool
+	// MapFile is where assemble writes the memory map ("" for none).
+	MapFile string
+	// Stats prints the build statistics; StatsFile is where assemble writes them as JSON
+	// ("" for none).
+	Stats     bool
+	StatsFile string
+	// ResultFile is where assemble writes the assembly result as JSON ("" for none).
+	ResultFile string
+	// Manifest writes a build manifest next to the HEX file, naming the tool, the device,
+	// the sources, the config words, the image checksum and these options.
+	Manifest bool
+	// SplitHex also writes each HEX region to a file of its own next to the HEX file;
+	// OmitHexRegions are left out of every HEX file.
+	SplitHex       bool
+	OmitHexRegions map[string]bool
+	// NoELA writes HEX files without Extended Linear Address records, for old programmers that
+	// only read 16-bit addresses.
+	NoELA bool
+	// HexFormat sets the digit case, line endings and record padding of the HEX files.
+	HexFormat HexFormat
+	// BuildTime pins the time given by __DATE__ and __TIME__ (zero for SOURCE_DATE_EPOCH or now).
+	BuildTime time.Time
+	// Debug builds for the in-circuit debugger: it defines __DEBUG, enables the debugger in the
+	// config words and keeps the code off the resources the device config's ICD entry reserves.
+	Debug bool
+	// IDChecksum stores the program checksum in the user ID locations.
+	IDChecksum bool
+	// AutoBanksel inserts bank selection before register accesses whose bank is not selected.
+	AutoBanksel bool
+	// AutoPagesel inserts PCLATH page selection before GOTO and CALL into another page.
+	AutoPagesel bool
+	// Compat is CompatMPASM to read the source the way MPASM does, or "".
+	Compat string
+	// StrictConfig makes unknown, unmapped and ambiguous fuse settings errors.
+	StrictConfig bool
+	// WarningsAsErrors fails the build when any warning was reported; it implies StrictConfig.
+	WarningsAsErrors bool
+	// SymbolOrder sorts the report's symbol table by SymbolOrderName (the default) or
+	// SymbolOrderAddress; SymbolDetails adds EQU symbols and SFRs with their type and
+	// defining line.
+	SymbolOrder   string
+	SymbolDetails bool
+}
+
+// newParser creates a parser set up for the target device and options.
+func newParser(mcConfig *MicrocontrollerConfig, opts *AssemblyOptions) *ASMParser {
 	parser := NewASMParser()
+	parser.coreWordBits = mcConfig.ProgramWordSizeBits
+	parser.programWords = mcConfig.ProgramMemorySize
+	_, parser.enhancedCore = mcConfig.InstructionSet["BRW"]
+	parser.aliases = instructionAliases(mcConfig)
+	parser.pseudoOps = pseudoOpMacros(mcConfig)
+	parser.missingEnd = SeverityWarning
+	parser.ctx = context.Background()
+	if opts != nil && opts.Compat == CompatMPASM {
+		parser.compat = newMPASMCompat(mcConfig)
+	}
+	var buildTime time.Time
+	if opts != nil {
+		buildTime = opts.BuildTime
+	}
+	if t, err := resolveBuildTime(buildTime); err == nil {
+		buildTime = t
+	} else {
+		parser.warnings = append(parser.warnings, Diagnostic{Severity: SeverityWarning, Message: err.Error() + "; using the current time."})
+		buildTime = time.Now()
+	}
+	parser.predefined, parser.buildTime = predefinedSymbols(mcConfig, buildTime), buildTime
+	if opts != nil && opts.Debug {
+		parser.predefined["__DEBUG"] = predefinedSymbol{"1", 1, true}
+	}
+	if opts != nil {
+		for name, value := range opts.Defines {
+			parser.parsedData.Defines[name] = value
+		}
+		parser.includeDirs, parser.includeCache = opts.IncludeDirs, opts.IncludeCache
+		parser.sourceDir, parser.noFileAccess = opts.SourceDir, opts.NoFileAccess
+		if opts.Context != nil {
+			parser.ctx = opts.Context
+		}
+		if opts.MissingEnd != "" {
+			parser.missingEnd = opts.MissingEnd
+		}
+		parser.plugins = make(map[string]directiveHandler)
+		for name, command := range opts.Plugins {
+			parser.plugins[strings.ToUpper(name)] = externalDirective(command, opts.PluginDir)
+		}
+	}
+	return parser
+}
+
+// assembleSource parses asmCodeString and runs both assembler passes without writing any output.
+// Warnings collected along the way are returned even when assembly fails.
+func assembleSource(asmCodeString string, mcConfig *MicrocontrollerConfig, opts *AssemblyOptions) (*PicAssembler, []Diagnostic, error) {
+	// --- Step 1: Parse and expand macros ---
+	stats := newBuildStats()
+	parser := newParser(mcConfig, opts)
 	parsedData, err := parser.Parse(asmCodeString)
 	if err != nil {
-		return fmt.Errorf("parsing failed: %w", err)
+		return nil, parser.warnings, trErrorf("parsing failed: %w", err)
 	}
+	stats.phase("parse")
 	expandedData, err := parser.ExpandMacros(parsedData)
 	if err != nil {
-		return fmt.Errorf("macro expansion failed: %w", err)
+		return nil, parser.warnings, trErrorf("macro expansion failed: %w", err)
+	}
+	stats.phase("expand")
+	// The passes decide the code under IF blocks on PASS(), so the steps that rewrite the
+	// code before them are left out
+	if opts != nil && (opts.Optimize || opts.AutoBanksel || opts.AutoPagesel) && hasPassConditionals(expandedData) {
+		parser.warnings = append(parser.warnings, Diagnostic{Severity: SeverityWarning, Message: tr("The source has IF blocks on PASS(), so -O, -auto-banksel and -auto-pagesel leave the code unchanged."), Code: CodePassRewrite})
+		unchanged := *opts
+		unchanged.Optimize, unchanged.AutoBanksel, unchanged.AutoPagesel = false, false, false
+		opts = &unchanged
+	}
+	var optimizations []OptimizationChange
+	if opts != nil && opts.Optimize {
+		expandedData, optimizations = optimizeAssembly(expandedData, parsedData.Symbols, mcConfig, opts.EnabledPasses, opts.DisabledPasses)
+		stats.phase("optimize")
+	}
+	if opts != nil && opts.AutoBanksel {
+		// A first pass over the code as written resolves the file register addresses
+		probe := NewPicAssembler(mcConfig, expandedData)
+		probe.predefined, probe.defines = parser.predefined, parsedData.Defines
+		if probe.firstPass() == nil {
+			var inserted []Diagnostic
+			expandedData, inserted = probe.autoBankSelect()
+			parser.warnings = append(parser.warnings, inserted...)
+		}
+		stats.phase("auto-banksel")
 	}
+	if opts != nil && opts.AutoPagesel {
+		var inserted []Diagnostic
+		expandedData, inserted = autoPageSelect(expandedData, mcConfig, parser.predefined, parsedData.Defines)
+		parser.warnings = append(parser.warnings, inserted...)
+		stats.phase("auto-pagesel")
+	}
+	stats.SourceLines, stats.Macros, stats.Expansions = parser.linesRead, len(parsedData.Macros), parser.expansions
 
 	// --- Step 2: Instantiate and run assembler ---
 	assembler := NewPicAssembler(mcConfig, expandedData)
+	assembler.predefined, assembler.defines = parser.predefined, parsedData.Defines
+	assembler.buildTime, assembler.included = parser.buildTime, parser.included
+	assembler.defineSites = collectDefineSites(parsedData, parser.initialDefines)
+	assembler.optimized = opts != nil && opts.Optimize
+	assembler.strictConfig = opts != nil && (opts.StrictConfig || opts.WarningsAsErrors)
+	assembler.optimizations = optimizations
+	assembler.stats = stats
+	assembler.ctx = parser.ctx
 	if err := assembler.firstPass(); err != nil {
-		return fmt.Errorf("first pass failed: %w", err)
+		return nil, append(parser.warnings, assembler.warnings...), trErrorf("first pass failed: %w", err)
 	}
+	stats.phase("first pass")
 	if err := assembler.secondPass(); err != nil {
-		return fmt.Errorf("second pass failed: %w", err)
+		return nil, append(parser.warnings, assembler.warnings...), trErrorf("second pass failed: %w", err)
+	}
+	if opts != nil && len(opts.FuseSettings) > 0 {
+		if err := assembler.applyFuseSettings(opts.FuseSettings); err != nil {
+			return nil, append(parser.warnings, assembler.warnings...), trErrorf("build profile config failed: %w", err)
+		}
+	}
+	stats.phase("second pass")
+	assembler.checkEquOverlaps()
+	if err := assembler.checkJumpTables(); err != nil {
+		return nil, append(parser.warnings, assembler.warnings...), trErrorf("jump table check failed: %w", err)
 	}
+	if err := assembler.checkSelfWriteRegions(); err != nil {
+		return nil, append(parser.warnings, assembler.warnings...), trErrorf("self-write region check failed: %w", err)
+	}
+	if opts != nil && opts.Debug {
+		if err := assembler.reserveDebugResources(); err != nil {
+			return nil, append(parser.warnings, assembler.warnings...), trErrorf("debug build failed: %w", err)
+		}
+	}
+	if opts != nil && opts.IDChecksum {
+		if err := assembler.writeChecksumID(); err != nil {
+			return nil, append(parser.warnings, assembler.warnings...), trErrorf("ID checksum failed: %w", err)
+		}
+	}
+	stats.phase("checks")
+	return assembler, append(parser.warnings, assembler.warnings...), nil
+}
+
+// AssemblyOutput holds everything produced from one source file. Warnings is filled in
+// even when assembly fails; the other fields are only set on success.
+type AssemblyOutput struct {
+	Assembler *PicAssembler
+	Hex       string
+	Report    string
+	Listing   string // only set when the options name a listing file
+	Map       string // only set when the options name a map file
+	Result    string // only set when the options name a result file
+	// RegionHex holds the HEX file of each region written to, only set with SplitHex.
+	RegionHex map[string]string
+	Warnings  []Diagnostic
+}
 
-	// --- Step 3: Generate HEX file ---
+// assembleOutput assembles source and renders the HEX file and report in memory.
+func assembleOutput(asmCodeString string, mcConfig *MicrocontrollerConfig, opts *AssemblyOptions) (*AssemblyOutput, error) {
+	// --- Steps 1-2: Parse, expand macros and run the assembler passes ---
+	assembler, warnings, err := assembleSource(asmCodeString, mcConfig, opts)
+	output := &AssemblyOutput{Warnings: warnings}
+	if err != nil {
+		return output, err
+	}
+
+	// --- Step 3: Generate HEX ---
 	hexGenerator := NewHexGenerator(mcConfig)
+	if opts != nil {
+		hexGenerator.noELA, hexGenerator.format = opts.NoELA, opts.HexFormat
+	}
 	hexContent, err := hexGenerator.GenerateHex(assembler.machineCodeWords, assembler.configWords)
+	output.Warnings = append(output.Warnings, hexGenerator.warnings...)
 	if err != nil {
-		return fmt.Errorf("HEX generation failed: %w", err)
+		return output, trErrorf("HEX generation failed: %w", err)
 	}
-
-	if err := os.WriteFile(hexFilePath, []byte(hexContent), 0644); err != nil {
-		return fmt.Errorf("failed to write HEX file: %w", err)
+	if opts != nil && (len(opts.OmitHexRegions) > 0 || opts.SplitHex) {
+		used := assembler.hexRegionsUsed()
+		kept := make(map[string]bool)
+		for _, region := range hexRegions {
+			kept[region] = !opts.OmitHexRegions[region]
+		}
+		if hexContent, err = hexGenerator.GenerateRegionsHex(assembler.machineCodeWords, assembler.configWords, kept); err != nil {
+			return output, trErrorf("HEX generation failed: %w", err)
+		}
+		for _, region := range hexRegions {
+			if !opts.SplitHex || !kept[region] || !used[region] {
+				continue
+			}
+			regionHex, err := hexGenerator.GenerateRegionsHex(assembler.machineCodeWords, assembler.configWords, map[string]bool{region: true})
+			if err != nil {
+				return output, trErrorf("HEX generation failed: %w", err)
+			}
+			if output.RegionHex == nil {
+				output.RegionHex = make(map[string]string)
+			}
+			output.RegionHex[region] = regionHex
+		}
+	}
+	assembler.stats.phase("hex")
+	if opts != nil && opts.WarningsAsErrors {
+		count := 0
+		for _, w := range output.Warnings {
+			if w.Severity == SeverityWarning {
+				count++
+			}
+		}
+		if count > 0 {
+			return output, trErrorf("%d warning(s) treated as errors (-Werror)", count)
+		}
 	}
-	fmt.Printf("Assembly successful. HEX file generated at %s\n", hexFilePath)
-	fmt.Printf("HEX file size: %d bytes\n", len(hexContent))
 
 	// --- Step 4: Generate Report ---
-	reportContent := assembler.GenerateReport(asmCodeString)
-	if reportFilePath != "" {
-		if err := os.WriteFile(reportFilePath, []byte(reportContent), 0644); err != nil {
-			return fmt.Errorf("failed to write report file: %w", err)
+	output.Assembler = assembler
+	output.Hex = hexContent
+	output.Report = assembler.GenerateReport(asmCodeString, opts)
+	if opts != nil && opts.ListingFile != "" {
+		output.Listing = assembler.GenerateListing(asmCodeString, !opts.NoExpand)
+	}
+	if opts != nil && opts.MapFile != "" {
+		output.Map = assembler.GenerateMap()
+	}
+	if opts != nil && opts.ResultFile != "" {
+		if output.Result, err = assembler.GenerateResultJSON(output.Warnings); err != nil {
+			return output, trErrorf("result generation failed: %w", err)
 		}
-		fmt.Printf("Assembly report generated at %s\n", reportFilePath)
-	} else {
-		fmt.Println(reportContent)
 	}
-
-	return nil
+	assembler.stats.phase("outputs")
+	assembler.recordStats(output)
+	return output, nil
 }
 
-// loadMicrocontrollerConfig reads and parses a JSON config file for a specific MCU.
-func loadMicrocontrollerConfig(configPath string) (*MicrocontrollerConfig, error) {
-	configFile, err := os.ReadFile(configPath)
+// assemble is the main function to process assembly code.
+func assemble(asmCodeString, hexFilePath string, mcConfig *MicrocontrollerConfig, reportFilePath string, printer *DiagnosticPrinter, opts *AssemblyOptions) error {
+	output, err := assembleOutput(asmCodeString, mcConfig, opts)
+	printer.Print(output.Warnings...)
 	if err != nil {
-		return nil, fmt.Errorf("could not read config file '%s': %w", configPath, err)
+		return err
 	}
+	printer.Print(optimizationLog(output.Assembler.optimizations)...)
 
-	var mcConfig MicrocontrollerConfig
-	err = json.Unmarshal(configFile, &mcConfig)
-	if err != nil {
-		return nil, fmt.Errorf("could not parse JSON from '%s': %w", configPath, err)
+	// Status messages move to stderr when stdout carries an output file
+	status := os.Stdout
+	if printer.Stderr {
+		status = os.Stderr
 	}
 
-	return &mcConfig, nil
-}
+	if err := writeOutputFile(hexFilePath, output.Hex); err != nil {
+		return trErrorf("failed to write HEX file: %w", err)
+	}
+	if hexFilePath == StdioPath {
+		fmt.Fprintln(status, tr("Assembly successful. HEX file written to stdout"))
+	} else {
+		fmt.Fprint(status, tr("Assembly successful. HEX file generated at %s\n", hexFilePath))
+	}
+	fmt.Fprint(status, tr("HEX file size: %d bytes\n", len(output.Hex)))
+	for _, region := range hexRegions {
+		if content, ok := output.RegionHex[region]; ok {
+			path := splitHexPath(hexFilePath, region)
+			if err := writeOutputFile(path, content); err != nil {
+				return trErrorf("failed to write %s HEX file: %w", region, err)
+			}
+			fmt.Fprint(status, tr("HEX file (%s) generated at %s\n", region, path))
+		}
+	}
 
-func main() {
-	// Define command-line flags
-	asmFile := flag.String("asm", "", "Path to the input assembly (.asm) file (required)")
-	mcu := flag.String("mcu", "", "Target microcontroller name, e.g., 'PIC16F687' (required)")
-	configDir := flag.String("config-dir", "./configs", "Directory containing microcontroller JSON config files")
-	outFile := flag.String("hex", "", "Path to the output HEX file (defaults to <asm-file-name>.hex)")
-	reportFile := flag.String("report", "", "Path to the output assembly report file (defaults to printing to console)")
-	flag.Parse()
+	if reportFilePath != "" {
+		if err := writeOutputFile(reportFilePath, output.Report); err != nil {
+			return trErrorf("failed to write report file: %w", err)
+		}
+		if reportFilePath != StdioPath {
+			fmt.Fprint(status, tr("Assembly report generated at %s\n", reportFilePath))
+		}
+	} else if !printer.Stderr {
+		fmt.Println(output.Report)
+	}
 
-	// Validate required flags
-	if *asmFile == "" || *mcu == "" {
-		fmt.Println("Error: -asm and -mcu flags are required.")
-		flag.Usage()
-		os.Exit(1)
+	if opts != nil && opts.ListingFile != "" {
+		if err := writeOutputFile(opts.ListingFile, output.Listing); err != nil {
+			return trErrorf("failed to write listing file: %w", err)
+		}
+		if opts.ListingFile != StdioPath {
+			fmt.Fprint(status, tr("Listing generated at %s\n", opts.ListingFile))
+		}
+	}
+	if opts != nil && opts.MapFile != "" {
+		if err := writeOutputFile(opts.MapFile, output.Map); err != nil {
+			return trErrorf("failed to write map file: %w", err)
+		}
+		if opts.MapFile != StdioPath {
+			fmt.Fprint(status, tr("Map file generated at %s\n", opts.MapFile))
+		}
+	}
+	if opts != nil && opts.ResultFile != "" {
+		if err := writeOutputFile(opts.ResultFile, output.Result); err != nil {
+			return trErrorf("failed to write result file: %w", err)
+		}
+		if opts.ResultFile != StdioPath {
+			fmt.Fprint(status, tr("Result JSON generated at %s\n", opts.ResultFile))
+		}
+	}
+	if opts != nil && opts.Manifest {
+		path := manifestPath(hexFilePath)
+		manifest, err := output.Assembler.GenerateManifest(asmCodeString, printer.File, hexFilePath, output.Hex, opts)
+		if err == nil {
+			err = writeOutputFile(path, manifest)
+		}
+		if err != nil {
+			return trErrorf("failed to write build manifest: %w", err)
+		}
+		fmt.Fprint(status, tr("Build manifest written to %s\n", path))
+	}
+	if opts != nil && opts.Stats {
+		fmt.Fprint(status, output.Assembler.stats)
+	}
+	if opts != nil && opts.StatsFile != "" {
+		statsJSON, err := output.Assembler.stats.JSON()
+		if err == nil {
+			err = writeOutputFile(opts.StatsFile, statsJSON)
+		}
+		if err != nil {
+			return trErrorf("failed to write stats file: %w", err)
+		}
+		if opts.StatsFile != StdioPath {
+			fmt.Fprint(status, tr("Build statistics written to %s\n", opts.StatsFile))
+		}
 	}
 
-	// --- Step 1: Load the MCU Configuration ---
-	configPath := filepath.Join(*configDir, strings.ToLower(*mcu)+".json")
-	mcConfig, err := loadMicrocontrollerConfig(configPath)
-	if err != nil {
-		log.Fatalf("Error loading configuration: %v", err)
+	return nil
+}
+
+// StdioPath is the file name that reads the source from stdin or writes an output to stdout.
+const StdioPath = "-"
+
+// writeOutputFile writes an output file, or writes it to stdout when path is StdioPath.
+func writeOutputFile(path, content string) error {
+	if path == StdioPath {
+		_, err := io.WriteString(os.Stdout, content)
+		return err
 	}
-	fmt.Printf("Configuration loaded for %s\n", *mcu)
+	return os.WriteFile(path, []byte(content), 0644)
+}
 
-	// --- Step 2: Read the Assembly Source Code ---
-	asmCodeBytes, err := os.ReadFile(*asmFile)
-	if err != nil {
-		log.Fatalf("Error reading assembly file '%s': %v", *asmFile, err)
+// parseMicrocontrollerConfig decodes a device config from its JSON text; source names it in errors.
+func parseMicrocontrollerConfig(data []byte, source string) (*MicrocontrollerConfig, error) {
+	var mcConfig MicrocontrollerConfig
+	if err := json.Unmarshal(data, &mcConfig); err != nil {
+		return nil, fmt.Errorf("could not parse JSON from '%s': %w", source, err)
 	}
+	if err := validateInstructionAliases(&mcConfig); err != nil {
+		return nil, fmt.Errorf("invalid config '%s': %w", source, err)
+	}
+	return &mcConfig, nil
+}
 
-	// --- Step 3: Determine Output Filenames ---
-	hexFilePath := *outFile
-	if hexFilePath == "" {
-		baseName := strings.TrimSuffix(*asmFile, filepath.Ext(*asmFile))
-		hexFilePath = baseName + ".hex"
+// loadMicrocontrollerConfig reads and parses a JSON config file for a specific MCU.
+func loadMicrocontrollerConfig(configPath string) (*MicrocontrollerConfig, error) {
+	configFile, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not read config file '%s': %w", configPath, err)
 	}
+	return parseMicrocontrollerConfig(configFile, configPath)
+}
 
-	// --- Step 4: Run the Assembler ---
-	err = assemble(string(asmCodeBytes), hexFilePath, mcConfig, *reportFile)
+// loadMicrocontrollerConfigByName resolves an MCU name to its JSON file in configDir and loads it.
+func loadMicrocontrollerConfigByName(configDir, mcu string) (*MicrocontrollerConfig, error) {
+	mcConfig, err := loadMicrocontrollerConfig(filepath.Join(configDir, strings.ToLower(mcu)+".json"))
 	if err != nil {
-		log.Fatalf("Assembly failed: %v", err)
+		return nil, err
 	}
+	mcConfig.Name = strings.ToUpper(mcu)
+	return mcConfig, nil
 }
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// --- Build Manifest ---
+
+// buildManifest is the sidecar JSON -manifest writes next to the HEX file, recording how the
+// image was produced so it can be traced back to its sources and rebuilt.
+type buildManifest struct {
+	Tool      string             `json:"tool"`
+	Version   string             `json:"version"`
+	BuildTime string             `json:"build_time"` // the time __DATE__ and __TIME__ give
+	Device    string             `json:"device"`
+	Sources   []manifestSource   `json:"sources"`
+	Config    []resultConfigWord `json:"config"`
+	Image     manifestImage      `json:"image"`
+	Options   manifestOptions    `json:"options"`
+}
+
+// manifestSource is a source file: the one assembled, then each file #INCLUDE read, with the
+// bundled libraries marked as such.
+type manifestSource struct {
+	File    string `json:"file"`
+	Library bool   `json:"library,omitempty"`
+	Bytes   int    `json:"bytes"`
+	SHA256  string `json:"sha256"`
+}
+
+type manifestImage struct {
+	Hex        string `json:"hex"`
+	Bytes      int    `json:"bytes"`
+	SHA256     string `json:"sha256"`
+	Checksum   int    `json:"checksum"`
+	IDChecksum *int   `json:"id_checksum,omitempty"`
+	Words      int    `json:"program_words_used"`
+}
+
+type manifestOptions struct {
+	Defines        map[string]string `json:"defines"`
+	FuseSettings   []string          `json:"fuse_settings"`
+	IncludeDirs    []string          `json:"include_dirs"`
+	Optimize       bool              `json:"optimize"`
+	EnabledPasses  []string          `json:"enabled_passes"`
+	DisabledPasses []string          `json:"disabled_passes"`
+	Debug          bool              `json:"debug"`
+	IDChecksum     bool              `json:"id_checksum"`
+	AutoBanksel    bool              `json:"auto_banksel"`
+	AutoPagesel    bool              `json:"auto_pagesel"`
+	Compat         string            `json:"compat"`
+	StrictConfig   bool              `json:"strict_config"`
+	Werror         bool              `json:"werror"`
+	NoELA          bool              `json:"no_ela"`
+	HexLowercase   bool              `json:"hex_lowercase"`
+	HexCRLF        bool              `json:"hex_crlf"`
+	HexPad         bool              `json:"hex_pad"`
+	HexFill        *int              `json:"hex_fill,omitempty"` // only with hex_pad
+	HexOmit        []string          `json:"hex_omit"`
+}
+
+// manifestPath returns the manifest file written next to a HEX file.
+func manifestPath(hexPath string) string {
+	return strings.TrimSuffix(hexPath, filepath.Ext(hexPath)) + "-manifest.json"
+}
+
+// sha256Hex returns the SHA-256 digest of text in hex.
+func sha256Hex(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// sortedNames returns the names set in a map of flags, sorted.
+func sortedNames(set map[string]bool) []string {
+	names := []string{}
+	for name, on := range set {
+		if on {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GenerateManifest renders the build manifest of an assembly of source, read from sourceName,
+// into the HEX content written to hexPath.
+func (a *PicAssembler) GenerateManifest(source, sourceName, hexPath, hexContent string, opts *AssemblyOptions) (string, error) {
+	manifest := buildManifest{
+		Tool:      "asm4pic",
+		Version:   asm4picVersion,
+		BuildTime: a.buildTime.Format(time.RFC3339),
+		Device:    a.mcConfig.Name,
+		Sources:   []manifestSource{{File: sourceName, Bytes: len(source), SHA256: sha256Hex(source)}},
+		Config:    a.resultConfigWords(),
+		Image: manifestImage{
+			Hex:      filepath.Base(hexPath),
+			Bytes:    len(hexContent),
+			SHA256:   sha256Hex(hexContent),
+			Checksum: a.programChecksum(),
+		},
+	}
+	for _, file := range a.included {
+		entry := manifestSource{File: file.Path, Bytes: len(file.Text), SHA256: sha256Hex(file.Text)}
+		if file.Path == "" {
+			entry.File, entry.Library = file.Name, true
+		}
+		manifest.Sources = append(manifest.Sources, entry)
+	}
+	if a.idChecksum >= 0 {
+		manifest.Image.IDChecksum = &a.idChecksum
+	}
+	for addr := range a.machineCodeWords {
+		if addr < a.mcConfig.ProgramMemorySize {
+			manifest.Image.Words++
+		}
+	}
+
+	options := manifestOptions{Defines: map[string]string{}, FuseSettings: []string{}, IncludeDirs: []string{},
+		EnabledPasses: []string{}, DisabledPasses: []string{}, HexOmit: []string{}}
+	if opts != nil {
+		for name, value := range opts.Defines {
+			options.Defines[name] = value
+		}
+		options.FuseSettings = append(options.FuseSettings, opts.FuseSettings...)
+		options.IncludeDirs = append(options.IncludeDirs, opts.IncludeDirs...)
+		options.Optimize, options.Debug, options.IDChecksum = opts.Optimize, opts.Debug, opts.IDChecksum
+		options.EnabledPasses, options.DisabledPasses = sortedNames(opts.EnabledPasses), sortedNames(opts.DisabledPasses)
+		options.AutoBanksel, options.AutoPagesel, options.Compat = opts.AutoBanksel, opts.AutoPagesel, opts.Compat
+		options.StrictConfig, options.Werror, options.NoELA = opts.StrictConfig, opts.WarningsAsErrors, opts.NoELA
+		format := opts.HexFormat
+		options.HexLowercase, options.HexCRLF, options.HexPad = format.Lowercase, format.CRLF, format.PadRecords
+		if format.PadRecords {
+			fill := int(format.Fill)
+			options.HexFill = &fill
+		}
+		options.HexOmit = sortedNames(opts.OmitHexRegions)
+	}
+	manifest.Options = options
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
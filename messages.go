@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// --- Localized Messages ---
+
+// Locales that diagnostics, status messages and the report can be written in. The messages
+// are written in English in the source; the other locales translate them from a catalog.
+const (
+	LocaleEnglish      = "en"
+	LocalePortugueseBR = "pt-BR"
+)
+
+// messageCatalogs holds the translations of each locale but English, keyed by the English
+// format string. A message missing from a catalog is written in English.
+var messageCatalogs = map[string]map[string]string{
+	LocalePortugueseBR: messagesPtBR,
+}
+
+// messageLocale is the locale messages are written in, chosen by -lang or the environment.
+var messageLocale = LocaleEnglish
+
+// tr formats a message in the current locale. format is the English text, which is also
+// the key of its translation; a translation has the same verbs, or refers to the arguments
+// by index (%[2]s) when it needs them in another order.
+func tr(format string, args ...interface{}) string {
+	if translated, ok := messageCatalogs[messageLocale][format]; ok {
+		return fmt.Sprintf(translated, args...)
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// translate returns a text without format verbs, such as a severity, in the current locale.
+func translate(text string) string {
+	if translated, ok := messageCatalogs[messageLocale][text]; ok {
+		return translated
+	}
+	return text
+}
+
+// trErrorf is fmt.Errorf with the format translated by tr, so %w still wraps an error.
+func trErrorf(format string, args ...interface{}) error {
+	if translated, ok := messageCatalogs[messageLocale][format]; ok {
+		return fmt.Errorf(translated, args...)
+	}
+	return fmt.Errorf(format, args...)
+}
+
+// availableLocales lists the locales -lang accepts.
+func availableLocales() []string {
+	locales := []string{LocaleEnglish}
+	for locale := range messageCatalogs {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// parseLocale resolves a locale name: pt-BR, or a POSIX name such as pt_BR.UTF-8. A
+// language with one locale matches by its language alone (pt), and C and POSIX are English.
+func parseLocale(name string) (string, bool) {
+	name, _, _ = strings.Cut(name, ".")
+	name, _, _ = strings.Cut(name, "@")
+	name = strings.ReplaceAll(name, "_", "-")
+	if name == "C" || name == "POSIX" {
+		return LocaleEnglish, true
+	}
+	language, _, _ := strings.Cut(name, "-")
+	var matches []string
+	for _, locale := range availableLocales() {
+		if strings.EqualFold(locale, name) {
+			return locale, true
+		}
+		if l, _, _ := strings.Cut(locale, "-"); strings.EqualFold(l, language) {
+			matches = append(matches, locale)
+		}
+	}
+	if len(matches) == 1 {
+		return matches[0], true
+	}
+	return "", false
+}
+
+// setLocale selects the locale of the messages, as -lang does.
+func setLocale(name string) error {
+	locale, ok := parseLocale(name)
+	if !ok {
+		return fmt.Errorf("unknown language '%s' (available: %s)", name, strings.Join(availableLocales(), ", "))
+	}
+	messageLocale = locale
+	return nil
+}
+
+// environmentLocale returns the locale the environment asks for in LC_ALL, LC_MESSAGES or
+// LANG, the first of them that is set, or English when it names a language without a catalog.
+func environmentLocale() string {
+	for _, variable := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if name := os.Getenv(variable); name != "" {
+			if locale, ok := parseLocale(name); ok {
+				return locale
+			}
+			return LocaleEnglish
+		}
+	}
+	return LocaleEnglish
+}
@@ -0,0 +1,311 @@
+package main
+
+// --- Brazilian Portuguese Messages ---
+
+// messagesPtBR translates the messages into Brazilian Portuguese. Mnemonics, directives,
+// register names and flags stay as written, since they are what the source and MPASM use.
+var messagesPtBR = map[string]string{
+	// Severities and diagnostic prefixes
+	"error":              "erro",
+	"warning":            "aviso",
+	"Line %d":            "Linha %d",
+	"Line %d: %s":        "Linha %d: %s",
+	"%s: Line %d: %s%s":  "%s: Linha %d: %s%s",
+	"Assembly failed":    "Falha na montagem",
+	"Label":              "Rótulo",
+	"Symbol":             "Símbolo",
+	"device":             "dispositivo",
+	"line %d":            "linha %d",
+	"%s (line %d)":       "%s (linha %d)",
+	"%s (%s, line %d)":   "%s (%s, linha %d)",
+	"%s (EQU, line %d)":  "%s (EQU, linha %d)",
+	"%s at line %d":      "%s na linha %d",
+	"%s at line %d (%s)": "%s na linha %d (%s)",
+	", overlays line %d": ", sobrepõe a linha %d",
+	"Using %s.":          "Usando %s.",
+	"Skipping.":          "Ignorado.",
+	"Ignoring.":          "Ignorado.",
+
+	// Status messages
+	"Configuration loaded for %s\n":                   "Configuração carregada para %s\n",
+	"Fetching package %s@%s from %s\n":                "Baixando o pacote %s@%s de %s\n",
+	"Assembly of %s failed\n":                         "Falha na montagem de %s\n",
+	"Assembled %d of %d sources\n":                    "Montados %d de %d arquivos-fonte\n",
+	"%d of %d sources failed to assemble":             "%d de %d arquivos-fonte falharam na montagem",
+	"Assembly successful. HEX file generated at %s\n": "Montagem concluída. Arquivo HEX gerado em %s\n",
+	"Assembly successful. HEX file written to stdout": "Montagem concluída. Arquivo HEX escrito na saída padrão",
+	"Assembly report generated at %s\n":               "Relatório da montagem gerado em %s\n",
+	"Build manifest written to %s\n":                  "Manifesto da compilação escrito em %s\n",
+	"Build statistics written to %s\n":                "Estatísticas da compilação escritas em %s\n",
+	"HEX file (%s) generated at %s\n":                 "Arquivo HEX (%s) gerado em %s\n",
+	"Listing generated at %s\n":                       "Listagem gerada em %s\n",
+	"Map file generated at %s\n":                      "Arquivo de mapa gerado em %s\n",
+	"Result JSON generated at %s\n":                   "JSON de resultado gerado em %s\n",
+	"Optimization saved %d word(s) and %d cycle(s).":  "A otimização economizou %d palavra(s) e %d ciclo(s).",
+	"%d warning(s) treated as errors (-Werror)":       "%d aviso(s) tratado(s) como erro (-Werror)",
+
+	// Errors of the assembly steps
+	"HEX generation failed: %w":               "falha na geração do HEX: %w",
+	"ID checksum failed: %w":                  "falha no checksum do ID: %w",
+	"build profile config failed: %w":         "falha na configuração do perfil de compilação: %w",
+	"debug build failed: %w":                  "falha na compilação de depuração: %w",
+	"failed to write %s HEX file: %w":         "falha ao escrever o arquivo HEX de %s: %w",
+	"failed to write HEX file: %w":            "falha ao escrever o arquivo HEX: %w",
+	"failed to write build manifest: %w":      "falha ao escrever o manifesto da compilação: %w",
+	"failed to write listing file: %w":        "falha ao escrever o arquivo de listagem: %w",
+	"failed to write map file: %w":            "falha ao escrever o arquivo de mapa: %w",
+	"failed to write report file: %w":         "falha ao escrever o arquivo de relatório: %w",
+	"failed to write result file: %w":         "falha ao escrever o arquivo de resultado: %w",
+	"failed to write stats file: %w":          "falha ao escrever o arquivo de estatísticas: %w",
+	"first pass failed: %w":                   "falha na primeira passagem: %w",
+	"second pass failed: %w":                  "falha na segunda passagem: %w",
+	"jump table check failed: %w":             "falha na verificação das tabelas de salto: %w",
+	"macro expansion failed: %w":              "falha na expansão de macros: %w",
+	"parsing failed: %w":                      "falha na análise: %w",
+	"build stopped: %w":                       "montagem interrompida: %w",
+	"result generation failed: %w":            "falha na geração do resultado: %w",
+	"self-write region check failed: %w":      "falha na verificação das regiões de autoescrita: %w",
+	"plugin '%s' failed: %s":                  "o plugin '%s' falhou: %s",
+	"plugin '%s' failed: %w":                  "o plugin '%s' falhou: %w",
+	"empty plugin command":                    "comando de plugin vazio",
+	"invalid SOURCE_DATE_EPOCH '%s'":          "SOURCE_DATE_EPOCH inválido '%s'",
+	"Skipped: %v":                             "Ignorada: %v",
+	"Skipped: no code at the reset vector":    "Ignorada: sem código no vetor de reset",
+	"Removed %d unreachable instruction(s)%s": "Removida(s) %d instrução(ões) inalcançável(is)%s",
+
+	// Source diagnostics
+	"%s '%s' does not match naming pattern %s.":                                                                           "%s '%s' não segue o padrão de nomes %s.",
+	"%s accesses '%s' in bank %d while bank %d is selected.":                                                              "%s acessa '%s' no banco %d enquanto o banco %d está selecionado.",
+	"%s address 0x%X is outside common RAM 0x%X-0x%X.":                                                                    "O endereço 0x%[2]X de %[1]s está fora da RAM comum 0x%[3]X-0x%[4]X.",
+	"%s at the interrupt vector jumps to page %d, but PCLATH cannot be changed before it is saved; jump to page 0 first.": "%s no vetor de interrupção salta para a página %d, mas o PCLATH não pode ser alterado antes de ser salvo; salte para a página 0 primeiro.",
+	"%s calls code using overlay '%s' (section at line %d) while this routine's variables from its section at line %d are live; they share the same addresses.": "%s chama código que usa o overlay '%s' (seção na linha %d) enquanto as variáveis desta rotina, da sua seção na linha %d, estão vivas; elas compartilham os mesmos endereços.",
+	"%s has no ICD entry in its device config, so -debug cannot reserve the debugger's resources.":                                                              "%s não tem entrada ICD na configuração do dispositivo, então -debug não pode reservar os recursos do depurador.",
+	"%s has no data": "%s não tem dados",
+	"%s has no column '%s' in its header on line %d":                                                                                            "%s não tem a coluna '%s' no cabeçalho da linha %d",
+	"%s has no rows for DTABLE":                                                                                                                 "%s não tem linhas para DTABLE",
+	"%s has no user ID locations":                                                                                                               "%s não tem posições de ID do usuário",
+	"%s is an asm4PIC extension; MPASM would reject it.":                                                                                        "%s é uma extensão do asm4PIC; o MPASM a rejeitaria.",
+	"%s is predefined by asm4PIC only; MPASM would report an undefined symbol.":                                                                 "%s é predefinido apenas pelo asm4PIC; o MPASM informaria um símbolo indefinido.",
+	"%s line %d has no value in column %d":                                                                                                      "a linha %[2]d de %[1]s não tem valor na coluna %[3]d",
+	"%s needs MPASM's relocatable mode (an object file and a linker script).":                                                                   "%s precisa do modo relocável do MPASM (um arquivo objeto e um script de linker).",
+	"%s needs common RAM, but the device config has no RAM_LAYOUT shared range.":                                                                "%s precisa de RAM comum, mas a configuração do dispositivo não tem faixa shared em RAM_LAYOUT.",
+	"%s needs general purpose RAM, but the device config has no RAM_LAYOUT gpr ranges.":                                                         "%s precisa de RAM de uso geral, mas a configuração do dispositivo não tem faixas gpr em RAM_LAYOUT.",
+	"%s offset %d is outside -32..31.":                                                                                                          "O deslocamento %[2]d de %[1]s está fora de -32..31.",
+	"%s operand '%s' is not one of k[FSRn], ++FSRn, --FSRn, FSRn++ or FSRn--.":                                                                  "O operando '%[2]s' de %[1]s não é k[FSRn], ++FSRn, --FSRn, FSRn++ nem FSRn--.",
+	"%s reads %s, but on some path its FSR has not been loaded; load the address into FSR first.":                                               "%s lê %s, mas em algum caminho o FSR não foi carregado; carregue o endereço no FSR primeiro.",
+	"%s reads '%s' while %s is still analog (digital reads return 0); clear the select bit first.":                                              "%s lê '%s' enquanto %s ainda é analógico (leituras digitais retornam 0); limpe o bit de seleção primeiro.",
+	"%s reads '%s' while %s are still analog (digital reads return 0); clear the select bit first.":                                             "%s lê '%s' enquanto %s ainda são analógicos (leituras digitais retornam 0); limpe os bits de seleção primeiro.",
+	"%s right after %s may write back a stale pin level of %s (read-modify-write); update a shadow register and copy it to %s with MOVF/MOVWF.": "%s logo após %s pode reescrever um nível de pino desatualizado de %s (leitura-modificação-escrita); atualize um registrador sombra e copie-o para %s com MOVF/MOVWF.",
+	"%s selects %s, but the target is %s.":                                                                                                      "%s seleciona %s, mas o alvo é %s.",
+	"%s takes %s":                                                                                                                               "%s recebe %s",
+	"%s takes one operand: k[FSRn], ++FSRn, --FSRn, FSRn++ or FSRn--.":                                                                          "%s recebe um operando: k[FSRn], ++FSRn, --FSRn, FSRn++ ou FSRn--.",
+	"%s takes one symbol name":                                                                                                                  "%s recebe um nome de símbolo",
+	"%s target '%s' is not a label or constant":                                                                                                 "O alvo '%[2]s' de %[1]s não é um rótulo nem uma constante",
+	"%s'%s' is not a valid number.":                                                                                                             "%s'%s' não é um número válido.",
+	"%v; use PACKED":                                                                                                                            "%v; use PACKED",
+	"%d arguments":                                                                                                                              "%d argumentos",
+	"%d or more arguments":                                                                                                                      "%d ou mais argumentos",
+	"%d to %d":                                                                                                                                  "%d a %d",
+	"%d cycles exceeds the longest generated delay":                                                                                             "%d ciclos excede o maior atraso que pode ser gerado",
+	"%d cycles needs %d counter register(s), got %d":                                                                                            "%d ciclos precisam de %d registrador(es) contador(es), recebidos %d",
+	"'%s' (0x%02X) is read but never written.":                                                                                                  "'%s' (0x%02X) é lido mas nunca escrito.",
+	"'%s' changes STATUS,%s, which the %s before it sets and the %s after it tests.":                                                            "'%s' altera STATUS,%s, que o %s antes dele define e o %s depois dele testa.",
+	"'%s' is in bank %d but the BSR selects bank %d.":                                                                                           "'%s' está no banco %d, mas o BSR seleciona o banco %d.",
+	"'%s' is not a numeric constant":                                                                                                            "'%s' não é uma constante numérica",
+	"'%s' is not a valid label name":                                                                                                            "'%s' não é um nome de rótulo válido",
+	"'%s' is not a valid number in radix %d.":                                                                                                   "'%s' não é um número válido na base %d.",
+	"'%s' is outside the access bank but the BSR is not known here; select bank %d with MOVLB or write '%s, BANKED'.": "'%s' está fora do access bank, mas o BSR não é conhecido aqui; selecione o banco %d com MOVLB ou escreva '%s, BANKED'.",
+	"'%s' overwrites W (%s), which the %s before it loads and the %s after it uses.":                                  "'%s' sobrescreve W (%s), que o %s antes dele carrega e o %s depois dele usa.",
+	"0x%X (no matching option)":                                         "0x%X (nenhuma opção corresponde)",
+	"; erasing it also erases the code at 0x%04X":                       "; apagá-la também apaga o código em 0x%04X",
+	"A'%s' must hold one character.":                                    "A'%s' deve conter um caractere.",
+	"Address 0x%04X is written twice: %s overlaps %s.":                  "O endereço 0x%04X é escrito duas vezes: %s sobrepõe %s.",
+	"Cannot include %s: %v":                                             "Não foi possível incluir %s: %v",
+	"files cannot be read in this assembly":                             "arquivos não podem ser lidos nesta montagem",
+	"absolute path %s is not allowed in this assembly":                  "o caminho absoluto %s não é permitido nesta montagem",
+	"%s climbs out of the include directories":                          "%s sai dos diretórios de inclusão",
+	"%s is %d bytes, more than the %d the program memory holds":         "%s tem %d bytes, mais do que os %d que a memória de programa comporta",
+	"Cannot select bank %d for %s after %s; select it before the skip.": "Não é possível selecionar o banco %d para %s após %s; selecione-o antes do salto condicional.",
+	"Code after END (line %d) is ignored.":                              "O código após END (linha %d) é ignorado.",
+	"Computed jump table 0x%04X-0x%04X crosses the page boundary at 0x%04X; ADDWF PCL, F only changes the low byte of the program counter. Move the table with ORG so it fits in one %d-word page.": "A tabela de salto calculado 0x%04X-0x%04X cruza o limite de página em 0x%04X; ADDWF PCL, F só altera o byte baixo do contador de programa. Mova a tabela com ORG para que caiba em uma página de %d palavras.",
+	"Computed jump table at 0x%04X is outside the first page, but PCLATH is not loaded on the way to the jump; load HIGH of the table address into PCLATH in the same routine, before the jump.":    "A tabela de salto calculado em 0x%04X está fora da primeira página, mas o PCLATH não é carregado no caminho até o salto; carregue HIGH do endereço da tabela no PCLATH na mesma rotina, antes do salto.",
+	"DELAY_US %d at %d Hz is not a whole number of cycles; rounded to %d cycles.":                                                                                                                   "DELAY_US %d a %d Hz não é um número inteiro de ciclos; arredondado para %d ciclos.",
+	"Directive '%s' is nested too deeply.":         "A diretiva '%s' está aninhada profundamente demais.",
+	"Duplicate label '%s'":                         "Rótulo duplicado '%s'",
+	"ENDSELFWRITE without SELFWRITE.":              "ENDSELFWRITE sem SELFWRITE.",
+	"%s without IF.":                               "%s sem IF.",
+	"EQU %s: file register 0x%02X is also SFR %s.": "EQU %s: o registrador 0x%02X também é o SFR %s.",
+	"EQU '%s' = 0x%X shadows the SFR %s at 0x%X defined by the device config.":   "EQU '%s' = 0x%X encobre o SFR %s em 0x%X definido pela configuração do dispositivo.",
+	"EQU directive must have a label.":                                           "A diretiva EQU deve ter um rótulo.",
+	"EQU symbols %s share file register 0x%02X; check the allocation.":           "Os símbolos EQU %s compartilham o registrador 0x%02X; verifique a alocação.",
+	"File register written as literal '%s' in %s; give it a name with EQU.":      "Registrador escrito como literal '%s' em %s; dê um nome a ele com EQU.",
+	"Found label after column 1 (%s).":                                           "Rótulo encontrado após a coluna 1 (%s).",
+	"Found opcode in column 1 (%s); MPASM expects labels there.":                 "Opcode encontrado na coluna 1 (%s); o MPASM espera rótulos ali.",
+	"Fuse group %s of %s is set twice on one line: %s and %s. Keep one of them.": "O grupo de fusíveis %s de %s é definido duas vezes em uma linha: %s e %s. Mantenha apenas um.",
+	"Fuse setting '%s' belongs to unmapped config word index %d.":                "A configuração de fusível '%s' pertence à palavra de configuração não mapeada de índice %d.",
+	"Fuse setting '%s' is in %s; name the word with __CONFIG _%s, ...":           "A configuração de fusível '%s' está em %s; nomeie a palavra com __CONFIG _%s, ...",
+	"Fuse setting '%s' is not in %s.":                                            "A configuração de fusível '%s' não está em %s.",
+	"HEX line %d: Checksum mismatch.":                                            "Linha HEX %d: checksum não confere.",
+	"HEX line %d: Invalid hex digits - %v":                                       "Linha HEX %d: dígitos hexadecimais inválidos - %v",
+	"HEX line %d: Record does not start with ':'.":                               "Linha HEX %d: o registro não começa com ':'.",
+	"HEX line %d: Record length mismatch.":                                       "Linha HEX %d: comprimento do registro não confere.",
+	"HEX line %d: Record type 0x%02X must hold 2 bytes, not %d.":                 "Linha HEX %d: o registro do tipo 0x%02X deve ter 2 bytes, não %d.",
+	"HEX line %d: Unsupported record type 0x%02X.":                               "Linha HEX %d: tipo de registro 0x%02X não suportado.",
+	"ISLABEL() is only known in the assembler passes":                            "ISLABEL() só é conhecido nas passagens do montador",
+	"IF expects a condition.":                                                    "IF espera uma condição.",
+	"IF condition '%s' is not a constant known at this line: %v":                 "A condição de IF '%s' não é uma constante conhecida nesta linha: %v",
+	"IF is never closed with ENDIF.":                                             "IF nunca é fechado com ENDIF.",
+	"Label '%s' is at 0x%04X when the code is generated but at 0x%04X when it was laid out; the branches of an IF on PASS() must take the same space.": "O rótulo '%s' está em 0x%04X quando o código é gerado, mas estava em 0x%04X quando foi disposto; os ramos de um IF sobre PASS() devem ocupar o mesmo espaço.",
+	"The source has IF blocks on PASS(), so -O, -auto-banksel and -auto-pagesel leave the code unchanged.":                                             "O fonte tem blocos IF sobre PASS(), então -O, -auto-banksel e -auto-pagesel deixam o código inalterado.",
+	"Includes nested too deeply at %s (include cycle?).":                                                                                               "Inclusões aninhadas demais em %s (ciclo de inclusão?).",
+	"Inserted %s to select bank %d for %s.":                                                                                                            "Inserido %s para selecionar o banco %d para %s.",
+	"Inserted %s to select page %d for %s.":                                                                                                            "Inserido %s para selecionar a página %d para %s.",
+	"Instruction '%s' expects %d operand(s), got %d.":                                                                                                  "A instrução '%s' espera %d operando(s), recebeu %d.",
+	"Internal error converting binary string '%s' to integer.":                                                                                         "Erro interno ao converter a string binária '%s' em inteiro.",
+	"Internal error: Generated binary string length mismatch for '%s'.":                                                                                "Erro interno: comprimento da string binária gerada não confere para '%s'.",
+	"Interrupt service routine changes STATUS (%s) without saving and restoring it; save it with SWAPF STATUS, W / MOVWF STATUS_TEMP and restore it with SWAPF STATUS_TEMP, W / MOVWF STATUS.": "A rotina de interrupção altera STATUS (%s) sem salvá-lo e restaurá-lo; salve-o com SWAPF STATUS, W / MOVWF STATUS_TEMP e restaure-o com SWAPF STATUS_TEMP, W / MOVWF STATUS.",
+	"Interrupt service routine changes W (%s) without saving and restoring it; start with MOVWF W_TEMP and end with SWAPF W_TEMP, F / SWAPF W_TEMP, W.":                                        "A rotina de interrupção altera W (%s) sem salvá-lo e restaurá-lo; comece com MOVWF W_TEMP e termine com SWAPF W_TEMP, F / SWAPF W_TEMP, W.",
+	"Interrupt service routine never executes RETFIE.":                               "A rotina de interrupção nunca executa RETFIE.",
+	"Interrupt service routine returns with %s; use RETFIE to re-enable interrupts.": "A rotina de interrupção retorna com %s; use RETFIE para reabilitar as interrupções.",
+	"Invalid %s address - %v":                                                        "Endereço de %s inválido - %v",
+	"Invalid %s data - %v":                                                           "Dados de %s inválidos - %v",
+	"Invalid EQU expression - %v":                                                    "Expressão de EQU inválida - %v",
+	"Invalid ORG address - %v":                                                       "Endereço de ORG inválido - %v",
+	"Invalid RES count - %v":                                                         "Contagem de RES inválida - %v",
+	"Invalid __VERSIONSTR - %v":                                                      "__VERSIONSTR inválido - %v",
+	"Invalid __VERSIONSTR address - %v":                                              "Endereço de __VERSIONSTR inválido - %v",
+	"Invalid access bank operand '%s'. Must be 'ACCESS' or 'BANKED'.":                "Operando de access bank inválido '%s'. Deve ser 'ACCESS' ou 'BANKED'.",
+	"Invalid config word '%s' - %v":                                                  "Palavra de configuração inválida '%s' - %v",
+	"Invalid destination '%s'. Must be 'W' or 'F'.":                                  "Destino inválido '%s'. Deve ser 'W' ou 'F'.",
+	"Invalid expression '%s': %v":                                                    "Expressão inválida '%s': %v",
+	"Invalid macro parameter '%s'.":                                                  "Parâmetro de macro inválido '%s'.",
+	"Invalid macro parameter list: %v.":                                              "Lista de parâmetros de macro inválida: %v.",
+	"Invalid operand '%s' for '%s' - %v":                                             "Operando inválido '%s' para '%s' - %v",
+	"MPASM directive %s is not supported by asm4PIC.":                                "A diretiva %s do MPASM não é suportada pelo asm4PIC.",
+	"MPASM reads %s as a hex number, not binary; write B'%s' for binary.":            "O MPASM lê %s como número hexadecimal, não binário; escreva B'%s' para binário.",
+	"MPASM reads '$' as the current address; asm4PIC reads it as a hex prefix and has no current-address symbol.": "O MPASM lê '$' como o endereço atual; o asm4PIC o lê como prefixo hexadecimal e não tem símbolo de endereço atual.",
+	"Macro '%s' expects %s argument(s), got %d.":                                                                  "A macro '%s' espera %s argumento(s), recebeu %d.",
+	"Macro '%s' is nested too deeply (recursive macro?).":                                                         "A macro '%s' está aninhada profundamente demais (macro recursiva?).",
+	"Macro parameter '%s' has an empty default.":                                                                  "O parâmetro de macro '%s' tem um valor padrão vazio.",
+	"Macro parameter '%s' has no default but follows one that has; parameters with defaults must come last.":      "O parâmetro de macro '%s' não tem valor padrão, mas vem depois de um que tem; parâmetros com valor padrão devem vir por último.",
+	"Missing END directive at the end of the source.":                                                             "Falta a diretiva END no fim do código-fonte.",
+	"Missing END directive.": "Falta a diretiva END.",
+	"No room for the %d byte(s) of overlay '%s' in general purpose RAM.": "Não há espaço para os %d byte(s) do overlay '%s' na RAM de uso geral.",
+	"ORG address '%s' is not a constant":                                 "O endereço de ORG '%s' não é uma constante",
+	"ORG address 0x%X out of range.":                                     "Endereço de ORG 0x%X fora da faixa.",
+	"#INCLUDE expects <name>, \"name\" or a file name, got %s.":          "#INCLUDE espera <nome>, \"nome\" ou um nome de arquivo, recebido %s.",
+	"PASS takes no arguments":                                            "PASS não recebe argumentos",
+	"PASS() is only known in the assembler passes":                       "PASS() só é conhecido nas passagens do montador",
+	"Processor include %s is for %s, but the target is %s.":              "O include de processador %s é para %s, mas o alvo é %s.",
+	"Program memory 0x%04X-0x%04X is reserved for the in-circuit debugger in -debug builds; this code reaches 0x%04X.": "A memória de programa 0x%04X-0x%04X é reservada para o depurador in-circuit em compilações -debug; este código alcança 0x%04X.",
+	"Program memory address 0x%X out of bounds.":                                                         "Endereço de memória de programa 0x%X fora dos limites.",
+	"Pseudo-op '%s' is nested too deeply (recursive definition?).":                                       "A pseudo-instrução '%s' está aninhada profundamente demais (definição recursiva?).",
+	"RES '%s' (%d byte(s) at 0x%X) overflows %s RAM ending at 0x%X.":                                     "RES '%s' (%d byte(s) em 0x%X) estoura a RAM %s que termina em 0x%X.",
+	"RES '%s' is not inside a data section (UDATA_SHR or UDATA_OVR).":                                    "RES '%s' não está dentro de uma seção de dados (UDATA_SHR ou UDATA_OVR).",
+	"Register 0x%03X is reserved for the in-circuit debugger in -debug builds, but holds %s.":            "O registrador 0x%03X é reservado para o depurador in-circuit em compilações -debug, mas contém %s.",
+	"Removed %s STATUS, %s overwritten by a later bank select":                                           "Removido %s STATUS, %s sobrescrito por uma seleção de banco posterior",
+	"Removed MOVF %s, W after MOVWF %s (W already holds the value)":                                      "Removido MOVF %s, W após MOVWF %s (W já contém o valor)",
+	"Removed MOVLW %s overwritten by MOVLW %s":                                                           "Removido MOVLW %s sobrescrito por MOVLW %s",
+	"Removed duplicate %s STATUS, %s":                                                                    "Removido %s STATUS, %s duplicado",
+	"Replaced CALL %s + RETURN with GOTO %s (saves a stack level)":                                       "Substituído CALL %s + RETURN por GOTO %s (economiza um nível de pilha)",
+	"Replaced CALL %s with GOTO %s before RETURN (saves a stack level)":                                  "Substituído CALL %s por GOTO %s antes de RETURN (economiza um nível de pilha)",
+	"SELFWRITE inside the region opened at line %d; close it with ENDSELFWRITE first.":                   "SELFWRITE dentro da região aberta na linha %d; feche-a com ENDSELFWRITE primeiro.",
+	"SELFWRITE region is never closed with ENDSELFWRITE.":                                                "A região SELFWRITE nunca é fechada com ENDSELFWRITE.",
+	"Second ELSE in the IF block opened at line %d.":                                                     "Segundo ELSE no bloco IF aberto na linha %d.",
+	"Self-write region 0x%04X-0x%04X only covers part of the %d-word flash row at 0x%04X%s.":             "A região de autoescrita 0x%04X-0x%04X cobre apenas parte da linha de flash de %d palavras em 0x%04X%s.",
+	"Self-write region ends at 0x%04X, inside the %d-word flash row at 0x%04X%s.":                        "A região de autoescrita termina em 0x%04X, dentro da linha de flash de %d palavras em 0x%04X%s.",
+	"Self-write region starts at 0x%04X, inside the %d-word flash row at 0x%04X%s.":                      "A região de autoescrita começa em 0x%04X, dentro da linha de flash de %d palavras em 0x%04X%s.",
+	"Symbol '%s' is already defined.":                                                                    "O símbolo '%s' já está definido.",
+	"Symbol '%s' redefined as 0x%X; line %d defined it as 0x%X.":                                         "Símbolo '%s' redefinido como 0x%X; a linha %d o definiu como 0x%X.",
+	"Symbol values did not settle after %d passes (circular EQU/ORG definitions?).":                      "Os valores dos símbolos não se estabilizaram após %d passagens (definições EQU/ORG circulares?).",
+	"The ICD config setting '%s' is not in the fuse maps of %s.":                                         "A configuração ICD '%s' não está nos mapas de fusíveis de %s.",
+	"The device config has no FLASH_ROW_SIZE, so the self-write region 0x%04X-0x%04X cannot be checked.": "A configuração do dispositivo não tem FLASH_ROW_SIZE, então a região de autoescrita 0x%04X-0x%04X não pode ser verificada.",
+	"UDATA_OVR needs a section name, e.g. 'TEMPS UDATA_OVR'.":                                            "UDATA_OVR precisa de um nome de seção, p. ex. 'TEMPS UDATA_OVR'.",
+	"Undefined symbol or invalid expression: '%s'":                                                       "Símbolo indefinido ou expressão inválida: '%s'",
+	"Unhandled line type: '%s'":                                                                          "Tipo de linha não tratado: '%s'",
+	"Unknown fuse setting '%s' in the build profile.":                                                    "Configuração de fusível desconhecida '%s' no perfil de compilação.",
+	"Unknown fuse setting '%s'.":                                                                         "Configuração de fusível desconhecida '%s'.",
+	"Unknown instruction or directive '%s'.":                                                             "Instrução ou diretiva desconhecida '%s'.",
+	"Unknown radix '%s'; expected HEX, DEC or OCT.":                                                      "Base desconhecida '%s'; esperado HEX, DEC ou OCT.",
+	"User ID location 0x%04X is written by %s, so the checksum cannot be stored there.":                  "A posição de ID do usuário 0x%04X é escrita por %s, então o checksum não pode ser armazenado ali.",
+	"Word 0x%04X at 0x%04X replaces %s = 0x%04X from __CONFIG.":                                          "A palavra 0x%04X em 0x%04X substitui %s = 0x%04X do __CONFIG.",
+	"\\x needs two hex digits in %s":                                                                     "\\x precisa de dois dígitos hexadecimais em %s",
+	"__VERSIONSTR at 0x%X does not fit in program memory.":                                               "__VERSIONSTR em 0x%X não cabe na memória de programa.",
+	"__VERSIONSTR takes one address, got '%s' and '%s'":                                                  "__VERSIONSTR recebe um endereço, recebidos '%s' e '%s'",
+	"argument %d is not positive":                                                                        "o argumento %d não é positivo",
+	"byte address 0x%X needs an Extended Linear Address record, which -no-ela leaves out":                "o endereço de byte 0x%X precisa de um registro Extended Linear Address, que -no-ela omite",
+	"cycle count %d is negative":                                                                         "a contagem de ciclos %d é negativa",
+	"DTABLE columns are numbered from 1, got %d":                                                         "as colunas de DTABLE são numeradas a partir de 1, recebido %d",
+	"DTABLE format must be retlw or dw, got '%s'":                                                        "format de DTABLE deve ser retlw ou dw, recebido '%s'",
+	"DTABLE option '%s' needs a value":                                                                   "a opção '%s' de DTABLE precisa de um valor",
+	"DTABLE skip must be a row count, got '%s'":                                                          "skip de DTABLE deve ser um número de linhas, recebido '%s'",
+	"INCBIN length %d runs past the end of %s (%d bytes from offset %d)":                                 "o comprimento %d de INCBIN passa do fim de %s (%d bytes a partir do deslocamento %d)",
+	"INCBIN offset %d is past the end of %s (%d bytes)":                                                  "o deslocamento %d de INCBIN passa do fim de %s (%d bytes)",
+	"INCBIN offset and length must be numbers, got '%s'":                                                 "o deslocamento e o comprimento de INCBIN devem ser números, recebido '%s'",
+	"cannot read %s: %v":    "não foi possível ler %s: %v",
+	"division by zero":      "divisão por zero",
+	"empty operand in '%s'": "operando vazio em '%s'",
+	"expected DTABLE \"file.csv\"[, col=N][, skip=N][, format=retlw | dw], got '%s'": "esperado DTABLE \"arquivo.csv\"[, col=N][, skip=N][, format=retlw | dw], recebido '%s'",
+	"expected INCBIN \"file\"[, offset[, length]], got '%s'":                         "esperado INCBIN \"arquivo\"[, deslocamento[, comprimento]], recebido '%s'",
+	"expected DELAY_CYCLES cycles[, counter1[, counter2[, counter3]]]":               "esperado DELAY_CYCLES ciclos[, contador1[, contador2[, contador3]]]",
+	"expected DELAY_US microseconds, fosc[, counter1[, counter2[, counter3]]]":       "esperado DELAY_US microssegundos, fosc[, contador1[, contador2[, contador3]]]",
+	"expected JUMPTABLE label, target1[, target2...]":                                "esperado JUMPTABLE rótulo, alvo1[, alvo2...]",
+	"expected __VERSIONSTR \"text\"[, address][, RETLW | PACKED]":                    "esperado __VERSIONSTR \"texto\"[, endereço][, RETLW | PACKED]",
+	"invalid number '%s'": "número inválido '%s'",
+	"library <%s> targets %d-bit cores, but the device has %d-bit program words": "a biblioteca <%s> é para núcleos de %d bits, mas o dispositivo tem palavras de programa de %d bits",
+	"missing ')'":                           "falta ')'",
+	"missing operand":                       "falta o operando",
+	"no config word at 0x%04X":              "nenhuma palavra de configuração em 0x%04X",
+	"oscillator frequency must be positive": "a frequência do oscilador deve ser positiva",
+	"string %s ends with '\\'":              "a string %s termina com '\\'",
+	"text after the string %s":              "texto após a string %s",
+	"the device has no RETLW":               "o dispositivo não tem RETLW",
+	"undefined symbol '%s'":                 "símbolo indefinido '%s'",
+	"unexpected '%s'":                       "'%s' inesperado",
+	"unknown escape '\\%c' in %s":           "escape desconhecido '\\%c' em %s",
+	"unknown DTABLE option '%s'":            "opção de DTABLE desconhecida '%s'",
+	"unknown library <%s> (available: %s)":  "biblioteca desconhecida <%s> (disponíveis: %s)",
+	"unterminated string in '%s'":           "string não terminada em '%s'",
+
+	// Report
+	"Assembly Process Report":   "Relatório do Processo de Montagem",
+	"Code Composition":          "Composição do Código",
+	"Configuration Words":       "Palavras de Configuração",
+	"Defines and Constants":     "Definições e Constantes",
+	"Expanded Assembly Code":    "Código Assembly Expandido",
+	"Generated Machine Code":    "Código de Máquina Gerado",
+	"Labels (Symbol Table)":     "Rótulos (Tabela de Símbolos)",
+	"Memory Usage":              "Uso de Memória",
+	"Optimizations":             "Otimizações",
+	"Original Assembly Code":    "Código Assembly Original",
+	"Symbol Table":              "Tabela de Símbolos",
+	"Program Memory\n\n":        "Memória de Programa\n\n",
+	"\nData Memory\n\n":         "\nMemória de Dados\n\n",
+	"HEX file size: %d bytes\n": "Tamanho do arquivo HEX: %d bytes\n",
+	"    <- optimized (%s)":     "    <- otimizado (%s)",
+	"    Bank %-2d 0x%04X-0x%04X  %4d byte(s)  %4d used  %4d free\n": "    Banco %-2d 0x%04X-0x%04X  %4d byte(s)  %4d usados  %4d livres\n",
+	"    Instructions  %5d  %5.1f%%\n":                               "    Instruções    %5d  %5.1f%%\n",
+	"    Tables        %5d  %5.1f%%\n":                               "    Tabelas       %5d  %5.1f%%\n",
+	"    Saved %d word(s), %d cycle(s)\n":                            "    Economizado: %d palavra(s), %d ciclo(s)\n",
+	"  %-16s %-10s %-13s  %3d byte(s)  line %d%s\n":                  "  %-16s %-10s %-13s  %3d byte(s)  linha %d%s\n",
+	"  %-20s = 0x%04X (checksum)\n":                                  "  %-20s = 0x%04X (soma de verificação)\n",
+	"  0x%04X-0x%04X  %5d word(s)  %s\n":                             "  0x%04X-0x%04X  %5d palavra(s)  %s\n",
+	"  Data memory (general purpose registers):\n":                   "  Memória de dados (registradores de uso geral):\n",
+	"  Data memory: no RAM_LAYOUT in the device config.\n":           "  Memória de dados: sem RAM_LAYOUT na configuração do dispositivo.\n",
+	"  Line %d [%s]: %s\n":                                           "  Linha %d [%s]: %s\n",
+	"  Macros, pseudo-ops and built-ins (invocations, words):\n":     "  Macros, pseudo-instruções e embutidos (invocações, palavras):\n",
+	"  No code after expansion.\n":                                   "  Nenhum código após a expansão.\n",
+	"  No code.\n":                                                   "  Nenhum código.\n",
+	"  No configuration words set.\n":                                "  Nenhuma palavra de configuração definida.\n",
+	"  No data sections.\n":                                          "  Nenhuma seção de dados.\n",
+	"  No defines or EQU symbols.\n":                                 "  Nenhuma definição ou símbolo EQU.\n",
+	"  No labels found.\n":                                           "  Nenhum rótulo encontrado.\n",
+	"  No machine code generated.\n":                                 "  Nenhum código de máquina gerado.\n",
+	"  No optimizations applied.\n":                                  "  Nenhuma otimização aplicada.\n",
+	"  No program code.\n":                                           "  Nenhum código de programa.\n",
+	"  No symbols found.\n":                                          "  Nenhum símbolo encontrado.\n",
+	"  Opcodes:\n":                                                   "  Opcodes:\n",
+	"  Program memory: %d of %d words used, %d free\n":               "  Memória de programa: %d de %d palavras usadas, %d livres\n",
+	"  Program words: %d\n":                                          "  Palavras de programa: %d\n",
+	"  Total: %d word(s), %d cycle(s) saved\n":                       "  Total: %d palavra(s), %d ciclo(s) economizados\n",
+}
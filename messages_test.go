@@ -0,0 +1,102 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+// translatedCalls are the functions that pass their format string through tr.
+var translatedCalls = map[string]bool{
+	"tr": true, "trErrorf": true, "translate": true,
+	"errorAt": true, "warn": true, "fail": true, "record": true,
+}
+
+// formatVerbRegex matches the verbs of a format string, so that formats made only of verbs
+// and punctuation, which read the same in every locale, need no translation.
+var formatVerbRegex = regexp.MustCompile(`%[-+# 0]*(\[\d+\])?\d*(\.\d+)?[a-zA-Z%]`)
+
+// literalText returns the text of a string literal, or of literals joined with +.
+func literalText(expr ast.Expr) (string, bool) {
+	switch e := expr.(type) {
+	case *ast.BasicLit:
+		if e.Kind != token.STRING {
+			return "", false
+		}
+		text, err := strconv.Unquote(e.Value)
+		return text, err == nil
+	case *ast.BinaryExpr:
+		if e.Op != token.ADD {
+			return "", false
+		}
+		left, ok := literalText(e.X)
+		if !ok {
+			return "", false
+		}
+		right, ok := literalText(e.Y)
+		return left + right, ok
+	case *ast.ParenExpr:
+		return literalText(e.X)
+	}
+	return "", false
+}
+
+func TestEveryMessageHasPtBRTranslation(t *testing.T) {
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	missing := map[string]string{}
+	for _, name := range files {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, name, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ast.Inspect(file, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			var callee string
+			switch f := call.Fun.(type) {
+			case *ast.Ident:
+				callee = f.Name
+			case *ast.SelectorExpr:
+				callee = f.Sel.Name
+			}
+			if !translatedCalls[callee] {
+				return true
+			}
+			// The format is the first string argument; codes and lines come before it
+			for _, arg := range call.Args {
+				if text, ok := literalText(arg); ok {
+					words := strings.IndexFunc(formatVerbRegex.ReplaceAllString(text, ""), unicode.IsLetter) >= 0
+					if _, ok := messagesPtBR[text]; !ok && words {
+						missing[text] = fset.Position(call.Pos()).String()
+					}
+					break
+				}
+			}
+			return true
+		})
+	}
+	var keys []string
+	for text := range missing {
+		keys = append(keys, text)
+	}
+	sort.Strings(keys)
+	for _, text := range keys {
+		t.Errorf("%s: no pt-BR translation for %q", missing[text], text)
+	}
+}
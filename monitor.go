@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+)
+
+// --- Serial Monitor ---
+
+func init() {
+	registerSubcommand("monitor", "Stream device output from a serial port to the terminal", runMonitor)
+}
+
+// monitorOptions configures a serial monitor session.
+type monitorOptions struct {
+	Port       string
+	Baud       int
+	LogPath    string
+	Timestamps bool
+}
+
+// runSerialMonitor opens the serial port and copies device output to the console (and an
+// optional log file) line by line until the port closes or the user presses Ctrl+C.
+func runSerialMonitor(opts monitorOptions) error {
+	port, err := openSerialPort(opts.Port, opts.Baud)
+	if err != nil {
+		return fmt.Errorf("could not open serial port '%s': %w", opts.Port, err)
+	}
+	defer port.Close()
+
+	out := io.Writer(os.Stdout)
+	if opts.LogPath != "" {
+		logFile, err := os.OpenFile(opts.LogPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("could not open log file '%s': %w", opts.LogPath, err)
+		}
+		defer logFile.Close()
+		out = io.MultiWriter(os.Stdout, logFile)
+	}
+
+	// Closing the port unblocks the reader below when the user interrupts the session.
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	go func() {
+		<-interrupt
+		port.Close()
+	}()
+
+	fmt.Fprintf(os.Stderr, "--- Monitoring %s at %d baud (Ctrl+C to quit) ---\n", opts.Port, opts.Baud)
+	reader := bufio.NewReader(port)
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			if opts.Timestamps {
+				fmt.Fprintf(out, "[%s] ", time.Now().Format("15:04:05.000"))
+			}
+			fmt.Fprint(out, line)
+			if line[len(line)-1] != '\n' {
+				fmt.Fprintln(out)
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "--- Monitor closed ---\n")
+			return nil
+		}
+	}
+}
+
+// runMonitor parses the monitor flags and starts a session.
+func runMonitor(args []string) error {
+	fs := flag.NewFlagSet("monitor", flag.ExitOnError)
+	port := fs.String("port", "", "Serial port to open, e.g., '/dev/ttyUSB0' (required)")
+	baud := fs.Int("baud", 9600, "Serial baud rate")
+	logPath := fs.String("log", "", "Also append the received output to this file")
+	timestamps := fs.Bool("timestamps", true, "Prefix each received line with the local time")
+	fs.Parse(args)
+
+	if *port == "" {
+		fs.Usage()
+		return fmt.Errorf("-port flag is required")
+	}
+	return runSerialMonitor(monitorOptions{Port: *port, Baud: *baud, LogPath: *logPath, Timestamps: *timestamps})
+}
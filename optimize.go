@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// --- Optimizer ---
+
+// OptimizationChange records one rewrite made by an optimization pass.
+type OptimizationChange struct {
+	Pass        string `json:"pass"`
+	Line        int    `json:"line"`
+	Message     string `json:"message"`
+	WordsSaved  int    `json:"wordsSaved"`
+	CyclesSaved int    `json:"cyclesSaved"`
+	// Before and After are the rewritten instructions as source text.
+	Before []string `json:"before,omitempty"`
+	After  []string `json:"after,omitempty"`
+}
+
+// optimizationPass is one rewrite over the expanded item stream, run before the assembler passes.
+type optimizationPass struct {
+	Name        string
+	Description string
+	Run         func(ctx *optimizeContext)
+	// OptIn passes only run when named explicitly, not just because optimization is on.
+	OptIn bool
+}
+
+// optimizationPasses holds every registered pass in the order they run.
+var optimizationPasses []optimizationPass
+
+// registerOptimizationPass adds a pass to the optimizer.
+func registerOptimizationPass(pass optimizationPass) {
+	optimizationPasses = append(optimizationPasses, pass)
+}
+
+// optimizeContext gives passes access to the expanded code and records their changes.
+type optimizeContext struct {
+	mcConfig *MicrocontrollerConfig
+	symbols  map[string]string // EQU values collected by the parser
+	expanded *ExpandedParsedAssembly
+	pass     string
+	changes  []OptimizationChange
+}
+
+// value resolves a numeric operand: a literal, an EQU symbol, an SFR name or an expression of those.
+func (ctx *optimizeContext) value(operand string) (int, bool) {
+	val, err := evalExpression(operand, symbolLookup(ctx.symbols, ctx.sfr))
+	return val, err == nil
+}
+
+// sfr resolves an SFR name.
+func (ctx *optimizeContext) sfr(name string) (int, bool) {
+	val, ok := ctx.mcConfig.SFRMap[strings.ToUpper(name)]
+	return val, ok
+}
+
+// code returns the item at i if it is a machine instruction (not a directive or macro call).
+func (ctx *optimizeContext) code(i int) (*Instruction, bool) {
+	inst, ok := ctx.expanded.Lines[i].(*Instruction)
+	if !ok {
+		return nil, false
+	}
+	_, known := ctx.mcConfig.InstructionSet[strings.ToUpper(inst.Opcode)]
+	return inst, known
+}
+
+// next returns the index of the next instruction after i, or -1. With throughLabels false,
+// a label (a possible jump target) in between also yields -1. ORG and END always stop the search.
+func (ctx *optimizeContext) next(i int, throughLabels bool) int {
+	for j := i + 1; j < len(ctx.expanded.Lines); j++ {
+		switch ctx.expanded.Lines[j].(type) {
+		case *Label:
+			if !throughLabels {
+				return -1
+			}
+		case *OrgDirective:
+			return -1
+		case *Instruction:
+			if _, ok := ctx.code(j); ok {
+				return j
+			}
+			return -1 // END or anything the assembler does not encode
+		}
+	}
+	return -1
+}
+
+// conditional reports whether the instruction at i directly follows a skip instruction,
+// so removing it would make the skip apply to a different instruction.
+func (ctx *optimizeContext) conditional(i int) bool {
+	for j := i - 1; j >= 0; j-- {
+		switch ctx.expanded.Lines[j].(type) {
+		case *OrgDirective:
+			return false
+		case *Instruction:
+			inst, ok := ctx.code(j)
+			return ok && isSkipInstruction(inst.Opcode)
+		}
+	}
+	return false
+}
+
+// record logs a change made by the current pass at item i that replaced the before
+// instructions with after, saving cyclesSaved cycles on the path through them.
+func (ctx *optimizeContext) record(i int, before, after []*Instruction, cyclesSaved int, format string, args ...interface{}) {
+	change := OptimizationChange{
+		Pass:        ctx.pass,
+		Line:        ctx.expanded.SourceLines[i],
+		Message:     tr(format, args...),
+		WordsSaved:  len(before) - len(after),
+		CyclesSaved: cyclesSaved,
+	}
+	for _, inst := range before {
+		change.Before = append(change.Before, instructionText(inst))
+	}
+	for _, inst := range after {
+		change.After = append(change.After, instructionText(inst))
+	}
+	ctx.changes = append(ctx.changes, change)
+}
+
+// instructionText formats an instruction the way it would be written in source.
+func instructionText(inst *Instruction) string {
+	if len(inst.Operands) == 0 {
+		return inst.Opcode
+	}
+	return inst.Opcode + " " + strings.Join(inst.Operands, ", ")
+}
+
+// remove deletes the items at the given indices.
+func (ctx *optimizeContext) remove(indices map[int]bool) {
+	if len(indices) == 0 {
+		return
+	}
+	out := &ExpandedParsedAssembly{}
+	for i, item := range ctx.expanded.Lines {
+		if !indices[i] {
+			out.Lines = append(out.Lines, item)
+			out.SourceLines = append(out.SourceLines, ctx.expanded.SourceLines[i])
+			if i < len(ctx.expanded.Origins) {
+				out.Origins = append(out.Origins, ctx.expanded.Origins[i])
+			}
+		}
+	}
+	ctx.expanded = out
+}
+
+// optimizeAssembly runs the default passes not listed in disabled plus the opt-in passes listed
+// in enabled, and returns the rewritten code together with a log of the applied changes.
+func optimizeAssembly(expanded *ExpandedParsedAssembly, symbols map[string]string, mcConfig *MicrocontrollerConfig, enabled, disabled map[string]bool) (*ExpandedParsedAssembly, []OptimizationChange) {
+	ctx := &optimizeContext{mcConfig: mcConfig, symbols: symbols, expanded: expanded}
+	for _, pass := range optimizationPasses {
+		if disabled[pass.Name] || (pass.OptIn && !enabled[pass.Name]) {
+			continue
+		}
+		ctx.pass = pass.Name
+		pass.Run(ctx)
+	}
+	return ctx.expanded, ctx.changes
+}
+
+// optimizationLog renders the change log as info diagnostics.
+func optimizationLog(changes []OptimizationChange) []Diagnostic {
+	var diagnostics []Diagnostic
+	for _, change := range sortedOptimizations(changes) {
+		diagnostics = append(diagnostics, Diagnostic{Severity: SeverityInfo, Line: change.Line, Message: fmt.Sprintf("%s [%s]", change.Message, change.Pass), Code: CodeOptimization})
+	}
+	if len(changes) > 0 {
+		words, cycles := optimizationTotals(changes)
+		diagnostics = append(diagnostics, Diagnostic{Severity: SeverityInfo, Message: tr("Optimization saved %d word(s) and %d cycle(s).", words, cycles), Code: CodeOptimization})
+	}
+	return diagnostics
+}
+
+// sortedOptimizations returns the changes ordered by source line, keeping pass order within a line.
+func sortedOptimizations(changes []OptimizationChange) []OptimizationChange {
+	sorted := append([]OptimizationChange(nil), changes...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Line < sorted[j].Line })
+	return sorted
+}
+
+// optimizationTotals sums the words and cycles saved by all changes.
+func optimizationTotals(changes []OptimizationChange) (words, cycles int) {
+	for _, change := range changes {
+		words += change.WordsSaved
+		cycles += change.CyclesSaved
+	}
+	return words, cycles
+}
+
+// parsePassList parses a comma-separated list of optimization pass names.
+func parsePassList(list string) (map[string]bool, error) {
+	names := make(map[string]bool)
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		known := false
+		for _, pass := range optimizationPasses {
+			known = known || pass.Name == name
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown optimization pass '%s'", name)
+		}
+		names[name] = true
+	}
+	return names, nil
+}
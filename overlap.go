@@ -0,0 +1,86 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// --- EQU Register Overlaps ---
+
+// identifierRegex matches an EQU value that is just another name, which makes the symbol an
+// alias of that register rather than an allocation of its own.
+var identifierRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// equVariables returns the EQU symbols that are used as file register operands, the variables
+// allocated by hand. EQUs whose value is another name are aliases and are left out.
+func (a *PicAssembler) equVariables() map[string]bool {
+	equValues := make(map[string]string)
+	for _, item := range a.parsedAssembly.Lines {
+		if v, ok := item.(*EquDirective); ok {
+			equValues[v.Symbol] = strings.TrimSpace(v.Value)
+		}
+	}
+
+	used := make(map[string]bool)
+	for i, item := range a.parsedAssembly.Lines {
+		inst, ok := item.(*Instruction)
+		if _, placed := a.itemAddresses[i]; !ok || !placed {
+			continue
+		}
+		info, ok := a.mcConfig.InstructionSet[strings.ToUpper(inst.Opcode)]
+		if !ok {
+			continue
+		}
+		operand, ok := operandOfKind(inst, info, "f")
+		operand = strings.TrimSpace(operand)
+		if value, isEqu := equValues[operand]; ok && isEqu && !identifierRegex.MatchString(value) {
+			used[operand] = true
+		}
+	}
+	return used
+}
+
+// checkEquOverlaps warns when EQU variables resolve to the same address as each other or as
+// an SFR, the usual sign of a copy-pasted variable allocation.
+func (a *PicAssembler) checkEquOverlaps() {
+	byAddress := make(map[int][]string)
+	for name := range a.equVariables() {
+		addr := a.symbolTable[name]
+		byAddress[addr] = append(byAddress[addr], name)
+	}
+	sfrAt := make(map[int][]string)
+	for name, addr := range a.mcConfig.SFRMap {
+		sfrAt[addr] = append(sfrAt[addr], name)
+	}
+
+	addresses := make([]int, 0, len(byAddress))
+	for addr := range byAddress {
+		addresses = append(addresses, addr)
+	}
+	sort.Ints(addresses)
+	for _, addr := range addresses {
+		names := byAddress[addr]
+		sfrs := sfrAt[addr]
+		if len(names) < 2 && len(sfrs) == 0 {
+			continue
+		}
+		// Report at the last definition, which is usually the copied one.
+		sort.Slice(names, func(i, j int) bool { return a.equLines[names[i]] < a.equLines[names[j]] })
+		described := make([]string, len(names))
+		for k, name := range names {
+			described[k] = tr("%s (line %d)", name, a.equLines[name])
+		}
+		list := strings.Join(described, ", ")
+		if k := strings.LastIndex(list, ", "); k >= 0 {
+			list = list[:k] + " and " + list[k+2:]
+		}
+		line := a.equLines[names[len(names)-1]]
+		if len(sfrs) > 0 {
+			sort.Strings(sfrs)
+			a.warn(line, CodeEquOverlap, "EQU %s: file register 0x%02X is also SFR %s.", list, addr, strings.Join(sfrs, "/"))
+			continue
+		}
+		a.warn(line, CodeEquOverlap, "EQU symbols %s share file register 0x%02X; check the allocation.", list, addr)
+	}
+}
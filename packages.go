@@ -0,0 +1,224 @@
+package main
+
+import (
+	"crypto/sha256"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// --- Packages ---
+
+func init() {
+	registerSubcommand("pkg", "Fetch and list the include packages of a project", runPkg)
+}
+
+// PackageRef is an include/macro package referenced by a project file. A package is either
+// a local directory (Path) or a git repository (URL) pinned to a tag or commit (Version).
+type PackageRef struct {
+	Name    string `json:"name"`
+	Path    string `json:"path,omitempty"`
+	URL     string `json:"url,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+// packageNameRegex restricts package names and versions to strings that are safe in a cache path.
+var packageNameRegex = regexp.MustCompile(`^[A-Za-z0-9_.\-]+$`)
+
+// commitRegex matches a full commit hash, which pins a version without a tag.
+var commitRegex = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// packageURLSchemes are the URL schemes packages are fetched over. Others, such as git's ext::
+// transport, could run commands.
+var packageURLSchemes = map[string]bool{"https": true, "ssh": true, "file": true}
+
+// validate checks that a package reference is complete and unambiguous.
+func (ref PackageRef) validate() error {
+	if !packageNameRegex.MatchString(ref.Name) || ref.Name == "." || ref.Name == ".." {
+		return fmt.Errorf("invalid package name '%s'", ref.Name)
+	}
+	switch {
+	case ref.Path != "" && ref.URL != "":
+		return fmt.Errorf("package '%s' sets both \"path\" and \"url\"", ref.Name)
+	case ref.Path == "" && ref.URL == "":
+		return fmt.Errorf("package '%s' must set \"path\" or \"url\"", ref.Name)
+	case ref.URL != "" && ref.Version == "":
+		return fmt.Errorf("package '%s' must pin a \"version\" (tag or commit)", ref.Name)
+	case ref.URL != "" && (!packageNameRegex.MatchString(ref.Version) || ref.Version == "." || ref.Version == ".."):
+		return fmt.Errorf("invalid version '%s' for package '%s'", ref.Version, ref.Name)
+	}
+	if ref.URL != "" {
+		if u, err := url.Parse(ref.URL); err != nil || !packageURLSchemes[u.Scheme] {
+			return fmt.Errorf("package '%s' has URL '%s'; only https://, ssh:// and file:// URLs are fetched", ref.Name, ref.URL)
+		}
+	}
+	return nil
+}
+
+// packageCacheDir returns the directory fetched packages are cached in.
+// ASM4PIC_PKG_CACHE overrides the per-user default.
+func packageCacheDir() (string, error) {
+	if dir := os.Getenv("ASM4PIC_PKG_CACHE"); dir != "" {
+		return dir, nil
+	}
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "asm4pic", "packages"), nil
+}
+
+// packageDir returns where a package's files live: its local path, or its cache entry. The
+// entry is named after the package and keyed on a hash of its URL and version, so projects
+// that give different repositories the same name and version do not share a checkout.
+func (p *ProjectFile) packageDir(ref PackageRef) (string, error) {
+	if ref.Path != "" {
+		return p.Path(ref.Path), nil
+	}
+	cache, err := packageCacheDir()
+	if err != nil {
+		return "", err
+	}
+	key := sha256.Sum256([]byte(ref.URL + "\x00" + ref.Version))
+	return filepath.Join(cache, fmt.Sprintf("%s@%s-%x", ref.Name, ref.Version, key[:6])), nil
+}
+
+// fetchGitPackage checks out version of a git repository into dest. version must be a tag or
+// a full commit hash: a branch moves, so the same version would fetch different code. The
+// checkout is made in a temporary directory and renamed into place so an interrupted fetch
+// never leaves a partial package.
+func fetchGitPackage(url, version, dest string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(dest), ".fetch-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	ref := version
+	if !commitRegex.MatchString(version) {
+		ref = "refs/tags/" + version
+		out, err := exec.Command("git", "ls-remote", "--tags", "--", url, ref).Output()
+		if err != nil {
+			return fmt.Errorf("git ls-remote failed: %v", err)
+		}
+		if strings.TrimSpace(string(out)) == "" {
+			return fmt.Errorf("version '%s' is not a tag of %s; pin a tag or a full commit hash", version, url)
+		}
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"fetch", "-q", "--depth", "1", "--", url, ref},
+		{"checkout", "-q", "FETCH_HEAD"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = tmp
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("git %s failed: %v\n%s", args[0], err, out)
+		}
+	}
+	if err := os.RemoveAll(filepath.Join(tmp, ".git")); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dest)
+}
+
+// resolvePackages returns the include directories of every project package, fetching
+// pinned URL packages that are not cached yet.
+func (p *ProjectFile) resolvePackages() ([]string, error) {
+	var dirs []string
+	for _, ref := range p.Packages {
+		if err := ref.validate(); err != nil {
+			return nil, err
+		}
+		dir, err := p.packageDir(ref)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(dir); err != nil {
+			if ref.URL == "" {
+				return nil, fmt.Errorf("package '%s': %w", ref.Name, err)
+			}
+			fmt.Fprint(os.Stderr, tr("Fetching package %s@%s from %s\n", ref.Name, ref.Version, ref.URL))
+			if err := fetchGitPackage(ref.URL, ref.Version, dir); err != nil {
+				return nil, fmt.Errorf("package '%s': %w", ref.Name, err)
+			}
+		}
+		dirs = append(dirs, dir)
+	}
+	return dirs, nil
+}
+
+// runPkg implements `pkg fetch` (download missing or, with -update, all URL packages)
+// and `pkg list` (show each package and where it resolves to).
+func runPkg(args []string) error {
+	fs := flag.NewFlagSet("pkg", flag.ExitOnError)
+	projectPath := fs.String("project", defaultProjectFileName, "Path to the asm4pic.json project file")
+	update := fs.Bool("update", false, "With fetch: fetch URL packages again even if they are cached")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: pkg [flags] fetch|list\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected one action: fetch or list")
+	}
+
+	project, err := loadProjectFile(*projectPath)
+	if err != nil {
+		return err
+	}
+
+	switch fs.Arg(0) {
+	case "fetch":
+		if *update {
+			for _, ref := range project.Packages {
+				if ref.URL == "" || ref.validate() != nil {
+					continue
+				}
+				dir, err := project.packageDir(ref)
+				if err != nil {
+					return err
+				}
+				if err := os.RemoveAll(dir); err != nil {
+					return err
+				}
+			}
+		}
+		dirs, err := project.resolvePackages()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d package(s) ready\n", len(dirs))
+		return nil
+	case "list":
+		for _, ref := range project.Packages {
+			if err := ref.validate(); err != nil {
+				return err
+			}
+			dir, err := project.packageDir(ref)
+			if err != nil {
+				return err
+			}
+			source, status := ref.Path, "ok"
+			if ref.URL != "" {
+				source = ref.URL + "@" + ref.Version
+			}
+			if _, err := os.Stat(dir); err != nil {
+				status = "missing"
+			}
+			fmt.Printf("%-16s %-8s %s -> %s\n", ref.Name, status, source, dir)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown action '%s' (expected fetch or list)", fs.Arg(0))
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPackageURLSchemes(t *testing.T) {
+	for url, ok := range map[string]bool{
+		"https://example.com/lcd.git":  true,
+		"ssh://git@example.com/lcd":    true,
+		"file:///srv/git/lcd":          true,
+		"http://example.com/lcd.git":   false,
+		"ext::sh -c touch% /tmp/owned": false,
+		"--upload-pack=touch /tmp/x":   false,
+		"git@example.com:lcd.git":      false,
+	} {
+		err := PackageRef{Name: "lcd", URL: url, Version: "v1"}.validate()
+		if (err == nil) != ok {
+			t.Errorf("%s: validate() = %v, want accepted %v", url, err, ok)
+		}
+	}
+}
+
+func TestPackageDirKeyedOnURL(t *testing.T) {
+	t.Setenv("ASM4PIC_PKG_CACHE", t.TempDir())
+	project := &ProjectFile{}
+	a, err := project.packageDir(PackageRef{Name: "lcd", URL: "https://example.com/a.git", Version: "v1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := project.packageDir(PackageRef{Name: "lcd", URL: "https://example.com/b.git", Version: "v1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == b {
+		t.Errorf("packages from different URLs share the cache entry %s", a)
+	}
+}
+
+func TestFetchGitPackageRequiresTag(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := t.TempDir()
+	writeFiles(t, repo, map[string]string{"lcd.inc": "LCD EQU 1\n"})
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "."},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "lcd"},
+		{"tag", "v1"},
+		{"branch", "dev"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repo
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", args[0], err, out)
+		}
+	}
+	url := "file://" + filepath.ToSlash(repo)
+	cache := t.TempDir()
+
+	if err := fetchGitPackage(url, "v1", filepath.Join(cache, "tag")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(cache, "tag", "lcd.inc")); err != nil {
+		t.Errorf("tag checkout: %v", err)
+	}
+	err := fetchGitPackage(url, "dev", filepath.Join(cache, "branch"))
+	if err == nil || !strings.Contains(err.Error(), "not a tag") {
+		t.Errorf("fetching a branch: %v, want refused", err)
+	}
+}
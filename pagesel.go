@@ -0,0 +1,260 @@
+package main
+
+import (
+	"fmt"
+	"math/bits"
+	"strings"
+)
+
+// --- Automatic Page Selection ---
+
+// autoPageselName names the code inserted by -auto-pagesel in provenance and diagnostics.
+const autoPageselName = "auto-pagesel"
+
+// pclathPageShift is the PCLATH bit holding the low bit of the page GOTO and CALL jump into.
+const pclathPageShift = 3
+
+func init() {
+	insertionModes[autoPageselName] = true
+}
+
+// pageSelector follows the page PCLATH selects through one layout of the code.
+type pageSelector struct {
+	a       *PicAssembler
+	mask    int // the page select bits of PCLATH, shifted down
+	pclath  int
+	unknown bankState
+}
+
+// pageSelectBits returns the width of the GOTO and CALL target, which gives the page size,
+// and the page select bits of PCLATH. ok is false when the jumps reach the whole program
+// memory, so there is nothing to select.
+func pageSelectBits(mcConfig *MicrocontrollerConfig) (pageBits, mask int, ok bool) {
+	info, hasGOTO := mcConfig.InstructionSet["GOTO"]
+	_, hasPCLATH := mcConfig.SFRMap["PCLATH"]
+	pageBits = strings.Count(info.OpcodePattern, "k")
+	if !hasGOTO || !hasPCLATH || pageBits == 0 {
+		return 0, 0, false
+	}
+	pages := (mcConfig.ProgramMemorySize + 1<<pageBits - 1) >> pageBits
+	return pageBits, 1<<bits.Len(uint(pages-1)) - 1, pages > 1
+}
+
+// pageSelection returns the instructions that load the page select bits of PCLATH with a
+// page. All bits are written, so the code is the same size whatever the page.
+func pageSelection(page, mask int) []*Instruction {
+	var code []*Instruction
+	for bit := 0; 1<<bit <= mask; bit++ {
+		opcode := "BCF"
+		if page>>bit&1 == 1 {
+			opcode = "BSF"
+		}
+		code = append(code, &Instruction{Opcode: opcode, Operands: []string{"PCLATH", fmt.Sprint(pclathPageShift + bit)}})
+	}
+	return code
+}
+
+// writesPCLATH reports whether an instruction stores into PCLATH.
+func (p *pageSelector) writesPCLATH(inst *Instruction, info InstructionInfo) bool {
+	operand, ok := operandOfKind(inst, info, "f")
+	if !ok || !writesFileRegister(inst, info) {
+		return false
+	}
+	addr, err := p.a.evaluateExpression(operand)
+	return err == nil && addr == p.pclath
+}
+
+// transfer returns the page selected by PCLATH after an instruction.
+func (p *pageSelector) transfer(s bankState, inst *Instruction, info InstructionInfo) bankState {
+	if !p.writesPCLATH(inst, info) {
+		return s
+	}
+	switch strings.ToUpper(inst.Opcode) {
+	case "CLRF":
+		return bankState{reached: true, known: p.mask}
+	case "BSF", "BCF":
+		bitOperand, _ := operandOfKind(inst, info, "b")
+		bit, err := p.a.evaluateExpression(bitOperand)
+		if err != nil {
+			return p.unknown
+		}
+		m := 1 << (bit - pclathPageShift) & p.mask
+		if bit < pclathPageShift || m == 0 {
+			return s
+		}
+		s.known |= m
+		s.value &^= m
+		if strings.ToUpper(inst.Opcode) == "BSF" {
+			s.value |= m
+		}
+		return s
+	}
+	return p.unknown
+}
+
+// pagesAt follows the page selected by PCLATH from the vectors and returns it before each
+// instruction, assuming the page in need is selected before the instructions needing one.
+// After a CALL it is what it was before, unless the subroutine writes PCLATH.
+func (p *pageSelector) pagesAt(g *flowGraph, need map[int]int) map[int]bankState {
+	lines := p.a.parsedAssembly.Lines
+	changesPCLATH := make(map[int]bool) // call target -> code reached from it writes PCLATH
+	calleeWritesPCLATH := func(target int) bool {
+		if changed, ok := changesPCLATH[target]; ok {
+			return changed
+		}
+		changesPCLATH[target] = false
+		for i := range g.reachable([]int{target}) {
+			inst := lines[i].(*Instruction)
+			if p.writesPCLATH(inst, p.a.mcConfig.InstructionSet[strings.ToUpper(inst.Opcode)]) {
+				changesPCLATH[target] = true
+			}
+		}
+		return changesPCLATH[target]
+	}
+
+	in := make(map[int]bankState)
+	var work []int
+	enter := func(i int, s bankState) {
+		if merged := in[i].merge(s); merged != in[i] {
+			in[i] = merged
+			work = append(work, i)
+		}
+	}
+	if i, ok := g.atAddress[resetVector]; ok {
+		enter(i, bankState{reached: true, known: p.mask}) // reset clears PCLATH
+	}
+	for _, i := range g.roots() {
+		if g.address[i] != resetVector {
+			enter(i, p.unknown)
+		}
+	}
+	for len(work) > 0 {
+		i := work[len(work)-1]
+		work = work[:len(work)-1]
+		s := in[i]
+		if page, ok := need[i]; ok && !(s.known == p.mask && s.value == page) {
+			s = bankState{reached: true, value: page, known: p.mask}
+		}
+		inst := lines[i].(*Instruction)
+		s = p.transfer(s, inst, p.a.mcConfig.InstructionSet[strings.ToUpper(inst.Opcode)])
+		successors := g.successors[i]
+		if strings.ToUpper(inst.Opcode) == "CALL" && len(successors) == 2 && calleeWritesPCLATH(successors[0]) {
+			enter(successors[0], s)
+			enter(successors[1], p.unknown)
+			continue
+		}
+		for _, next := range successors {
+			enter(next, s)
+		}
+	}
+	return in
+}
+
+// pageAnchor is a page selection to insert before an item: a GOTO or CALL, or the skip in
+// front of one.
+type pageAnchor struct {
+	page int
+	jump string // the GOTO or CALL it is for
+}
+
+// autoPageSelect inserts PCLATH page selection before each GOTO and CALL whose target is in
+// a page PCLATH is not known to select, or before the skip in front of it. Insertions move
+// the code after them, which can move targets into other pages, so the layout is redone until
+// no insertion is added or changed. Selections are never removed and always write every page
+// bit, which makes the process settle. A jump at the interrupt vector is left alone, since
+// PCLATH still belongs to the interrupted code there. It returns the new code and a
+// diagnostic for each insertion.
+func autoPageSelect(expanded *ExpandedParsedAssembly, mcConfig *MicrocontrollerConfig, predefined map[string]predefinedSymbol, defines map[string]string) (*ExpandedParsedAssembly, []Diagnostic) {
+	pageBits, mask, ok := pageSelectBits(mcConfig)
+	if !ok {
+		return expanded, nil
+	}
+	anchors := make(map[AssemblyItem]pageAnchor)
+	for {
+		code := &ExpandedParsedAssembly{}
+		for i, item := range expanded.Lines {
+			if anchor, ok := anchors[item]; ok {
+				inserted := insertedOrigin(expanded, i, autoPageselName)
+				for _, inst := range pageSelection(anchor.page, mask) {
+					code.Lines = append(code.Lines, inst)
+					code.SourceLines = append(code.SourceLines, expanded.SourceLines[i])
+					code.Origins = append(code.Origins, inserted)
+				}
+			}
+			code.Lines = append(code.Lines, item)
+			code.SourceLines = append(code.SourceLines, expanded.SourceLines[i])
+			var origin *Provenance
+			if i < len(expanded.Origins) {
+				origin = expanded.Origins[i]
+			}
+			code.Origins = append(code.Origins, origin)
+		}
+
+		a := NewPicAssembler(mcConfig, code)
+		a.predefined, a.defines = predefined, defines
+		if a.firstPass() != nil {
+			return code, nil // the assembler passes report the errors
+		}
+		p := &pageSelector{a: a, mask: mask, pclath: mcConfig.SFRMap["PCLATH"], unknown: bankState{reached: true}}
+		resolve := func(operand string) (int, bool) {
+			val, err := a.evaluateExpression(operand)
+			return val, err == nil
+		}
+		g := buildFlowGraph(code, mcConfig, resolve)
+
+		// The page each jump needs, and where its selection goes: before the jump, or before
+		// the skip in front of it
+		var diagnostics []Diagnostic
+		need := make(map[int]int)
+		jumps := make(map[int]int) // instruction the selection goes before -> its jump
+		for i, addr := range g.address {
+			inst := code.Lines[i].(*Instruction)
+			opcode := strings.ToUpper(inst.Opcode)
+			if (opcode != "GOTO" && opcode != "CALL") || len(inst.Operands) != 1 {
+				continue
+			}
+			target, ok := resolve(inst.Operands[0])
+			if !ok {
+				continue
+			}
+			page := target >> pageBits & mask
+			if addr == interruptVector {
+				if page != 0 {
+					diagnostics = append(diagnostics, Diagnostic{Severity: SeverityWarning, Line: code.SourceLines[i], Code: CodePageAtVector,
+						Message: tr("%s at the interrupt vector jumps to page %d, but PCLATH cannot be changed before it is saved; jump to page 0 first.", instructionText(inst), page)})
+				}
+				continue
+			}
+			at := i
+			if skip, ok := g.atAddress[addr-1]; ok && isSkipInstruction(flowOpcode(code, mcConfig, skip)) {
+				at = skip
+			}
+			need[at], jumps[at] = page, i
+		}
+		in := p.pagesAt(g, need)
+
+		changed := false
+		for at, page := range need {
+			if s := in[at]; s.reached && s.known == mask && s.value == page {
+				continue
+			}
+			if current, ok := anchors[code.Lines[at]]; !ok || current.page != page {
+				anchors[code.Lines[at]] = pageAnchor{page, instructionText(code.Lines[jumps[at]].(*Instruction))}
+				changed = true
+			}
+		}
+		if !changed {
+			for i, item := range code.Lines {
+				if anchor, ok := anchors[item]; ok {
+					var texts []string
+					for _, inst := range pageSelection(anchor.page, mask) {
+						texts = append(texts, instructionText(inst))
+					}
+					diagnostics = append(diagnostics, Diagnostic{Severity: SeverityInfo, Line: code.SourceLines[i], Code: CodePageselInserted,
+						Message: tr("Inserted %s to select page %d for %s.", strings.Join(texts, "; "), anchor.page, anchor.jump)})
+				}
+			}
+			return code, diagnostics
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import "strings"
+
+// --- Computed Jump Tables ---
+
+// pclPageSize is the number of program words ADDWF PCL, F can reach: it only changes the
+// low byte of the program counter, the high bits come from PCLATH.
+const pclPageSize = 256
+
+// jumpTableEnd returns the address of the last entry of the computed jump table that follows
+// the ADDWF PCL, F at index i: the run of RETLW and GOTO instructions and DT data after it, up
+// to the next label, which starts other code. It returns -1 if no entry follows.
+func (a *PicAssembler) jumpTableEnd(i int) int {
+	last := -1
+	for j := i + 1; j < len(a.parsedAssembly.Lines); j++ {
+		addr, placed := a.itemAddresses[j]
+		switch v := a.parsedAssembly.Lines[j].(type) {
+		case *Instruction:
+			opcode := strings.ToUpper(v.Opcode)
+			if !placed || (opcode != "RETLW" && opcode != "GOTO") {
+				return last
+			}
+			last = addr
+		case *DataDirective:
+			if !placed || v.Name != "DT" {
+				return last
+			}
+			last = addr + v.wordCount() - 1
+		case *Label, *OrgDirective:
+			return last
+		}
+	}
+	return last
+}
+
+// checkJumpTables verifies every computed jump (ADDWF PCL, F) and the table after it: the
+// whole table must lie in the 256-word page of the instruction after the ADDWF, or the jump
+// wraps to the start of the page, and PCLATH must be loaded on the way to the jump when the
+// table is not in the first page, which PCLATH selects after reset (see loadsPCLATH).
+func (a *PicAssembler) checkJumpTables() error {
+	pcl, ok := a.mcConfig.SFRMap["PCL"]
+	if !ok {
+		pcl = pclRegister
+	}
+	pclath, hasPCLATH := a.mcConfig.SFRMap["PCLATH"]
+	// writes returns the placed instruction at i if it writes the file register at addr.
+	writes := func(i, addr int) (*Instruction, bool) {
+		inst, ok := a.parsedAssembly.Lines[i].(*Instruction)
+		if _, placed := a.itemAddresses[i]; !ok || !placed {
+			return nil, false
+		}
+		info, ok := a.mcConfig.InstructionSet[strings.ToUpper(inst.Opcode)]
+		if !ok || !writesFileRegister(inst, info) {
+			return nil, false
+		}
+		operand, _ := operandOfKind(inst, info, "f")
+		val, err := a.evaluateExpression(operand)
+		return inst, err == nil && val&0x7F == addr&0x7F
+	}
+
+	// jumps maps each label to the GOTO, CALL and BRA instructions that jump to it.
+	jumps := make(map[string][]int)
+	for i, item := range a.parsedAssembly.Lines {
+		if inst, ok := item.(*Instruction); ok && len(inst.Operands) == 1 {
+			switch strings.ToUpper(inst.Opcode) {
+			case "GOTO", "CALL", "BRA":
+				jumps[inst.Operands[0]] = append(jumps[inst.Operands[0]], i)
+			}
+		}
+	}
+	// loadsPCLATH reports whether PCLATH is written in the straight-line code before the
+	// instruction at i, back to the previous jump, call or return. Code at a label is also
+	// entered by the jumps to it, so each of them must load PCLATH the same way; a write in
+	// another routine does not count, since it may run at any time.
+	var loadsPCLATH func(i int, seen map[string]bool) bool
+	loadsPCLATH = func(i int, seen map[string]bool) bool {
+		entered := false // every jump to a label passed on the way loads PCLATH
+		for j := i - 1; j >= 0; j-- {
+			switch v := a.parsedAssembly.Lines[j].(type) {
+			case *OrgDirective:
+				return entered
+			case *Label:
+				if seen[v.Name] {
+					return false
+				}
+				seen[v.Name] = true
+				for _, from := range jumps[v.Name] {
+					if !loadsPCLATH(from, seen) {
+						return false
+					}
+				}
+				entered = entered || len(jumps[v.Name]) > 0
+			case *Instruction:
+				if _, ok := writes(j, pclath); ok {
+					return true
+				}
+				switch strings.ToUpper(v.Opcode) {
+				case "GOTO", "BRA", "RETURN", "RETLW", "RETFIE":
+					return entered
+				case "CALL", "CALLW", "BRW":
+					return false
+				}
+			}
+		}
+		return entered
+	}
+
+	for i := range a.parsedAssembly.Lines {
+		inst, ok := writes(i, pcl)
+		if !ok || strings.ToUpper(inst.Opcode) != "ADDWF" {
+			continue
+		}
+		jump := a.itemAddresses[i]
+		last := a.jumpTableEnd(i)
+		if last < 0 {
+			continue
+		}
+		start := jump + 1
+		if start/pclPageSize != last/pclPageSize {
+			return a.errorAt(i, CodeTablePageCrossing, "Computed jump table 0x%04X-0x%04X crosses the page boundary at 0x%04X; ADDWF PCL, F only changes the low byte of the program counter. Move the table with ORG so it fits in one %d-word page.",
+				start, last, (last/pclPageSize)*pclPageSize, pclPageSize)
+		}
+		if hasPCLATH && start >= pclPageSize && !loadsPCLATH(i, make(map[string]bool)) {
+			return a.errorAt(i, CodePCLATHNotSet, "Computed jump table at 0x%04X is outside the first page, but PCLATH is not loaded on the way to the jump; load HIGH of the table address into PCLATH in the same routine, before the jump.", start)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+// tableSource calls a computed jump table at 0x100 from MAIN, running setup first.
+func tableSource(setup, other string) string {
+	return "        ORG 0\n        GOTO MAIN\n" + other + "MAIN:\n" + setup +
+		"        MOVLW 1\n        CALL TABLE\n        GOTO MAIN\n" +
+		"        ORG 0x100\nTABLE:\n        ADDWF PCL, F\n        RETLW 1\n        RETLW 2\n        END\n"
+}
+
+func TestJumpTablePCLATHLoadedInAnotherRoutine(t *testing.T) {
+	other := "SETPAGE:\n        MOVLW HIGH(TABLE)\n        MOVWF PCLATH\n        RETURN\n"
+	_, _, err := assembleSource(tableSource("        CALL SETPAGE\n", other), testConfig(t), nil)
+	if err == nil {
+		t.Fatal("PCLATH loaded in another routine was accepted")
+	}
+	if code := errorDiagnostic(err).Code; code != CodePCLATHNotSet {
+		t.Errorf("error code = %s, want %s: %v", code, CodePCLATHNotSet, err)
+	}
+}
+
+func TestJumpTablePCLATHLoadedBeforeCall(t *testing.T) {
+	setup := "        MOVLW HIGH(TABLE)\n        MOVWF PCLATH\n"
+	if _, _, err := assembleSource(tableSource(setup, ""), testConfig(t), nil); err != nil {
+		t.Fatal(err)
+	}
+}
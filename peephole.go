@@ -0,0 +1,154 @@
+package main
+
+import (
+	"strings"
+)
+
+// --- Peephole Optimizer ---
+
+func init() {
+	registerOptimizationPass(optimizationPass{
+		Name:        "peephole",
+		Description: "Remove redundant bank selects, reloads of W and overwritten literal loads",
+		Run:         peephole,
+	})
+}
+
+// statusRegister is the STATUS address; bankSelectBits are its IRP, RP1 and RP0 bits.
+const statusRegister = 0x03
+
+var bankSelectBits = map[int]string{5: "RP0", 6: "RP1", 7: "IRP"}
+
+// zeroFlagWriters are instructions that set Z from their result without reading it first.
+var zeroFlagWriters = map[string]bool{
+	"ADDWF": true, "ANDWF": true, "IORWF": true, "XORWF": true, "SUBWF": true,
+	"MOVF": true, "CLRF": true, "CLRW": true, "COMF": true, "DECF": true, "INCF": true,
+	"ADDLW": true, "ANDLW": true, "IORLW": true, "XORLW": true, "SUBLW": true,
+}
+
+// peephole applies each local rewrite once over the instruction stream.
+func peephole(ctx *optimizeContext) {
+	removed := make(map[int]bool)
+	peepholeBankSelects(ctx, removed)
+	peepholeReloadW(ctx, removed)
+	peepholeOverwrittenLiteral(ctx, removed)
+	ctx.remove(removed)
+}
+
+// statusBitOp decodes BCF/BSF STATUS, b on a bank select bit.
+func statusBitOp(ctx *optimizeContext, i int) (op string, bit int, ok bool) {
+	inst, isCode := ctx.code(i)
+	if !isCode || len(inst.Operands) != 2 {
+		return "", 0, false
+	}
+	op = strings.ToUpper(inst.Opcode)
+	if op != "BCF" && op != "BSF" {
+		return "", 0, false
+	}
+	reg, regOK := ctx.value(inst.Operands[0])
+	bit, bitOK := ctx.value(inst.Operands[1])
+	if !regOK || !bitOK || reg&0x7F != statusRegister || bankSelectBits[bit] == "" {
+		return "", 0, false
+	}
+	return op, bit, true
+}
+
+// peepholeBankSelects removes a bank select bit write that is overwritten by a later write
+// of the same bit in the same run of consecutive bank selects (e.g. BANK0 followed by BANK1).
+func peepholeBankSelects(ctx *optimizeContext, removed map[int]bool) {
+	for start := 0; start < len(ctx.expanded.Lines); start++ {
+		if _, _, ok := statusBitOp(ctx, start); !ok || removed[start] || ctx.conditional(start) {
+			continue
+		}
+		// Collect the run of bank selects starting here, stopping at labels
+		run := []int{start}
+		for j := ctx.next(start, false); j >= 0; j = ctx.next(j, false) {
+			if _, _, ok := statusBitOp(ctx, j); !ok {
+				break
+			}
+			run = append(run, j)
+		}
+		for k, i := range run {
+			op, bit, _ := statusBitOp(ctx, i)
+			for _, later := range run[k+1:] {
+				laterOp, laterBit, _ := statusBitOp(ctx, later)
+				if laterBit != bit {
+					continue
+				}
+				removed[i] = true
+				inst, _ := ctx.code(i)
+				if laterOp == op {
+					ctx.record(i, []*Instruction{inst}, nil, 1, "Removed duplicate %s STATUS, %s", op, bankSelectBits[bit])
+				} else {
+					ctx.record(i, []*Instruction{inst}, nil, 1, "Removed %s STATUS, %s overwritten by a later bank select", op, bankSelectBits[bit])
+				}
+				break
+			}
+		}
+		start = run[len(run)-1]
+	}
+}
+
+// peepholeReloadW removes MOVF f, W right after MOVWF f: W already holds f. Only general
+// purpose registers qualify (SFRs may read back differently), and the next instruction must
+// overwrite Z so the flag MOVF would have set is never observed.
+func peepholeReloadW(ctx *optimizeContext, removed map[int]bool) {
+	for i := range ctx.expanded.Lines {
+		store, ok := ctx.code(i)
+		if !ok || removed[i] || strings.ToUpper(store.Opcode) != "MOVWF" || len(store.Operands) != 1 || ctx.conditional(i) {
+			continue
+		}
+		j := ctx.next(i, false)
+		if j < 0 || removed[j] {
+			continue
+		}
+		load, _ := ctx.code(j)
+		if strings.ToUpper(load.Opcode) != "MOVF" || len(load.Operands) != 2 || strings.ToUpper(load.Operands[1]) != "W" {
+			continue
+		}
+		addr, ok := ctx.value(store.Operands[0])
+		loadAddr, loadOK := ctx.value(load.Operands[0])
+		if !ok || !loadOK || addr != loadAddr || addr&0x7F < 0x20 || isSFRAddress(ctx.mcConfig, addr) {
+			continue
+		}
+		k := ctx.next(j, true)
+		if k < 0 {
+			continue
+		}
+		after, _ := ctx.code(k)
+		if !zeroFlagWriters[strings.ToUpper(after.Opcode)] || readsStatus(ctx, after) {
+			continue
+		}
+		removed[j] = true
+		ctx.record(j, []*Instruction{load}, nil, 1, "Removed MOVF %s, W after MOVWF %s (W already holds the value)", load.Operands[0], store.Operands[0])
+	}
+}
+
+// readsStatus reports whether an instruction has STATUS as its file register operand.
+func readsStatus(ctx *optimizeContext, inst *Instruction) bool {
+	info := ctx.mcConfig.InstructionSet[strings.ToUpper(inst.Opcode)]
+	reg, ok := operandOfKind(inst, info, "f")
+	if !ok {
+		return false
+	}
+	addr, ok := ctx.value(reg)
+	return ok && addr&0x7F == statusRegister
+}
+
+// peepholeOverwrittenLiteral removes MOVLW k when the next instruction is another MOVLW.
+func peepholeOverwrittenLiteral(ctx *optimizeContext, removed map[int]bool) {
+	for i := range ctx.expanded.Lines {
+		first, ok := ctx.code(i)
+		if !ok || removed[i] || strings.ToUpper(first.Opcode) != "MOVLW" || ctx.conditional(i) {
+			continue
+		}
+		j := ctx.next(i, false)
+		if j < 0 || removed[j] {
+			continue
+		}
+		if second, _ := ctx.code(j); strings.ToUpper(second.Opcode) == "MOVLW" {
+			removed[i] = true
+			ctx.record(i, []*Instruction{first}, nil, 1, "Removed MOVLW %s overwritten by MOVLW %s", strings.Join(first.Operands, ", "), strings.Join(second.Operands, ", "))
+		}
+	}
+}
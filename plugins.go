@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// --- Directive Plugins ---
+
+// DirectiveInvocation is one use of a custom directive, as passed to its handler.
+type DirectiveInvocation struct {
+	Name         string   `json:"name"`
+	OperandText  string   `json:"operandText"`
+	Operands     []string `json:"operands"`
+	Line         int      `json:"line"`
+	CoreWordBits int      `json:"coreWordBits"`
+}
+
+// directiveHandler expands a custom directive into source lines, which are parsed and
+// expanded like code written in place of the directive (they may use macros).
+type directiveHandler func(inv DirectiveInvocation) ([]string, error)
+
+// directivePlugins holds the directives registered from Go, keyed by upper-case name.
+var directivePlugins = make(map[string]directiveHandler)
+
+// registerDirective adds a custom directive available to every assembly.
+func registerDirective(name string, handler directiveHandler) {
+	name = strings.ToUpper(name)
+	directivePlugins[name] = handler
+	directiveKeywords[name] = true
+}
+
+// directive returns the handler for a custom directive: one given for this assembly
+// (e.g. an external plugin) or one registered from Go.
+func (p *ASMParser) directive(name string) (directiveHandler, bool) {
+	name = strings.ToUpper(name)
+	if handler, ok := p.plugins[name]; ok {
+		return handler, true
+	}
+	handler, ok := directivePlugins[name]
+	return handler, ok
+}
+
+// externalDirective runs command in dir for every use of a directive. The invocation is
+// written to the command's stdin as JSON and each line it prints becomes a source line.
+func externalDirective(command, dir string) directiveHandler {
+	return func(inv DirectiveInvocation) ([]string, error) {
+		fields := strings.Fields(command)
+		if len(fields) == 0 {
+			return nil, trErrorf("empty plugin command")
+		}
+		input, err := json.Marshal(inv)
+		if err != nil {
+			return nil, err
+		}
+		cmd := exec.Command(fields[0], fields[1:]...)
+		cmd.Dir = dir
+		cmd.Stdin = bytes.NewReader(input)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				return nil, trErrorf("plugin '%s' failed: %s", command, msg)
+			}
+			return nil, trErrorf("plugin '%s' failed: %w", command, err)
+		}
+		return strings.Split(strings.TrimRight(strings.ReplaceAll(string(out), "\r\n", "\n"), "\n"), "\n"), nil
+	}
+}
+
+// pluginFlag collects repeated -plugin NAME=command flags.
+type pluginFlag map[string]string
+
+func (f pluginFlag) String() string { return "" }
+
+func (f pluginFlag) Set(value string) error {
+	name, command, ok := strings.Cut(value, "=")
+	if !ok || strings.TrimSpace(name) == "" || strings.TrimSpace(command) == "" {
+		return fmt.Errorf("expected NAME=command, got '%s'", value)
+	}
+	f[strings.ToUpper(strings.TrimSpace(name))] = strings.TrimSpace(command)
+	return nil
+}
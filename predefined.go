@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// --- Predefined Symbols ---
+
+// asm4picVersion is the assembler version given by __ASM4PIC_VERSION__.
+const asm4picVersion = "1.0.0"
+
+// predefinedSymbol is a symbol provided by the assembler. Text replaces its name in string
+// directives; Value is its value in expressions when Numeric is set.
+type predefinedSymbol struct {
+	Text    string
+	Value   int
+	Numeric bool
+}
+
+// predefinedSymbols returns the build-time symbols: __DATE__ (2026-10-15, or 20261015 in
+// expressions), __TIME__ (14:30:05, or 143005), __MCU__ (text only) and __ASM4PIC_VERSION__
+// (1.2.3, or 10203).
+func predefinedSymbols(mcConfig *MicrocontrollerConfig, buildTime time.Time) map[string]predefinedSymbol {
+	var major, minor, patch int
+	fmt.Sscanf(asm4picVersion, "%d.%d.%d", &major, &minor, &patch)
+	date, _ := strconv.Atoi(buildTime.Format("20060102"))
+	clock, _ := strconv.Atoi(buildTime.Format("150405"))
+	symbols := map[string]predefinedSymbol{
+		"__DATE__":            {buildTime.Format("2006-01-02"), date, true},
+		"__TIME__":            {buildTime.Format("15:04:05"), clock, true},
+		"__ASM4PIC_VERSION__": {asm4picVersion, major*10000 + minor*100 + patch, true},
+	}
+	if mcConfig.Name != "" {
+		symbols["__MCU__"] = predefinedSymbol{Text: mcConfig.Name}
+	}
+	return symbols
+}
+
+// resolveBuildTime returns the time __DATE__ and __TIME__ describe: the pinned time if set,
+// else SOURCE_DATE_EPOCH (in UTC) for reproducible builds, else the current time.
+func resolveBuildTime(pinned time.Time) (time.Time, error) {
+	if !pinned.IsZero() {
+		return pinned, nil
+	}
+	if epoch := os.Getenv("SOURCE_DATE_EPOCH"); epoch != "" {
+		seconds, err := strconv.ParseInt(epoch, 10, 64)
+		if err != nil {
+			return time.Time{}, trErrorf("invalid SOURCE_DATE_EPOCH '%s'", epoch)
+		}
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+	return time.Now(), nil
+}
+
+// parseBuildTime parses the -build-time flag: an RFC 3339 time or Unix seconds.
+func parseBuildTime(text string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+	t, err := time.Parse(time.RFC3339, text)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid build time '%s': expected RFC 3339 (2026-01-02T15:04:05Z) or Unix seconds", text)
+	}
+	return t, nil
+}
+
+// expandPredefined replaces the names of predefined symbols in the text of a string directive.
+func expandPredefined(text string, symbols map[string]predefinedSymbol) string {
+	for name, symbol := range symbols {
+		text = strings.ReplaceAll(text, name, symbol.Text)
+	}
+	return text
+}
+
+// lookupPredefined resolves a numeric predefined symbol in an expression.
+func lookupPredefined(symbols map[string]predefinedSymbol) exprLookup {
+	return func(name string) (int, bool) {
+		symbol, ok := symbols[name]
+		return symbol.Value, ok && symbol.Numeric
+	}
+}
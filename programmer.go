@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// --- Programmer Backends ---
+
+// Programmer drives an external device programming tool.
+type Programmer interface {
+	// ReadBack reads the device memory into an Intel HEX file at outPath.
+	ReadBack(mcu, outPath string) error
+	// Program erases the device and writes the Intel HEX file at hexPath to it.
+	Program(mcu, hexPath string) error
+}
+
+// externalProgrammer invokes a vendor command-line tool such as pk2cmd or ipecmd.
+type externalProgrammer struct {
+	executable string
+	baseArgs   []string
+}
+
+// deviceArg returns the MCU name in the form the vendor tools expect (e.g. "16F687").
+func (p *externalProgrammer) deviceArg(mcu string) string {
+	return "-P" + strings.TrimPrefix(strings.ToUpper(mcu), "PIC")
+}
+
+// run executes the tool with the given arguments, forwarding its output to the console.
+func (p *externalProgrammer) run(args ...string) error {
+	cmd := exec.Command(p.executable, append(append([]string{}, p.baseArgs...), args...)...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("'%s' failed: %w", p.executable, err)
+	}
+	return nil
+}
+
+func (p *externalProgrammer) ReadBack(mcu, outPath string) error {
+	return p.run(p.deviceArg(mcu), "-GF"+outPath)
+}
+
+func (p *externalProgrammer) Program(mcu, hexPath string) error {
+	return p.run(p.deviceArg(mcu), "-F"+hexPath, "-M")
+}
+
+// programmerBackends maps user-facing programmer names to the tool invocation that drives them.
+var programmerBackends = map[string]externalProgrammer{
+	"pickit2": {executable: "pk2cmd"},
+	"pickit3": {executable: "ipecmd", baseArgs: []string{"-TPPK3"}},
+	"pickit4": {executable: "ipecmd", baseArgs: []string{"-TPPK4"}},
+	"icd3":    {executable: "ipecmd", baseArgs: []string{"-TPICD3"}},
+	"icd4":    {executable: "ipecmd", baseArgs: []string{"-TPICD4"}},
+}
+
+// newProgrammer returns the backend for the named programmer, optionally overriding the tool path.
+func newProgrammer(name, toolPath string) (Programmer, error) {
+	backend, ok := programmerBackends[strings.ToLower(name)]
+	if !ok {
+		names := make([]string, 0, len(programmerBackends))
+		for n := range programmerBackends {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown programmer '%s' (supported: %s)", name, strings.Join(names, ", "))
+	}
+	if toolPath != "" {
+		backend.executable = toolPath
+	}
+	return &backend, nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// --- Project File ---
+
+// defaultProjectFileName is the project file looked up when no path is given.
+const defaultProjectFileName = "asm4pic.json"
+
+// ProjectFile describes an asm4pic.json project. Paths are relative to the project file's directory.
+type ProjectFile struct {
+	Name      string `json:"name"`
+	MCU       string `json:"mcu"`
+	Source    string `json:"source"`
+	ConfigDir string `json:"configDir,omitempty"`
+	Hex       string `json:"hex,omitempty"`
+	Report    string `json:"report,omitempty"`
+
+	// Packages are include/macro packages whose directories join the include search path.
+	Packages []PackageRef `json:"packages,omitempty"`
+	// Plugins maps custom directive names to commands run from the project directory.
+	Plugins map[string]string `json:"plugins,omitempty"`
+	// Profiles are named variants of the build, such as debug and release, chosen with -profile.
+	Profiles map[string]ProjectProfile `json:"profiles,omitempty"`
+
+	// Dir is the directory holding the project file; it is not part of the JSON.
+	Dir string `json:"-"`
+}
+
+// ProjectProfile is a named variant of the build. Its settings apply on top of the project's,
+// and flags given on the command line override both.
+type ProjectProfile struct {
+	// Defines are #DEFINE symbols set before the source is read.
+	Defines map[string]string `json:"defines,omitempty"`
+	// Config are fuse settings applied over the __CONFIG lines, e.g. "_CP_ON".
+	Config        []string `json:"config,omitempty"`
+	Optimize      bool     `json:"optimize,omitempty"`
+	EnablePasses  []string `json:"enablePasses,omitempty"`
+	DisablePasses []string `json:"disablePasses,omitempty"`
+	Debug         bool     `json:"debug,omitempty"`
+	Hex           string   `json:"hex,omitempty"`
+	Report        string   `json:"report,omitempty"`
+	Listing       string   `json:"listing,omitempty"`
+	Map           string   `json:"map,omitempty"`
+}
+
+// Profile returns the named build profile.
+func (p *ProjectFile) Profile(name string) (*ProjectProfile, error) {
+	profile, ok := p.Profiles[name]
+	if !ok {
+		names := make([]string, 0, len(p.Profiles))
+		for name := range p.Profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("the project has no profile '%s' (profiles: %s)", name, strings.Join(names, ", "))
+	}
+	return &profile, nil
+}
+
+// loadProjectFile reads an asm4pic.json project and fills in defaults for optional fields.
+func loadProjectFile(path string) (*ProjectFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read project file '%s': %w", path, err)
+	}
+	var project ProjectFile
+	if err := json.Unmarshal(data, &project); err != nil {
+		return nil, fmt.Errorf("could not parse JSON from '%s': %w", path, err)
+	}
+	if project.MCU == "" || project.Source == "" {
+		return nil, fmt.Errorf("project file '%s' must set \"mcu\" and \"source\"", path)
+	}
+
+	project.Dir = filepath.Dir(path)
+	if project.Name == "" {
+		project.Name = strings.TrimSuffix(filepath.Base(project.Source), filepath.Ext(project.Source))
+	}
+	if project.ConfigDir == "" {
+		project.ConfigDir = "configs"
+	}
+	if project.Hex == "" {
+		project.Hex = strings.TrimSuffix(project.Source, filepath.Ext(project.Source)) + ".hex"
+	}
+	return &project, nil
+}
+
+// Path resolves a project-relative path against the project directory.
+func (p *ProjectFile) Path(rel string) string {
+	if rel == "" || filepath.IsAbs(rel) {
+		return rel
+	}
+	return filepath.Join(p.Dir, rel)
+}
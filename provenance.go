@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// --- Source Provenance ---
+
+// SourceLocation is a line of source text. File is empty for the main source file; Path is
+// where the file was found, empty also for the bundled libraries.
+type SourceLocation struct {
+	File string
+	Path string
+	Line int
+}
+
+func (l SourceLocation) String() string {
+	if l.File == "" {
+		return fmt.Sprintf("line %d", l.Line)
+	}
+	return fmt.Sprintf("%s:%d", l.File, l.Line)
+}
+
+// MacroFrame is one macro, pseudo-op, built-in or define invocation an item was expanded from.
+type MacroFrame struct {
+	Name string
+	Call SourceLocation
+}
+
+// Provenance records where an expanded item came from: the line holding its text (a macro
+// body line for expanded items) and the chain of invocations that produced it, outermost first.
+type Provenance struct {
+	Location SourceLocation
+	Chain    []MacroFrame
+}
+
+// expandedFrom returns the provenance of an item produced by expanding name at this item,
+// whose text is at location.
+func (p *Provenance) expandedFrom(name string, location SourceLocation) *Provenance {
+	chain := append(append([]MacroFrame(nil), p.Chain...), MacroFrame{Name: name, Call: p.Location})
+	return &Provenance{Location: location, Chain: chain}
+}
+
+// insertionModes name the options that insert code of their own, such as auto-banksel.
+var insertionModes = make(map[string]bool)
+
+// insertedBy returns the option that inserted an item, or "" for code from the source.
+func (p *Provenance) insertedBy() string {
+	if p == nil || len(p.Chain) == 0 || !insertionModes[p.Chain[len(p.Chain)-1].Name] {
+		return ""
+	}
+	return p.Chain[len(p.Chain)-1].Name
+}
+
+// insertedOrigin returns the provenance of code the named option inserts before expanded item i.
+func insertedOrigin(expanded *ExpandedParsedAssembly, i int, option string) *Provenance {
+	origin := &Provenance{Location: SourceLocation{Line: expanded.SourceLines[i]}}
+	if i < len(expanded.Origins) && expanded.Origins[i] != nil {
+		origin = expanded.Origins[i]
+	}
+	return origin.expandedFrom(option, origin.Location)
+}
+
+// includeLocation returns the innermost include file line an item came from: the line
+// holding its text, or else the nearest invocation written in an include file.
+func (p *Provenance) includeLocation() (SourceLocation, bool) {
+	if p == nil {
+		return SourceLocation{}, false
+	}
+	if p.Location.File != "" {
+		return p.Location, true
+	}
+	for i := len(p.Chain) - 1; i >= 0; i-- {
+		if p.Chain[i].Call.File != "" {
+			return p.Chain[i].Call, true
+		}
+	}
+	return SourceLocation{}, false
+}
+
+// String describes the provenance of an item that did not come straight from the main
+// source, innermost first, e.g. "at std16.inc:12, in ADD16 called at line 40".
+// It is empty for plain lines of the main source.
+func (p *Provenance) String() string {
+	if p == nil || (len(p.Chain) == 0 && p.Location.File == "") {
+		return ""
+	}
+	parts := []string{"at " + p.Location.String()}
+	for i := len(p.Chain) - 1; i >= 0; i-- {
+		parts = append(parts, fmt.Sprintf("in %s called at %s", p.Chain[i].Name, p.Chain[i].Call))
+	}
+	return strings.Join(parts, ", ")
+}
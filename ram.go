@@ -0,0 +1,440 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// --- Data Memory ---
+
+// RAMRange is an inclusive range of data memory addresses.
+type RAMRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// Contains reports whether addr lies in the range.
+func (r RAMRange) Contains(addr int) bool {
+	return addr >= r.Start && addr <= r.End
+}
+
+// RAMLayout describes the banks of data memory: the general purpose register ranges and
+// the common RAM that is reachable from every bank, given by its bank 0 addresses. On PIC18
+// devices Access lists the access bank ranges, reached without the BSR.
+type RAMLayout struct {
+	BankSize int        `json:"bank_size"`
+	GPR      []RAMRange `json:"gpr"`
+	Shared   *RAMRange  `json:"shared,omitempty"`
+	Access   []RAMRange `json:"access,omitempty"`
+}
+
+// isSharedRAM reports whether a file register address is in common RAM, so it can be
+// accessed whatever bank is selected.
+func isSharedRAM(mcConfig *MicrocontrollerConfig, addr int) bool {
+	layout := mcConfig.RAMLayout
+	if layout == nil || layout.Shared == nil || layout.BankSize <= 0 {
+		return false
+	}
+	return layout.Shared.Contains(addr % layout.BankSize)
+}
+
+// DataSection starts a data section whose RES directives allocate registers. UDATA_SHR
+// allocates from common RAM; UDATA_OVR allocates from banked general purpose RAM, and all
+// UDATA_OVR sections with the same name share (overlay) the same addresses. Address
+// optionally fixes where the section starts.
+type DataSection struct {
+	Name    string // section name; required for UDATA_OVR
+	Kind    string // upper-case directive, e.g. UDATA_SHR
+	Address string
+	Comment string
+}
+
+func (d *DataSection) isAssemblyItem() {}
+
+// ReserveDirective reserves Count registers in the current data section and defines Symbol
+// as the address of the first one.
+type ReserveDirective struct {
+	Symbol  string
+	Count   string
+	Comment string
+}
+
+func (r *ReserveDirective) isAssemblyItem() {}
+
+var (
+	dataSectionRegex = regexp.MustCompile(`(?i)^(?:([A-Z_0-9.]+)\s+)?(UDATA_SHR|UDATA_OVR)(?:\s+(.+))?$`)
+	reserveRegex     = regexp.MustCompile(`(?i)^([A-Z_0-9]+)\s+RES\s+(.+)$`)
+)
+
+func init() {
+	directiveKeywords["UDATA_SHR"] = true
+	directiveKeywords["UDATA_OVR"] = true
+	directiveKeywords["RES"] = true
+	namingDirectiveKeywords["RES"] = true
+	namingDirectiveKeywords["UDATA_SHR"] = true
+	namingDirectiveKeywords["UDATA_OVR"] = true
+}
+
+// parseDataDirective parses UDATA_SHR, UDATA_OVR and RES lines; it returns nil for anything else.
+func parseDataDirective(lineContent, commentText string) AssemblyItem {
+	if match := dataSectionRegex.FindStringSubmatch(lineContent); match != nil {
+		return &DataSection{Name: match[1], Kind: strings.ToUpper(match[2]), Address: strings.TrimSpace(match[3]), Comment: commentText}
+	}
+	if match := reserveRegex.FindStringSubmatch(lineContent); match != nil {
+		return &ReserveDirective{Symbol: match[1], Count: strings.TrimSpace(match[2]), Comment: commentText}
+	}
+	return nil
+}
+
+// dataSectionLayout is where one data section was placed.
+type dataSectionLayout struct {
+	Name    string
+	Kind    string
+	Line    int
+	Start   int
+	Next    int // one past the last reserved register
+	Limit   int // last register the section may use
+	Symbols []string
+}
+
+// dataAllocator hands out data memory addresses to RES directives during a layout pass.
+type dataAllocator struct {
+	sections []*dataSectionLayout // in source order
+	current  *dataSectionLayout
+	overlays map[string]*dataSectionLayout // overlay name -> its first section
+	banked   int                           // next banked address free for an overlay
+}
+
+// overlaySizes returns the largest size of each overlay across its sections.
+func (d *dataAllocator) overlaySizes() map[string]int {
+	sizes := make(map[string]int)
+	for _, section := range d.sections {
+		if section.Kind == "UDATA_OVR" {
+			sizes[section.Name] = max(sizes[section.Name], section.Next-section.Start)
+		}
+	}
+	return sizes
+}
+
+// bankedRanges returns the general purpose RAM ranges without common RAM.
+func bankedRanges(layout *RAMLayout) []RAMRange {
+	var ranges []RAMRange
+	for _, r := range layout.GPR {
+		if layout.Shared != nil && r.Contains(layout.Shared.Start) {
+			r.End = layout.Shared.Start - 1
+		}
+		if r.End >= r.Start {
+			ranges = append(ranges, r)
+		}
+	}
+	return ranges
+}
+
+// openDataSection starts allocating from the range of a data section. Overlays are placed
+// one after the other in banked RAM using their sizes from the previous layout pass.
+func (a *PicAssembler) openDataSection(i int, v *DataSection) *AssemblerError {
+	layout := a.mcConfig.RAMLayout
+	section := &dataSectionLayout{Name: v.Name, Kind: v.Kind, Line: a.sourceLine(i)}
+	fixed := -1
+	if v.Address != "" {
+		addr, err := a.evaluateExpression(v.Address)
+		if err != nil {
+			return a.errorAt(i, errorCode(err, CodeInvalidExpression), "Invalid %s address - %v", v.Kind, err)
+		}
+		fixed = addr
+	}
+
+	switch v.Kind {
+	case "UDATA_SHR":
+		if layout == nil || layout.Shared == nil {
+			return a.errorAt(i, CodeNoSharedRAM, "%s needs common RAM, but the device config has no RAM_LAYOUT shared range.", v.Kind)
+		}
+		section.Start, section.Limit = layout.Shared.Start, layout.Shared.End
+		for _, previous := range a.data.sections {
+			if previous.Kind == v.Kind {
+				section.Start = previous.Next
+			}
+		}
+		if fixed >= 0 {
+			if !layout.Shared.Contains(fixed) {
+				return a.errorAt(i, CodeRAMOverflow, "%s address 0x%X is outside common RAM 0x%X-0x%X.", v.Kind, fixed, layout.Shared.Start, layout.Shared.End)
+			}
+			section.Start = fixed
+		}
+
+	case "UDATA_OVR":
+		if v.Name == "" {
+			return a.errorAt(i, CodeOverlayName, "UDATA_OVR needs a section name, e.g. 'TEMPS UDATA_OVR'.")
+		}
+		if layout == nil || len(layout.GPR) == 0 {
+			return a.errorAt(i, CodeNoSharedRAM, "%s needs general purpose RAM, but the device config has no RAM_LAYOUT gpr ranges.", v.Kind)
+		}
+		if first, ok := a.data.overlays[v.Name]; ok {
+			section.Start, section.Limit = first.Start, first.Limit
+			break
+		}
+		size := a.previousOverlaySizes[v.Name]
+		placed := false
+		for _, r := range bankedRanges(layout) {
+			start := max(r.Start, a.data.banked)
+			if fixed >= 0 {
+				start = fixed
+			}
+			if r.Contains(start) && start+size-1 <= r.End {
+				section.Start, section.Limit, placed = start, r.End, true
+				a.data.banked = max(a.data.banked, start+size)
+				break
+			}
+		}
+		if !placed {
+			return a.errorAt(i, CodeRAMOverflow, "No room for the %d byte(s) of overlay '%s' in general purpose RAM.", size, v.Name)
+		}
+		if a.data.overlays == nil {
+			a.data.overlays = make(map[string]*dataSectionLayout)
+		}
+		a.data.overlays[v.Name] = section
+	}
+	section.Next = section.Start
+	a.data.sections = append(a.data.sections, section)
+	a.data.current = section
+	return nil
+}
+
+// reserve allocates the registers of a RES directive and returns the first address.
+func (a *PicAssembler) reserve(i int, v *ReserveDirective) (int, *AssemblerError) {
+	section := a.data.current
+	if section == nil {
+		return 0, a.errorAt(i, CodeResOutsideSection, "RES '%s' is not inside a data section (UDATA_SHR or UDATA_OVR).", v.Symbol)
+	}
+	count, err := a.evaluateExpression(v.Count)
+	if err != nil {
+		return 0, a.errorAt(i, errorCode(err, CodeInvalidExpression), "Invalid RES count - %v", err)
+	}
+	addr := section.Next
+	if count < 0 || addr+count-1 > section.Limit {
+		return 0, a.errorAt(i, CodeRAMOverflow, "RES '%s' (%d byte(s) at 0x%X) overflows %s RAM ending at 0x%X.", v.Symbol, count, addr, section.Kind, section.Limit)
+	}
+	section.Next += count
+	section.Symbols = append(section.Symbols, v.Symbol)
+	return addr, nil
+}
+
+// checkOverlays verifies that overlays sharing addresses are never live at the same time:
+// no routine that uses the variables of one section of an overlay may call code that uses
+// another section of the same overlay.
+func (a *PicAssembler) checkOverlays() error {
+	owner := make(map[string]*dataSectionLayout) // RES symbol -> its overlay section
+	shared := false
+	for _, section := range a.data.sections {
+		if section.Kind != "UDATA_OVR" {
+			continue
+		}
+		if a.data.overlays[section.Name] != section {
+			shared = true
+		}
+		for _, symbol := range section.Symbols {
+			owner[symbol] = section
+		}
+	}
+	if !shared {
+		return nil
+	}
+
+	uses := make(map[int][]*dataSectionLayout) // instruction index -> overlay sections it uses
+	for i, item := range a.parsedAssembly.Lines {
+		inst, ok := item.(*Instruction)
+		if !ok {
+			continue
+		}
+		for _, operand := range inst.Operands {
+			for _, name := range strings.FieldsFunc(operand, func(r rune) bool { return r > 0x7F || !isIdentifierChar(byte(r)) }) {
+				if section, ok := owner[name]; ok {
+					uses[i] = append(uses[i], section)
+				}
+			}
+		}
+	}
+
+	resolve := func(operand string) (int, bool) {
+		val, err := a.evaluateExpression(operand)
+		return val, err == nil
+	}
+	g := buildFlowGraph(a.parsedAssembly, a.mcConfig, resolve)
+	usedBy := func(code map[int]bool) []*dataSectionLayout {
+		var sections []*dataSectionLayout
+		for i := range code {
+			sections = append(sections, uses[i]...)
+		}
+		return sections
+	}
+	entries := g.roots()
+	for i := range g.address {
+		if flowOpcode(a.parsedAssembly, a.mcConfig, i) == "CALL" {
+			for _, next := range g.successors[i] {
+				if g.address[next] != g.address[i]+1 {
+					entries = append(entries, next)
+				}
+			}
+		}
+	}
+	sort.Ints(entries)
+	for _, entry := range entries {
+		body := g.reachableWithoutCalls([]int{entry})
+		live := usedBy(body)
+		calls := make([]int, 0, len(body))
+		for i := range body {
+			calls = append(calls, i)
+		}
+		sort.Ints(calls)
+		for _, call := range calls {
+			if flowOpcode(a.parsedAssembly, a.mcConfig, call) != "CALL" {
+				continue
+			}
+			for _, target := range g.successors[call] {
+				if g.address[target] == g.address[call]+1 {
+					continue
+				}
+				for _, callee := range usedBy(g.reachable([]int{target})) {
+					for _, mine := range live {
+						if mine.Name == callee.Name && mine != callee {
+							return a.errorAt(call, CodeOverlayCollision, "%s calls code using overlay '%s' (section at line %d) while this routine's variables from its section at line %d are live; they share the same addresses.",
+								instructionText(a.parsedAssembly.Lines[call].(*Instruction)), mine.Name, callee.Line, mine.Line)
+						}
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// GenerateMap describes the final memory layout: the program memory regions and every data
+// section with the registers reserved in it. Sections of the same overlay share addresses.
+func (a *PicAssembler) GenerateMap() string {
+	var out strings.Builder
+	out.WriteString(tr("Program Memory\n\n"))
+	empty := true
+	for _, region := range a.regions {
+		if region.End <= region.Start {
+			continue
+		}
+		empty = false
+		out.WriteString(tr("  0x%04X-0x%04X  %5d word(s)  %s\n", region.Start, region.End-1, region.End-region.Start, regionOrigin(region)))
+	}
+	if empty {
+		out.WriteString(tr("  No code.\n"))
+	}
+	if version := a.versionText(); version != "" {
+		out.WriteString("\n  Version string " + version + "\n")
+	}
+
+	out.WriteString(tr("\nData Memory\n\n"))
+	if len(a.data.sections) == 0 {
+		out.WriteString(tr("  No data sections.\n"))
+	}
+	for _, section := range a.data.sections {
+		name := section.Name
+		if name == "" {
+			name = "(unnamed)"
+		}
+		span := "empty"
+		if section.Next > section.Start {
+			span = fmt.Sprintf("0x%04X-0x%04X", section.Start, section.Next-1)
+		}
+		note := ""
+		if first := a.data.overlays[section.Name]; section.Kind == "UDATA_OVR" && first != section {
+			note = tr(", overlays line %d", first.Line)
+		}
+		out.WriteString(tr("  %-16s %-10s %-13s  %3d byte(s)  line %d%s\n", name, section.Kind, span, section.Next-section.Start, section.Line, note))
+		for _, symbol := range section.Symbols {
+			out.WriteString(fmt.Sprintf("    %-20s 0x%04X\n", symbol, a.symbolTable[symbol]))
+		}
+	}
+	return out.String()
+}
+
+// memoryUsage renders the Memory Usage section of the report: the program words used and,
+// per general purpose RAM range, the registers taken by data sections and EQU variables and
+// those still free, followed by which variable holds each used register.
+func (a *PicAssembler) memoryUsage() string {
+	var out strings.Builder
+	words := a.programWordsUsed()
+	out.WriteString(tr("  Program memory: %d of %d words used, %d free\n", words, a.mcConfig.ProgramMemorySize, a.mcConfig.ProgramMemorySize-words))
+
+	layout := a.mcConfig.RAMLayout
+	if layout == nil || len(layout.GPR) == 0 {
+		out.WriteString(tr("  Data memory: no RAM_LAYOUT in the device config.\n"))
+		return out.String()
+	}
+
+	owners := a.ramOwners()
+	out.WriteString(tr("  Data memory (general purpose registers):\n"))
+	var used []int
+	for _, r := range layout.GPR {
+		count := 0
+		for addr := r.Start; addr <= r.End; addr++ {
+			if len(owners[addr]) > 0 {
+				count++
+				used = append(used, addr)
+			}
+		}
+		size := r.End - r.Start + 1
+		out.WriteString(tr("    Bank %-2d 0x%04X-0x%04X  %4d byte(s)  %4d used  %4d free\n", ramBank(a.mcConfig, r.Start), r.Start, r.End, size, count, size-count))
+	}
+	sort.Ints(used)
+	for _, addr := range used {
+		names := owners[addr]
+		sort.Strings(names)
+		out.WriteString(fmt.Sprintf("    0x%04X  %s\n", addr, strings.Join(names, ", ")))
+	}
+	return out.String()
+}
+
+// programWordsUsed counts the program memory words the assembly writes.
+func (a *PicAssembler) programWordsUsed() int {
+	words := 0
+	for addr := range a.machineCodeWords {
+		if addr < a.mcConfig.ProgramMemorySize {
+			words++
+		}
+	}
+	return words
+}
+
+// ramOwners maps each data memory register the assembly allocates, through data sections or
+// EQU variables, to descriptions of the variables using it. Mirrors of common RAM are
+// counted at their bank 0 address.
+func (a *PicAssembler) ramOwners() map[int][]string {
+	owners := make(map[int][]string)
+	for _, section := range a.data.sections {
+		symbols := append([]string(nil), section.Symbols...)
+		sort.SliceStable(symbols, func(i, j int) bool { return a.symbolTable[symbols[i]] < a.symbolTable[symbols[j]] })
+		for k, symbol := range symbols {
+			end := section.Next
+			if k+1 < len(symbols) {
+				end = a.symbolTable[symbols[k+1]]
+			}
+			for addr := a.symbolTable[symbol]; addr < end; addr++ {
+				owners[addr] = append(owners[addr], tr("%s (%s, line %d)", symbol, section.Kind, section.Line))
+			}
+		}
+	}
+	for name := range a.equVariables() {
+		addr := a.symbolTable[name]
+		if isSharedRAM(a.mcConfig, addr) {
+			addr %= a.mcConfig.RAMLayout.BankSize
+		}
+		owners[addr] = append(owners[addr], tr("%s (EQU, line %d)", name, a.equLines[name]))
+	}
+	return owners
+}
+
+// regionOrigin names where a program memory region was placed from.
+func regionOrigin(r orgRegion) string {
+	if r.Line == 0 {
+		return "before the first ORG"
+	}
+	return tr("%s at line %d", r.origin(), r.Line)
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// --- Machine-Readable Result ---
+
+// assemblyResult is the complete assembly result written by -result-json, for tools that
+// would otherwise parse the report or the HEX file. Addresses are word addresses, as in ORG.
+type assemblyResult struct {
+	MCU         string              `json:"mcu"`
+	Program     []resultWord        `json:"program"`
+	Config      []resultConfigWord  `json:"config"`
+	IDLocations []resultWord        `json:"id_locations"`
+	EEPROM      []resultWord        `json:"eeprom"`
+	Symbols     []resultSymbol      `json:"symbols"`
+	Regions     []resultRegion      `json:"regions"`
+	Sections    []resultDataSection `json:"data_sections"`
+	Stats       resultStats         `json:"stats"`
+	Warnings    []Diagnostic        `json:"warnings"`
+}
+
+type resultWord struct {
+	Address int `json:"address"`
+	Value   int `json:"value"`
+}
+
+type resultConfigWord struct {
+	Name     string              `json:"name"`
+	Address  int                 `json:"address"`
+	Value    int                 `json:"value"`
+	Settings []resultFuseSetting `json:"settings"`
+}
+
+type resultFuseSetting struct {
+	Group  string `json:"group"`
+	Option string `json:"option"` // "" when no option matches the bits
+	Value  int    `json:"value"`
+}
+
+type resultSymbol struct {
+	Name  string `json:"name"`
+	Kind  string `json:"kind"`
+	Value int    `json:"value"`
+	Line  int    `json:"line,omitempty"`
+}
+
+// resultRegion is a program memory region; End is the last word written.
+type resultRegion struct {
+	Start  int    `json:"start"`
+	End    int    `json:"end"`
+	Words  int    `json:"words"`
+	Origin string `json:"origin"`
+}
+
+type resultDataSection struct {
+	Name    string         `json:"name"`
+	Kind    string         `json:"kind"`
+	Line    int            `json:"line"`
+	Start   int            `json:"start"`
+	Size    int            `json:"size"`
+	Symbols []resultSymbol `json:"symbols"`
+}
+
+type resultStats struct {
+	ProgramWordsUsed int          `json:"program_words_used"`
+	ProgramWordsFree int          `json:"program_words_free"`
+	Banks            []resultBank `json:"ram_banks"`
+	Checksum         int          `json:"checksum"`
+	IDChecksum       *int         `json:"id_checksum,omitempty"`
+	Optimizations    int          `json:"optimizations"`
+	Warnings         int          `json:"warnings"`
+}
+
+type resultBank struct {
+	Bank  int `json:"bank"`
+	Start int `json:"start"`
+	End   int `json:"end"`
+	Used  int `json:"used"`
+	Free  int `json:"free"`
+}
+
+// resultConfigWords returns the config words with the fuse settings their values select.
+func (a *PicAssembler) resultConfigWords() []resultConfigWord {
+	words := []resultConfigWord{}
+	for _, name := range sortedConfigWordNames(a.configWords) {
+		word := resultConfigWord{Name: name, Address: a.mcConfig.ConfigWordDefaults[name].Address, Value: a.configWords[name], Settings: []resultFuseSetting{}}
+		for _, setting := range decodeConfigWord(a.mcConfig, name, a.configWords[name]) {
+			word.Settings = append(word.Settings, resultFuseSetting{setting.Group, setting.Option, setting.Value})
+		}
+		words = append(words, word)
+	}
+	return words
+}
+
+// GenerateResultJSON renders the assembly result as indented JSON. Warnings are the
+// diagnostics reported while assembling.
+func (a *PicAssembler) GenerateResultJSON(warnings []Diagnostic) (string, error) {
+	result := assemblyResult{
+		MCU:         a.mcConfig.Name,
+		Program:     []resultWord{},
+		IDLocations: []resultWord{},
+		EEPROM:      []resultWord{},
+		Symbols:     []resultSymbol{},
+		Regions:     []resultRegion{},
+		Sections:    []resultDataSection{},
+		Warnings:    warnings,
+	}
+	if result.Warnings == nil {
+		result.Warnings = []Diagnostic{}
+	}
+
+	addresses := make([]int, 0, len(a.machineCodeWords))
+	for addr := range a.machineCodeWords {
+		addresses = append(addresses, addr)
+	}
+	sort.Ints(addresses)
+	for _, addr := range addresses {
+		word := resultWord{addr, a.machineCodeWords[addr]}
+		switch {
+		case addr < a.mcConfig.ProgramMemorySize:
+			result.Program = append(result.Program, word)
+		case a.mcConfig.inIDLocations(addr):
+			result.IDLocations = append(result.IDLocations, word)
+		case a.mcConfig.inEEPROM(addr):
+			result.EEPROM = append(result.EEPROM, word)
+		}
+	}
+
+	result.Config = a.resultConfigWords()
+
+	for _, symbol := range a.reportSymbols(SymbolOrderName, true) {
+		result.Symbols = append(result.Symbols, resultSymbol{symbol.Name, symbol.Kind, symbol.Value, symbol.Line})
+	}
+
+	for _, region := range a.regions {
+		if region.End > region.Start {
+			result.Regions = append(result.Regions, resultRegion{region.Start, region.End - 1, region.End - region.Start, regionOrigin(region)})
+		}
+	}
+	for _, section := range a.data.sections {
+		s := resultDataSection{Name: section.Name, Kind: section.Kind, Line: section.Line, Start: section.Start, Size: section.Next - section.Start, Symbols: []resultSymbol{}}
+		for _, symbol := range section.Symbols {
+			s.Symbols = append(s.Symbols, resultSymbol{Name: symbol, Kind: SymbolKindRes, Value: a.symbolTable[symbol]})
+		}
+		result.Sections = append(result.Sections, s)
+	}
+
+	stats := &result.Stats
+	stats.ProgramWordsUsed = a.programWordsUsed()
+	stats.ProgramWordsFree = a.mcConfig.ProgramMemorySize - stats.ProgramWordsUsed
+	stats.Banks = []resultBank{}
+	if layout := a.mcConfig.RAMLayout; layout != nil {
+		owners := a.ramOwners()
+		for _, r := range layout.GPR {
+			bank := resultBank{Bank: ramBank(a.mcConfig, r.Start), Start: r.Start, End: r.End}
+			for addr := r.Start; addr <= r.End; addr++ {
+				if len(owners[addr]) > 0 {
+					bank.Used++
+				}
+			}
+			bank.Free = r.End - r.Start + 1 - bank.Used
+			stats.Banks = append(stats.Banks, bank)
+		}
+	}
+	stats.Checksum = a.programChecksum()
+	if a.idChecksum >= 0 {
+		stats.IDChecksum = &a.idChecksum
+	}
+	stats.Optimizations = len(a.optimizations)
+	for _, w := range warnings {
+		if w.Severity == SeverityWarning {
+			stats.Warnings++
+		}
+	}
+
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
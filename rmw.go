@@ -0,0 +1,58 @@
+package main
+
+import "strings"
+
+// --- Read-Modify-Write Lint ---
+
+func init() {
+	registerLintRule(lintRule{
+		Name:            "read-modify-write",
+		Description:     "Back-to-back BSF/BCF on the same PORT register on a device without LAT registers",
+		DefaultSeverity: SeverityWarning,
+		Check:           lintReadModifyWrite,
+	})
+}
+
+// lintReadModifyWrite reports a BSF or BCF on a PORT register that directly follows another
+// one on the same register. BSF/BCF read the pins rather than the output latch, so when the
+// pin driven by the first has not settled yet (e.g. under capacitive load) the second writes
+// its old level back. Devices with LAT registers avoid this by writing LATx instead.
+func lintReadModifyWrite(ctx *lintContext) []lintFinding {
+	ports := make(map[int]string)
+	for name, addr := range ctx.mcConfig.SFRMap {
+		if strings.HasPrefix(name, "LAT") {
+			return nil
+		}
+		if strings.HasPrefix(name, "PORT") {
+			ports[addr] = name
+		}
+	}
+
+	var findings []lintFinding
+	previous, previousText := -1, ""
+	for i, item := range ctx.expanded.Lines {
+		switch item.(type) {
+		case *Label, *OrgDirective:
+			previous = -1 // control may arrive here from elsewhere
+			continue
+		}
+		inst, info, ok := ctx.instructionAt(i)
+		if !ok {
+			continue
+		}
+		opcode := strings.ToUpper(inst.Opcode)
+		_, addr, hasAddr := ctx.fileRegisterAddress(inst, info)
+		if port, isPort := ports[addr]; hasAddr && isPort && (opcode == "BSF" || opcode == "BCF") {
+			if previous == addr {
+				findings = append(findings, lintFinding{
+					Line:    ctx.expanded.SourceLines[i],
+					Message: tr("%s right after %s may write back a stale pin level of %s (read-modify-write); update a shadow register and copy it to %s with MOVF/MOVWF.", instructionText(inst), previousText, port, port),
+				})
+			}
+			previous, previousText = addr, instructionText(inst)
+			continue
+		}
+		previous = -1
+	}
+	return findings
+}
@@ -0,0 +1,389 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// --- Device Config Self-Test ---
+
+func init() {
+	registerSubcommand("selftest", "Check device configs by assembling and disassembling every instruction and fuse option", runSelfTest)
+}
+
+// selfTestLine is one instruction of a self-test program and the operand fields its word
+// should decode to.
+type selfTestLine struct {
+	Text   string
+	Opcode string
+	Fields map[string]int
+}
+
+// selfTestField returns the value a self-test program gives an operand field of the given
+// width: alternating bits, so that a field placed one bit off does not decode back to it.
+func selfTestField(bits int) int {
+	return 0x5555 & (1<<bits - 1)
+}
+
+// selfTestInstructions returns every instruction of the device with operands filling each
+// of its fields, once per destination and access bank choice.
+func selfTestInstructions(mcConfig *MicrocontrollerConfig) []selfTestLine {
+	var lines []selfTestLine
+	for _, name := range sortedInstructionNames(mcConfig) {
+		info := mcConfig.InstructionSet[name]
+		forms := []selfTestLine{{Opcode: name, Fields: map[string]int{}}}
+		for _, kind := range info.Operands {
+			var choices []string
+			var values []int
+			switch kind {
+			case "d":
+				choices, values = []string{"W", "F"}, []int{0, 1}
+			case "a":
+				choices, values = []string{"ACCESS", "BANKED"}, []int{0, 1}
+			default:
+				value := selfTestField(strings.Count(info.OpcodePattern, string(operandPlaceholders[kind].letter)))
+				if kind == "k11" && mcConfig.ProgramMemorySize > 0 {
+					value %= mcConfig.ProgramMemorySize // a jump target in program memory
+				}
+				choices, values = []string{fmt.Sprintf("0x%02X", value)}, []int{value}
+			}
+			var next []selfTestLine
+			for _, form := range forms {
+				for k, choice := range choices {
+					fields := map[string]int{kind: values[k]}
+					for field, value := range form.Fields {
+						fields[field] = value
+					}
+					text := choice
+					if form.Text != "" {
+						text = form.Text + ", " + choice
+					}
+					next = append(next, selfTestLine{Text: text, Opcode: name, Fields: fields})
+				}
+			}
+			forms = next
+		}
+		for _, form := range forms {
+			form.Text = strings.TrimRight("        "+name+" "+form.Text, " ")
+			lines = append(lines, form)
+		}
+	}
+	return lines
+}
+
+// selfTestFuses returns, for each fuse map, the option a self-test round selects in each fuse
+// group: the round'th option in name order, starting over in groups with fewer.
+func selfTestFuses(mcConfig *MicrocontrollerConfig, round int) []map[string]string {
+	words := make([]map[string]string, len(mcConfig.AllConfigFuseMaps))
+	for i, fuseMap := range mcConfig.AllConfigFuseMaps {
+		options := make(map[string]string)
+		for group, info := range fuseMap {
+			names := make([]string, 0, len(info.Values))
+			for option := range info.Values {
+				names = append(names, option)
+			}
+			sort.Strings(names)
+			if len(names) > 0 {
+				options[group] = names[round%len(names)]
+			}
+		}
+		words[i] = options
+	}
+	return words
+}
+
+// selfTestRounds returns the number of self-test programs needed to select every fuse option.
+func selfTestRounds(mcConfig *MicrocontrollerConfig) int {
+	rounds := 1
+	for _, fuseMap := range mcConfig.AllConfigFuseMaps {
+		for _, info := range fuseMap {
+			rounds = max(rounds, len(info.Values))
+		}
+	}
+	return rounds
+}
+
+// checkDeviceConfig returns the problems of a device config that show without assembling:
+// instructions that cannot be encoded and fuse groups whose bits do not fit their word.
+func checkDeviceConfig(mcConfig *MicrocontrollerConfig) []string {
+	var problems []string
+	if mcConfig.ProgramWordSizeBits <= 0 || mcConfig.ProgramMemorySize <= 0 {
+		problems = append(problems, "PROGRAM_WORD_SIZE_BITS and PROGRAM_MEMORY_SIZE must be set")
+	}
+	if len(mcConfig.InstructionSet) == 0 {
+		problems = append(problems, "INSTRUCTION_SET is empty")
+	}
+	names := sortedInstructionNames(mcConfig)
+	for k, name := range names {
+		if err := validateInstruction(name, mcConfig.InstructionSet[name], mcConfig.ProgramWordSizeBits); err != nil {
+			problems = append(problems, err.Error())
+		}
+		for _, other := range names[k+1:] {
+			if patternsOverlap(mcConfig.InstructionSet[name].OpcodePattern, mcConfig.InstructionSet[other].OpcodePattern) {
+				problems = append(problems, fmt.Sprintf("instructions '%s' and '%s' have opcode patterns some words match both of", name, other))
+			}
+		}
+	}
+	for i, fuseMap := range mcConfig.AllConfigFuseMaps {
+		word := fmt.Sprintf("CONFIG%d", i+1)
+		if _, ok := mcConfig.ConfigWordDefaults[word]; !ok {
+			problems = append(problems, fmt.Sprintf("fuse map %d has no %s entry in CONFIG_WORD_DEFAULTS", i+1, word))
+		}
+		groups := make([]string, 0, len(fuseMap))
+		for group := range fuseMap {
+			groups = append(groups, group)
+		}
+		sort.Strings(groups)
+		used := 0
+		for _, group := range groups {
+			info := fuseMap[group]
+			switch {
+			case info.Mask == 0:
+				problems = append(problems, fmt.Sprintf("%s %s has no mask", word, group))
+			case info.Mask>>mcConfig.ProgramWordSizeBits != 0:
+				problems = append(problems, fmt.Sprintf("%s %s mask 0x%X is wider than a %d-bit word", word, group, info.Mask, mcConfig.ProgramWordSizeBits))
+			case info.Mask&used != 0:
+				problems = append(problems, fmt.Sprintf("%s %s mask 0x%X overlaps another group", word, group, info.Mask))
+			}
+			used |= info.Mask
+			if len(info.Values) == 0 {
+				problems = append(problems, fmt.Sprintf("%s %s has no options", word, group))
+			}
+			options := make([]string, 0, len(info.Values))
+			for option := range info.Values {
+				options = append(options, option)
+			}
+			sort.Strings(options)
+			selects := make(map[int]string)
+			for _, option := range options {
+				value := info.Values[option]
+				if value&^info.Mask != 0 {
+					problems = append(problems, fmt.Sprintf("%s %s option %s = 0x%X sets bits outside the mask 0x%X", word, group, option, value, info.Mask))
+				}
+				if other, ok := selects[value&info.Mask]; ok {
+					problems = append(problems, fmt.Sprintf("%s %s options %s and %s both select 0x%X", word, group, other, option, value&info.Mask))
+				}
+				selects[value&info.Mask] = option
+			}
+		}
+	}
+	return problems
+}
+
+// patternsOverlap reports whether a program word can match two opcode patterns, which would
+// leave the disassembler reading some words of one as the other. Don't care bits are written
+// as 0, so they only match 0.
+func patternsOverlap(a, b string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	fixed := func(c byte) (byte, bool) {
+		switch c {
+		case '0', 'x':
+			return '0', true
+		case '1':
+			return '1', true
+		}
+		return 0, false
+	}
+	for k := 0; k < len(a); k++ {
+		bitA, okA := fixed(a[k])
+		bitB, okB := fixed(b[k])
+		if okA && okB && bitA != bitB {
+			return false
+		}
+	}
+	return true
+}
+
+// sortedInstructionNames returns the instruction names of a device, in order.
+func sortedInstructionNames(mcConfig *MicrocontrollerConfig) []string {
+	names := make([]string, 0, len(mcConfig.InstructionSet))
+	for name := range mcConfig.InstructionSet {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// selfTestRound assembles the round'th self-test program and returns its problems: words that
+// do not decode back to the instruction and operands written, config words that do not
+// decode to the options selected, and a disassembly that does not reassemble to the same HEX
+// file.
+func selfTestRound(mcConfig *MicrocontrollerConfig, lines []selfTestLine, round int) []string {
+	fuses := selfTestFuses(mcConfig, round)
+	source := []string{fmt.Sprintf("; asm4pic self-test program %d for %s", round+1, mcConfig.Name)}
+	for i, options := range fuses {
+		groups := make([]string, 0, len(options))
+		for group := range options {
+			groups = append(groups, group)
+		}
+		sort.Strings(groups)
+		selected := make([]string, len(groups))
+		for k, group := range groups {
+			selected[k] = options[group]
+		}
+		if len(selected) > 0 {
+			source = append(source, fmt.Sprintf("        __CONFIG CONFIG%d, %s", i+1, strings.Join(selected, " & ")))
+		}
+	}
+	source = append(source, "        ORG 0")
+	expected := make(map[int]selfTestLine) // source line -> instruction
+	for _, line := range lines {
+		source = append(source, line.Text)
+		expected[len(source)] = line
+	}
+	source = append(source, "        END", "")
+
+	output, err := assembleOutput(strings.Join(source, "\n"), mcConfig, &AssemblyOptions{StrictConfig: true})
+	if err != nil {
+		return []string{fmt.Sprintf("program %d does not assemble: %v", round+1, err)}
+	}
+	var problems []string
+	a := output.Assembler
+	for i, item := range a.parsedAssembly.Lines {
+		line, ok := expected[a.sourceLine(i)]
+		addr, hasCode := a.itemAddresses[i]
+		if _, isInstruction := item.(*Instruction); !ok || !hasCode || !isInstruction {
+			continue
+		}
+		word := a.machineCodeWords[addr]
+		decoded, ok := decodeWord(mcConfig, word)
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("%s encodes as 0x%04X, which decodes as no instruction", strings.TrimSpace(line.Text), word))
+			continue
+		case decoded.Opcode != line.Opcode:
+			problems = append(problems, fmt.Sprintf("%s encodes as 0x%04X, which decodes as %s", strings.TrimSpace(line.Text), word, decoded.Opcode))
+			continue
+		}
+		for _, kind := range decoded.Info.Operands {
+			if got, want := decoded.Fields[kind], line.Fields[kind]; got != want {
+				problems = append(problems, fmt.Sprintf("%s encodes as 0x%04X, whose %s field decodes as 0x%X", strings.TrimSpace(line.Text), word, kind, got))
+			}
+		}
+	}
+	for i, options := range fuses {
+		word, fuseMap := fmt.Sprintf("CONFIG%d", i+1), mcConfig.AllConfigFuseMaps[i]
+		for _, setting := range decodeConfigWord(mcConfig, word, a.configWords[word]) {
+			option, ok := options[setting.Group]
+			if want := fuseMap[setting.Group].Values[option] & fuseMap[setting.Group].Mask; ok && setting.Value != want {
+				problems = append(problems, fmt.Sprintf("%s %s selects 0x%X, but %s reads back as %s", word, setting.Group, want, option, setting.optionText()))
+			}
+		}
+	}
+
+	memory, err := parseIntelHex(output.Hex)
+	if err != nil {
+		return append(problems, fmt.Sprintf("program %d gives an unreadable HEX file: %v", round+1, err))
+	}
+	disassembly := newDisassembler(mcConfig, memory).source(fmt.Sprintf("self-test program %d", round+1))
+	reassembled, err := assembleOutput(disassembly, mcConfig, nil)
+	if err != nil {
+		return append(problems, fmt.Sprintf("the disassembly of program %d does not reassemble: %v", round+1, err))
+	}
+	if reassembled.Hex != output.Hex {
+		again, _ := parseIntelHex(reassembled.Hex)
+		for _, addr := range sortedByteAddresses(memory, again) {
+			if memory[addr] != again[addr] {
+				problems = append(problems, fmt.Sprintf("the disassembly of program %d reassembles differently from HEX address 0x%04X", round+1, addr))
+				break
+			}
+		}
+	}
+	return problems
+}
+
+// sortedByteAddresses returns the addresses of two memory images, in order.
+func sortedByteAddresses(images ...map[int]byte) []int {
+	seen := make(map[int]bool)
+	var addrs []int
+	for _, image := range images {
+		for addr := range image {
+			if !seen[addr] {
+				seen[addr] = true
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+	sort.Ints(addrs)
+	return addrs
+}
+
+// selfTestDevice runs the self-test of one device config and returns its problems, the
+// static ones first, each reported once.
+func selfTestDevice(mcConfig *MicrocontrollerConfig) (problems []string, forms int) {
+	problems = checkDeviceConfig(mcConfig)
+	if len(problems) > 0 {
+		return problems, 0 // the programs would fail on the same problems
+	}
+	lines := selfTestInstructions(mcConfig)
+	seen := make(map[string]bool)
+	for round := 0; round < selfTestRounds(mcConfig); round++ {
+		for _, problem := range selfTestRound(mcConfig, lines, round) {
+			if !seen[problem] {
+				seen[problem] = true
+				problems = append(problems, problem)
+			}
+		}
+	}
+	return problems, len(lines)
+}
+
+// runSelfTest checks every device config in the config directory, or the one named by -mcu.
+func runSelfTest(args []string) error {
+	fs := flag.NewFlagSet("selftest", flag.ExitOnError)
+	mcu := fs.String("mcu", "", "Check only this microcontroller, e.g., 'PIC16F687' (default all)")
+	configDir := fs.String("config-dir", "./configs", "Directory containing microcontroller JSON config files")
+	fs.Parse(args)
+
+	var names []string
+	if *mcu != "" {
+		names = []string{*mcu}
+	} else {
+		paths, err := filepath.Glob(filepath.Join(*configDir, "*.json"))
+		if err != nil {
+			return err
+		}
+		for _, path := range paths {
+			names = append(names, strings.ToUpper(strings.TrimSuffix(filepath.Base(path), ".json")))
+		}
+		if len(names) == 0 {
+			return fmt.Errorf("no device configs in '%s'", *configDir)
+		}
+	}
+
+	failed := 0
+	for _, name := range names {
+		mcConfig, err := loadMicrocontrollerConfigByName(*configDir, name)
+		var problems []string
+		forms := 0
+		if err != nil {
+			problems = []string{err.Error()}
+		} else {
+			problems, forms = selfTestDevice(mcConfig)
+		}
+		if len(problems) == 0 {
+			groups, options := 0, 0
+			for _, fuseMap := range mcConfig.AllConfigFuseMaps {
+				for _, info := range fuseMap {
+					groups, options = groups+1, options+len(info.Values)
+				}
+			}
+			fmt.Printf("%s: ok (%d instructions in %d forms, %d fuse groups with %d options)\n", name, len(mcConfig.InstructionSet), forms, groups, options)
+			continue
+		}
+		failed++
+		fmt.Printf("%s: %d problem(s)\n", name, len(problems))
+		for _, problem := range problems {
+			fmt.Printf("  %s\n", problem)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d device configs failed the self-test", failed, len(names))
+	}
+	return nil
+}
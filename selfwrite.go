@@ -0,0 +1,101 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// --- Self-Write Regions ---
+
+// SelfWriteDirective opens (SELFWRITE) or closes (ENDSELFWRITE) a region of program memory
+// the program erases and rewrites at run time, e.g. calibration tables.
+type SelfWriteDirective struct {
+	End bool
+}
+
+func (s *SelfWriteDirective) isAssemblyItem() {}
+
+var selfWriteRegex = regexp.MustCompile(`(?i)^(SELFWRITE|ENDSELFWRITE)$`)
+
+func init() {
+	directiveKeywords["SELFWRITE"] = true
+	directiveKeywords["ENDSELFWRITE"] = true
+}
+
+// parseSelfWriteDirective parses SELFWRITE and ENDSELFWRITE, or returns nil.
+func parseSelfWriteDirective(lineContent string) AssemblyItem {
+	if match := selfWriteRegex.FindStringSubmatch(lineContent); match != nil {
+		return &SelfWriteDirective{End: strings.EqualFold(match[1], "ENDSELFWRITE")}
+	}
+	return nil
+}
+
+// checkSelfWriteRegions verifies that every SELFWRITE region covers whole flash erase rows
+// (FLASH_ROW_SIZE words in the device config). Erasing a row that the region only partly
+// covers also erases the code sharing it, so a region that starts or ends inside a row is
+// reported, naming that code if there is any.
+func (a *PicAssembler) checkSelfWriteRegions() error {
+	open, start, end := -1, -1, -1
+	for i, item := range a.parsedAssembly.Lines {
+		if addr, ok := a.itemAddresses[i]; ok && open >= 0 {
+			if start < 0 {
+				start = addr
+			}
+			end = addr + 1
+		}
+		directive, ok := item.(*SelfWriteDirective)
+		if !ok {
+			continue
+		}
+		switch {
+		case !directive.End && open >= 0:
+			return a.errorAt(i, CodeSelfWriteNesting, "SELFWRITE inside the region opened at line %d; close it with ENDSELFWRITE first.", a.sourceLine(open))
+		case !directive.End:
+			open, start, end = i, -1, -1
+		case open < 0:
+			return a.errorAt(i, CodeSelfWriteNesting, "ENDSELFWRITE without SELFWRITE.")
+		default:
+			if start >= 0 {
+				a.checkSelfWriteRegion(open, start, end)
+			}
+			open = -1
+		}
+	}
+	if open >= 0 {
+		return a.errorAt(open, CodeSelfWriteNesting, "SELFWRITE region is never closed with ENDSELFWRITE.")
+	}
+	return nil
+}
+
+// checkSelfWriteRegion checks the region [start, end) opened by the directive at index i.
+func (a *PicAssembler) checkSelfWriteRegion(i, start, end int) {
+	row := a.mcConfig.FlashRowSize
+	if row <= 0 {
+		a.warn(a.sourceLine(i), CodeSelfWriteAlignment, "The device config has no FLASH_ROW_SIZE, so the self-write region 0x%04X-0x%04X cannot be checked.", start, end-1)
+		return
+	}
+	// sharing describes the code outside the region in [from, to).
+	sharing := func(from, to int) string {
+		for addr := from; addr < to; addr++ {
+			if _, used := a.machineCodeWords[addr]; used && (addr < start || addr >= end) {
+				return tr("; erasing it also erases the code at 0x%04X", addr)
+			}
+		}
+		return ""
+	}
+	if first, last := start-start%row, (end-1)-(end-1)%row; first == last && (start%row != 0 || end%row != 0) {
+		a.warn(a.sourceLine(i), CodeSelfWriteAlignment, "Self-write region 0x%04X-0x%04X only covers part of the %d-word flash row at 0x%04X%s.",
+			start, end-1, row, first, sharing(first, first+row))
+		return
+	}
+	if start%row != 0 {
+		rowStart := start - start%row
+		a.warn(a.sourceLine(i), CodeSelfWriteAlignment, "Self-write region starts at 0x%04X, inside the %d-word flash row at 0x%04X%s.",
+			start, row, rowStart, sharing(rowStart, rowStart+row))
+	}
+	if end%row != 0 {
+		rowStart := end - end%row
+		a.warn(a.sourceLine(i), CodeSelfWriteAlignment, "Self-write region ends at 0x%04X, inside the %d-word flash row at 0x%04X%s.",
+			end-1, row, rowStart, sharing(rowStart, rowStart+row))
+	}
+}
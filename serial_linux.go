@@ -0,0 +1,64 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// serialBaudRates maps supported baud rates to their termios speed constants.
+var serialBaudRates = map[int]uint32{
+	1200:   syscall.B1200,
+	2400:   syscall.B2400,
+	4800:   syscall.B4800,
+	9600:   syscall.B9600,
+	19200:  syscall.B19200,
+	38400:  syscall.B38400,
+	57600:  syscall.B57600,
+	115200: syscall.B115200,
+	230400: syscall.B230400,
+}
+
+// openSerialPort opens a tty device in raw 8N1 mode at the given baud rate. The port is opened
+// non-blocking so the runtime poller can interrupt pending reads when it is closed.
+func openSerialPort(path string, baud int) (*os.File, error) {
+	speed, ok := serialBaudRates[baud]
+	if !ok {
+		return nil, fmt.Errorf("unsupported baud rate %d", baud)
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|syscall.O_NOCTTY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var tio syscall.Termios
+	tio.Cflag = syscall.CS8 | syscall.CREAD | syscall.CLOCAL | speed
+	tio.Ispeed = speed
+	tio.Ospeed = speed
+	tio.Cc[syscall.VMIN] = 1
+	tio.Cc[syscall.VTIME] = 0
+
+	// The ioctl goes through SyscallConn: f.Fd() would switch the descriptor to blocking
+	// mode and take it out of the poller, and Close would no longer interrupt a Read.
+	rc, err := f.SyscallConn()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	var errno syscall.Errno
+	if err := rc.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, uintptr(syscall.TCSETS), uintptr(unsafe.Pointer(&tio)))
+	}); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if errno != 0 {
+		f.Close()
+		return nil, fmt.Errorf("could not configure port: %w", errno)
+	}
+	return f, nil
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+)
+
+// openSerialPort is not implemented outside Linux.
+func openSerialPort(path string, baud int) (*os.File, error) {
+	return nil, fmt.Errorf("serial ports are not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --- HTTP Server ---
+
+func init() {
+	registerSubcommand("serve", "Serve an HTTP assembly API and web playground", runServe)
+}
+
+// assembleRequest is the JSON body accepted by POST /assemble.
+type assembleRequest struct {
+	Source string `json:"source"`
+	MCU    string `json:"mcu"`
+}
+
+// assembleResponse is the JSON body returned by POST /assemble.
+type assembleResponse struct {
+	Success     bool         `json:"success"`
+	Hex         string       `json:"hex,omitempty"`
+	Report      string       `json:"report,omitempty"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// mcuNameRegex restricts MCU names so they cannot escape the config directory.
+var mcuNameRegex = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// assemblyServer handles HTTP builds. Every request gets its own parser and assembler;
+// device configs are loaded once and shared read-only.
+type assemblyServer struct {
+	configDir    string
+	maxBodyBytes int64
+	buildTimeout time.Duration
+	slots        chan struct{}
+
+	mu      sync.Mutex
+	configs map[string]*MicrocontrollerConfig
+}
+
+// config returns the cached configuration for an MCU, loading it on first use.
+func (s *assemblyServer) config(mcu string) (*MicrocontrollerConfig, error) {
+	if !mcuNameRegex.MatchString(mcu) {
+		return nil, fmt.Errorf("invalid MCU name '%s'", mcu)
+	}
+	key := strings.ToLower(mcu)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if mcConfig, ok := s.configs[key]; ok {
+		return mcConfig, nil
+	}
+	mcConfig, err := loadMicrocontrollerConfigByName(s.configDir, key)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported MCU '%s'", mcu)
+	}
+	s.configs[key] = mcConfig
+	return mcConfig, nil
+}
+
+// writeJSON sends a JSON response with the given status code.
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// handleAssemble assembles the submitted source and returns HEX, report and diagnostics.
+func (s *assemblyServer) handleAssemble(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req assembleRequest
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxBodyBytes)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, assembleResponse{Diagnostics: []Diagnostic{{Severity: SeverityError, Message: "invalid request: " + err.Error()}}})
+		return
+	}
+	mcConfig, err := s.config(req.MCU)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, assembleResponse{Diagnostics: []Diagnostic{{Severity: SeverityError, Message: err.Error()}}})
+		return
+	}
+
+	// Limit concurrent builds; wait for a free slot until the build deadline.
+	ctx, cancel := context.WithTimeout(r.Context(), s.buildTimeout)
+	defer cancel()
+	select {
+	case s.slots <- struct{}{}:
+	case <-ctx.Done():
+		writeJSON(w, http.StatusServiceUnavailable, assembleResponse{Diagnostics: []Diagnostic{{Severity: SeverityError, Message: "server busy, try again later"}}})
+		return
+	}
+
+	type buildResult struct {
+		output *AssemblyOutput
+		err    error
+	}
+	done := make(chan buildResult, 1)
+	go func() {
+		defer func() { <-s.slots }()
+		output, err := assembleOutput(req.Source, mcConfig, &AssemblyOptions{NoFileAccess: true, Context: ctx})
+		done <- buildResult{output, err}
+	}()
+
+	select {
+	case result := <-done:
+		resp := assembleResponse{Diagnostics: append([]Diagnostic{}, result.output.Warnings...)}
+		if result.err != nil {
+			resp.Diagnostics = append(resp.Diagnostics, errorDiagnostic(result.err))
+		} else {
+			resp.Success = true
+			resp.Hex = result.output.Hex
+			resp.Report = result.output.Report
+		}
+		writeJSON(w, http.StatusOK, resp)
+	case <-ctx.Done():
+		writeJSON(w, http.StatusGatewayTimeout, assembleResponse{Diagnostics: []Diagnostic{{Severity: SeverityError, Message: "build timed out"}}})
+	}
+}
+
+// handleMCUs lists the MCUs that have a config file in the config directory.
+func (s *assemblyServer) handleMCUs(w http.ResponseWriter, r *http.Request) {
+	paths, _ := filepath.Glob(filepath.Join(s.configDir, "*.json"))
+	mcus := []string{}
+	for _, path := range paths {
+		mcus = append(mcus, strings.ToUpper(strings.TrimSuffix(filepath.Base(path), ".json")))
+	}
+	sort.Strings(mcus)
+	writeJSON(w, http.StatusOK, mcus)
+}
+
+// handlePlayground serves the single-page browser playground.
+func (s *assemblyServer) handlePlayground(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, playgroundHTML)
+}
+
+// runServe starts the HTTP server.
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", "127.0.0.1:8080", "Address to listen on")
+	configDir := fs.String("config-dir", "./configs", "Directory containing microcontroller JSON config files")
+	maxBodyBytes := fs.Int64("max-body-bytes", 1<<20, "Maximum size of a request body in bytes")
+	maxConcurrent := fs.Int("max-concurrent", 4, "Maximum number of builds running at the same time")
+	buildTimeout := fs.Duration("build-timeout", 10*time.Second, "Maximum time a request may wait for and run a build")
+	fs.Parse(args)
+
+	if *maxConcurrent < 1 {
+		return fmt.Errorf("-max-concurrent must be at least 1")
+	}
+	if _, err := os.Stat(*configDir); err != nil {
+		return fmt.Errorf("config directory: %w", err)
+	}
+
+	server := &assemblyServer{
+		configDir:    *configDir,
+		maxBodyBytes: *maxBodyBytes,
+		buildTimeout: *buildTimeout,
+		slots:        make(chan struct{}, *maxConcurrent),
+		configs:      make(map[string]*MicrocontrollerConfig),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/assemble", server.handleAssemble)
+	mux.HandleFunc("/mcus", server.handleMCUs)
+	mux.HandleFunc("/", server.handlePlayground)
+
+	httpServer := &http.Server{
+		Addr:              *addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      *buildTimeout + 30*time.Second,
+	}
+	log.Printf("asm4PIC server listening on http://%s", *addr)
+	return httpServer.ListenAndServe()
+}
+
+// playgroundHTML is a minimal browser front end for the /assemble endpoint.
+const playgroundHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>asm4PIC Playground</title>
+<style>
+body { font-family: sans-serif; margin: 1em; }
+textarea, pre { width: 100%; font-family: monospace; box-sizing: border-box; }
+textarea { height: 24em; }
+pre { background: #f4f4f4; padding: 0.5em; max-height: 24em; overflow: auto; }
+.error { color: #b00; } .warning { color: #a60; }
+</style>
+</head>
+<body>
+<h1>asm4PIC Playground</h1>
+<p><select id="mcu"></select> <button id="build">Assemble</button></p>
+<textarea id="source">    ORG 0x000
+    GOTO MAIN
+    ORG 0x005
+MAIN:
+    GOTO MAIN
+    END
+</textarea>
+<h2>Diagnostics</h2><div id="diagnostics"></div>
+<h2>HEX</h2><pre id="hex"></pre>
+<h2>Report</h2><pre id="report"></pre>
+<script>
+fetch("/mcus").then(r => r.json()).then(mcus => {
+  const select = document.getElementById("mcu");
+  for (const m of mcus) { const o = document.createElement("option"); o.textContent = m; select.appendChild(o); }
+});
+document.getElementById("build").onclick = async () => {
+  const body = { source: document.getElementById("source").value, mcu: document.getElementById("mcu").value };
+  const resp = await fetch("/assemble", { method: "POST", headers: { "Content-Type": "application/json" }, body: JSON.stringify(body) });
+  const result = await resp.json();
+  const diags = document.getElementById("diagnostics");
+  diags.innerHTML = "";
+  for (const d of result.diagnostics || []) {
+    const p = document.createElement("div");
+    p.className = d.severity;
+    p.textContent = (d.line ? "Line " + d.line + ": " : "") + d.severity + ": " + d.message;
+    diags.appendChild(p);
+  }
+  document.getElementById("hex").textContent = result.hex || "";
+  document.getElementById("report").textContent = result.report || "";
+};
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testServer returns a server with one build slot and the given build timeout.
+func testServer(buildTimeout time.Duration) *assemblyServer {
+	return &assemblyServer{
+		configDir:    "./configs",
+		maxBodyBytes: 1 << 20,
+		buildTimeout: buildTimeout,
+		slots:        make(chan struct{}, 1),
+		configs:      make(map[string]*MicrocontrollerConfig),
+	}
+}
+
+// post sends a source to the /assemble handler of server.
+func post(server *assemblyServer, source string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(assembleRequest{Source: source, MCU: "PIC16F687"})
+	recorder := httptest.NewRecorder()
+	server.handleAssemble(recorder, httptest.NewRequest(http.MethodPost, "/assemble", bytes.NewReader(body)))
+	return recorder
+}
+
+// postAssemble sends a source to the /assemble handler and returns the decoded response and
+// the raw body.
+func postAssemble(t *testing.T, source string) (assembleResponse, string) {
+	t.Helper()
+	recorder := post(testServer(10*time.Second), source)
+	var resp assembleResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	return resp, recorder.Body.String()
+}
+
+func TestServeRefusesFileReads(t *testing.T) {
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "secret.inc")
+	if err := os.WriteFile(secret, []byte("SECRET_VALUE EQU 0x42\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	relative, err := filepath.Rel(wd, secret)
+	if err != nil || !strings.HasPrefix(relative, "..") {
+		t.Skipf("no relative path from %s to %s", wd, secret)
+	}
+
+	for _, line := range []string{
+		`#INCLUDE "` + secret + `"`,
+		`#INCLUDE "` + relative + `"`,
+		`#INCLUDE <` + relative + `>`,
+		`INCBIN "` + secret + `"`,
+		`INCBIN "` + relative + `"`,
+		`DTABLE "` + relative + `"`,
+	} {
+		resp, raw := postAssemble(t, "        ORG 0\n"+line+"\n        END\n")
+		if resp.Success {
+			t.Errorf("%s: assembled, want the read refused", line)
+		}
+		if strings.Contains(raw, "SECRET_VALUE") {
+			t.Errorf("%s: response discloses the file: %s", line, raw)
+		}
+	}
+}
+
+func TestServeIncludesBundledLibrary(t *testing.T) {
+	resp, raw := postAssemble(t, "#INCLUDE <std16.inc>\n        ORG 0\n        CLR16 0x20, 0x21\n        END\n")
+	if !resp.Success {
+		t.Fatalf("bundled library include failed: %s", raw)
+	}
+}
+
+func TestServeTimeoutFreesSlot(t *testing.T) {
+	// Seven levels of macros invoking the level below ten times expand to ten million NOPs
+	source := "L0 MACRO\n        NOP\n        ENDM\n"
+	for level := 1; level <= 7; level++ {
+		source += fmt.Sprintf("L%d MACRO\n%s        ENDM\n", level, strings.Repeat(fmt.Sprintf("        L%d\n", level-1), 10))
+	}
+	source += "        ORG 0\n        L7\n        END\n"
+
+	server := testServer(100 * time.Millisecond)
+	if recorder := post(server, source); recorder.Code != http.StatusGatewayTimeout {
+		t.Fatalf("status = %d, want %d", recorder.Code, http.StatusGatewayTimeout)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for len(server.slots) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("the timed-out build still holds its slot")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if recorder := post(server, "        ORG 0\n        NOP\n        END\n"); recorder.Code != http.StatusOK {
+		t.Errorf("next build: status = %d, want %d", recorder.Code, http.StatusOK)
+	}
+}
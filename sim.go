@@ -0,0 +1,682 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// --- Simulator ---
+
+func init() {
+	registerSubcommand("sim", "Run a HEX file in the instruction set simulator", runSim)
+}
+
+// Addresses and bits of the mid-range core registers the simulator models.
+const (
+	indfRegister   = 0x00
+	fsrRegister    = 0x04
+	pclathRegister = 0x0A
+	intconRegister = 0x0B
+
+	statusC   = 0
+	statusDC  = 1
+	statusZ   = 2
+	statusPD  = 3
+	statusTO  = 4
+	statusIRP = 7
+	intconGIE = 7
+
+	// stackDepth is the number of return addresses the hardware stack holds; a ninth CALL
+	// overwrites the first.
+	stackDepth = 8
+)
+
+// simulator executes the program memory of a mid-range core one instruction at a time,
+// counting instruction cycles.
+type simulator struct {
+	mcConfig *MicrocontrollerConfig
+	code     []decodedInstruction // program memory, decoded
+	valid    []bool               // the word at an address decodes to an instruction
+	words    []int                // program memory words
+	ram      []byte               // data memory of every bank, by address
+	bankSize int
+	names    map[int]string    // register address -> SFR name, or the data symbol of a GPR
+	ports    map[int]int       // PORT register address -> its TRIS register address
+	pins     map[int]byte      // PORT register address -> levels driven onto its pins from outside
+	symbols  map[string]int    // symbols of the source the program was assembled from, if any
+	fuses    map[string]string // config fuse group -> the option the program's config words select
+
+	pc       int
+	w        byte
+	stack    [stackDepth]int
+	sp       int // next free stack slot
+	depth    int // return addresses pushed and not popped, up to stackDepth
+	cycles   uint64
+	sleeping bool
+	touched  int    // data memory address the last instruction accessed, -1 if none
+	jumped   bool   // the last instruction wrote PCL
+	current  int    // address of the executing instruction, or the last one executed
+	event    string // what the last step did instead of an instruction: an interrupt, a reset or a wake-up
+	woken    bool   // the core woke from SLEEP and has not run the instruction after it yet
+
+	foscMHz    float64        // oscillator frequency, for peripheral timing given in microseconds
+	adc        *simADC        // nil when the device config describes no ADC
+	interrupts *simInterrupts // nil when the device config describes no interrupts
+	resets     *simResets     // nil when the device config describes no reset flags or watchdog
+	stimulus   *simStimulus   // inputs applied as the cycle count reaches them, if any
+	log        io.Writer      // where peripheral models report misuse, nil to stay quiet
+
+	watchdogCleared uint64 // cycle the watchdog was last cleared
+}
+
+// newSimulator loads the program memory and config words of a HEX memory image and resets
+// the core. Words the image leaves unprogrammed hold all ones, which the core executes as
+// ADDLW 0xFF and which select the erased option of each fuse.
+func newSimulator(mcConfig *MicrocontrollerConfig, memory map[int]byte) (*simulator, error) {
+	if _, movlb := mcConfig.InstructionSet["MOVLB"]; movlb || mcConfig.ProgramWordSizeBits != 14 {
+		return nil, fmt.Errorf("the simulator supports mid-range cores only (14-bit words, banks selected with STATUS)")
+	}
+	s := &simulator{
+		mcConfig: mcConfig,
+		code:     make([]decodedInstruction, mcConfig.ProgramMemorySize),
+		valid:    make([]bool, mcConfig.ProgramMemorySize),
+		words:    make([]int, mcConfig.ProgramMemorySize),
+		bankSize: 0x80,
+		names:    sfrNamesByAddress(mcConfig),
+		ports:    make(map[int]int),
+		pins:     make(map[int]byte),
+		fuses:    make(map[string]string),
+		foscMHz:  4,
+	}
+	for name, addr := range mcConfig.SFRMap {
+		if tris, ok := mcConfig.SFRMap["TRIS"+strings.TrimPrefix(name, "PORT")]; ok && strings.HasPrefix(name, "PORT") {
+			s.ports[addr] = tris
+		}
+	}
+	if mcConfig.RAMLayout != nil && mcConfig.RAMLayout.BankSize > 0 {
+		s.bankSize = mcConfig.RAMLayout.BankSize
+	}
+	s.ram = make([]byte, (bankSelectMask(mcConfig)+1)*s.bankSize)
+	s.adc = newSimADC(s)
+	s.interrupts = newSimInterrupts(s)
+	s.resets = newSimResets(s)
+
+	wordMask := (1 << mcConfig.ProgramWordSizeBits) - 1
+	for name, info := range mcConfig.ConfigWordDefaults {
+		value, ok := mcConfig.hexValueAt(memory, info.Address)
+		if !ok {
+			value = wordMask
+		}
+		for _, setting := range decodeConfigWord(mcConfig, name, value&wordMask) {
+			s.fuses[setting.Group] = setting.Option
+		}
+	}
+	erased := decodedInstruction{Opcode: "ADDLW", Info: mcConfig.InstructionSet["ADDLW"], Fields: map[string]int{"k8": 0xFF}}
+	decoded := make(map[int]decodedInstruction)
+	for addr := range s.words {
+		word, ok := hexWordAt(memory, addr)
+		if !ok || word&wordMask == wordMask {
+			s.words[addr], s.code[addr], s.valid[addr] = wordMask, erased, true
+			continue
+		}
+		word &= wordMask
+		inst, seen := decoded[word]
+		if !seen {
+			if inst, ok = decodeWord(mcConfig, word); ok {
+				decoded[word] = inst
+			}
+		}
+		s.words[addr], s.code[addr], s.valid[addr] = word, inst, seen || ok
+	}
+	s.reset()
+	return s, nil
+}
+
+// reset puts the core in its power-on state: PC and W cleared, TO and PD set, OPTION_REG and
+// the TRIS registers all ones, so every pin is an input, analog pins selected as analog and
+// the watchdog cleared. Other registers read 0. The cycle count goes on.
+func (s *simulator) reset() {
+	s.pc, s.w, s.sp, s.depth, s.sleeping, s.woken = 0, 0, 0, 0, false, false
+	s.watchdogCleared = s.cycles
+	for i := range s.ram {
+		s.ram[i] = 0
+	}
+	s.ram[statusRegister] = 1<<statusTO | 1<<statusPD
+	for name, addr := range s.mcConfig.SFRMap {
+		if name == "OPTION_REG" || (len(name) == 5 && name[:4] == "TRIS") {
+			s.ram[addr%len(s.ram)] = 0xFF
+		}
+	}
+	for _, pins := range s.mcConfig.AnalogPins {
+		for _, pin := range pins {
+			if sel, ok := s.mcConfig.SFRMap[pin.Select]; ok {
+				s.ram[sel%len(s.ram)] |= 1 << pin.SelectBit
+			}
+		}
+	}
+	if s.adc != nil {
+		s.adc.reset()
+	}
+	if s.resets != nil && s.resets.hasPrescaler {
+		s.set(s.resets.prescaler, s.resets.config.WDTPrescalerReset)
+	}
+	if s.interrupts != nil {
+		s.interrupts.reset(s)
+	}
+}
+
+// setBit sets or clears a bit of a core register.
+func (s *simulator) setBit(addr, bit int, set bool) {
+	if set {
+		s.ram[addr] |= 1 << bit
+	} else {
+		s.ram[addr] &^= 1 << bit
+	}
+}
+
+// resolve maps the file register field of an instruction to a data memory address: INDF
+// goes through FSR and IRP, anything else through RP1:RP0. Core registers mirrored in every
+// bank and common RAM fold onto bank 0.
+func (s *simulator) resolve(f int) int {
+	var addr int
+	if f == indfRegister {
+		addr = int(s.ram[statusRegister]>>statusIRP&1)<<8 | int(s.ram[fsrRegister])
+	} else {
+		addr = int(s.ram[statusRegister]>>5&3)*s.bankSize + f
+	}
+	addr %= len(s.ram)
+	if low := addr % s.bankSize; coreMirroredRegisters[low] || isSharedRAM(s.mcConfig, addr) {
+		return low
+	}
+	return addr
+}
+
+// read returns the value of a data memory address. INDF read through itself is 0, and PCL is
+// the low byte of the PC, which already points past the executing instruction. A PORT reads
+// its output latch on output pins and the level driven from outside on input pins, except
+// that pins still selected as analog inputs read 0.
+func (s *simulator) read(addr int) byte {
+	switch addr {
+	case indfRegister:
+		return 0
+	case pclRegister:
+		return byte(s.pc)
+	}
+	if tris, ok := s.ports[addr]; ok {
+		inputs := s.ram[tris]
+		return s.ram[addr]&^inputs | s.pins[addr]&inputs&^s.analogPins(addr)
+	}
+	return s.ram[addr]
+}
+
+// analogPins returns the pins of a PORT that are selected as analog inputs.
+func (s *simulator) analogPins(port int) byte {
+	var mask byte
+	for _, pin := range s.mcConfig.AnalogPins[s.names[port]] {
+		if sel, ok := s.mcConfig.SFRMap[pin.Select]; ok && s.ram[sel%len(s.ram)]>>pin.SelectBit&1 == 1 {
+			mask |= 1 << pin.Bit
+		}
+	}
+	return mask
+}
+
+// write stores a value at a data memory address. Writing PCL jumps to PCLATH:value; TO and
+// PD of STATUS cannot be written.
+func (s *simulator) write(addr int, value byte) {
+	switch addr {
+	case indfRegister:
+		return
+	case pclRegister:
+		s.pc = (int(s.ram[pclathRegister])<<8 | int(value)) & 0x1FFF
+		s.jumped = true
+	case statusRegister:
+		value = value&^(1<<statusTO|1<<statusPD) | s.ram[statusRegister]&(1<<statusTO|1<<statusPD)
+	}
+	s.ram[addr] = value
+}
+
+// push saves a return address on the hardware stack, overwriting the oldest when it is full.
+func (s *simulator) push(addr int) {
+	s.stack[s.sp] = addr
+	s.sp = (s.sp + 1) % stackDepth
+	s.depth = min(s.depth+1, stackDepth)
+}
+
+// pop returns the last return address pushed. Popping an empty stack wraps around, as the
+// hardware does.
+func (s *simulator) pop() int {
+	s.sp = (s.sp + stackDepth - 1) % stackDepth
+	s.depth = max(s.depth-1, 0)
+	return s.stack[s.sp]
+}
+
+// setZ sets the Z flag from a result.
+func (s *simulator) setZ(result byte) {
+	s.setBit(statusRegister, statusZ, result == 0)
+}
+
+// add returns a + b, setting C, DC and Z.
+func (s *simulator) add(a, b byte) byte {
+	s.setBit(statusRegister, statusC, int(a)+int(b) > 0xFF)
+	s.setBit(statusRegister, statusDC, a&0x0F+b&0x0F > 0x0F)
+	s.setZ(a + b)
+	return a + b
+}
+
+// sub returns a - b, setting C and DC when there is no borrow, and Z.
+func (s *simulator) sub(a, b byte) byte {
+	s.setBit(statusRegister, statusC, a >= b)
+	s.setBit(statusRegister, statusDC, a&0x0F >= b&0x0F)
+	s.setZ(a - b)
+	return a - b
+}
+
+// step executes the instruction at the PC and advances the cycle count: two cycles for
+// jumps, calls, returns, taken skips and writes to PCL, one otherwise. When the watchdog
+// has timed out it resets the core instead, and when an enabled interrupt was pending
+// before the pins were sampled and GIE is set, it enters the interrupt.
+func (s *simulator) step() error {
+	s.touched, s.jumped, s.event = -1, false, ""
+	if expiry, running := s.watchdogExpiry(); running && s.cycles >= expiry {
+		s.event = "reset by the watchdog"
+		return s.resetBy("wdt")
+	}
+	woken := s.woken
+	s.woken = false
+	if s.interrupts != nil {
+		sources := s.interrupts.pending(s)
+		s.interrupts.sample(s)
+		if len(sources) > 0 && !woken && s.ram[intconRegister]>>intconGIE&1 == 1 {
+			s.interrupt(sources)
+			return nil
+		}
+	}
+	addr := s.pc % len(s.code)
+	if !s.valid[addr] {
+		return fmt.Errorf("word 0x%04X at 0x%04X is not an instruction", s.words[addr], addr)
+	}
+	inst := s.code[addr]
+	s.current = addr
+	s.pc = (s.pc + 1) & 0x1FFF
+	cycles := uint64(1)
+
+	f, hasFile := inst.Fields["f"]
+	var reg int
+	var value byte
+	if hasFile {
+		reg = s.resolve(f)
+		value = s.read(reg)
+		s.touched = reg
+	}
+	k := byte(inst.Fields["k8"])
+	bit := inst.Fields["b"]
+	// store writes an operation result to W or back to the file register, as d selects
+	store := func(result byte) {
+		if inst.Fields["d"] == 1 {
+			s.write(reg, result)
+		} else {
+			s.w = result
+		}
+	}
+	pc := s.pc
+
+	switch inst.Opcode {
+	case "ADDWF":
+		store(s.add(value, s.w))
+	case "ANDWF":
+		s.setZ(value & s.w)
+		store(value & s.w)
+	case "CLRF":
+		s.write(reg, 0)
+		s.setZ(0)
+	case "CLRW":
+		s.w = 0
+		s.setZ(0)
+	case "COMF":
+		s.setZ(^value)
+		store(^value)
+	case "DECF":
+		s.setZ(value - 1)
+		store(value - 1)
+	case "DECFSZ":
+		store(value - 1)
+		if value-1 == 0 {
+			s.pc = (s.pc + 1) & 0x1FFF
+		}
+	case "INCF":
+		s.setZ(value + 1)
+		store(value + 1)
+	case "INCFSZ":
+		store(value + 1)
+		if value+1 == 0 {
+			s.pc = (s.pc + 1) & 0x1FFF
+		}
+	case "IORWF":
+		s.setZ(value | s.w)
+		store(value | s.w)
+	case "MOVF":
+		s.setZ(value)
+		store(value)
+	case "MOVWF":
+		s.write(reg, s.w)
+	case "NOP":
+	case "RLF":
+		carry := value >> 7
+		store(value<<1 | s.ram[statusRegister]>>statusC&1)
+		s.setBit(statusRegister, statusC, carry == 1)
+	case "RRF":
+		carry := value & 1
+		store(value>>1 | s.ram[statusRegister]<<(7-statusC)&0x80)
+		s.setBit(statusRegister, statusC, carry == 1)
+	case "SUBWF":
+		store(s.sub(value, s.w))
+	case "SWAPF":
+		store(value<<4 | value>>4)
+	case "XORWF":
+		s.setZ(value ^ s.w)
+		store(value ^ s.w)
+	case "BCF":
+		s.write(reg, value&^(1<<bit))
+	case "BSF":
+		s.write(reg, value|1<<bit)
+	case "BTFSC", "BTFSS":
+		if (value>>bit&1 == 1) == (inst.Opcode == "BTFSS") {
+			s.pc = (s.pc + 1) & 0x1FFF
+		}
+	case "ADDLW":
+		s.w = s.add(k, s.w)
+	case "ANDLW":
+		s.w &= k
+		s.setZ(s.w)
+	case "CALL", "GOTO":
+		if inst.Opcode == "CALL" {
+			s.push(s.pc)
+		}
+		s.pc = int(s.ram[pclathRegister])&0x18<<8 | inst.Fields["k11"]
+	case "CLRWDT":
+		s.setBit(statusRegister, statusTO, true)
+		s.setBit(statusRegister, statusPD, true)
+		s.watchdogCleared = s.cycles
+	case "IORLW":
+		s.w |= k
+		s.setZ(s.w)
+	case "MOVLW":
+		s.w = k
+	case "RETFIE":
+		s.pc = s.pop()
+		s.setBit(intconRegister, intconGIE, true)
+	case "RETLW":
+		s.w = k
+		s.pc = s.pop()
+	case "RETURN":
+		s.pc = s.pop()
+	case "SLEEP":
+		s.setBit(statusRegister, statusTO, true)
+		s.setBit(statusRegister, statusPD, false)
+		s.sleeping = true
+		s.watchdogCleared = s.cycles
+	case "SUBLW":
+		s.w = s.sub(k, s.w)
+	case "XORLW":
+		s.w ^= k
+		s.setZ(s.w)
+	default:
+		s.pc = addr
+		return fmt.Errorf("%s at 0x%04X is not supported by the simulator", inst.Opcode, addr)
+	}
+	if s.pc != pc || s.jumped {
+		cycles = 2
+	}
+	if s.interrupts != nil && s.touched >= 0 {
+		s.interrupts.accessed(s, s.touched)
+	}
+	s.tick(cycles)
+	return nil
+}
+
+// tick advances the cycle count and brings the peripherals up to it.
+func (s *simulator) tick(cycles uint64) {
+	s.cycles += cycles
+	if s.adc != nil {
+		s.adc.advance(s)
+	}
+}
+
+// report writes a message from a peripheral model with the cycle count and the address of
+// the instruction that just ran.
+func (s *simulator) report(format string, args ...interface{}) {
+	if s.log != nil {
+		fmt.Fprintf(s.log, "%10d  0x%04X  %s\n", s.cycles, s.current, fmt.Sprintf(format, args...))
+	}
+}
+
+// run executes instructions until limit cycles have run in total or the core sleeps with
+// nothing to wake it, calling after (when not nil) with the address and start cycle of each
+// instruction, interrupt, reset and wake-up.
+func (s *simulator) run(limit uint64, after func(addr int, start uint64)) error {
+	for s.cycles < limit {
+		if s.stimulus != nil {
+			if err := s.stimulus.apply(s); err != nil {
+				return err
+			}
+		}
+		addr, start := s.pc%len(s.code), s.cycles
+		if s.sleeping {
+			if !s.doze(limit) {
+				break
+			}
+			if s.event == "" {
+				continue
+			}
+		} else if err := s.step(); err != nil {
+			return err
+		}
+		if after != nil {
+			after(addr, start)
+		}
+	}
+	return nil
+}
+
+// useSymbols takes the symbols of the source the program was assembled from, so registers
+// can be given by symbol and GPRs with a data symbol are named by it.
+func (s *simulator) useSymbols(a *PicAssembler) {
+	s.symbols = a.symbolTable
+	for name, value := range a.symbolTable {
+		if _, isLabel := a.labels[name]; isLabel || !s.isGPR(value) {
+			continue
+		}
+		if other, ok := s.names[value]; !ok || name < other {
+			s.names[value] = name
+		}
+	}
+}
+
+// isGPR reports whether a data memory address is a general purpose register.
+func (s *simulator) isGPR(addr int) bool {
+	if s.mcConfig.RAMLayout == nil {
+		return false
+	}
+	for _, r := range s.mcConfig.RAMLayout.GPR {
+		if r.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// registerName names a data memory address by its SFR or data symbol, or by the address
+// itself.
+func (s *simulator) registerName(addr int) string {
+	if name, ok := s.names[addr]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%03X", addr)
+}
+
+// summary describes where the core stopped and the core registers.
+func (s *simulator) summary() string {
+	state := "running"
+	if s.sleeping {
+		state = "sleeping"
+	}
+	return fmt.Sprintf("Stopped after %d cycles at PC 0x%04X (%s)\nW=0x%02X STATUS=0x%02X FSR=0x%02X PCLATH=0x%02X INTCON=0x%02X\n",
+		s.cycles, s.pc, state, s.w, s.ram[statusRegister], s.ram[fsrRegister], s.ram[pclathRegister], s.ram[intconRegister])
+}
+
+// simProgram loads the program memory to simulate from a HEX file or, with asmFile, by
+// assembling a source, whose assembler is returned for its symbols.
+func simProgram(mcConfig *MicrocontrollerConfig, hexFile, asmFile string) (map[int]byte, *PicAssembler, error) {
+	if asmFile == "" {
+		content, err := os.ReadFile(hexFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not read HEX file '%s': %w", hexFile, err)
+		}
+		memory, err := parseIntelHex(string(content))
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not parse '%s': %w", hexFile, err)
+		}
+		return memory, nil, nil
+	}
+	data, err := os.ReadFile(asmFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read assembly file '%s': %w", asmFile, err)
+	}
+	printer := &DiagnosticPrinter{File: asmFile, Source: string(data)}
+	output, err := assembleOutput(string(data), mcConfig, &AssemblyOptions{SourceDir: filepath.Dir(asmFile)})
+	printer.Print(output.Warnings...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("assembly of %s failed: %w", asmFile, err)
+	}
+	memory, err := parseIntelHex(output.Hex)
+	return memory, output.Assembler, err
+}
+
+func runSim(args []string) error {
+	fs := flag.NewFlagSet("sim", flag.ExitOnError)
+	hexFile := fs.String("hex", "", "Path to the HEX file to run")
+	asmFile := fs.String("asm", "", "Assemble this source and run it, with its symbols available (instead of -hex)")
+	mcu := fs.String("mcu", "", "Target microcontroller name, e.g., 'PIC16F687' (required)")
+	configDir := fs.String("config-dir", "./configs", "Directory containing microcontroller JSON config files")
+	cycles := fs.Uint64("cycles", 1000000, "Stop after this many instruction cycles")
+	scriptFile := fs.String("script", "", "Run the commands of this script file instead of running for -cycles")
+	traceFile := fs.String("trace", "", "Write one line per executed instruction to this file ('-' for stdout)")
+	var traceRanges addressRangesFlag
+	fs.Var(&traceRanges, "trace-range", "Only trace instructions at addresses in START-END or at ADDR (repeatable)")
+	var watchList watchFlag
+	fs.Var(&watchList, "watch", "Print a register, REG.BIT or HIGH:LOW pair whenever its value changes (repeatable)")
+	dump := fs.Bool("dump", false, "Print the GPR banks and the hardware stack when the run stops")
+	loadFile := fs.String("load", "", "Restore the simulator state from a snapshot file before running")
+	stimulusFile := fs.String("stimulus", "", "Apply the pin levels, analog values and resets of a stimulus file as the cycle count reaches them")
+	fosc := fs.Float64("fosc", 4, "Oscillator frequency in MHz, for peripheral timing")
+	saveFile := fs.String("save", "", "Save the simulator state to a snapshot file when the run stops")
+	profileFile := fs.String("profile", "", "Write the execution profile to this file ('-' for stdout)")
+	profileTop := fs.Int("profile-top", 20, "Number of addresses in the profile's hot spot list (0 for all)")
+	fs.Parse(args)
+
+	if (*hexFile == "") == (*asmFile == "") || *mcu == "" {
+		fs.Usage()
+		return fmt.Errorf("-mcu and one of -hex or -asm are required")
+	}
+
+	mcConfig, err := loadMicrocontrollerConfigByName(*configDir, *mcu)
+	if err != nil {
+		return err
+	}
+	memory, assembler, err := simProgram(mcConfig, *hexFile, *asmFile)
+	if err != nil {
+		return err
+	}
+	s, err := newSimulator(mcConfig, memory)
+	if err != nil {
+		return err
+	}
+	if assembler != nil {
+		s.useSymbols(assembler)
+	}
+	if *fosc <= 0 {
+		return fmt.Errorf("-fosc must be a positive frequency in MHz")
+	}
+	s.foscMHz, s.log = *fosc, os.Stdout
+	if *stimulusFile != "" {
+		if s.stimulus, err = loadSimStimulus(*stimulusFile); err != nil {
+			return err
+		}
+	}
+	if *loadFile != "" {
+		if err := s.loadSnapshot(*loadFile); err != nil {
+			return err
+		}
+	}
+
+	var trace *simTrace
+	if *traceFile != "" {
+		if trace, err = newSimTrace(*traceFile, traceRanges); err != nil {
+			return err
+		}
+		defer trace.Close()
+	}
+	var profile *simProfile
+	if *profileFile != "" {
+		profile = newSimProfile(s)
+	}
+	watches := &simWatches{out: os.Stdout}
+	for _, text := range watchList {
+		if err := watches.add(s, text); err != nil {
+			return err
+		}
+	}
+	after := func(addr int, start uint64) {
+		if trace != nil {
+			trace.record(s, addr, start)
+		}
+		if profile != nil {
+			profile.record(s, addr, start)
+		}
+		watches.check(addr, start)
+	}
+	var runErr error
+	if *scriptFile != "" {
+		runErr = (&simScript{sim: s, out: os.Stdout, after: after, watches: watches}).runFile(*scriptFile)
+	} else {
+		runErr = s.run(*cycles, after)
+	}
+	if trace != nil {
+		if err := trace.Close(); err != nil {
+			return err
+		}
+	}
+	if runErr != nil {
+		return runErr
+	}
+	if *saveFile != "" {
+		if err := s.saveSnapshot(*saveFile); err != nil {
+			return err
+		}
+	}
+	if profile != nil {
+		var source []string
+		if *asmFile != "" {
+			data, err := os.ReadFile(*asmFile)
+			if err != nil {
+				return err
+			}
+			source = strings.Split(string(data), "\n")
+		}
+		if err := writeOutputFile(*profileFile, profile.report(s, assembler, source, *profileTop)); err != nil {
+			return err
+		}
+	}
+	if *traceFile != StdioPath {
+		fmt.Print(s.summary())
+	}
+	if *dump {
+		s.dumpGPR(os.Stdout)
+		s.dumpStack(os.Stdout)
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// --- Simulator ADC ---
+
+// RegisterField is a bit field of an SFR: Width bits (one when 0) from bit Bit up.
+type RegisterField struct {
+	Register string `json:"register"`
+	Bit      int    `json:"bit"`
+	Width    int    `json:"width,omitempty"`
+}
+
+// ADCConfig describes the ADC of a device for the simulator: the fields of its control
+// registers, the result registers and the acquisition time the datasheet requires between
+// selecting a channel and starting a conversion.
+type ADCConfig struct {
+	Resolution    int           `json:"resolution"`
+	Enable        RegisterField `json:"enable"`        // ADON
+	Go            RegisterField `json:"go"`            // GO/DONE
+	Channel       RegisterField `json:"channel"`       // CHS
+	RightJustify  RegisterField `json:"right_justify"` // ADFM
+	Clock         RegisterField `json:"clock"`         // ADCS
+	Flag          RegisterField `json:"flag"`          // ADIF
+	ResultHigh    string        `json:"result_high"`
+	ResultLow     string        `json:"result_low"`
+	AcquisitionUS float64       `json:"acquisition_us"`
+}
+
+// adcClockDivisors are the TAD of each conversion clock select value, in oscillator periods;
+// 0 is the internal RC oscillator.
+var adcClockDivisors = []int{2, 8, 32, 0, 4, 16, 64, 0}
+
+// adcRCPeriodUS is the typical TAD of the ADC's internal RC oscillator, in microseconds.
+const adcRCPeriodUS = 4.0
+
+// adcConversionTADs is the number of TAD a conversion takes.
+const adcConversionTADs = 11
+
+// simField is a RegisterField resolved to a data memory address.
+type simField struct {
+	addr int
+	bit  int
+	mask int
+}
+
+// adcState is the part of the ADC model that is not in its registers.
+type adcState struct {
+	Inputs   map[string]int `json:"inputs,omitempty"` // channel (AN0, ...) -> input value in counts
+	Busy     bool           `json:"busy"`
+	DoneAt   uint64         `json:"done_at"`  // cycle the running conversion completes
+	Sample   int            `json:"sample"`   // input value held for the running conversion
+	Settling uint64         `json:"settling"` // cycle the selected input started acquiring
+	Selected int            `json:"selected"` // ADON and channel select at the last check, -1 if off
+}
+
+// simADC models an ADC: setting GO/DONE samples the selected channel's input, and after the
+// conversion time the result is written to the result registers, GO/DONE is cleared and the
+// interrupt flag is set. Clearing GO/DONE early aborts the conversion.
+type simADC struct {
+	config       *ADCConfig
+	enable       simField
+	start        simField // GO/DONE
+	channel      simField
+	rightJustify simField
+	clock        simField
+	flag         simField
+	resultHigh   int
+	resultLow    int
+	channels     map[string]bool // channels the device has, empty if unknown
+	highest      int             // largest input value
+	state        adcState
+}
+
+// newSimADC builds the ADC model of a device, or returns nil when its config describes none
+// or names registers it does not have.
+func newSimADC(s *simulator) *simADC {
+	config := s.mcConfig.ADC
+	if config == nil || config.Resolution <= 0 {
+		return nil
+	}
+	adc := &simADC{config: config, channels: make(map[string]bool), highest: 1<<config.Resolution - 1}
+	ok := true
+	resolve := func(f RegisterField) simField {
+		field, found := s.field(f)
+		ok = ok && found
+		return field
+	}
+	adc.enable, adc.start, adc.channel = resolve(config.Enable), resolve(config.Go), resolve(config.Channel)
+	adc.rightJustify, adc.clock, adc.flag = resolve(config.RightJustify), resolve(config.Clock), resolve(config.Flag)
+	high, foundHigh := s.mcConfig.SFRMap[config.ResultHigh]
+	low, foundLow := s.mcConfig.SFRMap[config.ResultLow]
+	if !ok || !foundHigh || !foundLow {
+		return nil
+	}
+	adc.resultHigh, adc.resultLow = high%len(s.ram), low%len(s.ram)
+	for _, pins := range s.mcConfig.AnalogPins {
+		for _, pin := range pins {
+			adc.channels[pin.Channel] = true
+		}
+	}
+	adc.reset()
+	return adc
+}
+
+// reset stops any conversion; the inputs keep their values, as they come from outside.
+func (adc *simADC) reset() {
+	adc.state.Busy, adc.state.DoneAt, adc.state.Sample, adc.state.Settling, adc.state.Selected = false, 0, 0, 0, -1
+	if adc.state.Inputs == nil {
+		adc.state.Inputs = make(map[string]int)
+	}
+}
+
+// field resolves a register field, reporting whether the device has its register.
+func (s *simulator) field(f RegisterField) (simField, bool) {
+	addr, found := s.mcConfig.SFRMap[f.Register]
+	return simField{addr: addr % len(s.ram), bit: f.Bit, mask: 1<<max(f.Width, 1) - 1}, found
+}
+
+// get reads a register field.
+func (s *simulator) get(f simField) int {
+	return int(s.ram[f.addr]) >> f.bit & f.mask
+}
+
+// set writes a register field.
+func (s *simulator) set(f simField, value int) {
+	s.ram[f.addr] = s.ram[f.addr]&^byte(f.mask<<f.bit) | byte(value&f.mask<<f.bit)
+}
+
+// setFlag sets or clears a one-bit register field.
+func (s *simulator) setFlag(f simField, set bool) {
+	s.setBit(f.addr, f.bit, set)
+}
+
+// setInput sets the value on an analog channel, e.g. AN0, in counts of the converter.
+func (adc *simADC) setInput(channel string, value int) error {
+	channel = strings.ToUpper(channel)
+	if len(adc.channels) > 0 && !adc.channels[channel] {
+		return fmt.Errorf("unknown analog channel '%s'", channel)
+	}
+	if value < 0 || value > adc.highest {
+		return fmt.Errorf("analog value %d is out of range 0-%d", value, adc.highest)
+	}
+	adc.state.Inputs[channel] = value
+	return nil
+}
+
+// conversionCycles returns the instruction cycles a conversion takes with the selected
+// conversion clock.
+func (adc *simADC) conversionCycles(s *simulator) uint64 {
+	tad := s.foscMHz * adcRCPeriodUS / 4
+	if divisor := adcClockDivisors[s.get(adc.clock)%len(adcClockDivisors)]; divisor != 0 {
+		tad = float64(divisor) / 4
+	}
+	return uint64(math.Ceil(adcConversionTADs * tad))
+}
+
+// advance brings the ADC up to the current cycle after an instruction ran.
+func (adc *simADC) advance(s *simulator) {
+	selected := -1
+	if s.get(adc.enable) == 1 {
+		selected = s.get(adc.channel)
+	}
+	if selected != adc.state.Selected {
+		adc.state.Selected, adc.state.Settling = selected, s.cycles
+	}
+	goDone := s.get(adc.start) == 1
+	switch {
+	case adc.state.Busy && !goDone:
+		adc.state.Busy = false
+	case adc.state.Busy && s.cycles >= adc.state.DoneAt:
+		adc.state.Busy = false
+		value := adc.state.Sample
+		if s.get(adc.rightJustify) == 1 {
+			s.ram[adc.resultHigh], s.ram[adc.resultLow] = byte(value>>8), byte(value)
+		} else {
+			shift := 16 - adc.config.Resolution
+			s.ram[adc.resultHigh], s.ram[adc.resultLow] = byte(value<<shift>>8), byte(value<<shift)
+		}
+		s.setFlag(adc.start, false)
+		s.setFlag(adc.flag, true)
+	case !adc.state.Busy && goDone && selected < 0:
+		s.setFlag(adc.start, false)
+	case !adc.state.Busy && goDone:
+		channel := fmt.Sprintf("AN%d", selected)
+		adc.state.Busy, adc.state.Sample = true, adc.state.Inputs[channel]
+		adc.state.DoneAt = s.cycles + adc.conversionCycles(s)
+		needed := uint64(math.Ceil(adc.config.AcquisitionUS * s.foscMHz / 4))
+		if waited := s.cycles - adc.state.Settling; waited < needed {
+			s.report("ADC conversion of %s started %d cycles after it was selected; acquisition takes %d", channel, waited, needed)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// --- Simulator Cross-Check ---
+
+func init() {
+	registerSubcommand("crosscheck", "Compare the simulator against gpsim on a HEX file", runCrossCheck)
+}
+
+// crossCheckMarker starts the output of each checkpoint in a gpsim session.
+const crossCheckMarker = "asm4pic-checkpoint"
+
+// simCheckpoint is the state compared after a number of instructions: the PC, W and the data
+// memory, of which gpsim reports the addresses it implements.
+type simCheckpoint struct {
+	pc  int
+	w   int
+	ram map[int]int
+}
+
+// gpsimCommands returns the gpsim command file that steps through the checkpoints, every
+// instructions apart, and prints W, the PC and the data memory at each.
+func gpsimCommands(checkpoints, every int) string {
+	var b strings.Builder
+	for i := 1; i <= checkpoints; i++ {
+		fmt.Fprintf(&b, "step %d\necho %s %d\nW\npc\ndump r\n", every, crossCheckMarker, i)
+	}
+	b.WriteString("quit\n")
+	return b.String()
+}
+
+var (
+	gpsimValueRe = regexp.MustCompile(`(?i)^\s*(W|pc)\b.*=\s*(0x[0-9a-f]+|[0-9]+)\s*$`)
+	gpsimDumpRe  = regexp.MustCompile(`(?i)^\s*([0-9a-f]{2,4}):\s+(.*)$`)
+)
+
+// parseGpsimTranscript reads the checkpoints of a gpsim session run with the commands of
+// gpsimCommands. Output between the checkpoints, such as the instructions step prints, is
+// skipped; registers gpsim dumps as -- are not implemented and left out.
+func parseGpsimTranscript(text string) ([]simCheckpoint, error) {
+	var checkpoints []simCheckpoint
+	var current *simCheckpoint
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), crossCheckMarker) {
+			checkpoints = append(checkpoints, simCheckpoint{pc: -1, w: -1, ram: make(map[int]int)})
+			current = &checkpoints[len(checkpoints)-1]
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		if m := gpsimValueRe.FindStringSubmatch(line); m != nil {
+			value, err := strconv.ParseInt(m[2], 0, 32)
+			if err != nil {
+				return nil, fmt.Errorf("checkpoint %d: could not read '%s'", len(checkpoints), strings.TrimSpace(line))
+			}
+			if strings.EqualFold(m[1], "W") {
+				current.w = int(value)
+			} else {
+				current.pc = int(value)
+			}
+			continue
+		}
+		m := gpsimDumpRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		row, _ := strconv.ParseInt(m[1], 16, 32)
+		for col, field := range strings.Fields(m[2]) {
+			if col == 16 {
+				break
+			}
+			if value, err := strconv.ParseUint(field, 16, 8); err == nil && len(field) == 2 {
+				current.ram[int(row)+col] = int(value)
+			}
+		}
+	}
+	for i, c := range checkpoints {
+		if c.pc < 0 || c.w < 0 {
+			return nil, fmt.Errorf("checkpoint %d of the gpsim session has no PC or W", i+1)
+		}
+	}
+	return checkpoints, nil
+}
+
+// runGpsim runs gpsim on a HEX file with a command file and returns what it printed.
+func runGpsim(executable string, mcConfig *MicrocontrollerConfig, hexFile, commands string) (string, error) {
+	tmpDir, err := os.MkdirTemp("", "asm4pic-crosscheck")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmpDir)
+	commandFile := filepath.Join(tmpDir, "crosscheck.stc")
+	if err := os.WriteFile(commandFile, []byte(commands), 0o644); err != nil {
+		return "", err
+	}
+	processor := "p" + strings.ToLower(strings.TrimPrefix(strings.ToUpper(mcConfig.Name), "PIC"))
+	output, err := exec.Command(executable, "-i", "-p", processor, "-c", commandFile, hexFile).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("'%s' failed: %w (use -transcript to compare a saved session)", executable, err)
+	}
+	return string(output), nil
+}
+
+// crossCheck runs the simulator to each checkpoint and compares it with gpsim's state,
+// returning a line per difference at the first checkpoint that differs. Only the core
+// registers the simulator models and the GPRs are compared.
+func (s *simulator) crossCheck(checkpoints []simCheckpoint, every int) (int, []string, error) {
+	compared := map[int]bool{statusRegister: true, fsrRegister: true, pclathRegister: true, intconRegister: true}
+	for i, want := range checkpoints {
+		for n := 0; n < every && !s.sleeping; n++ {
+			if err := s.step(); err != nil {
+				return i + 1, nil, err
+			}
+		}
+		var diffs []string
+		if s.pc != want.pc {
+			diffs = append(diffs, fmt.Sprintf("PC      0x%04X  gpsim 0x%04X", s.pc, want.pc))
+		}
+		if int(s.w) != want.w {
+			diffs = append(diffs, fmt.Sprintf("W       0x%02X    gpsim 0x%02X", s.w, want.w))
+		}
+		for addr := 0; addr < len(s.ram); addr++ {
+			value, reported := want.ram[addr]
+			if !reported || !(compared[addr] || s.isGPR(addr)) {
+				continue
+			}
+			if got := int(s.read(addr)); got != value {
+				diffs = append(diffs, fmt.Sprintf("%-7s 0x%02X    gpsim 0x%02X", s.registerName(addr), got, value))
+			}
+		}
+		if len(diffs) > 0 || s.sleeping {
+			return i + 1, diffs, nil
+		}
+	}
+	return len(checkpoints), nil, nil
+}
+
+func runCrossCheck(args []string) error {
+	fs := flag.NewFlagSet("crosscheck", flag.ExitOnError)
+	hexFile := fs.String("hex", "", "Path to the HEX file to run (required)")
+	mcu := fs.String("mcu", "", "Target microcontroller name, e.g., 'PIC16F687' (required)")
+	configDir := fs.String("config-dir", "./configs", "Directory containing microcontroller JSON config files")
+	steps := fs.Int("steps", 10000, "Compare over this many instructions")
+	every := fs.Int("every", 100, "Compare after every this many instructions")
+	gpsim := fs.String("gpsim", "gpsim", "Path to the gpsim executable")
+	transcript := fs.String("transcript", "", "Compare against a saved gpsim session instead of running gpsim")
+	commandsFile := fs.String("commands", "", "Write the gpsim command file for the checkpoints to this file and exit")
+	fs.Parse(args)
+
+	if *hexFile == "" || *mcu == "" {
+		fs.Usage()
+		return fmt.Errorf("-hex and -mcu flags are required")
+	}
+	if *every <= 0 || *steps < *every {
+		return fmt.Errorf("-every must be positive and no more than -steps")
+	}
+	commands := gpsimCommands(*steps / *every, *every)
+	if *commandsFile != "" {
+		return writeOutputFile(*commandsFile, commands)
+	}
+
+	mcConfig, err := loadMicrocontrollerConfigByName(*configDir, *mcu)
+	if err != nil {
+		return err
+	}
+	memory, _, err := simProgram(mcConfig, *hexFile, "")
+	if err != nil {
+		return err
+	}
+	s, err := newSimulator(mcConfig, memory)
+	if err != nil {
+		return err
+	}
+	var output string
+	if *transcript != "" {
+		data, err := os.ReadFile(*transcript)
+		if err != nil {
+			return fmt.Errorf("could not read gpsim transcript '%s': %w", *transcript, err)
+		}
+		output = string(data)
+	} else if output, err = runGpsim(*gpsim, mcConfig, *hexFile, commands); err != nil {
+		return err
+	}
+	checkpoints, err := parseGpsimTranscript(output)
+	if err != nil {
+		return err
+	}
+	if len(checkpoints) == 0 {
+		return fmt.Errorf("the gpsim session has no checkpoints")
+	}
+
+	reached, diffs, err := s.crossCheck(checkpoints, *every)
+	if err != nil {
+		return fmt.Errorf("checkpoint %d: %w", reached, err)
+	}
+	if len(diffs) > 0 {
+		fmt.Printf("Checkpoint %d (%d instructions) differs from gpsim:\n", reached, reached**every)
+		for _, diff := range diffs {
+			fmt.Printf("  %s\n", diff)
+		}
+		if reached > 1 {
+			fmt.Printf("Checkpoint %d (%d instructions) was the last to match.\n", reached-1, (reached-1)**every)
+		}
+		return fmt.Errorf("the simulator diverges from gpsim")
+	}
+	if s.sleeping {
+		fmt.Printf("The core sleeps at checkpoint %d; stopped comparing.\n", reached)
+	}
+	fmt.Printf("%d checkpoints of %d instructions match gpsim.\n", reached, *every)
+	return nil
+}
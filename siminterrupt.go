@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// --- Simulator Interrupts ---
+
+// InterruptConfig describes the interrupt sources of a device for the simulator. The bits
+// of INTCON and INTEDG of OPTION_REG are the same on every mid-range core; what differs is
+// the pin of the external interrupt, the ports with interrupt-on-change and the registers
+// of peripheral interrupt flags.
+type InterruptConfig struct {
+	IntPin      string                 `json:"int_pin"`       // e.g. RA2
+	IOC         map[string]string      `json:"ioc,omitempty"` // PORT -> its interrupt-on-change enable register
+	Peripherals []PeripheralInterrupts `json:"peripherals,omitempty"`
+}
+
+// PeripheralInterrupts pairs a register of peripheral interrupt flags with the register of
+// their enable bits, e.g. PIR1 and PIE1.
+type PeripheralInterrupts struct {
+	Flag   string `json:"flag"`
+	Enable string `json:"enable"`
+}
+
+// Interrupt bits of INTCON and OPTION_REG.
+const (
+	optionRegister = 0x81
+	intconPEIE     = 6
+	intconINTE     = 4
+	intconIOCIE    = 3 // RBIE, or RABIE where PORTA has interrupt-on-change too
+	intconINTF     = 1
+	intconIOCIF    = 0 // RBIF or RABIF
+	optionINTEDG   = 6
+)
+
+// interruptState is the part of the interrupt model that is not in its registers.
+type interruptState struct {
+	IntLevel int               `json:"int_level"`             // level of the INT pin when last sampled
+	Latches  map[string]int    `json:"ioc_latches,omitempty"` // PORT -> its levels when last accessed, for interrupt-on-change
+	Raised   map[string]uint64 `json:"raised,omitempty"`      // INT or IOC -> cycle a pin set its flag, while the flag is set
+}
+
+// iocPort is a port with interrupt-on-change and its enable register.
+type iocPort struct {
+	name   string
+	port   int
+	enable int
+}
+
+// peripheralFlags is a register of peripheral interrupt flags and its enable register.
+type peripheralFlags struct {
+	name   string
+	flag   int
+	enable int
+}
+
+// simInterrupts models the interrupt logic: an edge on the INT pin in the direction INTEDG
+// selects sets INTF, and an input pin with interrupt-on-change enabled that differs from
+// its level when the port was last read or written sets the IOC flag. With GIE set, an
+// enabled flag makes the core finish the instruction it is running, push the PC, clear GIE
+// and jump to the interrupt vector, which takes two more cycles.
+type simInterrupts struct {
+	intPort     int // PORT of the INT pin, -1 if there is none
+	intBit      int
+	ioc         []iocPort
+	peripherals []peripheralFlags
+	state       interruptState
+}
+
+// newSimInterrupts builds the interrupt model of a device, or returns nil when its config
+// describes none. Sources naming pins or registers the device does not have are left out.
+func newSimInterrupts(s *simulator) *simInterrupts {
+	config := s.mcConfig.Interrupts
+	if config == nil {
+		return nil
+	}
+	in := &simInterrupts{intPort: -1}
+	if port, bit, err := s.parsePin(config.IntPin); err == nil {
+		in.intPort, in.intBit = port, bit
+	}
+	for name, enable := range config.IOC {
+		port, isPort := s.mcConfig.SFRMap[name]
+		addr, found := s.mcConfig.SFRMap[enable]
+		if _, known := s.ports[port]; isPort && known && found {
+			in.ioc = append(in.ioc, iocPort{name: name, port: port, enable: addr % len(s.ram)})
+		}
+	}
+	for _, p := range config.Peripherals {
+		flag, foundFlag := s.mcConfig.SFRMap[p.Flag]
+		enable, foundEnable := s.mcConfig.SFRMap[p.Enable]
+		if foundFlag && foundEnable {
+			in.peripherals = append(in.peripherals, peripheralFlags{name: p.Flag, flag: flag % len(s.ram), enable: enable % len(s.ram)})
+		}
+	}
+	return in
+}
+
+// reset takes the levels of the pins after a reset as the ones edges and changes are seen
+// against.
+func (in *simInterrupts) reset(s *simulator) {
+	in.state = interruptState{Latches: make(map[string]int), Raised: make(map[string]uint64)}
+	in.state.IntLevel = in.intLevel(s)
+	for _, p := range in.ioc {
+		in.state.Latches[p.name] = int(s.read(p.port))
+	}
+}
+
+// intLevel returns the level the INT pin reads.
+func (in *simInterrupts) intLevel(s *simulator) int {
+	if in.intPort < 0 {
+		return 0
+	}
+	return int(s.read(in.intPort)) >> in.intBit & 1
+}
+
+// pending returns the sources whose flag and enable bits are both set.
+func (in *simInterrupts) pending(s *simulator) []string {
+	intcon := s.ram[intconRegister]
+	var sources []string
+	if intcon>>intconINTE&1 == 1 && intcon>>intconINTF&1 == 1 {
+		sources = append(sources, "INT")
+	}
+	if intcon>>intconIOCIE&1 == 1 && intcon>>intconIOCIF&1 == 1 {
+		sources = append(sources, "IOC")
+	}
+	if intcon>>intconPEIE&1 == 1 {
+		for _, p := range in.peripherals {
+			if s.ram[p.flag]&s.ram[p.enable] != 0 {
+				sources = append(sources, p.name)
+			}
+		}
+	}
+	return sources
+}
+
+// sample checks the pins for an INT edge or a change, setting the flags whether or not the
+// interrupts are enabled, as the hardware does.
+func (in *simInterrupts) sample(s *simulator) {
+	for source, flag := range map[string]int{"INT": intconINTF, "IOC": intconIOCIF} {
+		if s.ram[intconRegister]>>flag&1 == 0 {
+			delete(in.state.Raised, source)
+		}
+	}
+	level := in.intLevel(s)
+	rising := s.ram[optionRegister]>>optionINTEDG&1 == 1
+	if level != in.state.IntLevel && (level == 1) == rising {
+		in.raise(s, "INT", intconINTF)
+	}
+	in.state.IntLevel = level
+	for _, p := range in.ioc {
+		watched := s.ram[p.enable] & s.ram[s.ports[p.port]]
+		if (s.read(p.port)^byte(in.state.Latches[p.name]))&watched != 0 {
+			in.raise(s, "IOC", intconIOCIF)
+		}
+	}
+}
+
+// raise sets the flag of a pin interrupt, remembering when it was first set.
+func (in *simInterrupts) raise(s *simulator, source string, flag int) {
+	if _, ok := in.state.Raised[source]; !ok {
+		in.state.Raised[source] = s.cycles
+	}
+	s.setBit(intconRegister, flag, true)
+}
+
+// accessed ends the mismatch of a port with interrupt-on-change after an instruction read
+// or wrote it.
+func (in *simInterrupts) accessed(s *simulator, addr int) {
+	for _, p := range in.ioc {
+		if p.port == addr {
+			in.state.Latches[p.name] = int(s.read(addr))
+		}
+	}
+}
+
+// interrupt vectors the core to the interrupt vector for the pending sources, describing
+// them with the latency of those a pin raised: the cycles from the pin event to the first
+// instruction of the interrupt routine.
+func (s *simulator) interrupt(sources []string) {
+	s.push(s.pc)
+	s.setBit(intconRegister, intconGIE, false)
+	s.pc = interruptVector
+	s.tick(2)
+	described := make([]string, len(sources))
+	for i, source := range sources {
+		described[i] = source
+		if at, ok := s.interrupts.state.Raised[source]; ok {
+			described[i] = fmt.Sprintf("%s after %d cycles", source, s.cycles-at)
+		}
+	}
+	s.event = "interrupt " + strings.Join(described, ", ")
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// --- Simulator Profile ---
+
+// simProfile counts how often each program address ran during a simulation and the cycles
+// it took, so the report can show where the cycles go.
+type simProfile struct {
+	executions []uint64
+	cycles     []uint64
+	start      uint64 // cycle count when profiling started
+}
+
+// newSimProfile starts profiling a simulator from its current cycle count.
+func newSimProfile(s *simulator) *simProfile {
+	return &simProfile{executions: make([]uint64, len(s.code)), cycles: make([]uint64, len(s.code)), start: s.cycles}
+}
+
+// record counts the instruction at addr, which started on cycle start. Interrupt entries,
+// resets and wake-ups are not instructions and are left out.
+func (p *simProfile) record(s *simulator, addr int, start uint64) {
+	if s.event != "" {
+		return
+	}
+	p.executions[addr]++
+	p.cycles[addr] += s.cycles - start
+}
+
+// profileLabel is a label and the program addresses from it up to the next label.
+type profileLabel struct {
+	name       string
+	start, end int
+}
+
+// profileLabels returns the labels of an assembled program in address order, each covering
+// the addresses up to the next one, or up to the end of the code for the last. Of several
+// labels at one address the alphabetically first is used.
+func profileLabels(a *PicAssembler, size int) []profileLabel {
+	last := 0
+	for addr := range a.machineCodeWords {
+		if addr < size {
+			last = max(last, addr)
+		}
+	}
+	byAddress := make(map[int]string)
+	for name, addr := range a.labels {
+		if other, ok := byAddress[addr]; addr >= 0 && addr < size && (!ok || name < other) {
+			byAddress[addr] = name
+		}
+	}
+	labels := make([]profileLabel, 0, len(byAddress))
+	for addr, name := range byAddress {
+		labels = append(labels, profileLabel{name: name, start: addr})
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i].start < labels[j].start })
+	for i := range labels {
+		labels[i].end = max(last, labels[i].start)
+		if i+1 < len(labels) {
+			labels[i].end = labels[i+1].start - 1
+		}
+	}
+	return labels
+}
+
+// enclosingLabel returns the index of the label whose addresses include addr, or -1.
+func enclosingLabel(labels []profileLabel, addr int) int {
+	i := sort.Search(len(labels), func(i int) bool { return labels[i].start > addr }) - 1
+	if i >= 0 && addr <= labels[i].end {
+		return i
+	}
+	return -1
+}
+
+// report writes the profile: the cycles spent under each label, then the top addresses by
+// cycles with their label offset, source line and source text. Without an assembler (a HEX
+// file was run) only addresses and disassembly are shown.
+func (p *simProfile) report(s *simulator, a *PicAssembler, source []string, top int) string {
+	var total, instructions uint64
+	for addr := range p.cycles {
+		total += p.cycles[addr]
+		instructions += p.executions[addr]
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Profile of %d cycles: %d instructions", s.cycles-p.start, instructions)
+	if other := s.cycles - p.start - total; other > 0 {
+		fmt.Fprintf(&b, ", %d cycles asleep or entering interrupts", other)
+	}
+	b.WriteString("\n")
+	if total == 0 {
+		return b.String()
+	}
+	percent := func(cycles uint64) float64 { return 100 * float64(cycles) / float64(total) }
+
+	var labels []profileLabel
+	lines := make(map[int]string) // program address -> source line of its instruction
+	texts := make(map[int]string) // program address -> text of that line, when it is in the source
+	if a != nil {
+		labels = profileLabels(a, len(p.cycles))
+		for i, addr := range a.itemAddresses {
+			if _, isInstruction := a.parsedAssembly.Lines[i].(*Instruction); !isInstruction {
+				continue
+			}
+			if origin := a.origin(i); origin != nil && len(origin.Chain) == 0 && origin.Location.File != "" {
+				lines[addr] = origin.Location.String()
+				continue
+			}
+			n := a.sourceLine(i)
+			lines[addr] = fmt.Sprint(n)
+			if n >= 1 && n <= len(source) {
+				texts[addr] = strings.TrimSpace(source[n-1])
+			}
+		}
+	}
+
+	if len(labels) > 0 {
+		labelCycles := make([]uint64, len(labels))
+		labelExecutions := make([]uint64, len(labels))
+		for addr := range p.cycles {
+			if i := enclosingLabel(labels, addr); i >= 0 {
+				labelCycles[i] += p.cycles[addr]
+				labelExecutions[i] += p.executions[addr]
+			}
+		}
+		order := make([]int, 0, len(labels))
+		for i := range labels {
+			if labelCycles[i] > 0 {
+				order = append(order, i)
+			}
+		}
+		sort.SliceStable(order, func(x, y int) bool { return labelCycles[order[x]] > labelCycles[order[y]] })
+		fmt.Fprintf(&b, "\nCycles by label:\n%12s  %6s  %12s  %s\n", "cycles", "%", "instructions", "label")
+		for _, i := range order {
+			fmt.Fprintf(&b, "%12d  %5.1f%%  %12d  %s (0x%04X-0x%04X)\n", labelCycles[i], percent(labelCycles[i]), labelExecutions[i], labels[i].name, labels[i].start, labels[i].end)
+		}
+	}
+
+	hot := make([]int, 0, len(p.cycles))
+	for addr, cycles := range p.cycles {
+		if cycles > 0 {
+			hot = append(hot, addr)
+		}
+	}
+	sort.SliceStable(hot, func(x, y int) bool { return p.cycles[hot[x]] > p.cycles[hot[y]] })
+	if top > 0 && len(hot) > top {
+		hot = hot[:top]
+	}
+	fmt.Fprintf(&b, "\nHot spots:\n%12s  %6s  %12s  %-7s  %-20s  %5s  %s\n", "cycles", "%", "executions", "address", "location", "line", "source")
+	for _, addr := range hot {
+		location, text := "", s.renderInstruction(addr, -1, -1)
+		if i := enclosingLabel(labels, addr); i >= 0 {
+			location = labels[i].name
+			if offset := addr - labels[i].start; offset > 0 {
+				location = fmt.Sprintf("%s+%d", location, offset)
+			}
+		}
+		if source, ok := texts[addr]; ok {
+			text = source
+		}
+		fmt.Fprintf(&b, "%12d  %5.1f%%  %12d  0x%04X   %-20s  %5s  %s\n", p.cycles[addr], percent(p.cycles[addr]), p.executions[addr], addr, location, lines[addr], text)
+	}
+	return b.String()
+}
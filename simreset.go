@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// --- Simulator Resets and Sleep ---
+
+// ResetConfig describes the reset flags and the watchdog timer of a device for the
+// simulator. The watchdog period is its timeout with a prescaler value of 0 and the
+// OPTION_REG prescaler assigned to Timer0; each step of the prescaler doubles it.
+type ResetConfig struct {
+	PORFlag           RegisterField `json:"por_flag"`            // POR of PCON, cleared by a power-on reset
+	BORFlag           RegisterField `json:"bor_flag"`            // BOR of PCON, cleared by a brown-out reset
+	WDTPeriodUS       float64       `json:"wdt_period_us"`       // 0 when the watchdog is not simulated
+	WDTPrescaler      RegisterField `json:"wdt_prescaler"`       // WDTPS of WDTCON
+	WDTPrescalerReset int           `json:"wdt_prescaler_reset"` // WDTPS after a reset
+	WDTEnable         RegisterField `json:"wdt_enable"`          // SWDTEN, which turns on a watchdog the WDTE fuse leaves off
+}
+
+// OPTION_REG bits assigning the prescaler to the watchdog and selecting its rate.
+const (
+	optionPSA = 3
+	optionPS  = 0x07
+)
+
+// resetKinds are the reset sources the simulator models, by the name scripts and stimulus
+// files give them.
+var resetKinds = map[string]string{
+	"por":  "power-on",
+	"bor":  "brown-out",
+	"mclr": "MCLR",
+	"wdt":  "watchdog",
+}
+
+// simResets resolves the reset flags and watchdog registers of a ResetConfig.
+type simResets struct {
+	config       *ResetConfig
+	por          simField
+	bor          simField
+	hasFlags     bool
+	prescaler    simField
+	hasPrescaler bool
+	enable       simField
+	hasEnable    bool
+}
+
+// newSimResets resolves the reset config of a device, or returns nil when it has none.
+func newSimResets(s *simulator) *simResets {
+	config := s.mcConfig.Reset
+	if config == nil {
+		return nil
+	}
+	r := &simResets{config: config}
+	var foundPOR, foundBOR bool
+	r.por, foundPOR = s.field(config.PORFlag)
+	r.bor, foundBOR = s.field(config.BORFlag)
+	r.hasFlags = foundPOR && foundBOR
+	r.prescaler, r.hasPrescaler = s.field(config.WDTPrescaler)
+	r.enable, r.hasEnable = s.field(config.WDTEnable)
+	return r
+}
+
+// watchdogExpiry returns the cycle the watchdog times out on, and whether it is running:
+// turned on by the WDTE fuse or by software, with a period the device config gives.
+func (s *simulator) watchdogExpiry() (uint64, bool) {
+	r := s.resets
+	if r == nil || r.config.WDTPeriodUS <= 0 {
+		return 0, false
+	}
+	if s.fuses["WDTE"] != "ON" && !(r.hasEnable && s.get(r.enable) == 1) {
+		return 0, false
+	}
+	period := r.config.WDTPeriodUS
+	if r.hasPrescaler {
+		period *= float64(int(1) << s.get(r.prescaler))
+	}
+	if option := s.ram[optionRegister]; option>>optionPSA&1 == 1 {
+		period *= float64(int(1) << (option & optionPS))
+	}
+	return s.watchdogCleared + uint64(math.Ceil(period*s.foscMHz/4)), true
+}
+
+// resetBy resets the core as a reset source does. A power-on reset clears the data memory;
+// the others keep the GPRs and the port latches. Each leaves its signature in TO and PD of
+// STATUS and in the POR and BOR flags, which startup code reads to tell them apart:
+//
+//	power-on   TO=1 PD=1        POR=0 BOR=0
+//	brown-out  TO=1 PD=1        BOR=0
+//	MCLR       unchanged, or TO=1 PD=0 in sleep
+//	watchdog   TO=0
+//
+// A reset the config fuses disable is an error.
+func (s *simulator) resetBy(kind string) error {
+	kind = strings.ToLower(kind)
+	if _, ok := resetKinds[kind]; !ok {
+		return fmt.Errorf("unknown reset '%s' (expected por, bor, mclr or wdt)", kind)
+	}
+	switch {
+	case kind == "mclr" && s.fuses["MCLRE"] == "OFF":
+		return fmt.Errorf("MCLR resets are disabled: the MCLRE fuse makes the pin an input")
+	case kind == "bor" && (s.fuses["BOREN"] == "OFF" || s.fuses["BOREN"] == "NSLEEP" && s.sleeping):
+		return fmt.Errorf("brown-out resets are disabled by the BOREN fuse")
+	}
+	before, sleeping := append([]byte(nil), s.ram...), s.sleeping
+	s.reset()
+	if kind == "por" {
+		return nil
+	}
+	for addr := range s.ram {
+		if _, isPort := s.ports[addr]; isPort || s.isGPR(addr) {
+			s.ram[addr] = before[addr]
+		}
+	}
+	status := before[statusRegister]
+	switch kind {
+	case "mclr":
+		if sleeping {
+			status = status&^(1<<statusPD) | 1<<statusTO
+		}
+		s.ram[statusRegister] = s.ram[statusRegister]&^(1<<statusTO|1<<statusPD) | status&(1<<statusTO|1<<statusPD)
+	case "wdt":
+		s.ram[statusRegister] = s.ram[statusRegister]&^(1<<statusTO|1<<statusPD) | status&(1<<statusPD)
+	}
+	if r := s.resets; r != nil && r.hasFlags {
+		s.setFlag(r.por, before[r.por.addr]>>r.por.bit&1 == 1)
+		s.setFlag(r.bor, kind != "bor" && before[r.bor.addr]>>r.bor.bit&1 == 1)
+	}
+	return nil
+}
+
+// wake ends SLEEP. The core runs the instruction after SLEEP before it enters any interrupt.
+func (s *simulator) wake(cause string) {
+	s.sleeping, s.woken = false, true
+	s.event = "wake from SLEEP by " + cause
+}
+
+// doze lets time pass while the core sleeps, up to the next cycle something may wake it: the
+// watchdog timing out, a stimulus event, the end of an ADC conversion or the cycle limit. An
+// enabled interrupt flag wakes the core whether or not GIE is set, and a watchdog timeout
+// wakes it with TO cleared instead of resetting it. doze returns false when nothing can wake
+// the core.
+func (s *simulator) doze(limit uint64) bool {
+	s.touched, s.event = -1, ""
+	if s.interrupts != nil {
+		s.interrupts.sample(s)
+		if sources := s.interrupts.pending(s); len(sources) > 0 {
+			s.wake(strings.Join(sources, ", "))
+			return true
+		}
+	}
+	next, wakeable := limit, false
+	if expiry, running := s.watchdogExpiry(); running {
+		if expiry <= s.cycles {
+			s.setBit(statusRegister, statusTO, false)
+			s.watchdogCleared = s.cycles
+			s.wake("the watchdog")
+			return true
+		}
+		next, wakeable = min(next, expiry), true
+	}
+	if st := s.stimulus; st != nil && st.next < len(st.events) {
+		next, wakeable = min(next, st.events[st.next].cycle), true
+	}
+	if s.adc != nil && s.adc.state.Busy {
+		next, wakeable = min(next, s.adc.state.DoneAt), true
+	}
+	if !wakeable {
+		return false
+	}
+	s.tick(max(next, s.cycles+1) - s.cycles)
+	return true
+}
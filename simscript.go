@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// --- Simulator Scripts ---
+
+// simScript runs a file of simulator commands, one per line, against a simulator, so a
+// scenario can be replayed without interaction. Text after ';' or '#' is a comment.
+type simScript struct {
+	sim     *simulator
+	out     io.Writer                    // where dumps write
+	after   func(addr int, start uint64) // called after each instruction, as by simulator.run
+	watches *simWatches
+}
+
+// simCommands are the script commands: their usage and how to run them with their arguments,
+// of which there are as many as the usage names.
+var simCommands = map[string]struct {
+	usage string
+	run   func(sc *simScript, args []string) error
+}{
+	"run":     {"run CYCLES", (*simScript).runCycles},
+	"pin":     {"pin PIN LEVEL", (*simScript).drivePin},
+	"adc":     {"adc CHANNEL VALUE", (*simScript).setAnalog},
+	"reset":   {"reset SOURCE", (*simScript).reset},
+	"write":   {"write REGISTER VALUE", (*simScript).writeRegister},
+	"dump":    {"dump START END", (*simScript).dump},
+	"assert":  {"assert REGISTER VALUE", (*simScript).assert},
+	"watch":   {"watch EXPRESSION", (*simScript).watch},
+	"unwatch": {"unwatch EXPRESSION", (*simScript).unwatch},
+	"gpr":     {"gpr", (*simScript).dumpGPR},
+	"stack":   {"stack", (*simScript).dumpStack},
+	"save":    {"save FILE", (*simScript).save},
+	"load":    {"load FILE", (*simScript).load},
+}
+
+// runFile runs the commands of a script file, stopping at the first that fails.
+func (sc *simScript) runFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read script '%s': %w", path, err)
+	}
+	for i, line := range strings.Split(string(data), "\n") {
+		if end := strings.IndexAny(line, ";#"); end >= 0 {
+			line = line[:end]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		command, ok := simCommands[strings.ToLower(fields[0])]
+		if !ok {
+			return fmt.Errorf("%s:%d: unknown command '%s'", path, i+1, fields[0])
+		}
+		if len(fields) != len(strings.Fields(command.usage)) {
+			return fmt.Errorf("%s:%d: expected %s", path, i+1, command.usage)
+		}
+		if err := command.run(sc, fields[1:]); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, i+1, err)
+		}
+	}
+	return nil
+}
+
+// parseValue parses a number in any literal form the assembler accepts.
+func parseValue(text string) (int, error) {
+	value, ok, err := parseNumericLiteral(text)
+	if !ok || err != nil {
+		return 0, fmt.Errorf("expected a number, got '%s'", text)
+	}
+	return value, nil
+}
+
+// lookupRegister resolves a data memory address given by SFR name, symbol or number.
+func (s *simulator) lookupRegister(name string) (int, error) {
+	if addr, ok := s.mcConfig.SFRMap[strings.ToUpper(name)]; ok {
+		return addr % len(s.ram), nil
+	}
+	if addr, ok := s.symbols[name]; ok && addr >= 0 && addr < len(s.ram) {
+		return addr, nil
+	}
+	addr, err := parseValue(name)
+	if err != nil || addr < 0 || addr >= len(s.ram) {
+		return 0, fmt.Errorf("unknown register '%s'", name)
+	}
+	return addr, nil
+}
+
+// runCycles runs the given number of instruction cycles, or until the core sleeps.
+func (sc *simScript) runCycles(args []string) error {
+	cycles, err := parseValue(args[0])
+	if err != nil || cycles < 0 {
+		return fmt.Errorf("expected a number of cycles, got '%s'", args[0])
+	}
+	return sc.sim.run(sc.sim.cycles+uint64(cycles), sc.after)
+}
+
+// drivePin drives a port pin to a level.
+func (sc *simScript) drivePin(args []string) error {
+	return sc.sim.drivePin(args[0], args[1])
+}
+
+// setAnalog sets the input value of an analog channel.
+func (sc *simScript) setAnalog(args []string) error {
+	return sc.sim.setAnalog(args[0], args[1])
+}
+
+// reset resets the core as a reset source (por, bor, mclr or wdt) does.
+func (sc *simScript) reset(args []string) error {
+	return sc.sim.resetBy(args[0])
+}
+
+// writeRegister stores a value in W, the PC or a data memory address, as is: writes to PCL
+// do not jump and the TO and PD bits of STATUS can be set.
+func (sc *simScript) writeRegister(args []string) error {
+	value, err := parseValue(args[1])
+	if err != nil {
+		return err
+	}
+	switch strings.ToUpper(args[0]) {
+	case "W":
+		sc.sim.w = byte(value)
+	case "PC":
+		sc.sim.pc = value & 0x1FFF
+	default:
+		addr, err := sc.sim.lookupRegister(args[0])
+		if err != nil {
+			return err
+		}
+		sc.sim.ram[addr] = byte(value)
+	}
+	return nil
+}
+
+// dump writes the data memory from START to END, 16 addresses per line.
+func (sc *simScript) dump(args []string) error {
+	start, err := sc.sim.lookupRegister(args[0])
+	if err != nil {
+		return err
+	}
+	end, err := sc.sim.lookupRegister(args[1])
+	if err != nil {
+		return err
+	}
+	if end < start {
+		return fmt.Errorf("dump ends at 0x%03X, before it starts at 0x%03X", end, start)
+	}
+	for addr := start; addr <= end; addr++ {
+		if addr == start || addr%16 == 0 {
+			if addr != start {
+				fmt.Fprintln(sc.out)
+			}
+			fmt.Fprintf(sc.out, "0x%03X:", addr)
+		}
+		fmt.Fprintf(sc.out, " %02X", sc.sim.read(addr))
+	}
+	fmt.Fprintln(sc.out)
+	return nil
+}
+
+// assert fails unless W, the PC or a data memory address holds the value.
+func (sc *simScript) assert(args []string) error {
+	expected, err := parseValue(args[1])
+	if err != nil {
+		return err
+	}
+	read, _, err := sc.sim.registerReader(args[0])
+	if err != nil {
+		return err
+	}
+	if actual := read(); actual != expected {
+		return fmt.Errorf("assert %s failed: expected 0x%02X, got 0x%02X", args[0], expected, actual)
+	}
+	return nil
+}
+
+// watch prints an expression whenever its value changes from now on.
+func (sc *simScript) watch(args []string) error {
+	return sc.watches.add(sc.sim, args[0])
+}
+
+// unwatch stops printing an expression.
+func (sc *simScript) unwatch(args []string) error {
+	return sc.watches.remove(args[0])
+}
+
+// dumpGPR writes the general purpose registers of every bank.
+func (sc *simScript) dumpGPR(args []string) error {
+	sc.sim.dumpGPR(sc.out)
+	return nil
+}
+
+// dumpStack writes the return addresses on the hardware stack.
+func (sc *simScript) dumpStack(args []string) error {
+	sc.sim.dumpStack(sc.out)
+	return nil
+}
+
+// save writes the simulator state to a snapshot file.
+func (sc *simScript) save(args []string) error {
+	return sc.sim.saveSnapshot(args[0])
+}
+
+// load restores the simulator state from a snapshot file.
+func (sc *simScript) load(args []string) error {
+	return sc.sim.loadSnapshot(args[0])
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// --- Simulator Snapshots ---
+
+// simSnapshot is the complete state of a simulator as saved to a file: the core, the data
+// memory, the cycle count, the levels driven onto the pins and the peripheral models. It
+// names the device and a hash of the program memory, so it is only restored into the
+// program it was taken from.
+type simSnapshot struct {
+	MCU        string          `json:"mcu"`
+	Program    string          `json:"program_sha256"`
+	PC         int             `json:"pc"`
+	W          int             `json:"w"`
+	Stack      []int           `json:"stack"`
+	SP         int             `json:"sp"`
+	Depth      int             `json:"depth"`
+	Cycles     uint64          `json:"cycles"`
+	Sleeping   bool            `json:"sleeping"`
+	Woken      bool            `json:"woken,omitempty"`
+	Watchdog   uint64          `json:"watchdog_cleared"` // cycle the watchdog was last cleared
+	RAM        string          `json:"ram"`              // two hex digits per data memory address
+	Pins       map[string]int  `json:"pins,omitempty"`   // PORT name -> levels driven onto its pins
+	ADC        *adcState       `json:"adc,omitempty"`
+	Interrupts *interruptState `json:"interrupts,omitempty"`
+}
+
+// programHash identifies the program memory the simulator runs.
+func (s *simulator) programHash() string {
+	h := sha256.New()
+	for _, word := range s.words {
+		h.Write([]byte{byte(word >> 8), byte(word)})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// snapshot captures the state of the simulator.
+func (s *simulator) snapshot() simSnapshot {
+	snap := simSnapshot{
+		MCU:      s.mcConfig.Name,
+		Program:  s.programHash(),
+		PC:       s.pc,
+		W:        int(s.w),
+		Stack:    append([]int(nil), s.stack[:]...),
+		SP:       s.sp,
+		Depth:    s.depth,
+		Cycles:   s.cycles,
+		Sleeping: s.sleeping,
+		Woken:    s.woken,
+		Watchdog: s.watchdogCleared,
+		RAM:      hex.EncodeToString(s.ram),
+		Pins:     make(map[string]int),
+	}
+	for port, levels := range s.pins {
+		snap.Pins[s.names[port]] = int(levels)
+	}
+	if s.adc != nil {
+		state := s.adc.state
+		state.Inputs = make(map[string]int)
+		for channel, value := range s.adc.state.Inputs {
+			state.Inputs[channel] = value
+		}
+		snap.ADC = &state
+	}
+	if s.interrupts != nil {
+		state := interruptState{IntLevel: s.interrupts.state.IntLevel, Latches: make(map[string]int), Raised: make(map[string]uint64)}
+		for port, levels := range s.interrupts.state.Latches {
+			state.Latches[port] = levels
+		}
+		for source, cycle := range s.interrupts.state.Raised {
+			state.Raised[source] = cycle
+		}
+		snap.Interrupts = &state
+	}
+	return snap
+}
+
+// restore puts the simulator back in the state of a snapshot taken from the same device and
+// program.
+func (s *simulator) restore(snap simSnapshot) error {
+	if snap.MCU != s.mcConfig.Name {
+		return fmt.Errorf("the snapshot was taken on %s, not %s", snap.MCU, s.mcConfig.Name)
+	}
+	if snap.Program != s.programHash() {
+		return fmt.Errorf("the snapshot was taken from a different program")
+	}
+	ram, err := hex.DecodeString(snap.RAM)
+	if err != nil || len(ram) != len(s.ram) || len(snap.Stack) != stackDepth {
+		return fmt.Errorf("the snapshot's data memory or stack does not fit %s", s.mcConfig.Name)
+	}
+	pins := make(map[int]byte)
+	for name, levels := range snap.Pins {
+		addr, ok := s.mcConfig.SFRMap[name]
+		if _, isPort := s.ports[addr]; !ok || !isPort {
+			return fmt.Errorf("the snapshot drives pins of '%s', which is not a port", name)
+		}
+		pins[addr] = byte(levels)
+	}
+	s.pc, s.w, s.sp, s.depth = snap.PC&0x1FFF, byte(snap.W), snap.SP%stackDepth, min(snap.Depth, stackDepth)
+	s.cycles, s.sleeping, s.woken, s.watchdogCleared = snap.Cycles, snap.Sleeping, snap.Woken, snap.Watchdog
+	copy(s.stack[:], snap.Stack)
+	copy(s.ram, ram)
+	s.pins = pins
+	if s.adc != nil && snap.ADC != nil {
+		s.adc.state = *snap.ADC
+		if s.adc.state.Inputs == nil {
+			s.adc.state.Inputs = make(map[string]int)
+		}
+	}
+	if s.interrupts != nil && snap.Interrupts != nil {
+		s.interrupts.state = *snap.Interrupts
+		if s.interrupts.state.Latches == nil {
+			s.interrupts.state.Latches = make(map[string]int)
+		}
+		if s.interrupts.state.Raised == nil {
+			s.interrupts.state.Raised = make(map[string]uint64)
+		}
+	}
+	return nil
+}
+
+// saveSnapshot writes the state of the simulator to a file.
+func (s *simulator) saveSnapshot(path string) error {
+	data, err := json.MarshalIndent(s.snapshot(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeOutputFile(path, string(data)+"\n")
+}
+
+// loadSnapshot restores the state of the simulator from a file.
+func (s *simulator) loadSnapshot(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read snapshot '%s': %w", path, err)
+	}
+	var snap simSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("could not parse JSON from '%s': %w", path, err)
+	}
+	if err := s.restore(snap); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// --- Simulator Stimulus ---
+
+// parsePin resolves a port pin named RA0 or PORTA.0 to its PORT register address and bit.
+func (s *simulator) parsePin(pin string) (int, int, error) {
+	name := strings.ToUpper(pin)
+	port, bitText, dotted := strings.Cut(name, ".")
+	if !dotted && len(name) == 3 && name[0] == 'R' {
+		port, bitText = "PORT"+name[1:2], name[2:]
+	}
+	addr, known := s.mcConfig.SFRMap[port]
+	_, isPort := s.ports[addr]
+	bit, err := parseValue(bitText)
+	if !known || !isPort || err != nil || bit < 0 || bit > 7 {
+		return 0, 0, fmt.Errorf("unknown pin '%s'", pin)
+	}
+	return addr, bit, nil
+}
+
+// drivePin drives a port pin, named RA0 or PORTA.0, to a level: 1, 0, high or low. The
+// level is what the port reads while the pin is a digital input.
+func (s *simulator) drivePin(pin, level string) error {
+	addr, bit, err := s.parsePin(pin)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(level) {
+	case "1", "high":
+		s.pins[addr] |= 1 << bit
+	case "0", "low":
+		s.pins[addr] &^= 1 << bit
+	default:
+		return fmt.Errorf("expected a pin level (1, 0, high or low), got '%s'", level)
+	}
+	return nil
+}
+
+// setAnalog sets the input value of an analog channel, e.g. AN0, in counts of the ADC.
+func (s *simulator) setAnalog(channel, value string) error {
+	if s.adc == nil {
+		return fmt.Errorf("the simulator has no ADC model for %s", s.mcConfig.Name)
+	}
+	counts, err := parseValue(value)
+	if err != nil {
+		return err
+	}
+	return s.adc.setInput(channel, counts)
+}
+
+// stimulusEvent is one line of a stimulus file: at a cycle, drive a pin, set an analog
+// channel or reset the core.
+type stimulusEvent struct {
+	cycle         uint64
+	target, value string
+	line          int
+}
+
+// simStimulus applies the events of a stimulus file as the cycle count reaches them.
+type simStimulus struct {
+	path   string
+	events []stimulusEvent // sorted by cycle, in file order within a cycle
+	next   int
+}
+
+// loadSimStimulus reads a stimulus file: one event per line as CYCLE TARGET VALUE, where the
+// target is a pin (RA0, PORTA.0) driven to a level, an analog channel (AN0) set to a value
+// in counts, or RESET with the reset source (por, bor, mclr or wdt). Text after ';' or '#'
+// is a comment.
+func loadSimStimulus(path string) (*simStimulus, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read stimulus file '%s': %w", path, err)
+	}
+	st := &simStimulus{path: path}
+	for i, line := range strings.Split(string(data), "\n") {
+		if end := strings.IndexAny(line, ";#"); end >= 0 {
+			line = line[:end]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("%s:%d: expected CYCLE TARGET VALUE", path, i+1)
+		}
+		cycle, err := parseValue(fields[0])
+		if err != nil || cycle < 0 {
+			return nil, fmt.Errorf("%s:%d: expected a cycle number, got '%s'", path, i+1, fields[0])
+		}
+		st.events = append(st.events, stimulusEvent{cycle: uint64(cycle), target: fields[1], value: fields[2], line: i + 1})
+	}
+	sort.SliceStable(st.events, func(i, j int) bool { return st.events[i].cycle < st.events[j].cycle })
+	return st, nil
+}
+
+// apply applies the events due by the current cycle count.
+func (st *simStimulus) apply(s *simulator) error {
+	for ; st.next < len(st.events) && st.events[st.next].cycle <= s.cycles; st.next++ {
+		event := st.events[st.next]
+		var err error
+		switch target := strings.ToUpper(event.target); {
+		case target == "RESET":
+			err = s.resetBy(event.value)
+		case strings.HasPrefix(target, "AN"):
+			err = s.setAnalog(event.target, event.value)
+		default:
+			err = s.drivePin(event.target, event.value)
+		}
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", st.path, event.line, err)
+		}
+	}
+	return nil
+}
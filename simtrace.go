@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// --- Simulator Trace ---
+
+// addressRangesFlag collects program address ranges given as START-END or a single ADDR by a
+// repeatable flag.
+type addressRangesFlag []RAMRange
+
+func (f *addressRangesFlag) String() string { return "" }
+
+func (f *addressRangesFlag) Set(value string) error {
+	first, last, isRange := strings.Cut(value, "-")
+	start, err := parseAddress(first)
+	if err != nil {
+		return err
+	}
+	end := start
+	if isRange {
+		if end, err = parseAddress(last); err != nil {
+			return err
+		}
+	}
+	if end < start {
+		return fmt.Errorf("range '%s' ends before it starts", value)
+	}
+	*f = append(*f, RAMRange{Start: start, End: end})
+	return nil
+}
+
+// parseAddress parses a numeric address in any literal form the assembler accepts.
+func parseAddress(text string) (int, error) {
+	addr, ok, err := parseNumericLiteral(strings.TrimSpace(text))
+	if !ok || err != nil || addr < 0 {
+		return 0, fmt.Errorf("expected an address, got '%s'", text)
+	}
+	return addr, nil
+}
+
+// simTrace writes one line per executed instruction: the cycle it started on, its address,
+// its disassembly, W and STATUS after it and the file register it accessed with its new
+// value. With ranges, only instructions at addresses inside one of them are written.
+type simTrace struct {
+	file   *os.File // nil when tracing to stdout
+	out    *bufio.Writer
+	ranges []RAMRange
+}
+
+// newSimTrace creates the trace file and writes its header.
+func newSimTrace(path string, ranges []RAMRange) (*simTrace, error) {
+	t := &simTrace{ranges: ranges}
+	if path == StdioPath {
+		t.out = bufio.NewWriter(os.Stdout)
+	} else {
+		file, err := os.Create(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not create trace file '%s': %w", path, err)
+		}
+		t.file, t.out = file, bufio.NewWriter(file)
+	}
+	fmt.Fprintf(t.out, "%10s  %-6s  %-24s  %-4s  %-6s  %s\n", "cycle", "pc", "instruction", "w", "status", "register")
+	return t, nil
+}
+
+// record writes the line of the instruction at addr, which started on cycle start.
+func (t *simTrace) record(s *simulator, addr int, start uint64) {
+	if len(t.ranges) > 0 {
+		inRange := false
+		for _, r := range t.ranges {
+			inRange = inRange || r.Contains(addr)
+		}
+		if !inRange {
+			return
+		}
+	}
+	instruction, register := s.event, ""
+	if instruction == "" {
+		instruction = s.disassemble(addr)
+	}
+	if s.touched >= 0 {
+		register = fmt.Sprintf("%s=0x%02X", s.registerName(s.touched), s.read(s.touched))
+	}
+	fmt.Fprintf(t.out, "%10d  0x%04X  %-24s  0x%02X  0x%02X    %s\n", start, addr, instruction, s.w, s.ram[statusRegister], register)
+}
+
+// Close flushes the trace and closes its file. Closing it again does nothing.
+func (t *simTrace) Close() error {
+	if t.out == nil {
+		return nil
+	}
+	err := t.out.Flush()
+	if t.file != nil {
+		if closeErr := t.file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	t.file, t.out = nil, nil
+	return err
+}
+
+// disassemble renders the instruction at addr as it last executed: its file register named
+// from the bank it accessed and jump targets in full, with the page PCLATH selected.
+func (s *simulator) disassemble(addr int) string {
+	return s.renderInstruction(addr, s.touched, int(s.ram[pclathRegister])&0x18<<8)
+}
+
+// renderInstruction renders the instruction at addr with its file register named from the
+// data memory address touched, or from the bare field when touched is -1, and jump targets
+// in the program memory page given, or as the bare field when page is -1.
+func (s *simulator) renderInstruction(addr, touched, page int) string {
+	inst := s.code[addr]
+	operands := make([]string, 0, len(inst.Info.Operands))
+	for _, kind := range inst.Info.Operands {
+		value := inst.Fields[kind]
+		switch kind {
+		case "f":
+			switch {
+			case value == indfRegister:
+				operands = append(operands, "INDF")
+			case touched < 0:
+				operands = append(operands, s.registerName(value))
+			default:
+				operands = append(operands, s.registerName(touched))
+			}
+		case "d":
+			operands = append(operands, map[int]string{0: "W", 1: "F"}[value])
+		case "b":
+			operands = append(operands, fmt.Sprint(value))
+		case "k11":
+			if page < 0 {
+				operands = append(operands, fmt.Sprintf("0x%03X", value))
+			} else {
+				operands = append(operands, fmt.Sprintf("0x%04X", page|value))
+			}
+		default:
+			operands = append(operands, fmt.Sprintf("0x%02X", value))
+		}
+	}
+	if len(operands) == 0 {
+		return inst.Opcode
+	}
+	return inst.Opcode + " " + strings.Join(operands, ", ")
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// --- Simulator Watches and Dumps ---
+
+// watchFlag collects watch expressions from a repeatable flag.
+type watchFlag []string
+
+func (f *watchFlag) String() string { return "" }
+
+func (f *watchFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// registerReader returns a function reading W, the PC or a data memory address given by SFR
+// name, symbol or number, and the number of hex digits its value is written with.
+func (s *simulator) registerReader(name string) (func() int, int, error) {
+	switch strings.ToUpper(name) {
+	case "W":
+		return func() int { return int(s.w) }, 2, nil
+	case "PC":
+		return func() int { return s.pc }, 4, nil
+	}
+	addr, err := s.lookupRegister(name)
+	if err != nil {
+		return nil, 0, err
+	}
+	return func() int { return int(s.read(addr)) }, 2, nil
+}
+
+// simWatch is a watched expression and its value when last checked.
+type simWatch struct {
+	text   string
+	digits int // hex digits of the value; 0 for a single bit
+	value  func() int
+	last   int
+}
+
+// newSimWatch parses a watch expression: a register, W or PC; one bit of it as REG.BIT; or
+// two registers read as one 16-bit value as HIGH:LOW, e.g. TMR1H:TMR1L.
+func (s *simulator) newSimWatch(text string) (*simWatch, error) {
+	if high, low, isPair := strings.Cut(text, ":"); isPair {
+		readHigh, _, err := s.registerReader(high)
+		if err != nil {
+			return nil, err
+		}
+		readLow, _, err := s.registerReader(low)
+		if err != nil {
+			return nil, err
+		}
+		return &simWatch{text: text, digits: 4, value: func() int { return readHigh()<<8 | readLow() }}, nil
+	}
+	if name, bitText, isBit := strings.Cut(text, "."); isBit {
+		read, _, err := s.registerReader(name)
+		if err != nil {
+			return nil, err
+		}
+		bit, err := parseValue(bitText)
+		if err != nil || bit < 0 || bit > 7 {
+			return nil, fmt.Errorf("expected a bit number 0-7, got '%s'", bitText)
+		}
+		return &simWatch{text: text, value: func() int { return read() >> bit & 1 }}, nil
+	}
+	read, digits, err := s.registerReader(text)
+	if err != nil {
+		return nil, err
+	}
+	return &simWatch{text: text, digits: digits, value: read}, nil
+}
+
+// format writes a watched value.
+func (w *simWatch) format(value int) string {
+	if w.digits == 0 {
+		return fmt.Sprint(value)
+	}
+	return fmt.Sprintf("0x%0*X", w.digits, value)
+}
+
+// simWatches prints each watch whose value an instruction changed, with the cycle and address
+// of the instruction.
+type simWatches struct {
+	out     io.Writer
+	watches []*simWatch
+}
+
+// add starts watching an expression and prints its current value.
+func (ws *simWatches) add(s *simulator, text string) error {
+	w, err := s.newSimWatch(text)
+	if err != nil {
+		return fmt.Errorf("watch %s: %w", text, err)
+	}
+	w.last = w.value()
+	ws.watches = append(ws.watches, w)
+	fmt.Fprintf(ws.out, "watch %s = %s\n", w.text, w.format(w.last))
+	return nil
+}
+
+// remove stops watching an expression.
+func (ws *simWatches) remove(text string) error {
+	for i, w := range ws.watches {
+		if w.text == text {
+			ws.watches = append(ws.watches[:i], ws.watches[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("'%s' is not watched", text)
+}
+
+// check prints the watches the instruction at addr, which started on cycle start, changed.
+func (ws *simWatches) check(addr int, start uint64) {
+	for _, w := range ws.watches {
+		if value := w.value(); value != w.last {
+			fmt.Fprintf(ws.out, "%10d  0x%04X  %s = %s (was %s)\n", start, addr, w.text, w.format(value), w.format(w.last))
+			w.last = value
+		}
+	}
+}
+
+// dumpGPR writes the general purpose registers of every bank, 16 to a line under a column
+// header, followed by the data symbols naming registers in them.
+func (s *simulator) dumpGPR(out io.Writer) {
+	if s.mcConfig.RAMLayout == nil {
+		return
+	}
+	for _, r := range s.mcConfig.RAMLayout.GPR {
+		fmt.Fprintf(out, "GPR 0x%03X-0x%03X (bank %d):\n      ", r.Start, r.End, r.Start/s.bankSize)
+		for col := 0; col < 16; col++ {
+			fmt.Fprintf(out, " +%X", col)
+		}
+		fmt.Fprintln(out)
+		var named []string
+		for row := r.Start &^ 0xF; row <= r.End; row += 16 {
+			fmt.Fprintf(out, "0x%03X:", row)
+			for addr := row; addr < row+16; addr++ {
+				if !r.Contains(addr) {
+					fmt.Fprint(out, "   ")
+					continue
+				}
+				fmt.Fprintf(out, " %02X", s.read(addr))
+				if name, ok := s.names[addr]; ok {
+					named = append(named, fmt.Sprintf("%s=0x%02X", name, s.read(addr)))
+				}
+			}
+			fmt.Fprintln(out)
+		}
+		if len(named) > 0 {
+			fmt.Fprintf(out, "  %s\n", strings.Join(named, " "))
+		}
+	}
+}
+
+// dumpStack writes the return addresses on the hardware stack, the most recent first.
+func (s *simulator) dumpStack(out io.Writer) {
+	fmt.Fprintf(out, "Stack (%d of %d levels):\n", s.depth, stackDepth)
+	for level := 1; level <= s.depth; level++ {
+		fmt.Fprintf(out, "  %d: 0x%04X\n", level, s.stack[(s.sp+stackDepth-level)%stackDepth])
+	}
+}
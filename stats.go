@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// --- Build Statistics ---
+
+// buildStats summarizes one build for -stats: how long each phase took, how much source went
+// in and how much output came out.
+type buildStats struct {
+	Phases       []phaseTime    `json:"phases"`
+	Milliseconds float64        `json:"total_ms"`
+	SourceLines  int            `json:"source_lines"` // after include expansion
+	Macros       int            `json:"macros"`       // macro definitions
+	Expansions   int            `json:"expansions"`   // macro, pseudo-op, built-in and directive invocations expanded
+	Instructions int            `json:"instructions"` // after expansion and optimization
+	ProgramWords int            `json:"program_words"`
+	Outputs      map[string]int `json:"output_bytes"` // output -> size in bytes
+	last         time.Time
+}
+
+// phaseTime is the time one build phase took.
+type phaseTime struct {
+	Name         string  `json:"name"`
+	Milliseconds float64 `json:"ms"`
+}
+
+func newBuildStats() *buildStats {
+	return &buildStats{Outputs: make(map[string]int), last: time.Now()}
+}
+
+// phase records that the named phase ended now, having started when the previous one ended.
+func (s *buildStats) phase(name string) {
+	now := time.Now()
+	s.Phases = append(s.Phases, phaseTime{name, float64(now.Sub(s.last).Microseconds()) / 1000})
+	s.Milliseconds += s.Phases[len(s.Phases)-1].Milliseconds
+	s.last = now
+}
+
+// String renders the build summary printed by -stats.
+func (s *buildStats) String() string {
+	var out strings.Builder
+	out.WriteString("Build statistics:\n")
+	for _, p := range s.Phases {
+		out.WriteString(fmt.Sprintf("  %-12s %9.3f ms\n", p.Name, p.Milliseconds))
+	}
+	out.WriteString(fmt.Sprintf("  %-12s %9.3f ms\n", "total", s.Milliseconds))
+	out.WriteString(fmt.Sprintf("  Source lines: %d, macros: %d, expansions: %d\n", s.SourceLines, s.Macros, s.Expansions))
+	out.WriteString(fmt.Sprintf("  Instructions: %d, program words: %d\n", s.Instructions, s.ProgramWords))
+	names := make([]string, 0, len(s.Outputs))
+	for name := range s.Outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		out.WriteString(fmt.Sprintf("  Output %s: %d bytes\n", name, s.Outputs[name]))
+	}
+	return out.String()
+}
+
+// JSON renders the build summary written by -stats-json.
+func (s *buildStats) JSON() (string, error) {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
+
+// recordStats fills in the counts of the build once its outputs are rendered.
+func (a *PicAssembler) recordStats(output *AssemblyOutput) {
+	for i, item := range a.parsedAssembly.Lines {
+		if _, ok := item.(*Instruction); ok {
+			if _, placed := a.itemAddresses[i]; placed {
+				a.stats.Instructions++
+			}
+		}
+	}
+	a.stats.ProgramWords = a.programWordsUsed()
+	for name, text := range map[string]string{"hex": output.Hex, "report": output.Report, "listing": output.Listing, "map": output.Map, "result": output.Result} {
+		if text != "" {
+			a.stats.Outputs[name] = len(text)
+		}
+	}
+	for region, text := range output.RegionHex {
+		a.stats.Outputs["hex-"+region] = len(text)
+	}
+}
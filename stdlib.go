@@ -0,0 +1,40 @@
+package main
+
+import (
+	"embed"
+	"sort"
+	"strings"
+)
+
+// --- Standard Macro Library ---
+
+//go:embed stdlib/*.inc
+var stdLibraryFS embed.FS
+
+// stdLibraryCores maps each bundled library to the program word size (in bits) of the core it targets.
+var stdLibraryCores = map[string]int{
+	"std16.inc": 14,
+}
+
+// loadStdLibrary returns the text of a bundled library included as #INCLUDE <name>.
+// coreWordBits is the target's program word size; 0 skips the core check.
+func loadStdLibrary(name string, coreWordBits int) (string, error) {
+	key := strings.ToLower(name)
+	core, ok := stdLibraryCores[key]
+	if !ok {
+		available := make([]string, 0, len(stdLibraryCores))
+		for lib := range stdLibraryCores {
+			available = append(available, "<"+lib+">")
+		}
+		sort.Strings(available)
+		return "", trErrorf("unknown library <%s> (available: %s)", name, strings.Join(available, ", "))
+	}
+	if coreWordBits != 0 && coreWordBits != core {
+		return "", trErrorf("library <%s> targets %d-bit cores, but the device has %d-bit program words", name, core, coreWordBits)
+	}
+	data, err := stdLibraryFS.ReadFile("stdlib/" + key)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
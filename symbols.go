@@ -0,0 +1,265 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// --- Source Symbol Index ---
+
+// Symbol kinds reported by findSymbolDefinitions.
+const (
+	SymbolKindLabel  = "label"
+	SymbolKindEqu    = "equ"
+	SymbolKindDefine = "define"
+	SymbolKindMacro  = "macro"
+)
+
+// SymbolDefinition locates a name defined in assembly source.
+type SymbolDefinition struct {
+	Name    string
+	Kind    string
+	Value   string
+	Line    int  // 1-based source line
+	Column  int  // 0-based byte offset of the name within the line
+	InMacro bool // Defined inside a macro body
+}
+
+// findSymbolDefinitions scans source text for label, EQU, #DEFINE and MACRO definitions.
+// Unlike the parser it keeps labels declared inside macro bodies under their written names.
+func findSymbolDefinitions(text string) []SymbolDefinition {
+	var defs []SymbolDefinition
+	parser := NewASMParser()
+	inMacro := false
+
+	for i, line := range strings.Split(text, "\n") {
+		content, _ := parser.extractLineContentAndComment(line)
+		if content == "" {
+			continue
+		}
+		if strings.ToUpper(content) == "ENDM" {
+			inMacro = false
+			continue
+		}
+
+		var name, kind, value string
+		if match := defineRegex.FindStringSubmatch(content); match != nil {
+			name, kind, value = match[1], SymbolKindDefine, strings.TrimSpace(match[2])
+		} else if match := macroStartRegex.FindStringSubmatch(content); match != nil {
+			name, kind = match[1], SymbolKindMacro
+		} else if match := equRegex.FindStringSubmatch(content); match != nil {
+			name, kind, value = match[1], SymbolKindEqu, match[2]
+		} else if match := labelRegex.FindStringSubmatch(content); match != nil {
+			name, kind = match[1], SymbolKindLabel
+		} else {
+			continue
+		}
+
+		column := strings.Index(line, name)
+		if column < 0 {
+			column = 0
+		}
+		defs = append(defs, SymbolDefinition{Name: name, Kind: kind, Value: value, Line: i + 1, Column: column, InMacro: inMacro})
+		if kind == SymbolKindMacro {
+			inMacro = true
+		}
+	}
+	return defs
+}
+
+// lookupSymbolDefinition finds the definition of name, preferring top-level definitions
+// over labels local to a macro body.
+func lookupSymbolDefinition(defs []SymbolDefinition, name string) (SymbolDefinition, bool) {
+	var fallback *SymbolDefinition
+	for i, def := range defs {
+		if def.Name != name {
+			continue
+		}
+		if !def.InMacro {
+			return def, true
+		}
+		if fallback == nil {
+			fallback = &defs[i]
+		}
+	}
+	if fallback != nil {
+		return *fallback, true
+	}
+	return SymbolDefinition{}, false
+}
+
+// --- Report Symbol Table ---
+
+// SymbolKindSFR and SymbolKindRes mark special function registers and registers reserved
+// with RES in the report's symbol table.
+const (
+	SymbolKindSFR = "sfr"
+	SymbolKindRes = "res"
+)
+
+// Symbol table orders accepted by AssemblyOptions.SymbolOrder.
+const (
+	SymbolOrderName    = "name"
+	SymbolOrderAddress = "address"
+)
+
+// reportSymbol is one entry of the report's symbol table.
+type reportSymbol struct {
+	Name  string
+	Kind  string
+	Value int
+	Line  int // defining source line, 0 for SFRs from the device config
+}
+
+// reportSymbols lists the labels of the assembly and, with all set, also its EQU symbols and
+// the SFRs its instructions refer to, sorted by name or by address (then name).
+func (a *PicAssembler) reportSymbols(order string, all bool) []reportSymbol {
+	var symbols []reportSymbol
+	seen := make(map[string]bool)
+	for i, item := range a.parsedAssembly.Lines {
+		switch v := item.(type) {
+		case *Label:
+			if addr, ok := a.labels[v.Name]; ok && !seen[v.Name] {
+				seen[v.Name] = true
+				symbols = append(symbols, reportSymbol{v.Name, SymbolKindLabel, addr, a.sourceLine(i)})
+			}
+		case *EquDirective:
+			if val, ok := a.symbolTable[v.Symbol]; ok && all && !seen[v.Symbol] {
+				seen[v.Symbol] = true
+				symbols = append(symbols, reportSymbol{v.Symbol, SymbolKindEqu, val, a.equLines[v.Symbol]})
+			}
+		case *VersionString:
+			if addr, ok := a.labels[versionSymbol]; ok && !seen[versionSymbol] {
+				seen[versionSymbol] = true
+				symbols = append(symbols, reportSymbol{versionSymbol, SymbolKindLabel, addr, a.sourceLine(i)})
+			}
+		case *ReserveDirective:
+			if val, ok := a.symbolTable[v.Symbol]; ok && all && !seen[v.Symbol] {
+				seen[v.Symbol] = true
+				symbols = append(symbols, reportSymbol{v.Symbol, SymbolKindRes, val, a.sourceLine(i)})
+			}
+		}
+	}
+	if all {
+		for _, item := range a.parsedAssembly.Lines {
+			inst, ok := item.(*Instruction)
+			if !ok {
+				continue
+			}
+			for _, operand := range inst.Operands {
+				for _, name := range strings.FieldsFunc(operand, func(r rune) bool { return r > 0x7F || !isIdentifierChar(byte(r)) }) {
+					name = strings.ToUpper(name)
+					if addr, isSFR := a.mcConfig.SFRMap[name]; isSFR && !seen[name] {
+						seen[name] = true
+						symbols = append(symbols, reportSymbol{name, SymbolKindSFR, addr, 0})
+					}
+				}
+			}
+		}
+	}
+	sort.Slice(symbols, func(i, j int) bool {
+		if order == SymbolOrderAddress && symbols[i].Value != symbols[j].Value {
+			return symbols[i].Value < symbols[j].Value
+		}
+		return symbols[i].Name < symbols[j].Name
+	})
+	return symbols
+}
+
+// --- Report Defines and Constants ---
+
+// defineSite is a #DEFINE or #UNDEFINE line of the source, or a define given on the command
+// line with -D or a build profile, which has no location.
+type defineSite struct {
+	Name     string
+	Value    string
+	Undefine bool
+	Location *SourceLocation
+}
+
+// collectDefineSites lists the command-line defines by name, then the #DEFINE and #UNDEFINE
+// lines of the parsed source in order.
+func collectDefineSites(parsed *ParsedAssembly, commandLine map[string]string) []defineSite {
+	names := make([]string, 0, len(commandLine))
+	for name := range commandLine {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var sites []defineSite
+	for _, name := range names {
+		sites = append(sites, defineSite{Name: name, Value: commandLine[name]})
+	}
+	for i, item := range parsed.Lines {
+		location := SourceLocation{Line: i + 1}
+		if i < len(parsed.Origins) && parsed.Origins[i].Line > 0 {
+			location = parsed.Origins[i]
+		} else if i < len(parsed.SourceLines) {
+			location.Line = parsed.SourceLines[i]
+		}
+		switch v := item.(type) {
+		case *Define:
+			sites = append(sites, defineSite{Name: v.Name, Value: v.Value, Location: &location})
+		case *Undefine:
+			sites = append(sites, defineSite{Name: v.Name, Undefine: true, Location: &location})
+		}
+	}
+	return sites
+}
+
+// constantRow is one entry of the report's Defines and Constants section.
+type constantRow struct {
+	Name    string
+	Kind    string
+	Value   string
+	Defined string
+}
+
+// reportConstants lists every define and EQU symbol with its value and where it was set,
+// sorted by name with the definitions of one name in source order. A #DEFINE or #UNDEFINE
+// that replaces a command-line define says so.
+func (a *PicAssembler) reportConstants() []constantRow {
+	var rows []constantRow
+	fromCommandLine := make(map[string]bool)
+	for _, site := range a.defineSites {
+		row := constantRow{Name: site.Name, Kind: "#DEFINE", Value: site.Value}
+		if site.Undefine {
+			row.Kind = "#UNDEFINE"
+		}
+		if site.Location == nil {
+			row.Defined = "command line"
+			fromCommandLine[site.Name] = true
+		} else {
+			row.Defined = site.Location.String()
+			if fromCommandLine[site.Name] {
+				row.Defined += ", replaces the command-line value"
+				fromCommandLine[site.Name] = false
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	seen := make(map[string]bool)
+	for i, item := range a.parsedAssembly.Lines {
+		equ, ok := item.(*EquDirective)
+		if !ok || seen[equ.Symbol] {
+			continue
+		}
+		val, ok := a.symbolTable[equ.Symbol]
+		if !ok {
+			continue
+		}
+		seen[equ.Symbol] = true
+		value := fmt.Sprintf("0x%04X", val)
+		if _, literal, _ := parseNumericLiteral(strings.TrimSpace(equ.Value)); !literal {
+			value += " (" + strings.TrimSpace(equ.Value) + ")"
+		}
+		defined := fmt.Sprintf("line %d", a.sourceLine(i))
+		if i < len(a.parsedAssembly.Origins) && a.parsedAssembly.Origins[i] != nil && a.parsedAssembly.Origins[i].Location.Line > 0 {
+			defined = a.parsedAssembly.Origins[i].Location.String()
+		}
+		rows = append(rows, constantRow{Name: equ.Symbol, Kind: "EQU", Value: value, Defined: defined})
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].Name < rows[j].Name })
+	return rows
+}
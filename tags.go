@@ -0,0 +1,123 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// --- Tags Generation ---
+
+func init() {
+	registerSubcommand("tags", "Generate ctags/etags files for labels, EQUs, defines and macros", runTags)
+}
+
+// ctagsKinds maps symbol kinds to their single-letter ctags kind.
+var ctagsKinds = map[string]string{
+	SymbolKindLabel:  "l",
+	SymbolKindEqu:    "c",
+	SymbolKindDefine: "d",
+	SymbolKindMacro:  "m",
+}
+
+// taggedFile holds the source lines and symbol definitions found in one file.
+type taggedFile struct {
+	Path  string
+	Lines []string
+	Defs  []SymbolDefinition
+}
+
+// generateCtags renders definitions in the sorted, extended ctags format read by vim.
+func generateCtags(files []taggedFile) string {
+	type entry struct{ name, line string }
+	var entries []entry
+	for _, f := range files {
+		for _, def := range f.Defs {
+			text := strings.TrimRight(f.Lines[def.Line-1], "\r")
+			pattern := strings.NewReplacer(`\`, `\\`, `/`, `\/`).Replace(text)
+			entries = append(entries, entry{def.Name, fmt.Sprintf("%s\t%s\t/^%s$/;\"\t%s\tline:%d", def.Name, f.Path, pattern, ctagsKinds[def.Kind], def.Line)})
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+
+	var out strings.Builder
+	out.WriteString("!_TAG_FILE_FORMAT\t2\t/extended format/\n")
+	out.WriteString("!_TAG_FILE_SORTED\t1\t/0=unsorted, 1=sorted/\n")
+	out.WriteString("!_TAG_PROGRAM_NAME\tasm4PIC\t//\n")
+	for _, e := range entries {
+		out.WriteString(e.line + "\n")
+	}
+	return out.String()
+}
+
+// generateEtags renders definitions in the Emacs TAGS format.
+func generateEtags(files []taggedFile) string {
+	var out strings.Builder
+	for _, f := range files {
+		// Byte offset of the start of each line, as Emacs expects.
+		offsets := make([]int, len(f.Lines))
+		offset := 0
+		for i, line := range f.Lines {
+			offsets[i] = offset
+			offset += len(line) + 1
+		}
+
+		var section strings.Builder
+		for _, def := range f.Defs {
+			line := f.Lines[def.Line-1]
+			prefix := line[:def.Column+len(def.Name)]
+			fmt.Fprintf(&section, "%s\x7f%s\x01%d,%d\n", prefix, def.Name, def.Line, offsets[def.Line-1])
+		}
+		fmt.Fprintf(&out, "\x0c\n%s,%d\n%s", f.Path, section.Len(), section.String())
+	}
+	return out.String()
+}
+
+// runTags indexes the given source files and writes a tags file.
+func runTags(args []string) error {
+	fs := flag.NewFlagSet("tags", flag.ExitOnError)
+	emacs := fs.Bool("e", false, "Write an Emacs TAGS file instead of a ctags file")
+	outPath := fs.String("o", "", "Output file (defaults to 'tags', or 'TAGS' with -e; '-' for stdout)")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("at least one source file is required")
+	}
+
+	var files []taggedFile
+	for _, path := range fs.Args() {
+		source, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("could not read '%s': %w", path, err)
+		}
+		text := string(source)
+		files = append(files, taggedFile{Path: path, Lines: strings.Split(text, "\n"), Defs: findSymbolDefinitions(text)})
+	}
+
+	var content string
+	if *emacs {
+		content = generateEtags(files)
+	} else {
+		content = generateCtags(files)
+	}
+
+	path := *outPath
+	if path == "" {
+		path = "tags"
+		if *emacs {
+			path = "TAGS"
+		}
+	}
+	if path == "-" {
+		fmt.Print(content)
+		return nil
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("could not write '%s': %w", path, err)
+	}
+	fmt.Printf("Tags for %d file(s) written to %s\n", len(files), path)
+	return nil
+}
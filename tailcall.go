@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+)
+
+// --- Tail Call Optimization ---
+
+func init() {
+	registerOptimizationPass(optimizationPass{
+		Name:        "tailcall",
+		Description: "Rewrite CALL followed by RETURN into GOTO",
+		Run:         tailCalls,
+		OptIn:       true,
+	})
+}
+
+// tailCalls rewrites CALL X directly followed by RETURN into GOTO X: X's own return then goes
+// straight back to the caller, saving a stack level and two cycles. The RETURN is removed as
+// well when nothing else can reach it.
+func tailCalls(ctx *optimizeContext) {
+	g := buildFlowGraph(ctx.expanded, ctx.mcConfig, ctx.value)
+	predecessors := make(map[int]int)
+	for _, successors := range g.successors {
+		for _, to := range successors {
+			predecessors[to]++
+		}
+	}
+	addressTaken := make(map[int]bool)
+	for _, i := range g.addressTaken {
+		addressTaken[i] = true
+	}
+
+	removed := make(map[int]bool)
+	for i := range ctx.expanded.Lines {
+		call, ok := ctx.code(i)
+		if !ok || strings.ToUpper(call.Opcode) != "CALL" || len(call.Operands) != 1 {
+			continue
+		}
+		j := ctx.next(i, true)
+		if j < 0 {
+			continue
+		}
+		ret, _ := ctx.code(j)
+		if strings.ToUpper(ret.Opcode) != "RETURN" {
+			continue
+		}
+		jump := &Instruction{Opcode: "GOTO", Operands: call.Operands, Comment: call.Comment}
+		ctx.expanded.Lines[i] = jump
+
+		// The RETURN stays if it is a jump target, follows a skip or may be reached some other way
+		if ctx.next(i, false) == j && !ctx.conditional(i) && g.unresolved == nil && predecessors[j] == 1 && !addressTaken[j] {
+			removed[j] = true
+			ctx.record(i, []*Instruction{call, ret}, []*Instruction{jump}, 2, "Replaced CALL %s + RETURN with GOTO %s (saves a stack level)", call.Operands[0], call.Operands[0])
+		} else {
+			ctx.record(i, []*Instruction{call, ret}, []*Instruction{jump, ret}, 2, "Replaced CALL %s with GOTO %s before RETURN (saves a stack level)", call.Operands[0], call.Operands[0])
+		}
+	}
+	ctx.remove(removed)
+}
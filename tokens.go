@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// --- Semantic Tokens ---
+
+func init() {
+	registerSubcommand("tokens", "Export token classifications with source ranges as JSON", runTokens)
+}
+
+// Token types emitted by classifySourceTokens.
+const (
+	TokenOpcode    = "opcode"
+	TokenDirective = "directive"
+	TokenLabel     = "label"
+	TokenSymbol    = "symbol"
+	TokenLiteral   = "literal"
+	TokenComment   = "comment"
+)
+
+// SemanticToken classifies a range of source text.
+type SemanticToken struct {
+	Line   int    `json:"line"`   // 1-based
+	Column int    `json:"column"` // 0-based byte offset
+	Length int    `json:"length"`
+	Type   string `json:"type"`
+	Text   string `json:"text"`
+}
+
+// isIdentifierChar reports whether c may appear in a symbol name.
+func isIdentifierChar(c byte) bool {
+	return c == '_' || c == '.' || (c >= '0' && c <= '9') || (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z')
+}
+
+// operandTokens splits operand text into symbol and literal tokens, skipping operators.
+func operandTokens(text string, line, offset int) []SemanticToken {
+	var tokens []SemanticToken
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == '"' || c == '\'':
+			end := i + 1
+			for end < len(text) && text[end] != c {
+				if text[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end < len(text) {
+				end++
+			}
+			tokens = append(tokens, SemanticToken{Line: line, Column: offset + i, Length: end - i, Type: TokenLiteral, Text: text[i:end]})
+			i = end
+		case isIdentifierChar(c) || c == '$' || c == '%':
+			end := i + 1
+			for end < len(text) && isIdentifierChar(text[end]) {
+				end++
+			}
+			word := text[i:end]
+			tokenType := TokenSymbol
+			if isNumericLiteral(word) {
+				tokenType = TokenLiteral
+			}
+			tokens = append(tokens, SemanticToken{Line: line, Column: offset + i, Length: end - i, Type: tokenType, Text: word})
+			i = end
+		default:
+			i++
+		}
+	}
+	return tokens
+}
+
+// classifySourceTokens classifies every token of the source using the assembler's line grammar.
+func classifySourceTokens(text string) []SemanticToken {
+	tokens := []SemanticToken{}
+	for i, rawLine := range strings.Split(text, "\n") {
+		lineNum := i + 1
+		fields := splitSourceLine(rawLine)
+		add := func(f sourceField, tokenType string) {
+			if f.Text != "" {
+				tokens = append(tokens, SemanticToken{Line: lineNum, Column: f.Column, Length: len(f.Text), Type: tokenType, Text: f.Text})
+			}
+		}
+
+		add(fields.Label, TokenLabel)
+		if fields.Opcode.Text != "" {
+			if directiveKeywords[strings.ToUpper(fields.Opcode.Text)] {
+				add(fields.Opcode, TokenDirective)
+			} else {
+				add(fields.Opcode, TokenOpcode)
+			}
+		}
+		for idx, op := range fields.Operands {
+			if strings.HasPrefix(fields.Opcode.Text, "#") && idx == 0 {
+				add(op, TokenSymbol) // Name being defined
+				continue
+			}
+			tokens = append(tokens, operandTokens(op.Text, lineNum, op.Column)...)
+		}
+		add(fields.Comment, TokenComment)
+	}
+	return tokens
+}
+
+// runTokens prints the semantic tokens of each source file as JSON.
+func runTokens(args []string) error {
+	fs := flag.NewFlagSet("tokens", flag.ExitOnError)
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("at least one source file is required")
+	}
+
+	type fileTokens struct {
+		File   string          `json:"file"`
+		Tokens []SemanticToken `json:"tokens"`
+	}
+	var result []fileTokens
+	for _, path := range fs.Args() {
+		source, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("could not read '%s': %w", path, err)
+		}
+		result = append(result, fileTokens{File: path, Tokens: classifySourceTokens(string(source))})
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(result)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// --- Verify Subcommand ---
+
+func init() {
+	registerSubcommand("verify", "Compare device contents against a HEX file", runVerify)
+}
+
+// memoryMismatch records one program or config word that differs between two images.
+type memoryMismatch struct {
+	Region   string
+	Address  int
+	Expected int
+	Actual   int
+}
+
+// compareImages compares the program memory and config words of two HEX memory images.
+// Words missing from either image are treated as erased.
+func compareImages(mcConfig *MicrocontrollerConfig, expected, actual map[int]byte) []memoryMismatch {
+	var mismatches []memoryMismatch
+	wordMask := (1 << mcConfig.ProgramWordSizeBits) - 1
+	erased := wordMask
+
+	readWord := func(memory map[int]byte, addr int) int {
+		if word, ok := hexWordAt(memory, addr); ok {
+			return word & wordMask
+		}
+		return erased
+	}
+
+	for addr := 0; addr < mcConfig.ProgramMemorySize; addr++ {
+		want, got := readWord(expected, addr), readWord(actual, addr)
+		if want != got {
+			mismatches = append(mismatches, memoryMismatch{"program", addr, want, got})
+		}
+	}
+
+	// Only compare config bits that belong to a fuse group; unimplemented bits read back as 0.
+	names := make([]string, 0, len(mcConfig.ConfigWordDefaults))
+	for name := range mcConfig.ConfigWordDefaults {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return mcConfig.ConfigWordDefaults[names[i]].Address < mcConfig.ConfigWordDefaults[names[j]].Address
+	})
+	for i, name := range names {
+		implemented := 0
+		if i < len(mcConfig.AllConfigFuseMaps) {
+			for _, group := range mcConfig.AllConfigFuseMaps[i] {
+				implemented |= group.Mask
+			}
+		}
+		addr := mcConfig.ConfigWordDefaults[name].Address
+		readConfig := func(memory map[int]byte) int {
+			if value, ok := mcConfig.hexValueAt(memory, addr); ok {
+				return value & wordMask
+			}
+			return erased
+		}
+		want, got := readConfig(expected)&implemented, readConfig(actual)&implemented
+		if want != got {
+			mismatches = append(mismatches, memoryMismatch{name, addr, want, got})
+		}
+	}
+	return mismatches
+}
+
+// runVerify reads back the device (or a previously read dump) and compares it with a HEX file.
+func runVerify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	hexFile := fs.String("hex", "", "Path to the expected HEX file (required)")
+	mcu := fs.String("mcu", "", "Target microcontroller name, e.g., 'PIC16F687' (required)")
+	configDir := fs.String("config-dir", "./configs", "Directory containing microcontroller JSON config files")
+	readFile := fs.String("read", "", "Compare against an existing device read-back HEX instead of reading the device")
+	programmerName := fs.String("programmer", "pickit2", "Programmer used to read the device (pickit2, pickit3, pickit4, icd3, icd4)")
+	programmerPath := fs.String("programmer-path", "", "Path to the programmer's command-line tool (defaults to searching PATH)")
+	fs.Parse(args)
+
+	if *hexFile == "" || *mcu == "" {
+		fs.Usage()
+		return fmt.Errorf("-hex and -mcu flags are required")
+	}
+
+	mcConfig, err := loadMicrocontrollerConfigByName(*configDir, *mcu)
+	if err != nil {
+		return err
+	}
+
+	expectedContent, err := os.ReadFile(*hexFile)
+	if err != nil {
+		return fmt.Errorf("could not read HEX file '%s': %w", *hexFile, err)
+	}
+	expected, err := parseIntelHex(string(expectedContent))
+	if err != nil {
+		return fmt.Errorf("could not parse '%s': %w", *hexFile, err)
+	}
+
+	readPath := *readFile
+	if readPath == "" {
+		programmer, err := newProgrammer(*programmerName, *programmerPath)
+		if err != nil {
+			return err
+		}
+		tmpDir, err := os.MkdirTemp("", "asm4pic-verify")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tmpDir)
+		readPath = filepath.Join(tmpDir, "readback.hex")
+		fmt.Printf("Reading %s with %s...\n", *mcu, *programmerName)
+		if err := programmer.ReadBack(*mcu, readPath); err != nil {
+			return err
+		}
+	}
+	return verifyDump(mcConfig, expected, readPath, *hexFile)
+}
+
+// verifyDump compares a device read-back HEX file with the expected image, listing every
+// mismatched word, and fails when any differ. hexFile names the expected image in messages.
+func verifyDump(mcConfig *MicrocontrollerConfig, expected map[int]byte, readPath, hexFile string) error {
+	actualContent, err := os.ReadFile(readPath)
+	if err != nil {
+		return fmt.Errorf("could not read device dump '%s': %w", readPath, err)
+	}
+	actual, err := parseIntelHex(string(actualContent))
+	if err != nil {
+		return fmt.Errorf("could not parse device dump '%s': %w", readPath, err)
+	}
+
+	mismatches := compareImages(mcConfig, expected, actual)
+	if len(mismatches) == 0 {
+		fmt.Printf("Verify successful. Device matches %s\n", hexFile)
+		return nil
+	}
+	for _, m := range mismatches {
+		fmt.Printf("  %-8s 0x%04X: expected 0x%04X, device 0x%04X\n", m.Region, m.Address, m.Expected, m.Actual)
+	}
+	return fmt.Errorf("%d word(s) differ from %s", len(mismatches), hexFile)
+}
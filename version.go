@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// --- Version String ---
+
+// VersionString embeds a build metadata string in program memory:
+// __VERSIONSTR "text"[, address][, RETLW | PACKED]. As a RETLW table (the default) each
+// character is returned by one RETLW; PACKED stores two characters per word, high half
+// first. Both end with a zero character. Without an address the string is placed at the
+// current location; with one it is placed there without moving the location counter.
+type VersionString struct {
+	Text    string
+	Address string
+	Packed  bool
+}
+
+func (v *VersionString) isAssemblyItem() {}
+
+// versionSymbol is the symbol defined as the start address of the version string.
+const versionSymbol = "__VERSIONSTR"
+
+var versionStringRegex = regexp.MustCompile(`(?i)^__VERSIONSTR\s+"([^"]*)"\s*(?:,\s*(.*))?$`)
+
+func init() {
+	directiveKeywords["__VERSIONSTR"] = true
+}
+
+// parseVersionString parses a __VERSIONSTR directive, or returns nil if the line is not one.
+// Predefined symbol names in the text are replaced by their values.
+func parseVersionString(lineContent string, predefined map[string]predefinedSymbol) (AssemblyItem, error) {
+	if !strings.HasPrefix(strings.ToUpper(lineContent), versionSymbol) {
+		return nil, nil
+	}
+	match := versionStringRegex.FindStringSubmatch(lineContent)
+	if match == nil {
+		return nil, trErrorf(`expected __VERSIONSTR "text"[, address][, RETLW | PACKED]`)
+	}
+	v := &VersionString{Text: expandPredefined(match[1], predefined)}
+	for _, operand := range strings.Split(match[2], ",") {
+		switch operand = strings.TrimSpace(operand); strings.ToUpper(operand) {
+		case "":
+		case "PACKED":
+			v.Packed = true
+		case "RETLW":
+			v.Packed = false
+		default:
+			if v.Address != "" {
+				return nil, trErrorf("__VERSIONSTR takes one address, got '%s' and '%s'", v.Address, operand)
+			}
+			v.Address = operand
+		}
+	}
+	return v, nil
+}
+
+// wordCount returns the number of program words the string takes.
+func (v *VersionString) wordCount() int {
+	if v.Packed {
+		return (len(v.Text) + 2) / 2
+	}
+	return len(v.Text) + 1
+}
+
+// encode returns the program words of the string for the device.
+func (v *VersionString) encode(mcConfig *MicrocontrollerConfig) ([]int, error) {
+	text := append([]byte(v.Text), 0)
+	var words []int
+	if v.Packed {
+		half := mcConfig.ProgramWordSizeBits / 2
+		if len(text)%2 != 0 {
+			text = append(text, 0)
+		}
+		for k := 0; k < len(text); k += 2 {
+			words = append(words, int(text[k])&(1<<half-1)<<half|int(text[k+1])&(1<<half-1))
+		}
+		return words, nil
+	}
+	for _, c := range text {
+		word, err := encodeRETLW(mcConfig, int(c))
+		if err != nil {
+			return nil, trErrorf("%v; use PACKED", err)
+		}
+		words = append(words, word)
+	}
+	return words, nil
+}
+
+// versionText describes a placed version string for the report and map file.
+func (a *PicAssembler) versionText() string {
+	for i, item := range a.parsedAssembly.Lines {
+		v, ok := item.(*VersionString)
+		start, placed := a.itemAddresses[i]
+		if !ok || !placed {
+			continue
+		}
+		format := "RETLW table"
+		if v.Packed {
+			format = "packed"
+		}
+		return fmt.Sprintf("%q at 0x%04X-0x%04X (%s, %s)", v.Text, start, start+v.wordCount()-1, format, versionSymbol)
+	}
+	return ""
+}
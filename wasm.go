@@ -0,0 +1,60 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"syscall/js"
+)
+
+// --- WebAssembly Entry Point ---
+
+// jsAssemble implements asm4pic.assemble(source, configJSON) for JavaScript callers.
+// It returns {success, hex, report, diagnostics: [{severity, line, message}]}.
+func jsAssemble(this js.Value, args []js.Value) interface{} {
+	result := map[string]interface{}{"success": false, "hex": "", "report": ""}
+	var diagnostics []Diagnostic
+
+	if len(args) < 2 {
+		diagnostics = append(diagnostics, Diagnostic{Severity: SeverityError, Message: "assemble(source, configJSON) expects two arguments"})
+	} else if mcConfig, err := parseMicrocontrollerConfig([]byte(args[1].String()), "configJSON"); err != nil {
+		diagnostics = append(diagnostics, errorDiagnostic(err))
+	} else {
+		output, err := assembleOutput(args[0].String(), mcConfig, &AssemblyOptions{NoFileAccess: true})
+		diagnostics = append(diagnostics, output.Warnings...)
+		if err != nil {
+			diagnostics = append(diagnostics, errorDiagnostic(err))
+		} else {
+			result["success"] = true
+			result["hex"] = output.Hex
+			result["report"] = output.Report
+		}
+	}
+
+	jsDiagnostics := make([]interface{}, len(diagnostics))
+	for i, d := range diagnostics {
+		jsDiagnostics[i] = map[string]interface{}{"severity": d.Severity, "line": d.Line, "message": d.Message}
+	}
+	result["diagnostics"] = jsDiagnostics
+	return js.ValueOf(result)
+}
+
+// jsSetLocale implements asm4pic.setLocale(name), which selects the language of the
+// diagnostics and the report. It returns "" on success, else the error.
+func jsSetLocale(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return "setLocale(name) expects one argument"
+	}
+	if err := setLocale(args[0].String()); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+func main() {
+	js.Global().Set("asm4pic", js.ValueOf(map[string]interface{}{
+		"assemble":  js.FuncOf(jsAssemble),
+		"setLocale": js.FuncOf(jsSetLocale),
+	}))
+	// Keep the Go runtime alive so the exported functions stay callable.
+	select {}
+}